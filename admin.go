@@ -0,0 +1,165 @@
+package kasper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+)
+
+// Admin serves an HTTP API for operating a running TopicProcessor without restarting it:
+//
+//	POST /pause               stops consuming messages (see TopicProcessor.Pause)
+//	POST /resume              resumes consuming messages
+//	POST /flush               processes the messages received so far (see TopicProcessor.Flush)
+//	GET  /offsets             returns the offsets and the number of messages behind the high water mark
+//	POST /loglevel?level=...  changes the level of Config.Logger, which must implement LevelSetter (see NewLevelLogger)
+//	POST /config              applies a JSON RuntimeConfig (see TopicProcessor.Reconfigure)
+//	GET  /diagnostics         returns the Diagnostics of the TopicProcessor
+//
+// Serve it on a TCP address with http.ListenAndServe, or on a Unix socket with ListenAndServeUnix so that
+// only local operators running as the same user can reach it. Errors are returned as {"error": "..."}.
+type Admin struct {
+	topicProcessor *TopicProcessor
+	mux            *http.ServeMux
+}
+
+// AdminOffsets is the body of the /offsets response.
+type AdminOffsets struct {
+	Offsets                     map[string]map[int]int64 `json:"offsets"`
+	MessagesBehindHighWaterMark map[string]map[int]int64 `json:"messagesBehindHighWaterMark"`
+}
+
+// NewAdmin creates an Admin API for a TopicProcessor.
+func NewAdmin(topicProcessor *TopicProcessor) *Admin {
+	a := &Admin{topicProcessor, http.NewServeMux()}
+	a.mux.HandleFunc("/pause", a.post(topicProcessor.Pause))
+	a.mux.HandleFunc("/resume", a.post(topicProcessor.Resume))
+	a.mux.HandleFunc("/flush", a.post(topicProcessor.Flush))
+	a.mux.HandleFunc("/offsets", a.offsets)
+	a.mux.HandleFunc("/loglevel", a.logLevel)
+	a.mux.HandleFunc("/config", a.reconfigure)
+	a.mux.HandleFunc("/diagnostics", a.diagnostics)
+	return a
+}
+
+// ServeHTTP serves the Admin API.
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServeUnix serves the Admin API on a Unix socket that only the current user can connect to.
+// A stale socket left at path by a previous process is replaced, but any other file is left untouched and
+// makes ListenAndServeUnix return an error.
+func (a *Admin) ListenAndServeUnix(path string) error {
+	listener, err := listenUnix(path)
+	if err != nil {
+		return err
+	}
+	return http.Serve(listener, a)
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (a *Admin) post(operation func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		if err := operation(); err != nil {
+			status := http.StatusInternalServerError
+			if err == ErrNotRunning {
+				status = http.StatusConflict
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	}
+}
+
+func (a *Admin) offsets(w http.ResponseWriter, r *http.Request) {
+	offsets, err := a.topicProcessor.Offsets()
+	if err == ErrNotRunning {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, AdminOffsets{offsets, a.topicProcessor.Metrics().MessagesBehindHighWaterMark})
+}
+
+func (a *Admin) logLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	setter, ok := a.topicProcessor.config.Logger.(LevelSetter)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("Config.Logger does not support changing the level at runtime (see NewLevelLogger)"))
+		return
+	}
+	if err := setter.SetLevel(r.URL.Query().Get("level")); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (a *Admin) reconfigure(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	var update RuntimeConfig
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err := a.topicProcessor.Reconfigure(update)
+	switch err.(type) {
+	case nil:
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	case *ConfigError:
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		status := http.StatusInternalServerError
+		if err == ErrNotRunning {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err)
+	}
+}
+
+func (a *Admin) diagnostics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.topicProcessor.Diagnostics())
+}
@@ -0,0 +1,120 @@
+package kasper
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func adminRequest(admin *Admin, method, path string) (int, map[string]interface{}) {
+	recorder := httptest.NewRecorder()
+	admin.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	var body map[string]interface{}
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	return recorder.Code, body
+}
+
+func TestAdmin(t *testing.T) {
+	processor := &countingProcessor{}
+	tp := newFakeTopicProcessor(&Config{}, processor)
+	admin := NewAdmin(tp.TopicProcessor)
+
+	code, body := adminRequest(admin, "POST", "/pause")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, "topic processor is not running", body["error"])
+
+	done := tp.start()
+	code, _ = adminRequest(admin, "GET", "/pause")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, body = adminRequest(admin, "POST", "/pause")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, body["ok"])
+	code, _ = adminRequest(admin, "POST", "/resume")
+	assert.Equal(t, http.StatusOK, code)
+
+	tp.send(0, 7, "a")
+	waitFor(t, func() bool {
+		code, _ = adminRequest(admin, "POST", "/flush")
+		return processor.processed() == 1
+	})
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body = adminRequest(admin, "GET", "/offsets")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"input": map[string]interface{}{"0": float64(8)}}, body["offsets"])
+
+	tp.Close()
+	assert.Nil(t, <-done)
+}
+
+func TestAdmin_LogLevel(t *testing.T) {
+	admin := NewAdmin(newFakeTopicProcessor(&Config{}, &countingProcessor{}).TopicProcessor)
+	code, _ := adminRequest(admin, "POST", "/loglevel?level=debug")
+	assert.Equal(t, http.StatusNotImplemented, code)
+
+	logger, _ := NewLevelLogger(&noopLogger{}, "info")
+	admin = NewAdmin(newFakeTopicProcessor(&Config{Logger: logger}, &countingProcessor{}).TopicProcessor)
+	code, _ = adminRequest(admin, "POST", "/loglevel?level=debug")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, logger.enabled(levelDebug))
+	code, body := adminRequest(admin, "POST", "/loglevel?level=verbose")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, `unknown log level "verbose" (expected debug, info or error)`, body["error"])
+}
+
+func TestAdmin_Config(t *testing.T) {
+	tp := newFakeTopicProcessor(&Config{}, &countingProcessor{})
+	admin := NewAdmin(tp.TopicProcessor)
+	done := tp.start()
+
+	recorder := httptest.NewRecorder()
+	admin.ServeHTTP(recorder, httptest.NewRequest("POST", "/config", strings.NewReader(`{"batchSize": 5, "batchWaitDuration": "1m"}`)))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	recorder = httptest.NewRecorder()
+	admin.ServeHTTP(recorder, httptest.NewRequest("POST", "/config", strings.NewReader(`{"batchSize": -5}`)))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	tp.Close()
+	assert.Nil(t, <-done)
+	assert.Equal(t, 5, tp.config.BatchSize)
+	assert.Equal(t, time.Minute, tp.config.BatchWaitDuration)
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kasper-admin")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "admin.sock")
+	listener, err := listenUnix(path)
+	assert.Nil(t, err)
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// An existing socket is replaced
+	replaced, err := listenUnix(path)
+	assert.Nil(t, err)
+	conn, err := net.Dial("unix", path)
+	assert.Nil(t, err)
+	conn.Close()
+	replaced.Close()
+	listener.Close()
+
+	// Other files are left untouched
+	file := filepath.Join(dir, "config.json")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("{}"), 0644))
+	_, err = listenUnix(file)
+	assert.EqualError(t, err, file+" exists and is not a socket")
+	content, _ := ioutil.ReadFile(file)
+	assert.Equal(t, "{}", string(content))
+}
@@ -0,0 +1,194 @@
+package kasper
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// AuditRecord describes a mutation of a Store, as recorded by AuditStore.
+type AuditRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Store     string    `json:"store"`
+	// "Put" or "Delete" (PutAll produces one record per key)
+	Operation string `json:"operation"`
+	Key       string `json:"key"`
+	// Size of the value in bytes, 0 for deletes
+	Size int `json:"size"`
+	// Topic, partition and offset of the message that caused the mutation, see AuditStore.SetSourceMessage
+	Topic     string `json:"topic,omitempty"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// AuditLog receives the records of an AuditStore.
+type AuditLog interface {
+	Append(records []AuditRecord) error
+}
+
+// AuditStore wraps a Store and records every successful Put, PutAll and Delete to an AuditLog, for investigating
+// when a key was changed and by which message. Values are not recorded, only their size.
+type AuditStore struct {
+	store   Store
+	name    string
+	log     AuditLog
+	clock   Clock
+	message *sarama.ConsumerMessage
+}
+
+// NewAuditStore creates AuditStore instances. The name is recorded in AuditRecord.Store.
+func NewAuditStore(config *Config, store Store, name string, log AuditLog) *AuditStore {
+	return &AuditStore{
+		store,
+		name,
+		log,
+		config.clock(),
+		nil,
+	}
+}
+
+// SetSourceMessage sets the message whose processing causes the next mutations.
+// Call it from MessageProcessor.Process before updating the store for each message.
+func (s *AuditStore) SetSourceMessage(message *sarama.ConsumerMessage) {
+	s.message = message
+}
+
+func (s *AuditStore) record(operation string, key string, size int) AuditRecord {
+	record := AuditRecord{
+		Timestamp: s.clock.Now(),
+		Store:     s.name,
+		Operation: operation,
+		Key:       key,
+		Size:      size,
+		Partition: -1,
+		Offset:    -1,
+	}
+	if s.message != nil {
+		record.Topic = s.message.Topic
+		record.Partition = int(s.message.Partition)
+		record.Offset = s.message.Offset
+	}
+	return record
+}
+
+// Get gets a value by key from the underlying store.
+func (s *AuditStore) Get(key string) ([]byte, error) {
+	return s.store.Get(key)
+}
+
+// GetAll gets multiple values by key from the underlying store.
+func (s *AuditStore) GetAll(keys []string) (map[string][]byte, error) {
+	return s.store.GetAll(keys)
+}
+
+// Put inserts or updates a value by key in the underlying store, then records the mutation.
+func (s *AuditStore) Put(key string, value []byte) error {
+	err := s.store.Put(key, value)
+	if err != nil {
+		return err
+	}
+	return s.log.Append([]AuditRecord{s.record("Put", key, len(value))})
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store, then records one mutation per key.
+func (s *AuditStore) PutAll(kvs map[string][]byte) error {
+	err := s.store.PutAll(kvs)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	records := make([]AuditRecord, len(keys))
+	for i, key := range keys {
+		records[i] = s.record("Put", key, len(kvs[key]))
+	}
+	return s.log.Append(records)
+}
+
+// Delete deletes a key from the underlying store, then records the mutation.
+func (s *AuditStore) Delete(key string) error {
+	err := s.store.Delete(key)
+	if err != nil {
+		return err
+	}
+	return s.log.Append([]AuditRecord{s.record("Delete", key, 0)})
+}
+
+// DeleteAll deletes multiple keys from the underlying store, then records one mutation per key.
+func (s *AuditStore) DeleteAll(keys []string) error {
+	err := DeleteAll(s.store, keys)
+	if err != nil {
+		return err
+	}
+	records := make([]AuditRecord, len(keys))
+	for i, key := range keys {
+		records[i] = s.record("Delete", key, 0)
+	}
+	return s.log.Append(records)
+}
+
+// Flush flushes the underlying store.
+func (s *AuditStore) Flush() error {
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *AuditStore) GetStore() Store {
+	return s.store
+}
+
+type loggerAuditLog struct {
+	logger Logger
+}
+
+// NewLoggerAuditLog creates an AuditLog that writes records at info level, with the record as fields.
+func NewLoggerAuditLog(logger Logger) AuditLog {
+	return &loggerAuditLog{logger}
+}
+
+func (l *loggerAuditLog) Append(records []AuditRecord) error {
+	for _, record := range records {
+		WithFields(l.logger,
+			Field{"store", record.Store},
+			Field{"operation", record.Operation},
+			Field{"key", record.Key},
+			Field{"size", record.Size},
+			Field{"topic", record.Topic},
+			Field{"partition", record.Partition},
+			Field{"offset", record.Offset},
+		).Info("Audit")
+	}
+	return nil
+}
+
+type kafkaAuditLog struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+// NewKafkaAuditLog creates an AuditLog that produces records as JSON messages to topic, keyed by the store key
+// so that the history of a key can be found in a single partition.
+func NewKafkaAuditLog(producer sarama.SyncProducer, topic string) AuditLog {
+	return &kafkaAuditLog{producer, topic}
+}
+
+func (l *kafkaAuditLog) Append(records []AuditRecord) error {
+	messages := make([]*sarama.ProducerMessage, len(records))
+	for i, record := range records {
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		messages[i] = &sarama.ProducerMessage{
+			Topic: l.topic,
+			Key:   sarama.StringEncoder(record.Key),
+			Value: sarama.ByteEncoder(value),
+		}
+	}
+	return l.producer.SendMessages(messages)
+}
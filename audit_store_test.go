@@ -0,0 +1,100 @@
+package kasper
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedClock struct {
+	systemClock
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+type recordingAuditLog struct {
+	records []AuditRecord
+}
+
+func (l *recordingAuditLog) Append(records []AuditRecord) error {
+	l.records = append(l.records, records...)
+	return nil
+}
+
+type recordingSyncProducer struct {
+	sarama.SyncProducer
+	messages []*sarama.ProducerMessage
+}
+
+func (p *recordingSyncProducer) SendMessages(messages []*sarama.ProducerMessage) error {
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+type failingStore struct {
+	Store
+	err error
+}
+
+func (s *failingStore) Put(key string, value []byte) error {
+	return s.err
+}
+
+func TestAuditStore(t *testing.T) {
+	now := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	log := &recordingAuditLog{}
+	store := NewAuditStore(&Config{Clock: fixedClock{now: now}}, NewMap(10), "characters", log)
+
+	assert.Nil(t, store.Put("arthur", []byte("dent")))
+	store.SetSourceMessage(&sarama.ConsumerMessage{Topic: "characters", Partition: 3, Offset: 42})
+	assert.Nil(t, store.PutAll(map[string][]byte{"zaphod": []byte("beeblebrox"), "ford": []byte("prefect")}))
+	assert.Nil(t, store.Delete("arthur"))
+	value, err := store.Get("ford")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("prefect"), value)
+
+	assert.Equal(t, []AuditRecord{
+		{now, "characters", "Put", "arthur", 4, "", -1, -1},
+		{now, "characters", "Put", "ford", 7, "characters", 3, 42},
+		{now, "characters", "Put", "zaphod", 10, "characters", 3, 42},
+		{now, "characters", "Delete", "arthur", 0, "characters", 3, 42},
+	}, log.records)
+}
+
+func TestAuditStore_Error(t *testing.T) {
+	log := &recordingAuditLog{}
+	store := NewAuditStore(&Config{}, &failingStore{NewMap(10), errors.New("boom")}, "characters", log)
+	assert.EqualError(t, store.Put("arthur", []byte("dent")), "boom")
+	assert.Empty(t, log.records)
+}
+
+func TestKafkaAuditLog(t *testing.T) {
+	producer := &recordingSyncProducer{}
+	record := AuditRecord{time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC), "characters", "Put", "ford", 7, "characters", 3, 42}
+	assert.Nil(t, NewKafkaAuditLog(producer, "audit").Append([]AuditRecord{record}))
+
+	assert.Len(t, producer.messages, 1)
+	message := producer.messages[0]
+	assert.Equal(t, "audit", message.Topic)
+	assert.Equal(t, sarama.StringEncoder("ford"), message.Key)
+	var decoded AuditRecord
+	value, _ := message.Value.Encode()
+	assert.Nil(t, json.Unmarshal(value, &decoded))
+	assert.Equal(t, record, decoded)
+}
+
+func TestLoggerAuditLog(t *testing.T) {
+	zap := &recordingZapLogger{}
+	record := AuditRecord{time.Now(), "characters", "Delete", "ford", 0, "characters", 3, 42}
+	assert.Nil(t, NewLoggerAuditLog(NewZapLogger(zap)).Append([]AuditRecord{record}))
+	assert.Equal(t, []string{
+		"INFO Audit [store characters operation Delete key ford size 0 topic characters partition 3 offset 42]",
+	}, zap.entries)
+}
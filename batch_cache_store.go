@@ -0,0 +1,108 @@
+package kasper
+
+// BatchCacheStore remembers the values read and written during a batch, so that a MessageProcessor looking up
+// the same keys for many messages of a batch fetches each distinct key from the underlying store only once.
+// Duplicate keys passed to GetAll are also fetched once. The cache is cleared by Flush, which the TopicProcessor
+// calls after each batch if the store is registered in Config.Stores:
+//
+//	users := kasper.NewBatchCacheStore(redis)
+//	config.Stores = append(config.Stores, kasper.NamedStore{Name: "users", Store: users})
+//
+// Unlike CachingStore, values are never kept across batches, so changes made by other processes are seen
+// by the next batch.
+type BatchCacheStore struct {
+	store   Store
+	entries map[string][]byte
+}
+
+// NewBatchCacheStore creates BatchCacheStore instances.
+func NewBatchCacheStore(store Store) *BatchCacheStore {
+	return &BatchCacheStore{store, make(map[string][]byte)}
+}
+
+// Get gets a value by key from the cache, or from the underlying store.
+func (s *BatchCacheStore) Get(key string) ([]byte, error) {
+	if value, found := s.entries[key]; found {
+		return value, nil
+	}
+	value, err := s.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	s.entries[key] = value
+	return value, nil
+}
+
+// GetAll gets multiple values by key from the cache, and the distinct keys that are not cached from the
+// underlying store.
+func (s *BatchCacheStore) GetAll(keys []string) (map[string][]byte, error) {
+	var missing []string
+	requested := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if _, found := s.entries[key]; !found && !requested[key] {
+			missing = append(missing, key)
+		}
+		requested[key] = true
+	}
+	if len(missing) > 0 {
+		stored, err := s.store.GetAll(missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range missing {
+			s.entries[key] = stored[key]
+		}
+	}
+	kvs := make(map[string][]byte, len(requested))
+	for key := range requested {
+		if value := s.entries[key]; value != nil {
+			kvs[key] = value
+		}
+	}
+	return kvs, nil
+}
+
+// Put inserts or updates a value by key in the underlying store and in the cache.
+func (s *BatchCacheStore) Put(key string, value []byte) error {
+	if err := s.store.Put(key, value); err != nil {
+		delete(s.entries, key)
+		return err
+	}
+	s.entries[key] = value
+	return nil
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store and in the cache.
+func (s *BatchCacheStore) PutAll(kvs map[string][]byte) error {
+	if err := s.store.PutAll(kvs); err != nil {
+		for key := range kvs {
+			delete(s.entries, key)
+		}
+		return err
+	}
+	for key, value := range kvs {
+		s.entries[key] = value
+	}
+	return nil
+}
+
+// Delete deletes a key from the underlying store and caches its absence.
+func (s *BatchCacheStore) Delete(key string) error {
+	if err := s.store.Delete(key); err != nil {
+		delete(s.entries, key)
+		return err
+	}
+	s.entries[key] = nil
+	return nil
+}
+
+// Flush clears the cache and flushes the underlying store.
+func (s *BatchCacheStore) Flush() error {
+	s.entries = make(map[string][]byte)
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *BatchCacheStore) GetStore() Store {
+	return s.store
+}
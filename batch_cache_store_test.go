@@ -0,0 +1,44 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type getAllRecordingStore struct {
+	Store
+	requests [][]string
+}
+
+func (s *getAllRecordingStore) GetAll(keys []string) (map[string][]byte, error) {
+	s.requests = append(s.requests, keys)
+	return s.Store.GetAll(keys)
+}
+
+func TestBatchCacheStore(t *testing.T) {
+	s := NewMap(10)
+	s.PutAll(map[string][]byte{"mercury": mercury, "venus": venus})
+	recording := &getAllRecordingStore{Store: s}
+	store := NewBatchCacheStore(recording)
+
+	kvs, err := store.GetAll([]string{"mercury", "pluto", "mercury"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"mercury": mercury}, kvs)
+	kvs, err = store.GetAll([]string{"venus", "mercury", "pluto", "venus"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"mercury": mercury, "venus": venus}, kvs)
+	assert.Equal(t, [][]string{{"mercury", "pluto"}, {"venus"}}, recording.requests)
+
+	assert.Nil(t, store.Put("pluto", []byte("pluto")))
+	assert.Nil(t, store.Delete("mercury"))
+	s.Put("venus", earth)
+	kvs, _ = store.GetAll([]string{"mercury", "venus", "pluto"})
+	assert.Equal(t, map[string][]byte{"venus": venus, "pluto": []byte("pluto")}, kvs)
+	assert.Equal(t, 2, len(recording.requests))
+
+	// The next batch reads the underlying store again
+	assert.Nil(t, store.Flush())
+	value, _ := store.Get("venus")
+	assert.Equal(t, earth, value)
+}
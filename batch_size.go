@@ -0,0 +1,57 @@
+package kasper
+
+import "time"
+
+// batchSizeController adjusts the batch size after each full batch so that processing a batch takes about
+// Config.TargetBatchLatency, between Config.MinBatchSize and Config.BatchSize. It is used from the RunLoop goroutine.
+type batchSizeController struct {
+	target  time.Duration
+	min     int
+	size    int
+	gauge   Gauge
+	enabled bool
+}
+
+func newBatchSizeController(config *Config) *batchSizeController {
+	min := config.MinBatchSize
+	if min <= 0 {
+		min = 1
+	}
+	return &batchSizeController{
+		config.TargetBatchLatency,
+		min,
+		config.BatchSize,
+		config.metricsProvider().NewGauge("batch_size", "Number of messages processed in one go"),
+		config.TargetBatchLatency > 0,
+	}
+}
+
+// batchSize returns the current batch size, which never exceeds max (i.e. Config.BatchSize).
+func (c *batchSizeController) batchSize(max int) int {
+	if !c.enabled || c.size > max {
+		c.size = max
+	}
+	return c.size
+}
+
+// observe adjusts the batch size after a full batch of size messages was processed in latency.
+// The batch size grows by a quarter while batches are faster than the target, and shrinks proportionally
+// when they are slower.
+func (c *batchSizeController) observe(size, max int, latency time.Duration) {
+	if !c.enabled {
+		return
+	}
+	switch {
+	case latency > c.target:
+		c.size = int(float64(size) * float64(c.target) / float64(latency))
+	case latency < c.target*4/5:
+		c.size = size + size/4 + 1
+	}
+	if c.size < c.min {
+		c.size = c.min
+	}
+	if c.size > max {
+		c.size = max
+	}
+	c.gauge.Set(float64(c.size))
+}
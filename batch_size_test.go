@@ -0,0 +1,56 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchSizeController(t *testing.T) {
+	provider := newRecordingMetricsProvider()
+	config := &Config{ContainerID: "c0", MetricsProvider: provider, BatchSize: 1000, MinBatchSize: 10, TargetBatchLatency: time.Second}
+	c := newBatchSizeController(config)
+	assert.Equal(t, 1000, c.batchSize(1000))
+
+	c.observe(1000, 1000, 4*time.Second)
+	assert.Equal(t, 250, c.batchSize(1000))
+	assert.Equal(t, 250.0, provider.values["batch_size{c0,}"])
+	c.observe(250, 1000, 900*time.Millisecond)
+	assert.Equal(t, 250, c.batchSize(1000))
+	c.observe(250, 1000, 500*time.Millisecond)
+	assert.Equal(t, 313, c.batchSize(1000))
+	c.observe(313, 1000, time.Minute)
+	assert.Equal(t, 10, c.batchSize(1000))
+
+	// The batch size never exceeds Config.BatchSize, which can be changed by Reconfigure
+	assert.Equal(t, 5, c.batchSize(5))
+	c.observe(5, 5, time.Millisecond)
+	assert.Equal(t, 5, c.batchSize(5))
+
+	c = newBatchSizeController(&Config{BatchSize: 100})
+	c.observe(100, 100, time.Hour)
+	assert.Equal(t, 100, c.batchSize(100))
+}
+
+func TestTopicProcessor_TargetBatchLatency(t *testing.T) {
+	var sizes []int
+	processor := processorFunc(func(messages []*sarama.ConsumerMessage, sender Sender) error {
+		sizes = append(sizes, len(messages))
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	tp := newFakeTopicProcessor(&Config{BatchSize: 4, TargetBatchLatency: time.Millisecond}, processor)
+	done := tp.start()
+	for offset := int64(0); offset < 6; offset++ {
+		tp.send(0, offset, "mars")
+	}
+	waitFor(t, func() bool {
+		offset, _ := tp.offsets[0].NextOffset()
+		return offset == 6
+	})
+	tp.Close()
+	assert.Nil(t, <-done)
+	assert.Equal(t, []int{4, 1, 1}, sizes)
+}
@@ -0,0 +1,180 @@
+/*
+Package bench measures the throughput, batch latency and allocations of MessageProcessors, without Kafka.
+
+Run calls MessageProcessor.Process directly: the TopicProcessor loop, the producer and offset marking are not
+measured. Use kaspertest to exercise a MessageProcessor through a TopicProcessor.
+
+	result, err := bench.Run(map[int]kasper.MessageProcessor{0: processor}, bench.Options{Messages: 1000000})
+	fmt.Println(result)
+*/
+package bench
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+	"github.com/movio/kasper/kaspertest"
+)
+
+// Options configures a benchmark run.
+type Options struct {
+	// Total number of messages, defaults to 100000
+	Messages int
+	// Number of messages per call to MessageProcessor.Process, defaults to 1000
+	BatchSize int
+	// Topic of the generated messages, defaults to "bench"
+	Topic string
+	// Size of the generated message values, defaults to 100 bytes
+	ValueSize int
+	// Generates the message with the given sequence number. Defaults to messages with key "key-<i % 1000>"
+	// and ValueSize random-looking bytes, spread across the partitions of the MessageProcessors.
+	Generator func(i int) *sarama.ConsumerMessage
+}
+
+// Result contains the measurements of a benchmark run.
+type Result struct {
+	Messages          int
+	Duration          time.Duration
+	MessagesPerSecond float64
+	// Percentiles of the latency of a batch, i.e. of one call to MessageProcessor.Process with Options.BatchSize
+	// messages (not of a single message)
+	P50              time.Duration
+	P99              time.Duration
+	Max              time.Duration
+	AllocsPerMessage float64
+	BytesPerMessage  float64
+}
+
+func (r *Result) String() string {
+	return fmt.Sprintf("%d messages in %s: %.0f msg/s, batch latency p50=%s p99=%s max=%s, %.1f allocs/msg, %.0f B/msg",
+		r.Messages, r.Duration, r.MessagesPerSecond, r.P50, r.P99, r.Max, r.AllocsPerMessage, r.BytesPerMessage)
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+
+func (d durations) percentile(p float64) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	index := int(p*float64(len(d))+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(d) {
+		index = len(d) - 1
+	}
+	return d[index]
+}
+
+func (opts *Options) setDefaults(messageProcessors map[int]kasper.MessageProcessor) {
+	if opts.Messages == 0 {
+		opts.Messages = 100000
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.Topic == "" {
+		opts.Topic = "bench"
+	}
+	if opts.ValueSize == 0 {
+		opts.ValueSize = 100
+	}
+	if opts.Generator == nil {
+		var partitions []int
+		for partition := range messageProcessors {
+			partitions = append(partitions, partition)
+		}
+		sort.Ints(partitions)
+		value := make([]byte, opts.ValueSize)
+		for i := range value {
+			value[i] = byte('a' + i%26)
+		}
+		topic := opts.Topic
+		opts.Generator = func(i int) *sarama.ConsumerMessage {
+			return &sarama.ConsumerMessage{
+				Topic:     topic,
+				Partition: int32(partitions[i%len(partitions)]),
+				Offset:    int64(i / len(partitions)),
+				Key:       []byte("key-" + strconv.Itoa(i%1000)),
+				Value:     value,
+			}
+		}
+	}
+}
+
+// Run generates opts.Messages messages and passes them to the MessageProcessor of their partition in batches
+// of opts.BatchSize. Messages are generated before the measurement starts, and messages sent by the
+// MessageProcessors are discarded.
+func Run(messageProcessors map[int]kasper.MessageProcessor, opts Options) (*Result, error) {
+	if len(messageProcessors) == 0 {
+		return nil, fmt.Errorf("no MessageProcessor")
+	}
+	opts.setDefaults(messageProcessors)
+	batches := generateBatches(opts)
+
+	latencies := make(durations, 0, len(batches))
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for _, batch := range batches {
+		messageProcessor, found := messageProcessors[int(batch[0].Partition)]
+		if !found {
+			return nil, fmt.Errorf("no MessageProcessor for partition %d", batch[0].Partition)
+		}
+		batchStart := time.Now()
+		err := messageProcessor.Process(batch, kaspertest.NewRecordingSender())
+		if err != nil {
+			return nil, err
+		}
+		latencies = append(latencies, time.Since(batchStart))
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	sort.Sort(latencies)
+	return &Result{
+		Messages:          opts.Messages,
+		Duration:          elapsed,
+		MessagesPerSecond: float64(opts.Messages) / elapsed.Seconds(),
+		P50:               latencies.percentile(0.50),
+		P99:               latencies.percentile(0.99),
+		Max:               latencies.percentile(1),
+		AllocsPerMessage:  float64(after.Mallocs-before.Mallocs) / float64(opts.Messages),
+		BytesPerMessage:   float64(after.TotalAlloc-before.TotalAlloc) / float64(opts.Messages),
+	}, nil
+}
+
+// generateBatches groups generated messages by partition into batches of at most opts.BatchSize messages,
+// in the order in which batches fill up.
+func generateBatches(opts Options) [][]*sarama.ConsumerMessage {
+	var batches [][]*sarama.ConsumerMessage
+	pending := make(map[int32][]*sarama.ConsumerMessage)
+	var order []int32
+	for i := 0; i < opts.Messages; i++ {
+		message := opts.Generator(i)
+		if _, found := pending[message.Partition]; !found {
+			order = append(order, message.Partition)
+		}
+		pending[message.Partition] = append(pending[message.Partition], message)
+		if len(pending[message.Partition]) == opts.BatchSize {
+			batches = append(batches, pending[message.Partition])
+			pending[message.Partition] = []*sarama.ConsumerMessage{}
+		}
+	}
+	for _, partition := range order {
+		if len(pending[partition]) > 0 {
+			batches = append(batches, pending[partition])
+		}
+	}
+	return batches
+}
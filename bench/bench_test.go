@@ -0,0 +1,57 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingProcessor struct {
+	store kasper.Store
+}
+
+func (processor *countingProcessor) Process(messages []*sarama.ConsumerMessage, sender kasper.Sender) error {
+	kvs := make(map[string][]byte, len(messages))
+	for _, message := range messages {
+		kvs[string(message.Key)] = message.Value
+		sender.Send(&sarama.ProducerMessage{Topic: "out", Value: sarama.ByteEncoder(message.Value)})
+	}
+	return processor.store.PutAll(kvs)
+}
+
+func TestRun(t *testing.T) {
+	store := kasper.NewMap(1000)
+	processor := &countingProcessor{store}
+	result, err := Run(map[int]kasper.MessageProcessor{0: processor, 1: processor}, Options{Messages: 2500, BatchSize: 500})
+	assert.Nil(t, err)
+	assert.Equal(t, 2500, result.Messages)
+	assert.True(t, result.MessagesPerSecond > 0)
+	assert.True(t, result.P50 <= result.P99)
+	assert.True(t, result.P99 <= result.Max)
+	assert.Equal(t, 1000, len(store.GetMap()))
+}
+
+func TestGenerateBatches(t *testing.T) {
+	opts := Options{Messages: 7, BatchSize: 2}
+	opts.setDefaults(map[int]kasper.MessageProcessor{0: nil, 1: nil})
+	batches := generateBatches(opts)
+	var sizes []int
+	for _, batch := range batches {
+		sizes = append(sizes, len(batch))
+	}
+	assert.Equal(t, []int{2, 2, 2, 1}, sizes)
+	assert.Equal(t, int32(1), batches[3][0].Partition)
+}
+
+func BenchmarkStoreMetrics(b *testing.B) {
+	config := &kasper.Config{TopicProcessorName: "bench"}
+	store := kasper.NewStoreMetrics(config, kasper.NewMap(1000), "map")
+	processor := &countingProcessor{store}
+	result, err := Run(map[int]kasper.MessageProcessor{0: processor}, Options{Messages: b.N})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Log(result)
+}
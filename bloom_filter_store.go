@@ -0,0 +1,155 @@
+package kasper
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// BloomFilterStore keeps a bloom filter of the keys of a remote store, so that reads of keys that have never been
+// written skip the round trip to the store:
+//
+//	store := kasper.NewBloomFilterStore(config, redis, "users", 1000000, 0.01)
+//	if err := store.Rebuild(); err != nil {
+//		...
+//	}
+//
+// The filter only knows about the keys written through the BloomFilterStore, so it must be rebuilt with Rebuild
+// from the keys of the store, which must implement IterableStore, before it is used for reads. Until then,
+// all reads go to the store. Deleted keys stay in the filter, so their reads go to the store until the next
+// Rebuild. Each partition processor should use its own BloomFilterStore, rebuilt on partition assignment.
+type BloomFilterStore struct {
+	store       Store
+	bits        []uint64
+	hashes      uint32
+	ready       bool
+	skipCounter Counter
+	labelValues []string
+}
+
+// NewBloomFilterStore creates BloomFilterStore instances. The filter is sized so that falsePositiveRate of the
+// reads of missing keys go to the store when it holds expectedKeys keys. falsePositiveRate must be between 0 and 1
+// (exclusive), and defaults to 0.01 otherwise. The name is used as the value of the "store" label of the
+// bloom_filter_skip_count metric.
+func NewBloomFilterStore(config *Config, store Store, name string, expectedKeys int, falsePositiveRate float64) *BloomFilterStore {
+	if expectedKeys < 1 {
+		expectedKeys = 1
+	}
+	if !(falsePositiveRate > 0 && falsePositiveRate < 1) {
+		falsePositiveRate = 0.01
+	}
+	bits := math.Ceil(-float64(expectedKeys) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := math.Max(1, math.Floor(bits/float64(expectedKeys)*math.Ln2+0.5))
+	return &BloomFilterStore{
+		store,
+		make([]uint64, (int(bits)+63)/64),
+		uint32(hashes),
+		false,
+		config.storeMetricsProvider().NewCounter("bloom_filter_skip_count", "Number of reads of missing keys answered by the bloom filter", "store"),
+		[]string{name},
+	}
+}
+
+// locations returns the bit positions of key, using double hashing.
+func (s *BloomFilterStore) locations(key string) []uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	sum := hash.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	size := uint64(len(s.bits) * 64)
+	locations := make([]uint64, s.hashes)
+	for i := range locations {
+		locations[i] = (h1 + uint64(i)*h2) % size
+	}
+	return locations
+}
+
+func (s *BloomFilterStore) add(key string) {
+	for _, location := range s.locations(key) {
+		s.bits[location/64] |= 1 << (location % 64)
+	}
+}
+
+// MayContain returns false if key has never been written to the store, and true if it may have been.
+// It always returns true until Rebuild is called.
+func (s *BloomFilterStore) MayContain(key string) bool {
+	if !s.ready {
+		return true
+	}
+	for _, location := range s.locations(key) {
+		if s.bits[location/64]&(1<<(location%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Rebuild clears the filter and adds the keys of the underlying store. The filter is used for reads once
+// Rebuild has succeeded.
+func (s *BloomFilterStore) Rebuild() error {
+	s.ready = false
+	for i := range s.bits {
+		s.bits[i] = 0
+	}
+	err := Iterate(s.store, "", func(kv KeyValue) bool {
+		s.add(kv.Key)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	s.ready = true
+	return nil
+}
+
+// Get gets a value by key from the underlying store, unless the filter shows that the key is missing.
+func (s *BloomFilterStore) Get(key string) ([]byte, error) {
+	if !s.MayContain(key) {
+		s.skipCounter.Inc(s.labelValues...)
+		return nil, nil
+	}
+	return s.store.Get(key)
+}
+
+// GetAll gets from the underlying store the values of the keys that the filter does not show as missing.
+func (s *BloomFilterStore) GetAll(keys []string) (map[string][]byte, error) {
+	var candidates []string
+	for _, key := range keys {
+		if s.MayContain(key) {
+			candidates = append(candidates, key)
+		}
+	}
+	s.skipCounter.Add(float64(len(keys)-len(candidates)), s.labelValues...)
+	if len(candidates) == 0 {
+		return map[string][]byte{}, nil
+	}
+	return s.store.GetAll(candidates)
+}
+
+// Put inserts or updates a value by key in the underlying store, and adds the key to the filter.
+func (s *BloomFilterStore) Put(key string, value []byte) error {
+	s.add(key)
+	return s.store.Put(key, value)
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store, and adds the keys to the filter.
+func (s *BloomFilterStore) PutAll(kvs map[string][]byte) error {
+	for key := range kvs {
+		s.add(key)
+	}
+	return s.store.PutAll(kvs)
+}
+
+// Delete deletes a key from the underlying store. The key stays in the filter.
+func (s *BloomFilterStore) Delete(key string) error {
+	return s.store.Delete(key)
+}
+
+// Flush flushes the underlying store.
+func (s *BloomFilterStore) Flush() error {
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *BloomFilterStore) GetStore() Store {
+	return s.store
+}
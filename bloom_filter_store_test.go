@@ -0,0 +1,58 @@
+package kasper
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomFilterStore(t *testing.T) {
+	provider := newRecordingMetricsProvider()
+	config := &Config{TopicProcessorName: "hari-seldon", ContainerID: "container-1", MetricsProvider: provider}
+	s := NewMap(10)
+	s.Put("mercury", mercury)
+	store := NewBloomFilterStore(config, s, "planets", 1000, 0.01)
+
+	// All reads go to the store until the filter is rebuilt
+	assert.True(t, store.MayContain("pluto"))
+	assert.Nil(t, store.Rebuild())
+	assert.True(t, store.MayContain("mercury"))
+	assert.False(t, store.MayContain("pluto"))
+
+	assert.Nil(t, store.PutAll(map[string][]byte{"venus": venus, "earth": earth}))
+	kvs, err := store.GetAll([]string{"mercury", "venus", "pluto"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"mercury": mercury, "venus": venus}, kvs)
+
+	// A key written directly to the underlying store is not seen until the filter is rebuilt
+	s.Put("pluto", []byte("pluto"))
+	value, _ := store.Get("pluto")
+	assert.Nil(t, value)
+	assert.Equal(t, 2.0, provider.values["bloom_filter_skip_count{planets,,container-1,hari-seldon}"])
+	assert.Nil(t, store.Rebuild())
+	value, _ = store.Get("pluto")
+	assert.Equal(t, []byte("pluto"), value)
+
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		store.Put(fmt.Sprintf("written-%d", i), mars)
+		if store.MayContain(fmt.Sprintf("missing-%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.True(t, falsePositives < 50)
+}
+
+func TestBloomFilterStore_InvalidFalsePositiveRate(t *testing.T) {
+	for _, rate := range []float64{0, 1, -0.5, 2, math.NaN()} {
+		store := NewBloomFilterStore(&Config{}, NewMap(10), "planets", 1000, rate)
+		assert.Equal(t, NewBloomFilterStore(&Config{}, NewMap(10), "planets", 1000, 0.01).bits, store.bits)
+		assert.Nil(t, store.Rebuild())
+		assert.Nil(t, store.Put("mercury", mercury))
+		value, err := store.Get("mercury")
+		assert.Nil(t, err)
+		assert.Equal(t, mercury, value)
+	}
+}
@@ -0,0 +1,55 @@
+package kasper
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// BufferPool reuses the byte buffers holding the payloads of outgoing messages, so that producing a message
+// does not allocate a new slice once the pool is warm:
+//
+//	buffer := config.BufferPool.Get()
+//	if err := serde.SerializeTo(buffer, wordCount); err != nil {
+//		...
+//	}
+//	sender.(kasper.BufferSender).SendBuffer(&sarama.ProducerMessage{Topic: "word-counts", Key: key}, buffer)
+//
+// It is safe for concurrent use.
+type BufferPool struct {
+	pool          sync.Pool
+	maxBufferSize int
+}
+
+// NewBufferPool creates BufferPool instances. Buffers that have grown beyond maxBufferSize bytes are not
+// reused, so that a few large payloads do not pin memory (0 means no limit).
+func NewBufferPool(maxBufferSize int) *BufferPool {
+	return &BufferPool{
+		sync.Pool{New: func() interface{} { return new(bytes.Buffer) }},
+		maxBufferSize,
+	}
+}
+
+// Get returns an empty buffer.
+func (p *BufferPool) Get() *bytes.Buffer {
+	buffer := p.pool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	return buffer
+}
+
+// Put returns a buffer to the pool. The buffer and its content must not be used afterwards.
+func (p *BufferPool) Put(buffer *bytes.Buffer) {
+	if p.maxBufferSize > 0 && buffer.Cap() > p.maxBufferSize {
+		return
+	}
+	p.pool.Put(buffer)
+}
+
+// BufferSender is implemented by the Sender given to MessageProcessor.Process.
+type BufferSender interface {
+	Sender
+	// SendBuffer sets the value of msg to the content of buffer and sends msg like Send. The buffer is returned to
+	// Config.BufferPool once msg has been produced, so neither buffer nor its content must be used afterwards.
+	SendBuffer(msg *sarama.ProducerMessage, buffer *bytes.Buffer)
+}
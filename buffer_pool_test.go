@@ -0,0 +1,47 @@
+package kasper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferPool(t *testing.T) {
+	pool := NewBufferPool(16)
+	buffer := pool.Get()
+	buffer.WriteString("mars")
+	pool.Put(buffer)
+	assert.Equal(t, 0, pool.Get().Len())
+
+	pool.Put(bytes.NewBuffer(make([]byte, 0, 1024)))
+	assert.True(t, pool.Get().Cap() <= 16)
+}
+
+func TestSender_SendBuffer(t *testing.T) {
+	serde := NewJSONSerde(func() interface{} { return &serdeTestPlanet{} })
+	config := &Config{BufferPool: NewBufferPool(0)}
+	processor := processorFunc(func(messages []*sarama.ConsumerMessage, sender Sender) error {
+		for _, message := range messages {
+			buffer := config.BufferPool.Get()
+			if err := serde.SerializeTo(buffer, &serdeTestPlanet{string(message.Value), 2}); err != nil {
+				return err
+			}
+			sender.(BufferSender).SendBuffer(&sarama.ProducerMessage{Topic: "output"}, buffer)
+		}
+		return nil
+	})
+	tp := newFakeTopicProcessor(config, processor)
+	done := tp.start()
+	tp.send(0, 0, "mars")
+	waitFor(t, func() bool {
+		assert.Nil(t, tp.Flush())
+		offset, _ := tp.offsets[0].NextOffset()
+		return offset == 1
+	})
+	tp.Close()
+	assert.Nil(t, <-done)
+	assert.Equal(t, 1, len(tp.producer.messages))
+	assert.Equal(t, sarama.ByteEncoder(`{"name":"mars","moons":2}`), tp.producer.messages[0].Value)
+}
@@ -0,0 +1,270 @@
+package kasper
+
+import (
+	"sync"
+	"time"
+)
+
+// CachingStore wraps a Store with an in-memory cache. Reads go through the cache, and writes go to the underlying
+// store and then update the cache. Keys that are not found can be cached as well (negative caching), which helps
+// joins that look up the same missing reference keys repeatedly.
+//
+// Writes made to the underlying store by other processes are only seen when the cached entries expire or are
+// invalidated, see Invalidate and InvalidateFrom. The number of hits and misses are counted in the
+// cache_hit_count and cache_miss_count metrics. CachingStore is safe for concurrent use if the underlying
+// store is: values read after a miss are not cached if the cache was written or invalidated in the meantime, so
+// that a concurrent Put is never overwritten by a stale value.
+type CachingStore struct {
+	store       Store
+	name        string
+	clock       Clock
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	mutex       sync.Mutex
+	entries     map[string]cacheEntry
+	generation  uint64
+	hitCounter  Counter
+	missCounter Counter
+	labelValues []string
+	hits        int64
+	misses      int64
+}
+
+type cacheEntry struct {
+	value  []byte
+	expiry time.Time
+}
+
+// NewCachingStore creates CachingStore instances. Values are cached for ttl, and missing keys for negativeTTL
+// (0 disables negative caching). When the cache holds maxEntries entries, an arbitrary entry is evicted to make
+// room for a new one (0 means unlimited). The name is used as the value of the "store" label.
+func NewCachingStore(config *Config, store Store, name string, ttl, negativeTTL time.Duration, maxEntries int) *CachingStore {
+	metrics := config.storeMetricsProvider()
+	return &CachingStore{
+		store,
+		name,
+		config.clock(),
+		ttl,
+		negativeTTL,
+		maxEntries,
+		sync.Mutex{},
+		make(map[string]cacheEntry),
+		0,
+		metrics.NewCounter("cache_hit_count", "Number of keys read from the cache", "store"),
+		metrics.NewCounter("cache_miss_count", "Number of keys read from the underlying store", "store"),
+		[]string{name},
+		0,
+		0,
+	}
+}
+
+// lookup returns the cached value of key, and the generation of the cache to pass to fill after a miss.
+func (s *CachingStore) lookup(key string, now time.Time) ([]byte, bool, uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry, found := s.entries[key]
+	if !found || !now.Before(entry.expiry) {
+		return nil, false, s.generation
+	}
+	return entry.value, true, s.generation
+}
+
+// cache caches a value that has just been written.
+func (s *CachingStore) cache(key string, value []byte, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.generation++
+	s.cacheLocked(key, value, now)
+}
+
+// fill caches values read from the underlying store, unless the cache has been written or invalidated since
+// generation was returned by lookup.
+func (s *CachingStore) fill(kvs map[string][]byte, keys []string, now time.Time, generation uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.generation != generation {
+		return
+	}
+	for _, key := range keys {
+		s.cacheLocked(key, kvs[key], now)
+	}
+}
+
+func (s *CachingStore) cacheLocked(key string, value []byte, now time.Time) {
+	ttl := s.ttl
+	if value == nil {
+		ttl = s.negativeTTL
+	}
+	if ttl <= 0 {
+		delete(s.entries, key)
+		return
+	}
+	if _, found := s.entries[key]; !found && s.maxEntries > 0 && len(s.entries) >= s.maxEntries {
+		for evicted := range s.entries {
+			delete(s.entries, evicted)
+			break
+		}
+	}
+	s.entries[key] = cacheEntry{value, now.Add(ttl)}
+}
+
+func (s *CachingStore) count(hits, misses int) {
+	s.hitCounter.Add(float64(hits), s.labelValues...)
+	s.missCounter.Add(float64(misses), s.labelValues...)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hits += int64(hits)
+	s.misses += int64(misses)
+}
+
+// Get gets a value by key from the cache, or from the underlying store.
+func (s *CachingStore) Get(key string) ([]byte, error) {
+	now := s.clock.Now()
+	value, found, generation := s.lookup(key, now)
+	if found {
+		s.count(1, 0)
+		return value, nil
+	}
+	s.count(0, 1)
+	value, err := s.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	s.fill(map[string][]byte{key: value}, []string{key}, now, generation)
+	return value, nil
+}
+
+// GetAll gets multiple values by key from the cache, and the keys that are not cached from the underlying store.
+func (s *CachingStore) GetAll(keys []string) (map[string][]byte, error) {
+	now := s.clock.Now()
+	kvs := make(map[string][]byte, len(keys))
+	var missing []string
+	var generation uint64
+	for _, key := range keys {
+		var value []byte
+		var found bool
+		value, found, generation = s.lookup(key, now)
+		if !found {
+			missing = append(missing, key)
+		} else if value != nil {
+			kvs[key] = value
+		}
+	}
+	s.count(len(keys)-len(missing), len(missing))
+	if len(missing) == 0 {
+		return kvs, nil
+	}
+	stored, err := s.store.GetAll(missing)
+	if err != nil {
+		return nil, err
+	}
+	s.fill(stored, missing, now, generation)
+	for _, key := range missing {
+		if value := stored[key]; value != nil {
+			kvs[key] = value
+		}
+	}
+	return kvs, nil
+}
+
+// Put inserts or updates a value by key in the underlying store, then caches it.
+func (s *CachingStore) Put(key string, value []byte) error {
+	if err := s.store.Put(key, value); err != nil {
+		s.Invalidate(key)
+		return err
+	}
+	s.cache(key, value, s.clock.Now())
+	return nil
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store, then caches them.
+func (s *CachingStore) PutAll(kvs map[string][]byte) error {
+	if err := s.store.PutAll(kvs); err != nil {
+		for key := range kvs {
+			s.Invalidate(key)
+		}
+		return err
+	}
+	now := s.clock.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.generation++
+	for key, value := range kvs {
+		s.cacheLocked(key, value, now)
+	}
+	return nil
+}
+
+// Delete deletes a key from the underlying store, then caches it as missing if negative caching is enabled.
+func (s *CachingStore) Delete(key string) error {
+	if err := s.store.Delete(key); err != nil {
+		s.Invalidate(key)
+		return err
+	}
+	s.cache(key, nil, s.clock.Now())
+	return nil
+}
+
+// Flush flushes the underlying store. The cache is kept.
+func (s *CachingStore) Flush() error {
+	return s.store.Flush()
+}
+
+// Invalidate removes keys from the cache, so that they are read from the underlying store next time.
+func (s *CachingStore) Invalidate(keys ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.generation++
+	for _, key := range keys {
+		delete(s.entries, key)
+	}
+}
+
+// InvalidateAll empties the cache.
+func (s *CachingStore) InvalidateAll() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.generation++
+	s.entries = make(map[string]cacheEntry)
+}
+
+// InvalidateFrom invalidates the keys received on a channel from its own goroutine, until the channel is closed.
+// The channel is typically fed with the keys of a changelog topic consumed by another TopicProcessor.
+func (s *CachingStore) InvalidateFrom(keys <-chan string) {
+	go func() {
+		for key := range keys {
+			s.Invalidate(key)
+		}
+	}()
+}
+
+// Len returns the number of cached entries, including expired entries that have not been replaced yet.
+func (s *CachingStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.entries)
+}
+
+// Stats returns the statistics of the underlying store if it implements StatsStore, with the rate of keys read
+// from the cache since the CachingStore was created.
+func (s *CachingStore) Stats() (StoreStats, error) {
+	var stats StoreStats
+	if statsStore, ok := s.store.(StatsStore); ok {
+		var err error
+		if stats, err = statsStore.Stats(); err != nil && err != ErrStatsNotSupported {
+			return stats, err
+		}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.hits+s.misses > 0 {
+		stats.CacheHitRate = float64(s.hits) / float64(s.hits+s.misses)
+	}
+	return stats, nil
+}
+
+// GetStore returns the underlying Store
+func (s *CachingStore) GetStore() Store {
+	return s.store
+}
@@ -0,0 +1,88 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachingStore(t *testing.T) {
+	provider := newRecordingMetricsProvider()
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	config := &Config{TopicProcessorName: "hari-seldon", ContainerID: "container-1", MetricsProvider: provider, Clock: clock}
+	s := NewMap(10)
+	s.Put("mercury", mercury)
+	store := NewCachingStore(config, s, "planets", time.Minute, time.Second, 10)
+
+	value, _ := store.Get("mercury")
+	assert.Equal(t, mercury, value)
+	value, _ = store.Get("pluto")
+	assert.Nil(t, value)
+
+	// Changes made directly to the underlying store are not seen until the entries expire
+	s.Put("mercury", venus)
+	s.Put("pluto", []byte("pluto"))
+	kvs, err := store.GetAll([]string{"mercury", "pluto", "earth"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"mercury": mercury}, kvs)
+	assert.Equal(t, 2.0, provider.values["cache_hit_count{planets,,container-1,hari-seldon}"])
+	assert.Equal(t, 3.0, provider.values["cache_miss_count{planets,,container-1,hari-seldon}"])
+
+	clock.now = clock.now.Add(time.Second)
+	value, _ = store.Get("pluto")
+	assert.Equal(t, []byte("pluto"), value)
+	store.Invalidate("mercury")
+	value, _ = store.Get("mercury")
+	assert.Equal(t, venus, value)
+
+	// Writes go through
+	store.Put("mars", mars)
+	value, _ = s.Get("mars")
+	assert.Equal(t, mars, value)
+	store.Delete("mars")
+	value, _ = store.Get("mars")
+	assert.Nil(t, value)
+	assert.Equal(t, 4, store.Len())
+
+	keys := make(chan string)
+	store.InvalidateFrom(keys)
+	keys <- "pluto"
+	close(keys)
+	waitFor(t, func() bool { return store.Len() == 3 })
+	store.InvalidateAll()
+	assert.Equal(t, 0, store.Len())
+
+	// An entry is evicted when the cache is full
+	store = NewCachingStore(config, s, "planets", time.Minute, time.Second, 1)
+	store.GetAll([]string{"mercury", "pluto"})
+	assert.Equal(t, 1, store.Len())
+}
+
+// racingStore calls onGet after each read, to simulate writes made while a read is in flight.
+type racingStore struct {
+	Store
+	onGet func()
+}
+
+func (s *racingStore) Get(key string) ([]byte, error) {
+	value, err := s.Store.Get(key)
+	s.onGet()
+	return value, err
+}
+
+func TestCachingStore_ConcurrentPut(t *testing.T) {
+	config := &Config{TopicProcessorName: "hari-seldon", ContainerID: "container-1", Clock: &fixedClock{now: time.Unix(1000, 0)}}
+	s := &racingStore{Store: NewMap(10)}
+	s.Put("mercury", mercury)
+	store := NewCachingStore(config, s, "planets", time.Minute, time.Second, 10)
+	s.onGet = func() {
+		s.onGet = func() {}
+		store.Put("mercury", venus)
+	}
+
+	value, _ := store.Get("mercury")
+	assert.Equal(t, mercury, value)
+	value, _ = store.Get("mercury")
+	assert.Equal(t, venus, value)
+}
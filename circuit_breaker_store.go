@@ -0,0 +1,166 @@
+package kasper
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by CircuitBreakerStore while the circuit is open.
+var ErrCircuitOpen = errors.New("kasper: store circuit breaker is open")
+
+const (
+	circuitClosed = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// CircuitBreakerStore wraps a Store and fails fast while the store is down, so that processors do not pile up
+// on timeouts. The circuit opens after a number of consecutive failures, and calls then return ErrCircuitOpen
+// without reaching the store. After a delay, a single call is let through to probe the store: the circuit closes if
+// it succeeds, and opens again otherwise. Only errors classified as retryable by IsRetryable count as failures.
+// The state of the circuit is reported by the store_circuit_state gauge: 0 when closed, 1 when open and 2 when
+// half-open (probing). CircuitBreakerStore is safe for concurrent use if the underlying store is.
+type CircuitBreakerStore struct {
+	store     Store
+	name      string
+	clock     Clock
+	logger    Logger
+	failures  int
+	openDelay time.Duration
+	gauge     Gauge
+	mutex     sync.Mutex
+	state     int
+	failed    int
+	openedAt  time.Time
+}
+
+// NewCircuitBreakerStore creates CircuitBreakerStore instances. The circuit opens after failures consecutive
+// failures, and is probed every openDelay while open. The name is used as the value of the "store" label.
+func NewCircuitBreakerStore(config *Config, store Store, name string, failures int, openDelay time.Duration) *CircuitBreakerStore {
+	s := &CircuitBreakerStore{
+		store,
+		name,
+		config.clock(),
+		WithFields(config.logger(), Field{"store", name}),
+		failures,
+		openDelay,
+		config.storeMetricsProvider().NewGauge("store_circuit_state",
+			"State of the store circuit breaker: 0 closed, 1 open, 2 half-open", "store"),
+		sync.Mutex{},
+		circuitClosed,
+		0,
+		time.Time{},
+	}
+	s.gauge.Set(circuitClosed, name)
+	return s
+}
+
+// IsOpen returns true if calls currently fail fast.
+func (s *CircuitBreakerStore) IsOpen() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state == circuitOpen && s.clock.Now().Sub(s.openedAt) < s.openDelay
+}
+
+func (s *CircuitBreakerStore) setState(state int) {
+	s.state = state
+	s.gauge.Set(float64(state), s.name)
+}
+
+func (s *CircuitBreakerStore) allow() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch s.state {
+	case circuitOpen:
+		if s.clock.Now().Sub(s.openedAt) < s.openDelay {
+			return ErrCircuitOpen
+		}
+		s.setState(circuitHalfOpen)
+		return nil
+	case circuitHalfOpen:
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+func (s *CircuitBreakerStore) done(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err == nil || !IsRetryable(err) {
+		if s.state != circuitClosed {
+			s.logger.Info("Store circuit breaker closed")
+		}
+		s.failed = 0
+		s.setState(circuitClosed)
+		return
+	}
+	s.failed++
+	if s.state == circuitHalfOpen || s.failed >= s.failures {
+		if s.state != circuitOpen {
+			s.logger.Errorf("Store circuit breaker open after %d failures: %s", s.failed, err)
+		}
+		s.openedAt = s.clock.Now()
+		s.setState(circuitOpen)
+	}
+}
+
+func (s *CircuitBreakerStore) call(fn func() error) error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	s.done(err)
+	return err
+}
+
+// Get gets a value by key from the underlying store.
+func (s *CircuitBreakerStore) Get(key string) (value []byte, err error) {
+	err = s.call(func() error {
+		value, err = s.store.Get(key)
+		return err
+	})
+	return value, err
+}
+
+// GetAll gets multiple values by key from the underlying store.
+func (s *CircuitBreakerStore) GetAll(keys []string) (kvs map[string][]byte, err error) {
+	err = s.call(func() error {
+		kvs, err = s.store.GetAll(keys)
+		return err
+	})
+	return kvs, err
+}
+
+// Put inserts or updates a value by key in the underlying store.
+func (s *CircuitBreakerStore) Put(key string, value []byte) error {
+	return s.call(func() error {
+		return s.store.Put(key, value)
+	})
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store.
+func (s *CircuitBreakerStore) PutAll(kvs map[string][]byte) error {
+	return s.call(func() error {
+		return s.store.PutAll(kvs)
+	})
+}
+
+// Delete deletes a key from the underlying store.
+func (s *CircuitBreakerStore) Delete(key string) error {
+	return s.call(func() error {
+		return s.store.Delete(key)
+	})
+}
+
+// Flush flushes the underlying store.
+func (s *CircuitBreakerStore) Flush() error {
+	return s.call(func() error {
+		return s.store.Flush()
+	})
+}
+
+// GetStore returns the underlying Store
+func (s *CircuitBreakerStore) GetStore() Store {
+	return s.store
+}
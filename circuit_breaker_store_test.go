@@ -0,0 +1,43 @@
+package kasper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerStore(t *testing.T) {
+	provider := newRecordingMetricsProvider()
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	config := &Config{TopicProcessorName: "hari-seldon", ContainerID: "container-1", MetricsProvider: provider, Clock: clock}
+	inner := &flakyStore{NewMap(10), 100, errors.New("connection refused"), 0}
+	store := NewCircuitBreakerStore(config, inner, "planets", 3, time.Minute)
+	state := "store_circuit_state{planets,,container-1,hari-seldon}"
+
+	for i := 0; i < 3; i++ {
+		assert.EqualError(t, store.Put("mars", mars), "connection refused")
+	}
+	assert.True(t, store.IsOpen())
+	assert.Equal(t, 1.0, provider.values[state])
+	_, err := store.Get("mars")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, 3, inner.calls)
+
+	// A failed probe opens the circuit again
+	clock.now = clock.now.Add(time.Minute)
+	assert.False(t, store.IsOpen())
+	_, err = store.Get("mars")
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, ErrCircuitOpen, store.Put("mars", mars))
+
+	// A successful probe closes the circuit
+	clock.now = clock.now.Add(time.Minute)
+	inner.failures = 0
+	assert.Nil(t, store.Put("mars", mars))
+	assert.False(t, store.IsOpen())
+	assert.Equal(t, 0.0, provider.values[state])
+	value, _ := store.Get("mars")
+	assert.Equal(t, mars, value)
+}
@@ -0,0 +1,211 @@
+package kasper
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CloudEventsSpecVersion is the version of the CloudEvents specification implemented by CloudEventSerde.
+const CloudEventsSpecVersion = "1.0"
+
+// CloudEvent is an event in the CloudEvents format (https://cloudevents.io), e.g. produced by Knative or
+// EventBridge. ID, Source and Type are required.
+type CloudEvent struct {
+	ID              string
+	Source          string
+	Type            string
+	DataContentType string
+	DataSchema      string
+	Subject         string
+	Time            time.Time
+	// Extension attributes, such as "traceparent" or "partitionkey"
+	Extensions map[string]interface{}
+	// Payload of the event, serialized by the data Serde of CloudEventSerde
+	Data interface{}
+}
+
+// Validate returns an error if a required attribute is missing or if an extension name is invalid.
+func (e *CloudEvent) Validate() error {
+	var missing []string
+	for _, attribute := range []struct{ name, value string }{{"id", e.ID}, {"source", e.Source}, {"type", e.Type}} {
+		if attribute.value == "" {
+			missing = append(missing, attribute.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid cloud event: %s is required", strings.Join(missing, ", "))
+	}
+	for name := range e.Extensions {
+		if !isCloudEventAttributeName(name) || cloudEventAttributes[name] {
+			return fmt.Errorf("invalid cloud event: %q is not a valid extension name", name)
+		}
+	}
+	return nil
+}
+
+var cloudEventAttributes = map[string]bool{
+	"specversion": true, "id": true, "source": true, "type": true, "datacontenttype": true, "dataschema": true,
+	"subject": true, "time": true, "data": true, "data_base64": true,
+}
+
+func isCloudEventAttributeName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isJSONContentType returns true if data of this content type is embedded as JSON in structured events.
+func isJSONContentType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	return mediaType == "" || mediaType == "application/json" || mediaType == "text/json" ||
+		strings.HasSuffix(mediaType, "+json")
+}
+
+// CloudEventSerde is a Serde for *CloudEvent values in the structured JSON content mode of CloudEvents, where the
+// attributes and the data are encoded together in the message value. The data is serialized by another Serde and
+// embedded as JSON if DataContentType is a JSON media type (or empty), or base64-encoded in data_base64 otherwise.
+//
+// The binary content mode, where the attributes are Kafka record headers, is not supported because a Serde only
+// encodes the message value.
+type CloudEventSerde struct {
+	data Serde
+}
+
+// NewCloudEventSerde creates a CloudEventSerde which serializes the data of events with data, e.g. a JSONSerde.
+func NewCloudEventSerde(data Serde) *CloudEventSerde {
+	return &CloudEventSerde{data}
+}
+
+// Serialize encodes a *CloudEvent.
+func (serde *CloudEventSerde) Serialize(value interface{}) ([]byte, error) {
+	event, ok := value.(*CloudEvent)
+	if !ok {
+		return nil, fmt.Errorf("cannot serialize %T as a cloud event", value)
+	}
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]interface{}, len(event.Extensions)+8)
+	for name, value := range event.Extensions {
+		attributes[name] = value
+	}
+	attributes["specversion"] = CloudEventsSpecVersion
+	attributes["id"] = event.ID
+	attributes["source"] = event.Source
+	attributes["type"] = event.Type
+	for name, value := range map[string]string{
+		"datacontenttype": event.DataContentType,
+		"dataschema":      event.DataSchema,
+		"subject":         event.Subject,
+	} {
+		if value != "" {
+			attributes[name] = value
+		}
+	}
+	if !event.Time.IsZero() {
+		attributes["time"] = event.Time.Format(time.RFC3339Nano)
+	}
+	if event.Data != nil {
+		data, err := serde.data.Serialize(event.Data)
+		if err != nil {
+			return nil, err
+		}
+		if isJSONContentType(event.DataContentType) {
+			attributes["data"] = json.RawMessage(data)
+		} else {
+			attributes["data_base64"] = base64.StdEncoding.EncodeToString(data)
+		}
+	}
+	return json.Marshal(attributes)
+}
+
+// Deserialize decodes a *CloudEvent. Extension attributes are decoded as by encoding/json into interface{} values.
+func (serde *CloudEventSerde) Deserialize(data []byte) (interface{}, error) {
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return nil, fmt.Errorf("invalid cloud event: %s", err)
+	}
+	var specVersion string
+	event := &CloudEvent{}
+	for name, target := range map[string]*string{
+		"specversion":     &specVersion,
+		"id":              &event.ID,
+		"source":          &event.Source,
+		"type":            &event.Type,
+		"datacontenttype": &event.DataContentType,
+		"dataschema":      &event.DataSchema,
+		"subject":         &event.Subject,
+	} {
+		if raw, found := attributes[name]; found {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return nil, fmt.Errorf("invalid cloud event: %s is not a string", name)
+			}
+		}
+	}
+	if specVersion != CloudEventsSpecVersion {
+		return nil, fmt.Errorf("invalid cloud event: unsupported specversion %q", specVersion)
+	}
+	if raw, found := attributes["time"]; found {
+		var timestamp string
+		if err := json.Unmarshal(raw, &timestamp); err != nil {
+			return nil, fmt.Errorf("invalid cloud event: time is not a string")
+		}
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cloud event: %s", err)
+		}
+		event.Time = t
+	}
+	var payload []byte
+	if raw, found := attributes["data_base64"]; found {
+		var encoded string
+		if err := json.Unmarshal(raw, &encoded); err != nil {
+			return nil, fmt.Errorf("invalid cloud event: data_base64 is not a string")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cloud event: %s", err)
+		}
+		payload = decoded
+	} else if raw, found := attributes["data"]; found && string(raw) != "null" {
+		payload = raw
+		// Data which is not JSON, such as text/plain, is encoded as a JSON string
+		var text string
+		if !isJSONContentType(event.DataContentType) && json.Unmarshal(raw, &text) == nil {
+			payload = []byte(text)
+		}
+	}
+	if payload != nil {
+		value, err := serde.data.Deserialize(payload)
+		if err != nil {
+			return nil, err
+		}
+		event.Data = value
+	}
+	for name, raw := range attributes {
+		if cloudEventAttributes[name] {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("invalid cloud event: %s", err)
+		}
+		if event.Extensions == nil {
+			event.Extensions = make(map[string]interface{})
+		}
+		event.Extensions[name] = value
+	}
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
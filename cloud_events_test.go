@@ -0,0 +1,77 @@
+package kasper
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stringSerde struct{}
+
+func (stringSerde) Serialize(value interface{}) ([]byte, error)  { return []byte(value.(string)), nil }
+func (stringSerde) Deserialize(data []byte) (interface{}, error) { return string(data), nil }
+
+func TestCloudEventSerde(t *testing.T) {
+	serde := NewCloudEventSerde(NewJSONSerde(func() interface{} { return &serdeTestPlanet{} }))
+	event := &CloudEvent{
+		ID:         "42",
+		Source:     "/planets",
+		Type:       "planet.discovered",
+		Subject:    "mars",
+		Time:       time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC),
+		Extensions: map[string]interface{}{"partitionkey": "mars"},
+		Data:       &serdeTestPlanet{"mars", 2},
+	}
+	data, err := serde.Serialize(event)
+	assert.Nil(t, err)
+	var envelope map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &envelope))
+	assert.Equal(t, map[string]interface{}{
+		"specversion":  "1.0",
+		"id":           "42",
+		"source":       "/planets",
+		"type":         "planet.discovered",
+		"subject":      "mars",
+		"time":         "2017-04-01T12:00:00Z",
+		"partitionkey": "mars",
+		"data":         map[string]interface{}{"name": "mars", "moons": 2.0},
+	}, envelope)
+
+	value, err := serde.Deserialize(data)
+	assert.Nil(t, err)
+	assert.Equal(t, event, value)
+}
+
+func TestCloudEventSerde_NonJSONData(t *testing.T) {
+	serde := NewCloudEventSerde(stringSerde{})
+	event := &CloudEvent{ID: "1", Source: "/planets", Type: "planet.named", DataContentType: "text/plain", Data: "mars"}
+	data, err := serde.Serialize(event)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"data_base64":"bWFycw=="`)
+	value, err := serde.Deserialize(data)
+	assert.Nil(t, err)
+	assert.Equal(t, event, value)
+
+	value, err = serde.Deserialize([]byte(`{"specversion":"1.0","id":"1","source":"/planets","type":"planet.named","datacontenttype":"text/plain","data":"venus"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "venus", value.(*CloudEvent).Data)
+}
+
+func TestCloudEventSerde_Invalid(t *testing.T) {
+	serde := NewCloudEventSerde(stringSerde{})
+	_, err := serde.Serialize(&CloudEvent{Source: "/planets"})
+	assert.EqualError(t, err, "invalid cloud event: id, type is required")
+	_, err = serde.Serialize(&CloudEvent{ID: "1", Source: "/planets", Type: "planet", Extensions: map[string]interface{}{"Moons": 2}})
+	assert.EqualError(t, err, `invalid cloud event: "Moons" is not a valid extension name`)
+	_, err = serde.Serialize("mars")
+	assert.EqualError(t, err, "cannot serialize string as a cloud event")
+
+	_, err = serde.Deserialize([]byte(`{"specversion":"0.3","id":"1","source":"/planets","type":"planet"}`))
+	assert.EqualError(t, err, `invalid cloud event: unsupported specversion "0.3"`)
+	_, err = serde.Deserialize([]byte(`{"specversion":"1.0","id":1,"source":"/planets","type":"planet"}`))
+	assert.EqualError(t, err, "invalid cloud event: id is not a string")
+	_, err = serde.Deserialize([]byte(`{"specversion":"1.0","source":"/planets","type":"planet"}`))
+	assert.EqualError(t, err, "invalid cloud event: id is required")
+}
@@ -0,0 +1,49 @@
+// Command kasper-copy-offsets copies the committed offsets of a topic processor to another topic processor name,
+// to rename a processor or deploy a rewrite side by side without reprocessing its input topics. Stop both
+// topic processors first.
+//
+//	kasper-copy-offsets -brokers kafka:9092 -from twitter-reach -to twitter-reach-v2 -topics tweets,twitter-followers
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+)
+
+func main() {
+	brokers := flag.String("brokers", "localhost:9092", "Comma-separated list of Kafka brokers")
+	from := flag.String("from", "", "TopicProcessorName to copy the offsets from")
+	to := flag.String("to", "", "TopicProcessorName to copy the offsets to")
+	topics := flag.String("topics", "", "Comma-separated list of input topics")
+	flag.Parse()
+
+	if *from == "" || *to == "" || *topics == "" {
+		log.Fatal("-from, -to and -topics are required")
+	}
+	client, err := sarama.NewClient(strings.Split(*brokers, ","), sarama.NewConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	copied, err := kasper.CopyOffsets(client, *from, *to, strings.Split(*topics, ","))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, topic := range strings.Split(*topics, ",") {
+		partitions := make([]int, 0, len(copied[topic]))
+		for partition := range copied[topic] {
+			partitions = append(partitions, partition)
+		}
+		sort.Ints(partitions)
+		for _, partition := range partitions {
+			fmt.Printf("%s-%d: %d\n", topic, partition, copied[topic][partition])
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Command kasper-monitor exports the lag of a topic processor's consumer group, and the size and health of its
+// stores, to Prometheus without processing any messages, see kasper.Monitor. It can be deployed next to existing
+// topic processors that do not export metrics themselves.
+//
+//	kasper-monitor -brokers kafka:9092 -name twitter-reach -topics tweets,twitter-followers -redis redis://redis:6379 -listen :9100
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/garyburd/redigo/redis"
+	"github.com/movio/kasper"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+func main() {
+	brokers := flag.String("brokers", "localhost:9092", "Comma-separated list of Kafka brokers")
+	name := flag.String("name", "", "TopicProcessorName of the observed topic processor")
+	topics := flag.String("topics", "", "Comma-separated list of input topics")
+	partitions := flag.String("partitions", "", "Comma-separated list of input partitions (defaults to all partitions)")
+	redisURL := flag.String("redis", "", "Redis URL of a store to observe, e.g. redis://localhost:6379")
+	redisPrefix := flag.String("redis-prefix", "kasper", "Key prefix of the Redis store")
+	elasticsearchURL := flag.String("elasticsearch", "", "Elasticsearch URL of a store to observe, e.g. http://localhost:9200")
+	elasticsearchIndex := flag.String("elasticsearch-index", "kasper", "Index of the Elasticsearch store")
+	elasticsearchType := flag.String("elasticsearch-type", "kasper", "Type of the Elasticsearch store")
+	interval := flag.Duration("interval", 15*time.Second, "Metrics update interval")
+	listen := flag.String("listen", ":9100", "Address serving the metrics on /metrics")
+	flag.Parse()
+
+	if *name == "" || *topics == "" {
+		log.Fatal("-name and -topics are required")
+	}
+	client, err := sarama.NewClient(strings.Split(*brokers, ","), sarama.NewConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+	prometheus := kasper.NewPrometheus(*name)
+	config := &kasper.Config{
+		TopicProcessorName:    *name,
+		Client:                client,
+		InputTopics:           strings.Split(*topics, ","),
+		InputPartitions:       mustParsePartitions(*partitions),
+		Logger:                kasper.NewBasicLogger(false),
+		MetricsProvider:       prometheus,
+		MetricsUpdateInterval: *interval,
+	}
+	if *redisURL != "" {
+		conn, err := redis.DialURL(*redisURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer conn.Close()
+		config.Stores = append(config.Stores, kasper.NamedStore{Name: "redis", Store: kasper.NewRedis(config, conn, *redisPrefix)})
+	}
+	if *elasticsearchURL != "" {
+		elasticClient, err := elastic.NewClient(elastic.SetURL(*elasticsearchURL), elastic.SetSniff(false))
+		if err != nil {
+			log.Fatal(err)
+		}
+		store := kasper.NewElasticsearch(config, elasticClient, *elasticsearchIndex, *elasticsearchType)
+		config.Stores = append(config.Stores, kasper.NamedStore{Name: "elasticsearch", Store: store})
+	}
+
+	monitor, err := kasper.NewMonitor(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/metrics", prometheus)
+	go func() {
+		log.Fatal(http.ListenAndServe(*listen, nil))
+	}()
+	if err := monitor.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func mustParsePartitions(value string) []int {
+	if value == "" {
+		return nil
+	}
+	var partitions []int
+	for _, item := range strings.Split(value, ",") {
+		partition, err := strconv.Atoi(item)
+		if err != nil {
+			log.Fatalf("Invalid partition %q", item)
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions
+}
@@ -0,0 +1,98 @@
+// Command kasper-replay saves a slice of a Kafka topic partition to a file, to be replayed offline through a
+// MessageProcessor with replay.ReadMessages and replay.Run.
+//
+//	kasper-replay -brokers kafka:9092 -topic tweets -partition 3 -start-time 2017-04-01T10:00:00Z -end-time 2017-04-01T10:05:00Z -output incident.jsonl
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper/replay"
+)
+
+func main() {
+	brokers := flag.String("brokers", "localhost:9092", "Comma-separated list of Kafka brokers")
+	topic := flag.String("topic", "", "Topic to read")
+	partition := flag.Int("partition", 0, "Partition to read")
+	startOffset := flag.Int64("start-offset", 0, "First offset to read (defaults to the oldest offset)")
+	endOffset := flag.Int64("end-offset", 0, "Offset at which to stop, exclusive (defaults to the high water mark)")
+	startTime := flag.String("start-time", "", "Read from the first message at or after this RFC 3339 time")
+	endTime := flag.String("end-time", "", "Stop at the first message after this RFC 3339 time")
+	timeout := flag.Duration("timeout", 30*time.Second, "Maximum time to wait for a single message")
+	output := flag.String("output", "", "Output file (defaults to stdout)")
+	kafkaVersion := flag.String("kafka-version", "0.10.0.0", "Kafka protocol version (0.10.0.0 or later, message timestamps are required by -end-time)")
+	flag.Parse()
+
+	if *topic == "" {
+		log.Fatal("-topic is required")
+	}
+	r := replay.Range{
+		Topic:       *topic,
+		Partition:   *partition,
+		StartOffset: *startOffset,
+		EndOffset:   *endOffset,
+		StartTime:   mustParseTime(*startTime),
+		EndTime:     mustParseTime(*endTime),
+	}
+
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = mustParseKafkaVersion(*kafkaVersion)
+	client, err := sarama.NewClient(strings.Split(*brokers, ","), saramaConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	messages, err := replay.Fetch(client, r, *timeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		w = file
+	}
+	err = replay.WriteMessages(w, messages)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(messages) > 0 {
+		fmt.Fprintf(os.Stderr, "Saved %d messages (offsets %d to %d)\n", len(messages), messages[0].Offset, messages[len(messages)-1].Offset)
+	} else {
+		fmt.Fprintln(os.Stderr, "No messages in range")
+	}
+}
+
+func mustParseTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return t
+}
+
+// mustParseKafkaVersion accepts the versions supported by sarama that include message timestamps
+func mustParseKafkaVersion(value string) sarama.KafkaVersion {
+	for _, version := range sarama.SupportedVersions {
+		if version.String() == value && version.IsAtLeast(sarama.V0_10_0_0) {
+			return version
+		}
+	}
+	log.Fatalf("Unsupported Kafka version %s (expected 0.10.0.0 to %s)", value, sarama.MaxVersion)
+	return sarama.KafkaVersion{}
+}
@@ -0,0 +1,100 @@
+// Command kasper-smoketest checks that the Kafka brokers, input topics and stores of a Kasper application are
+// reachable, prints a readiness report and exits with status 1 if the application is not ready.
+//
+//	kasper-smoketest -brokers kafka:9092 -topics tweets,users -partitions 0,1,2 -json-topics tweets -redis redis://redis:6379
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/garyburd/redigo/redis"
+	"github.com/movio/kasper"
+	"github.com/movio/kasper/smoketest"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+func main() {
+	brokers := flag.String("brokers", "localhost:9092", "Comma-separated list of Kafka brokers")
+	topics := flag.String("topics", "", "Comma-separated list of input topics")
+	partitions := flag.String("partitions", "0", "Comma-separated list of input partitions")
+	jsonTopics := flag.String("json-topics", "", "Comma-separated list of topics whose messages must be valid JSON")
+	sampleSize := flag.Int("sample-size", 10, "Number of messages sampled per partition")
+	redisURL := flag.String("redis", "", "Redis URL to check, e.g. redis://localhost:6379")
+	elasticsearchURL := flag.String("elasticsearch", "", "Elasticsearch URL to check, e.g. http://localhost:9200")
+	elasticsearchIndex := flag.String("elasticsearch-index", "kasper", "Elasticsearch index to check")
+	timeout := flag.Duration("timeout", 10*time.Second, "Maximum time spent sampling each partition")
+	flag.Parse()
+
+	client, err := sarama.NewClient(strings.Split(*brokers, ","), sarama.NewConfig())
+	if err != nil {
+		fmt.Printf("[FAIL] brokers: %s\nNOT READY\n", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+	config := &kasper.Config{
+		TopicProcessorName: "smoke-test",
+		Client:             client,
+		InputTopics:        splitList(*topics),
+		InputPartitions:    mustParsePartitions(*partitions),
+		Logger:             kasper.NewBasicLogger(false),
+	}
+
+	opts := smoketest.Options{
+		Serdes:     make(map[string]kasper.Serde),
+		SampleSize: *sampleSize,
+		Stores:     make(map[string]kasper.Store),
+		Timeout:    *timeout,
+	}
+	for _, topic := range splitList(*jsonTopics) {
+		opts.Serdes[topic] = kasper.NewJSONSerde(func() interface{} { return new(interface{}) })
+	}
+	if *redisURL != "" {
+		conn, err := redis.DialURL(*redisURL)
+		if err != nil {
+			fmt.Printf("[FAIL] store redis: %s\nNOT READY\n", err)
+			os.Exit(1)
+		}
+		defer conn.Close()
+		opts.Stores["redis"] = kasper.NewRedis(config, conn, "kasper")
+	}
+	if *elasticsearchURL != "" {
+		elasticClient, err := elastic.NewClient(elastic.SetURL(*elasticsearchURL), elastic.SetSniff(false))
+		if err != nil {
+			fmt.Printf("[FAIL] store elasticsearch: %s\nNOT READY\n", err)
+			os.Exit(1)
+		}
+		opts.Stores["elasticsearch"] = kasper.NewElasticsearch(config, elasticClient, *elasticsearchIndex, "kasper")
+	}
+
+	report := smoketest.Run(config, opts)
+	fmt.Print(report)
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func mustParsePartitions(value string) []int {
+	var partitions []int
+	for _, item := range splitList(value) {
+		partition, err := strconv.Atoi(item)
+		if err != nil {
+			log.Fatalf("Invalid partition %q", item)
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions
+}
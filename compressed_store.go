@@ -0,0 +1,162 @@
+package kasper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+)
+
+// CompressionCodec compresses the values of a CompressedStore.
+type CompressionCodec interface {
+	// ID identifies the codec in the header of compressed values. It must not change once values are stored.
+	ID() byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCodec is a CompressionCodec using compress/gzip, with ID 1.
+type GzipCodec struct {
+	// Compression level, see compress/gzip (0 means gzip.DefaultCompression)
+	Level int
+}
+
+// ID returns 1.
+func (GzipCodec) ID() byte {
+	return 1
+}
+
+// Compress compresses data with gzip.
+func (c GzipCodec) Compress(data []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Decompress decompresses gzip data.
+func (GzipCodec) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// compressionMagic starts the header of compressed values, followed by the codec ID.
+var compressionMagic = []byte{0, 'K', 'Z'}
+
+// CompressedStore wraps a Store and compresses values on writes and decompresses them on reads.
+// Compressed values start with a 4-byte header made of a magic number and the codec ID, while values without the
+// header are returned unchanged, so a store can be migrated to compression gradually. Values compressed with a
+// different codec than the configured one cannot be read. To compress Elasticsearch documents, which must stay
+// JSON, set the index.codec setting of the index to best_compression instead.
+type CompressedStore struct {
+	store Store
+	codec CompressionCodec
+}
+
+// NewCompressedStore creates CompressedStore instances.
+func NewCompressedStore(store Store, codec CompressionCodec) *CompressedStore {
+	return &CompressedStore{store, codec}
+}
+
+func (s *CompressedStore) compress(value []byte) ([]byte, error) {
+	compressed, err := s.codec.Compress(value)
+	if err != nil {
+		return nil, err
+	}
+	header := len(compressionMagic) + 1
+	encoded := make([]byte, header+len(compressed))
+	copy(encoded, compressionMagic)
+	encoded[len(compressionMagic)] = s.codec.ID()
+	copy(encoded[header:], compressed)
+	return encoded, nil
+}
+
+func (s *CompressedStore) decompress(key string, value []byte) ([]byte, error) {
+	header := len(compressionMagic) + 1
+	if len(value) < header || !bytes.Equal(value[:len(compressionMagic)], compressionMagic) {
+		return value, nil
+	}
+	if id := value[len(compressionMagic)]; id != s.codec.ID() {
+		return nil, fmt.Errorf("value of %s is compressed with unknown codec %d", key, id)
+	}
+	decompressed, err := s.codec.Decompress(value[header:])
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress value of %s: %s", key, err)
+	}
+	return decompressed, nil
+}
+
+// Get gets a value by key from the underlying store and decompresses it.
+func (s *CompressedStore) Get(key string) ([]byte, error) {
+	value, err := s.store.Get(key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return s.decompress(key, value)
+}
+
+// GetAll gets multiple values by key from the underlying store and decompresses them.
+func (s *CompressedStore) GetAll(keys []string) (map[string][]byte, error) {
+	kvs, err := s.store.GetAll(keys)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range kvs {
+		if kvs[key], err = s.decompress(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return kvs, nil
+}
+
+// Put compresses a value and inserts or updates it in the underlying store.
+func (s *CompressedStore) Put(key string, value []byte) error {
+	compressed, err := s.compress(value)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(key, compressed)
+}
+
+// PutAll compresses multiple values and inserts or updates them in the underlying store.
+func (s *CompressedStore) PutAll(kvs map[string][]byte) error {
+	compressed := make(map[string][]byte, len(kvs))
+	for key, value := range kvs {
+		var err error
+		if compressed[key], err = s.compress(value); err != nil {
+			return err
+		}
+	}
+	return s.store.PutAll(compressed)
+}
+
+// Delete deletes a key from the underlying store.
+func (s *CompressedStore) Delete(key string) error {
+	return s.store.Delete(key)
+}
+
+// Flush flushes the underlying store.
+func (s *CompressedStore) Flush() error {
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *CompressedStore) GetStore() Store {
+	return s.store
+}
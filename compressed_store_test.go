@@ -0,0 +1,38 @@
+package kasper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCodec struct{}
+
+func (fakeCodec) ID() byte                               { return 9 }
+func (fakeCodec) Compress(data []byte) ([]byte, error)   { return data, nil }
+func (fakeCodec) Decompress(data []byte) ([]byte, error) { return data, nil }
+
+func TestCompressedStore(t *testing.T) {
+	s := NewMap(10)
+	store := NewCompressedStore(s, GzipCodec{})
+	large := bytes.Repeat(jupiter, 100)
+
+	assert.Nil(t, store.Put("jupiter", large))
+	assert.Nil(t, store.PutAll(map[string][]byte{"saturn": saturn}))
+	compressed, _ := s.Get("jupiter")
+	assert.Equal(t, []byte{0, 'K', 'Z', 1}, compressed[:4])
+	assert.True(t, len(compressed) < len(large))
+
+	// Values written before compression was enabled are returned unchanged
+	s.Put("uranus", uranus)
+	kvs, err := store.GetAll([]string{"jupiter", "saturn", "uranus", "neptune"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"jupiter": large, "saturn": saturn, "uranus": uranus}, kvs)
+
+	_, err = NewCompressedStore(s, fakeCodec{}).Get("saturn")
+	assert.EqualError(t, err, "value of saturn is compressed with unknown codec 1")
+	s.Put("neptune", []byte{0, 'K', 'Z', 1, 'x'})
+	_, err = store.Get("neptune")
+	assert.EqualError(t, err, "cannot decompress value of neptune: unexpected EOF")
+}
@@ -3,9 +3,14 @@ package kasper
 import (
 	"fmt"
 	"github.com/Shopify/sarama"
+	"os"
+	"reflect"
 	"time"
 )
 
+// defaultBatchSize is the BatchSize used when Config.BatchSize is 0.
+const defaultBatchSize = 1000
+
 // Config contains the configuration settings for a TopicProcessor.
 type Config struct {
 	// Used for logging, metrics, and Kafka consumer group
@@ -16,16 +21,89 @@ type Config struct {
 	InputTopics []string
 	// Input partitions (cannot overlap between TopicProcessor instances)
 	InputPartitions []int
+	// Topics the MessageProcessors produce to (optional, only used by Validate)
+	OutputTopics []string
+	// Serdes used by the application by topic (optional, only used by Validate)
+	Serdes map[string]Serde
 	// Maximum number of messages processed in one go
 	BatchSize int
 	// Maximum amount of time spent waiting for a batch to be filled
 	BatchWaitDuration time.Duration
+	// When set, the batch size is adjusted after each full batch so that processing a batch (including the
+	// store operations and producing) takes about this long, between MinBatchSize and BatchSize
+	TargetBatchLatency time.Duration
+	// Smallest batch size used when TargetBatchLatency is set, defaults to 1
+	MinBatchSize int
 	// Use NewBasicLogger() or any other Logger
 	Logger Logger
-	// Use NewPrometheus() or any other MetricsProvider
+	// Use NewPrometheus(), NewInfluxDB() or any other MetricsProvider
 	MetricsProvider MetricsProvider
 	// 15 seconds is a sensible value
 	MetricsUpdateInterval time.Duration
+	// Identifies the container (or process) running this TopicProcessor in metrics, defaults to the hostname
+	ContainerID string
+	// Static labels attached to all metrics (e.g. {"environment": "production"}). Labels used by Kasper's own
+	// metrics (topicProcessor, containerID, topic, partition, store...) are reserved and ignored.
+	MetricsLabels map[string]string
+	// OnSlowConsumer is called when fewer than this many messages per second are received while messages remain
+	// to be consumed (0 disables the check)
+	SlowConsumerRateThreshold float64
+	// OnSlowConsumer is called when the number of messages behind the high water mark grows for this many
+	// consecutive metrics update intervals (0 disables the check)
+	SlowConsumerLagIntervals int
+	// Called from the processing loop on every metrics update interval for which the consumer is detected as slow
+	OnSlowConsumer func(SlowConsumerEvent)
+	// Source of time for batching, metrics and runtime statistics, defaults to the system clock
+	Clock Clock
+	// Called with errors that cannot be returned to the caller, such as NewTopicProcessor failing to connect to
+	// Kafka, to decide how the application should exit (e.g. flushing logs and calling os.Exit).
+	// It must not return: if it does, Kasper panics through Logger.Panic, which is also the default.
+	OnFatalError func(error)
+	// Record header of incoming messages holding the trace ID attached to the loggers returned by MessageLogger
+	// (Kafka 0.11 or later), defaults to "traceparent". The trace ID is extracted from W3C traceparent values.
+	TraceIDHeader string
+	// Extracts a trace ID from incoming messages instead of TraceIDHeader, e.g. from their key or value
+	MessageTraceID func(*sarama.ConsumerMessage) string
+	// Records the stages of processing each batch as spans (tracing is disabled by default)
+	Tracer Tracer
+	// Logs the key and value of one in every PayloadSampleRate incoming messages at debug level (0 disables sampling)
+	PayloadSampleRate int
+	// Sampled keys and values are truncated to this many bytes, defaults to 1024
+	PayloadSampleMaxBytes int
+	// Fields of sampled JSON payloads whose values are replaced with "[REDACTED]", at any depth. When set, sampled
+	// keys and values that are not valid JSON are not logged.
+	PayloadRedactedFields []string
+	// Identical errors logged by Kasper are logged at most once per interval (0 disables throttling),
+	// see NewThrottledLogger
+	ErrorLogThrottleInterval time.Duration
+	// Consumes and processes messages without producing the messages passed to Sender, which are counted and
+	// logged at debug level instead, e.g. to validate a new version of a processor against live traffic under
+	// another TopicProcessorName. Offsets are committed. Wrap stores with NewDryRunStore to suppress their writes.
+	DryRun bool
+	// Stores used by the MessageProcessors, which can retrieve them by name with Config.Store. They are flushed
+	// in this order after each batch is processed and its messages produced, before the offsets are committed.
+	Stores []NamedStore
+	// Stores the committed offsets instead of the Kafka consumer group, e.g. a store writing to the same database
+	// as the state of the MessageProcessors. The offsets of each batch are written before Config.Stores are flushed,
+	// so if OffsetStore is one of Config.Stores, or commits the same transaction, the state and the offsets are
+	// committed atomically and messages are processed effectively once.
+	OffsetStore Store
+	// Compacted topic the Progress of each partition is published to after its batches are processed
+	// (disabled if empty)
+	ProgressTopic string
+	// The progress of a partition is published at most once per interval, defaults to after every batch
+	ProgressInterval time.Duration
+	// Pool of the buffers passed to BufferSender.SendBuffer, which are returned to it once their messages have been
+	// produced. Buffers are not reused if nil.
+	BufferPool *BufferPool
+	// Adds headers describing the batch that produced them to the messages passed to Sender (Kafka 0.11 or later),
+	// for lineage tracking across chained TopicProcessors, see ProvenanceHeaderProcessor
+	ProvenanceHeaders bool
+
+	labeledMetricsProvider *labeledMetricsProvider
+	throttledLogger        *throttledLogger
+	runtimeStats           *runtimeStats
+	activePartition        int32
 }
 
 func (config *Config) kafkaConsumerGroup() string {
@@ -38,7 +116,7 @@ func (config *Config) producerClientID() string {
 
 func (config *Config) setDefaults() {
 	if config.BatchSize == 0 {
-		config.BatchSize = 1000
+		config.BatchSize = defaultBatchSize
 	}
 	if config.BatchWaitDuration == 0 {
 		config.BatchWaitDuration = 5 * time.Second
@@ -49,6 +127,12 @@ func (config *Config) setDefaults() {
 	if config.MetricsProvider == nil {
 		config.MetricsProvider = &NoopMetricsProvider{}
 	}
+	if config.ContainerID == "" {
+		config.ContainerID = defaultContainerID()
+	}
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
 	if config.MetricsUpdateInterval == 0 {
 		config.MetricsUpdateInterval = 15 * time.Second
 	}
@@ -57,3 +141,300 @@ func (config *Config) setDefaults() {
 		config.Client.Config().Producer.Return.Successes = true
 	}
 }
+
+// metricsProvider returns Config.MetricsProvider wrapped so that all metrics carry the topicProcessor and
+// containerID labels, as well as the labels defined in Config.MetricsLabels.
+func (config *Config) metricsProvider() MetricsProvider {
+	if config.MetricsProvider == nil {
+		config.MetricsProvider = &NoopMetricsProvider{}
+	}
+	if config.ContainerID == "" {
+		config.ContainerID = defaultContainerID()
+	}
+	if config.labeledMetricsProvider == nil || changed(config.labeledMetricsProvider.provider, config.MetricsProvider) {
+		for _, problem := range validateMetricsLabels(config.MetricsLabels) {
+			config.logger().Errorf("Ignoring Config.MetricsLabels: %s", problem)
+		}
+		labels := make(map[string]string, len(config.MetricsLabels)+2)
+		for name, value := range config.MetricsLabels {
+			labels[name] = value
+		}
+		for _, name := range reservedMetricsLabels {
+			delete(labels, name)
+		}
+		labels["topicProcessor"] = config.TopicProcessorName
+		labels["containerID"] = config.ContainerID
+		config.labeledMetricsProvider = newLabeledMetricsProvider(config.MetricsProvider, labels)
+	}
+	return config.labeledMetricsProvider
+}
+
+// storeMetricsProvider is like metricsProvider, but the metrics also carry the partition being processed.
+func (config *Config) storeMetricsProvider() MetricsProvider {
+	return &partitionMetricsProvider{config.metricsProvider(), config}
+}
+
+// changed compares a cached interface value with the current one. Values of uncomparable types (which would make
+// == panic) are only compared by type.
+func changed(cached interface{}, current interface{}) bool {
+	if reflect.TypeOf(cached) != reflect.TypeOf(current) {
+		return true
+	}
+	if current == nil || !reflect.TypeOf(current).Comparable() {
+		return false
+	}
+	return cached != current
+}
+
+// logger returns Config.Logger, defaulting to NewBasicLogger and wrapped with NewThrottledLogger
+// if Config.ErrorLogThrottleInterval is set. Kasper components should use it instead of Config.Logger.
+func (config *Config) logger() Logger {
+	if config.Logger == nil {
+		config.Logger = NewBasicLogger(false)
+	}
+	if config.ErrorLogThrottleInterval <= 0 {
+		return config.Logger
+	}
+	if config.throttledLogger == nil || changed(config.throttledLogger.logger, config.Logger) {
+		config.throttledLogger = NewThrottledLogger(config.Logger, config.ErrorLogThrottleInterval, config.clock()).(*throttledLogger)
+	}
+	return config.throttledLogger
+}
+
+// messageTraceID returns Config.MessageTraceID, defaulting to reading the trace ID from Config.TraceIDHeader.
+func (config *Config) messageTraceID() func(*sarama.ConsumerMessage) string {
+	if config.MessageTraceID != nil {
+		return config.MessageTraceID
+	}
+	header := config.TraceIDHeader
+	if header == "" {
+		header = "traceparent"
+	}
+	return func(message *sarama.ConsumerMessage) string {
+		return headerTraceID(message, header)
+	}
+}
+
+func (config *Config) tracer() Tracer {
+	if config.Tracer == nil {
+		return noopTracer{}
+	}
+	return config.Tracer
+}
+
+// NamedStore is a Store registered in Config.Stores.
+type NamedStore struct {
+	Name  string
+	Store Store
+}
+
+// Store returns the store registered in Config.Stores under name, or nil.
+func (config *Config) Store(name string) Store {
+	for _, store := range config.Stores {
+		if store.Name == name {
+			return store.Store
+		}
+	}
+	return nil
+}
+
+// flushStores flushes Config.Stores in order and returns the first error.
+func (config *Config) flushStores() error {
+	for _, store := range config.Stores {
+		if err := store.Store.Flush(); err != nil {
+			return fmt.Errorf("cannot flush store %s: %s", store.Name, err)
+		}
+	}
+	return nil
+}
+
+// flushOffsetStore flushes Config.OffsetStore, unless it is one of Config.Stores.
+func (config *Config) flushOffsetStore() error {
+	if config.OffsetStore == nil {
+		return nil
+	}
+	for _, store := range config.Stores {
+		if store.Store == config.OffsetStore {
+			return nil
+		}
+	}
+	if err := config.OffsetStore.Flush(); err != nil {
+		return fmt.Errorf("cannot flush offset store: %s", err)
+	}
+	return nil
+}
+
+// offsetManager returns the offset manager of Config.OffsetStore, or of the Kafka consumer group.
+func (config *Config) offsetManager() (sarama.OffsetManager, error) {
+	if config.OffsetStore != nil {
+		initial := config.Client.Config().Consumer.Offsets.Initial
+		return newStoreOffsetManager(config.OffsetStore, config.kafkaConsumerGroup(), initial), nil
+	}
+	return sarama.NewOffsetManagerFromClient(config.kafkaConsumerGroup(), config.Client)
+}
+
+// Validate checks the configuration before creating a TopicProcessor and reports all problems at once in a
+// ConfigError. It checks that the settings and intervals are sane, that the brokers are reachable,
+// that the input, output and progress topics exist, that all input topics have the same number of partitions and
+// contain all input partitions, and that Serdes (if set) has an entry for every input and output topic.
+func (config *Config) Validate() error {
+	problems := validateProcessing(config.TopicProcessorName, config.InputTopics, config.InputPartitions,
+		config.BatchSize, config.BatchWaitDuration, config.MetricsUpdateInterval, config.MetricsLabels)
+	if config.SlowConsumerRateThreshold < 0 || config.SlowConsumerLagIntervals < 0 {
+		problems = append(problems, "slow consumer thresholds cannot be negative")
+	}
+	if config.PayloadSampleRate < 0 {
+		problems = append(problems, "PayloadSampleRate cannot be negative")
+	}
+	problems = append(problems, validateBatchSizes(config.BatchSize, config.MinBatchSize, config.TargetBatchLatency)...)
+	storeNames := make(map[string]bool, len(config.Stores))
+	for _, store := range config.Stores {
+		if store.Name == "" || store.Store == nil {
+			problems = append(problems, "stores must have a name and a Store")
+		} else if storeNames[store.Name] {
+			problems = append(problems, fmt.Sprintf("store %s is registered more than once", store.Name))
+		}
+		storeNames[store.Name] = true
+	}
+	if config.Serdes != nil {
+		for _, topic := range append(append([]string{}, config.InputTopics...), config.OutputTopics...) {
+			if _, found := config.Serdes[topic]; !found {
+				problems = append(problems, fmt.Sprintf("no serde for topic %s", topic))
+			}
+		}
+	}
+	if config.Client == nil {
+		problems = append(problems, "Client is required")
+	} else {
+		problems = append(problems, config.validateTopics()...)
+	}
+	if len(problems) > 0 {
+		return &ConfigError{problems}
+	}
+	return nil
+}
+
+// validateProcessing checks the settings shared by Config and Settings, so that both are validated with the same
+// rules.
+func validateProcessing(topicProcessorName string, inputTopics []string, inputPartitions []int, batchSize int,
+	batchWaitDuration time.Duration, metricsUpdateInterval time.Duration, metricsLabels map[string]string) []string {
+	var problems []string
+	if topicProcessorName == "" {
+		problems = append(problems, "topic processor name is required")
+	}
+	if len(inputTopics) == 0 {
+		problems = append(problems, "at least one input topic is required")
+	}
+	if len(inputPartitions) == 0 {
+		problems = append(problems, "at least one input partition is required")
+	}
+	seen := make(map[int]bool, len(inputPartitions))
+	for _, partition := range inputPartitions {
+		if partition < 0 {
+			problems = append(problems, fmt.Sprintf("input partition %d is negative", partition))
+		}
+		if seen[partition] {
+			problems = append(problems, fmt.Sprintf("input partition %d is listed more than once", partition))
+		}
+		seen[partition] = true
+	}
+	if batchSize < 0 {
+		problems = append(problems, "batch size cannot be negative")
+	}
+	if batchWaitDuration < 0 {
+		problems = append(problems, "batch wait duration cannot be negative")
+	}
+	if metricsUpdateInterval < 0 {
+		problems = append(problems, "metrics update interval cannot be negative")
+	}
+	return append(problems, validateMetricsLabels(metricsLabels)...)
+}
+
+// validateBatchSizes checks MinBatchSize and TargetBatchLatency against the batch size that will be used, i.e. the
+// default batch size when batchSize is 0.
+func validateBatchSizes(batchSize int, minBatchSize int, targetBatchLatency time.Duration) []string {
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+	if targetBatchLatency < 0 || minBatchSize < 0 || minBatchSize > batchSize {
+		return []string{"MinBatchSize must be between 0 and BatchSize, and TargetBatchLatency cannot be negative"}
+	}
+	return nil
+}
+
+func (config *Config) validateTopics() []string {
+	topics := append(append([]string{}, config.InputTopics...), config.OutputTopics...)
+	if config.ProgressTopic != "" {
+		topics = append(topics, config.ProgressTopic)
+	}
+	if err := config.Client.RefreshMetadata(topics...); err != nil && err != sarama.ErrUnknownTopicOrPartition {
+		return []string{fmt.Sprintf("cannot reach Kafka brokers: %s", err)}
+	}
+	var problems []string
+	partitionCount := -1
+	for _, topic := range config.InputTopics {
+		partitions, err := config.Client.Partitions(topic)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("input topic %s: %s", topic, err))
+			continue
+		}
+		if partitionCount == -1 {
+			partitionCount = len(partitions)
+		} else if len(partitions) != partitionCount {
+			problems = append(problems, fmt.Sprintf("input topic %s has %d partitions, but %s has %d", topic, len(partitions), config.InputTopics[0], partitionCount))
+		}
+		for _, partition := range config.InputPartitions {
+			if partition < 0 || partition >= len(partitions) {
+				problems = append(problems, fmt.Sprintf("input topic %s has no partition %d", topic, partition))
+			}
+		}
+	}
+	for _, topic := range config.OutputTopics {
+		if _, err := config.Client.Partitions(topic); err != nil {
+			problems = append(problems, fmt.Sprintf("output topic %s: %s", topic, err))
+		}
+	}
+	if config.ProgressTopic != "" {
+		if _, err := config.Client.Partitions(config.ProgressTopic); err != nil {
+			problems = append(problems, fmt.Sprintf("progress topic %s: %s", config.ProgressTopic, err))
+		}
+	}
+	return problems
+}
+
+// stats returns the runtimeStats shared by the TopicProcessor and the stores created with this Config.
+func (config *Config) stats() *runtimeStats {
+	if config.runtimeStats == nil {
+		config.runtimeStats = newRuntimeStats(config.clock())
+	}
+	return config.runtimeStats
+}
+
+// clock returns Config.Clock, defaulting to the system clock.
+func (config *Config) clock() Clock {
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+	return config.Clock
+}
+
+// fatalError passes err to Config.OnFatalError, then panics through Config.Logger if it returns. It never returns.
+func (config *Config) fatalError(err error) {
+	if config.OnFatalError != nil {
+		config.OnFatalError(err)
+	}
+	if config.Logger == nil {
+		config.Logger = NewBasicLogger(false)
+	}
+	config.Logger.Panic(err)
+	// In case Logger.Panic does not panic
+	panic(err)
+}
+
+func defaultContainerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
@@ -0,0 +1,80 @@
+package kasper
+
+// CopyOnReadStore wraps an in-memory store, such as Map or CachingStore, and copies the values it reads and
+// writes, so that a MessageProcessor modifying a value in place cannot corrupt the values held by the store:
+//
+//	store := kasper.NewCopyOnReadStore(kasper.NewCachingStore(config, redis, "users", time.Minute, 0, 10000))
+//
+// Stores that deserialize values on every read, such as Redis and Elasticsearch, do not need it.
+type CopyOnReadStore struct {
+	store Store
+}
+
+// NewCopyOnReadStore creates CopyOnReadStore instances.
+func NewCopyOnReadStore(store Store) *CopyOnReadStore {
+	return &CopyOnReadStore{store}
+}
+
+func copyValue(value []byte) []byte {
+	if value == nil {
+		return nil
+	}
+	copied := make([]byte, len(value))
+	copy(copied, value)
+	return copied
+}
+
+func copyValues(kvs map[string][]byte) map[string][]byte {
+	copied := make(map[string][]byte, len(kvs))
+	for key, value := range kvs {
+		copied[key] = copyValue(value)
+	}
+	return copied
+}
+
+// Get returns a copy of the value of key in the underlying store.
+func (s *CopyOnReadStore) Get(key string) ([]byte, error) {
+	value, err := s.store.Get(key)
+	return copyValue(value), err
+}
+
+// GetAll returns copies of the values of keys in the underlying store.
+func (s *CopyOnReadStore) GetAll(keys []string) (map[string][]byte, error) {
+	kvs, err := s.store.GetAll(keys)
+	if err != nil {
+		return nil, err
+	}
+	return copyValues(kvs), nil
+}
+
+// Put inserts or updates a copy of value in the underlying store.
+func (s *CopyOnReadStore) Put(key string, value []byte) error {
+	return s.store.Put(key, copyValue(value))
+}
+
+// PutAll inserts or updates copies of multiple key-value pairs in the underlying store.
+func (s *CopyOnReadStore) PutAll(kvs map[string][]byte) error {
+	return s.store.PutAll(copyValues(kvs))
+}
+
+// Delete deletes a key from the underlying store.
+func (s *CopyOnReadStore) Delete(key string) error {
+	return s.store.Delete(key)
+}
+
+// Iterate iterates the keys of the underlying store with copies of their values, see the Iterate function.
+func (s *CopyOnReadStore) Iterate(prefix string, fn func(KeyValue) bool) error {
+	return Iterate(s.store, prefix, func(kv KeyValue) bool {
+		return fn(KeyValue{kv.Key, copyValue(kv.Value)})
+	})
+}
+
+// Flush flushes the underlying store.
+func (s *CopyOnReadStore) Flush() error {
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *CopyOnReadStore) GetStore() Store {
+	return s.store
+}
@@ -0,0 +1,31 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyOnReadStore(t *testing.T) {
+	s := NewMap(10)
+	store := NewCopyOnReadStore(NewCachingStore(&Config{}, s, "planets", time.Minute, 0, 0))
+
+	value := []byte("mars")
+	assert.Nil(t, store.Put("mars", value))
+	value[0] = 'b'
+	value, _ = store.Get("mars")
+	assert.Equal(t, mars, value)
+	value[0] = 'b'
+
+	kvs, err := store.GetAll([]string{"mars", "pluto"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"mars": mars}, kvs)
+	kvs["mars"][0] = 'b'
+
+	assert.Nil(t, NewCopyOnReadStore(s).Iterate("", func(kv KeyValue) bool {
+		kv.Value[0] = 'b'
+		return true
+	}))
+	assert.Equal(t, map[string][]byte{"mars": mars}, s.GetMap())
+}
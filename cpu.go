@@ -0,0 +1,64 @@
+package kasper
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+var cgroupRoot = "/sys/fs/cgroup"
+
+// AvailableCPUs returns the number of CPUs the process can use: runtime.NumCPU, capped by the CPU quota of the
+// container if there is one (cgroup v2 cpu.max or cgroup v1 cpu.cfs_quota_us), rounded up.
+func AvailableCPUs() int {
+	cpus := runtime.NumCPU()
+	if quota := cgroupCPUQuota(); quota > 0 && quota < float64(cpus) {
+		cpus = int(math.Ceil(quota))
+	}
+	return cpus
+}
+
+// SetMaxProcs sets GOMAXPROCS to AvailableCPUs and returns it. Call it at the start of main in containers with
+// CPU limits: Go 1.7 sizes GOMAXPROCS to the CPUs of the host, so the runtime would otherwise run more threads
+// than the quota allows and get throttled.
+func SetMaxProcs() int {
+	cpus := AvailableCPUs()
+	runtime.GOMAXPROCS(cpus)
+	return cpus
+}
+
+// cgroupCPUQuota returns the CPU quota in CPUs, or 0 if there is no quota.
+func cgroupCPUQuota() float64 {
+	if fields := readCgroupFields("cpu.max"); len(fields) == 2 {
+		return cpuQuota(fields[0], fields[1])
+	}
+	quota := readCgroupFields(filepath.Join("cpu", "cpu.cfs_quota_us"))
+	period := readCgroupFields(filepath.Join("cpu", "cpu.cfs_period_us"))
+	if len(quota) == 1 && len(period) == 1 {
+		return cpuQuota(quota[0], period[0])
+	}
+	return 0
+}
+
+func cpuQuota(quota, period string) float64 {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+func readCgroupFields(name string) []string {
+	data, err := ioutil.ReadFile(filepath.Join(cgroupRoot, name))
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(data))
+}
@@ -0,0 +1,37 @@
+package kasper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvailableCPUs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer func(root string) { cgroupRoot = root }(cgroupRoot)
+	cgroupRoot = dir
+
+	assert.Equal(t, runtime.NumCPU(), AvailableCPUs())
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "cpu.max"), []byte("max 100000\n"), 0644))
+	assert.Equal(t, runtime.NumCPU(), AvailableCPUs())
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "cpu.max"), []byte("50000 100000\n"), 0644))
+	assert.Equal(t, 1, AvailableCPUs())
+	assert.Equal(t, 0.5, cgroupCPUQuota())
+
+	assert.Nil(t, os.Remove(filepath.Join(dir, "cpu.max")))
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "cpu"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "cpu", "cpu.cfs_quota_us"), []byte("150000\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "cpu", "cpu.cfs_period_us"), []byte("100000\n"), 0644))
+	assert.Equal(t, 1.5, cgroupCPUQuota())
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "cpu", "cpu.cfs_quota_us"), []byte("-1\n"), 0644))
+	assert.Equal(t, 0.0, cgroupCPUQuota())
+}
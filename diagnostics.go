@@ -0,0 +1,106 @@
+package kasper
+
+import (
+	"runtime"
+	"time"
+)
+
+// Version is the version of Kasper reported by Diagnostics. Release builds can set it with
+// -ldflags "-X github.com/movio/kasper.Version=1.2.3".
+var Version = "dev"
+
+// Diagnostics describes a TopicProcessor and the process running it, for support tickets and incident
+// debugging. It contains no secrets. See TopicProcessor.Diagnostics and the /diagnostics Admin endpoint.
+type Diagnostics struct {
+	KasperVersion string             `json:"kasperVersion"`
+	GoVersion     string             `json:"goVersion"`
+	Runtime       RuntimeDiagnostics `json:"runtime"`
+	Config        ConfigDiagnostics  `json:"config"`
+	Running       bool               `json:"running"`
+	// Partitions assigned to the TopicProcessor
+	Partitions []int `json:"partitions"`
+	// Number of stores created with the Config by backend (e.g. "Redis")
+	Stores map[string]int `json:"stores"`
+	// Statistics of the stores of Config.Stores that implement StatsStore, as of the last metrics update
+	StoreStats map[string]StoreStats `json:"storeStats"`
+	Metrics    Snapshot              `json:"metrics"`
+}
+
+// RuntimeDiagnostics contains Go runtime statistics.
+type RuntimeDiagnostics struct {
+	Goroutines     int           `json:"goroutines"`
+	CPUs           int           `json:"cpus"`
+	MaxProcs       int           `json:"maxProcs"`
+	HeapAllocBytes uint64        `json:"heapAllocBytes"`
+	HeapObjects    uint64        `json:"heapObjects"`
+	GCCount        uint32        `json:"gcCount"`
+	GCPauseTotal   time.Duration `json:"gcPauseTotal"`
+}
+
+// ConfigDiagnostics is the configuration of a TopicProcessor, without the credentials.
+type ConfigDiagnostics struct {
+	TopicProcessorName    string            `json:"topicProcessorName"`
+	ContainerID           string            `json:"containerID"`
+	Brokers               []string          `json:"brokers"`
+	KafkaVersion          string            `json:"kafkaVersion"`
+	TLS                   bool              `json:"tls"`
+	SASLUser              string            `json:"saslUser,omitempty"`
+	InputTopics           []string          `json:"inputTopics"`
+	BatchSize             int               `json:"batchSize"`
+	BatchWaitDuration     time.Duration     `json:"batchWaitDuration"`
+	MetricsUpdateInterval time.Duration     `json:"metricsUpdateInterval"`
+	MetricsLabels         map[string]string `json:"metricsLabels"`
+}
+
+// Diagnostics returns the diagnostics of the TopicProcessor. It is safe to call Diagnostics from any goroutine,
+// but the batch size and batch wait duration may be stale while Reconfigure is running.
+func (tp *TopicProcessor) Diagnostics() Diagnostics {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return Diagnostics{
+		KasperVersion: Version,
+		GoVersion:     runtime.Version(),
+		Runtime: RuntimeDiagnostics{
+			Goroutines:     runtime.NumGoroutine(),
+			CPUs:           runtime.NumCPU(),
+			MaxProcs:       runtime.GOMAXPROCS(0),
+			HeapAllocBytes: memStats.HeapAlloc,
+			HeapObjects:    memStats.HeapObjects,
+			GCCount:        memStats.NumGC,
+			GCPauseTotal:   time.Duration(memStats.PauseTotalNs),
+		},
+		Config:     tp.configDiagnostics(),
+		Running:    tp.IsRunning(),
+		Partitions: tp.partitions,
+		Stores:     tp.stats.stores(),
+		StoreStats: tp.storeStatsReporter.lastStats(),
+		Metrics:    tp.Metrics(),
+	}
+}
+
+func (tp *TopicProcessor) configDiagnostics() ConfigDiagnostics {
+	config := tp.config
+	diagnostics := ConfigDiagnostics{
+		TopicProcessorName:    config.TopicProcessorName,
+		ContainerID:           config.ContainerID,
+		InputTopics:           config.InputTopics,
+		BatchSize:             config.BatchSize,
+		BatchWaitDuration:     config.BatchWaitDuration,
+		MetricsUpdateInterval: config.MetricsUpdateInterval,
+		MetricsLabels:         config.MetricsLabels,
+	}
+	if config.Client == nil {
+		return diagnostics
+	}
+	for _, broker := range config.Client.Brokers() {
+		diagnostics.Brokers = append(diagnostics.Brokers, broker.Addr())
+	}
+	if saramaConfig := config.Client.Config(); saramaConfig != nil {
+		diagnostics.KafkaVersion = kafkaVersionString(saramaConfig.Version)
+		diagnostics.TLS = saramaConfig.Net.TLS.Enable
+		if saramaConfig.Net.SASL.Enable {
+			diagnostics.SASLUser = saramaConfig.Net.SASL.User
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,56 @@
+package kasper
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type brokersClient struct {
+	metadataClient
+	config *sarama.Config
+}
+
+func (c *brokersClient) Brokers() []*sarama.Broker {
+	return []*sarama.Broker{sarama.NewBroker("kafka-1:9092")}
+}
+
+func (c *brokersClient) Config() *sarama.Config {
+	return c.config
+}
+
+func TestTopicProcessor_Diagnostics(t *testing.T) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V0_10_1_0
+	saramaConfig.Net.SASL.Enable = true
+	saramaConfig.Net.SASL.User = "arthur"
+	saramaConfig.Net.SASL.Password = "42"
+	config := &Config{Client: &brokersClient{config: saramaConfig}, ContainerID: "c0", BatchSize: 10}
+	tp := newFakeTopicProcessor(config, &countingProcessor{})
+	NewRedis(config, nil, "dragon")
+
+	diagnostics := tp.Diagnostics()
+	assert.Equal(t, "dev", diagnostics.KasperVersion)
+	assert.Equal(t, ConfigDiagnostics{
+		TopicProcessorName:    "fake",
+		ContainerID:           "c0",
+		Brokers:               []string{"kafka-1:9092"},
+		KafkaVersion:          "0.10.1.0",
+		SASLUser:              "arthur",
+		InputTopics:           []string{"input"},
+		BatchSize:             10,
+		BatchWaitDuration:     time.Hour,
+		MetricsUpdateInterval: time.Hour,
+	}, diagnostics.Config)
+	assert.Equal(t, []int{0}, diagnostics.Partitions)
+	assert.Equal(t, map[string]int{"Redis": 1}, diagnostics.Stores)
+	assert.False(t, diagnostics.Running)
+	assert.True(t, diagnostics.Runtime.Goroutines > 0)
+
+	code, body := adminRequest(NewAdmin(tp.TopicProcessor), "GET", "/diagnostics")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "fake", body["config"].(map[string]interface{})["topicProcessorName"])
+}
@@ -0,0 +1,87 @@
+package kasper
+
+import (
+	"fmt"
+	"time"
+)
+
+// DryRunStore wraps a Store when Config.DryRun is set, see NewDryRunStore. Reads are passed to the underlying
+// store, while writes are logged at debug level and counted but not executed. Reads therefore do not see the
+// values written during the dry run.
+type DryRunStore struct {
+	store   Store
+	name    string
+	logger  Logger
+	counter Counter
+}
+
+// NewDryRunStore returns store unchanged, unless Config.DryRun is set, in which case it returns a DryRunStore.
+// The name is used as the value of the "store" label of the dry_run_suppressed_count metric.
+// Settings.OpenStore wraps the stores it opens with NewDryRunStore.
+func NewDryRunStore(config *Config, store Store, name string) Store {
+	if !config.DryRun {
+		return store
+	}
+	return &DryRunStore{
+		store,
+		name,
+		WithFields(config.logger(), Field{"store", name}),
+		config.storeMetricsProvider().NewCounter("dry_run_suppressed_count",
+			"Number of store writes suppressed by dry run mode", "store", "operation"),
+	}
+}
+
+func (s *DryRunStore) suppress(operation string, count int, description string) {
+	s.logger.Debugf("Dry run: not executing %s", description)
+	s.counter.Add(float64(count), s.name, operation)
+}
+
+// Get gets a value by key from the underlying store.
+func (s *DryRunStore) Get(key string) ([]byte, error) {
+	return s.store.Get(key)
+}
+
+// GetAll gets multiple values by key from the underlying store.
+func (s *DryRunStore) GetAll(keys []string) (map[string][]byte, error) {
+	return s.store.GetAll(keys)
+}
+
+// Iterate iterates the keys of the underlying store, see the Iterate function.
+func (s *DryRunStore) Iterate(prefix string, fn func(KeyValue) bool) error {
+	return Iterate(s.store, prefix, fn)
+}
+
+// Put logs and counts the write without executing it.
+func (s *DryRunStore) Put(key string, value []byte) error {
+	s.suppress("Put", 1, fmt.Sprintf("Put %s (%d bytes)", key, len(value)))
+	return nil
+}
+
+// PutWithTTL logs and counts the write without executing it.
+func (s *DryRunStore) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	s.suppress("PutWithTTL", 1, fmt.Sprintf("PutWithTTL %s (%d bytes, TTL %s)", key, len(value), ttl))
+	return nil
+}
+
+// PutAll logs and counts the writes without executing them.
+func (s *DryRunStore) PutAll(kvs map[string][]byte) error {
+	s.suppress("PutAll", len(kvs), fmt.Sprintf("PutAll of %d keys", len(kvs)))
+	return nil
+}
+
+// Delete logs and counts the deletion without executing it.
+func (s *DryRunStore) Delete(key string) error {
+	s.suppress("Delete", 1, fmt.Sprintf("Delete %s", key))
+	return nil
+}
+
+// DeleteAll logs and counts the deletions without executing them.
+func (s *DryRunStore) DeleteAll(keys []string) error {
+	s.suppress("DeleteAll", len(keys), fmt.Sprintf("DeleteAll of %d keys", len(keys)))
+	return nil
+}
+
+// Flush does nothing, since no writes were executed.
+func (s *DryRunStore) Flush() error {
+	return nil
+}
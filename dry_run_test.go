@@ -0,0 +1,46 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDryRunStore(t *testing.T) {
+	store := NewMap(10)
+	assert.Equal(t, store, NewDryRunStore(&Config{}, store, "words"))
+
+	metrics := newRecordingMetricsProvider()
+	config := &Config{TopicProcessorName: "words", ContainerID: "c0", MetricsProvider: metrics, DryRun: true}
+	store.Put("hello", []byte("1"))
+	dryRun := NewDryRunStore(config, store, "words")
+	assert.Nil(t, dryRun.Put("hello", []byte("2")))
+	assert.Nil(t, dryRun.PutAll(map[string][]byte{"world": []byte("1"), "kasper": []byte("1")}))
+	assert.Nil(t, dryRun.Delete("hello"))
+	value, err := dryRun.Get("hello")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1"), value)
+	assert.Equal(t, 1.0, metrics.values["dry_run_suppressed_count{words,Put,,c0,words}"])
+	assert.Equal(t, 2.0, metrics.values["dry_run_suppressed_count{words,PutAll,,c0,words}"])
+}
+
+func TestTopicProcessor_DryRun(t *testing.T) {
+	processor := processorFunc(func(messages []*sarama.ConsumerMessage, sender Sender) error {
+		for _, message := range messages {
+			sender.Send(&sarama.ProducerMessage{Topic: "output", Value: sarama.ByteEncoder(message.Value)})
+		}
+		return nil
+	})
+	tp := newFakeTopicProcessor(&Config{DryRun: true}, processor)
+	done := tp.start()
+	tp.send(0, 3, "a")
+	waitFor(t, func() bool {
+		assert.Nil(t, tp.Flush())
+		offset, _ := tp.offsets[0].NextOffset()
+		return offset == 4
+	})
+	tp.Close()
+	assert.Nil(t, <-done)
+	assert.Empty(t, tp.producer.messages)
+}
@@ -1,8 +1,12 @@
 package kasper
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
+	"time"
 
 	"golang.org/x/net/context"
 	elastic "gopkg.in/olivere/elastic.v5"
@@ -10,6 +14,33 @@ import (
 
 const maxBulkErrorReasons = 5
 
+// Number of documents returned by each scroll request of Elasticsearch.Iterate
+const elasticsearchScrollSize = 100
+
+// Time Elasticsearch keeps a scroll context alive between two requests of Elasticsearch.Iterate
+const elasticsearchScrollKeepAlive = "1m"
+
+// Number of documents read by each multi get request of Elasticsearch.GetAll, whose latency and memory use degrade
+// badly beyond about 10000 documents
+var elasticsearchMultiGetSize = 1000
+
+// Elasticsearch.GetAll reads more keys than this with an ids query paginated with search_after instead of multi gets
+var elasticsearchSearchGetAllMinKeys = 10000
+
+// Number of documents returned by each search request of Elasticsearch.GetAll
+var elasticsearchSearchGetAllSize = 1000
+
+// Number of times Elasticsearch retries scripted updates that conflict with concurrent updates
+const elasticsearchRetryOnConflict = 3
+
+// Painless script of Elasticsearch.Increment, which starts the count of documents without one at 0
+const elasticsearchIncrementScript = "if (ctx._source.count == null) { ctx._source.count = params.delta } " +
+	"else { ctx._source.count += params.delta }"
+
+// Field of the documents written by Elasticsearch.PutWithTTL that holds their expiry time, in milliseconds since
+// the epoch
+const elasticsearchExpiryField = "kasper_expires_at"
+
 // Elasticsearch is an implementation of Store that uses Elasticsearch.
 // Each instance provides key-value access to a given index and a given document type.
 // This implementation supports Elasticsearch 5.x and uses Oliver Eilhard's Go Elasticsearch client.
@@ -19,8 +50,10 @@ type Elasticsearch struct {
 	context   context.Context
 	indexName string
 	typeName  string
+	clock     Clock
 
 	logger        Logger
+	stats         *runtimeStats
 	labelValues   []string
 	getCounter    Counter
 	getAllSummary Summary
@@ -28,26 +61,38 @@ type Elasticsearch struct {
 	putAllSummary Summary
 	deleteCounter Counter
 	flushCounter  Counter
+
+	getBytesSummary    Summary
+	getAllBytesSummary Summary
+	putBytesSummary    Summary
+	putAllBytesSummary Summary
 }
 
 // NewElasticsearch creates Elasticsearch instances. All documents read and written will correspond to the URL:
 //	 https://{cluster}:9092/{indexName}/{typeName}/{key}
 func NewElasticsearch(config *Config, client *elastic.Client, indexName, typeName string) *Elasticsearch {
-	metrics := config.MetricsProvider
-	labelNames := []string{"topicProcessor", "index", "type"}
+	config.stats().addStore("Elasticsearch")
+	metrics := config.storeMetricsProvider()
+	labelNames := []string{"index", "type"}
 	s := &Elasticsearch{
 		client,
 		context.Background(),
 		indexName,
 		typeName,
-		config.Logger,
-		[]string{config.TopicProcessorName, indexName, typeName},
+		config.clock(),
+		WithFields(config.logger(), Field{"store", "Elasticsearch"}, Field{"index", indexName}, Field{"type", typeName}),
+		config.stats(),
+		[]string{indexName, typeName},
 		metrics.NewCounter("Elasticsearch_Get", "Number of Get() calls", labelNames...),
 		metrics.NewSummary("Elasticsearch_GetAll", "Summary of GetAll() calls", labelNames...),
 		metrics.NewCounter("Elasticsearch_Put", "Number of Put() calls", labelNames...),
 		metrics.NewSummary("Elasticsearch_PutAll", "Summary of PutAll() calls", labelNames...),
 		metrics.NewCounter("Elasticsearch_Delete", "Number of Delete() calls", labelNames...),
 		metrics.NewCounter("Elasticsearch_Flush", "Summary of Flush() calls", labelNames...),
+		metrics.NewSummary("Elasticsearch_Get_Bytes", "Summary of Get() bytes read", labelNames...),
+		metrics.NewSummary("Elasticsearch_GetAll_Bytes", "Summary of GetAll() bytes read", labelNames...),
+		metrics.NewSummary("Elasticsearch_Put_Bytes", "Summary of Put() bytes written", labelNames...),
+		metrics.NewSummary("Elasticsearch_PutAll_Bytes", "Summary of PutAll() bytes written", labelNames...),
 	}
 	return s
 }
@@ -55,16 +100,22 @@ func NewElasticsearch(config *Config, client *elastic.Client, indexName, typeNam
 // Get gets a document by key (i.e. the Elasticsearch _id).
 // It is implemented by using the Elasticsearch Get API.
 // The returned byte slice contains the UTF8-encoded JSON document (i.e., _source).
-// This function returns (nil, nil) if the document does not exist.
+// This function returns (nil, nil) if the document does not exist or has expired (see PutWithTTL).
 // See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-get.html
 func (s *Elasticsearch) Get(key string) ([]byte, error) {
+	return s.GetContext(s.context, key)
+}
+
+// GetContext is like Get, with a context that cancels the request.
+func (s *Elasticsearch) GetContext(ctx context.Context, key string) (_ []byte, err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.Get", s.stats.now(), &err)
 	s.logger.Debugf("Elasticsearch Get: %s/%s/%s", s.indexName, s.typeName, key)
 	s.getCounter.Inc(s.labelValues...)
 	rawValue, err := s.client.Get().
 		Index(s.indexName).
 		Type(s.typeName).
 		Id(key).
-		Do(s.context)
+		Do(ctx)
 
 	if fmt.Sprintf("%s", err) == "elastic: Error 404 (Not Found)" {
 		return nil, nil
@@ -74,21 +125,55 @@ func (s *Elasticsearch) Get(key string) ([]byte, error) {
 		return nil, err
 	}
 
-	if !rawValue.Found {
+	if !rawValue.Found || s.expired(*rawValue.Source, s.clock.Now()) {
 		return nil, nil
 	}
 
+	s.getBytesSummary.Observe(float64(len(*rawValue.Source)), s.labelValues...)
 	return *rawValue.Source, nil
 }
 
-// GetAll gets multiple document from the store. It is implemented using the Elasticsearch MultiGet API.
+// GetAll gets multiple document from the store. The returned map does not contain expired documents.
+// Up to 10000 keys, it is implemented using the Elasticsearch MultiGet API, which is real-time like Get, with one
+// request per 1000 keys. Beyond, it refreshes the index, so that searches see all the documents written so far,
+// and reads the documents with an ids query paginated with search_after, as many multi gets would be slower.
 // See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-multi-get.html
+// and https://www.elastic.co/guide/en/elasticsearch/reference/current/search-request-search-after.html
 func (s *Elasticsearch) GetAll(keys []string) (map[string][]byte, error) {
+	return s.GetAllContext(s.context, keys)
+}
+
+// GetAllContext is like GetAll, with a context that cancels the request.
+func (s *Elasticsearch) GetAllContext(ctx context.Context, keys []string) (_ map[string][]byte, err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.GetAll", s.stats.now(), &err)
 	s.getAllSummary.Observe(float64(len(keys)), s.labelValues...)
 	if len(keys) == 0 {
 		return map[string][]byte{}, nil
 	}
 	s.logger.Debug("Elasticsearch GetAll: ", keys)
+	kvs := make(map[string][]byte, len(keys))
+	if len(keys) > elasticsearchSearchGetAllMinKeys {
+		if err := s.searchAll(ctx, keys, kvs); err != nil {
+			return nil, err
+		}
+		s.getAllBytesSummary.Observe(float64(countBytes(kvs)), s.labelValues...)
+		return kvs, nil
+	}
+	for start := 0; start < len(keys); start += elasticsearchMultiGetSize {
+		end := start + elasticsearchMultiGetSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := s.multiGet(ctx, keys[start:end], kvs); err != nil {
+			return nil, err
+		}
+	}
+	s.getAllBytesSummary.Observe(float64(countBytes(kvs)), s.labelValues...)
+	return kvs, nil
+}
+
+// multiGet adds the documents of keys to kvs with one multi get request.
+func (s *Elasticsearch) multiGet(ctx context.Context, keys []string, kvs map[string][]byte) error {
 	multiGet := s.client.MultiGet()
 	for _, key := range keys {
 
@@ -99,17 +184,58 @@ func (s *Elasticsearch) GetAll(keys []string) (map[string][]byte, error) {
 
 		multiGet.Add(item)
 	}
-	response, err := multiGet.Do(s.context)
+	response, err := multiGet.Do(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	kvs := make(map[string][]byte, len(keys))
+	now := s.clock.Now()
 	for i, doc := range response.Docs {
-		if doc.Found {
+		if doc.Found && !s.expired(*doc.Source, now) {
 			kvs[keys[i]] = *doc.Source
 		}
 	}
-	return kvs, nil
+	return nil
+}
+
+// searchAll adds the documents of keys to kvs. A search only sees the documents indexed before the last refresh of
+// the index (every second by default), so it first refreshes the index with the Refresh API. The documents are then
+// read with an ids query sorted by _uid and paginated with search_after, which unlike from/size pagination does not
+// need to skip the previous pages.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/indices-refresh.html
+func (s *Elasticsearch) searchAll(ctx context.Context, keys []string, kvs map[string][]byte) error {
+	if _, err := s.client.Refresh(s.indexName).Do(ctx); err != nil {
+		return err
+	}
+	query := elastic.NewIdsQuery(s.typeName).Ids(keys...)
+	now := s.clock.Now()
+	var after []interface{}
+	for {
+		search := s.client.Search(s.indexName).
+			Type(s.typeName).
+			Query(query).
+			Size(elasticsearchSearchGetAllSize).
+			Sort("_uid", true)
+		if after != nil {
+			search = search.SearchAfter(after...)
+		}
+		result, err := search.Do(ctx)
+		if err != nil {
+			return err
+		}
+		if result == nil || result.Hits == nil || len(result.Hits.Hits) == 0 {
+			return nil
+		}
+		hits := result.Hits.Hits
+		for _, hit := range hits {
+			if hit.Source != nil && !s.expired(*hit.Source, now) {
+				kvs[hit.Id] = *hit.Source
+			}
+		}
+		if len(hits) < elasticsearchSearchGetAllSize {
+			return nil
+		}
+		after = hits[len(hits)-1].Sort
+	}
 }
 
 // Put inserts or updates a document in the store (key is used as the document _id).
@@ -117,14 +243,21 @@ func (s *Elasticsearch) GetAll(keys []string) (map[string][]byte, error) {
 // The value byte slice must contain the UTF8-encoded JSON document (i.e., _source).
 // See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-index_.html
 func (s *Elasticsearch) Put(key string, value []byte) error {
+	return s.PutContext(s.context, key, value)
+}
+
+// PutContext is like Put, with a context that cancels the request.
+func (s *Elasticsearch) PutContext(ctx context.Context, key string, value []byte) (err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.Put", s.stats.now(), &err)
 	s.logger.Debugf("Elasticsearch Put: %s/%s/%s %#v", s.indexName, s.typeName, key, value)
 	s.putCounter.Inc(s.labelValues...)
-	_, err := s.client.Index().
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	_, err = s.client.Index().
 		Index(s.indexName).
 		Type(s.typeName).
 		Id(key).
 		BodyString(string(value)).
-		Do(s.context)
+		Do(ctx)
 
 	return err
 }
@@ -134,11 +267,18 @@ func (s *Elasticsearch) Put(key string, value []byte) error {
 // It returns an error if any operation fails.
 // See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-bulk.html
 func (s *Elasticsearch) PutAll(kvs map[string][]byte) error {
+	return s.PutAllContext(s.context, kvs)
+}
+
+// PutAllContext is like PutAll, with a context that cancels the request.
+func (s *Elasticsearch) PutAllContext(ctx context.Context, kvs map[string][]byte) (err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.PutAll", s.stats.now(), &err)
 	s.logger.Debugf("Elasticsearch PutAll of %d keys", len(kvs))
 	s.putAllSummary.Observe(float64(len(kvs)), s.labelValues...)
 	if len(kvs) == 0 {
 		return nil
 	}
+	s.putAllBytesSummary.Observe(float64(countBytes(kvs)), s.labelValues...)
 	bulk := s.client.Bulk()
 	for key, value := range kvs {
 		bulk.Add(elastic.NewBulkIndexRequest().
@@ -148,12 +288,12 @@ func (s *Elasticsearch) PutAll(kvs map[string][]byte) error {
 			Doc(string(value)),
 		)
 	}
-	response, err := bulk.Do(s.context)
+	response, err := bulk.Do(ctx)
 	if err != nil {
 		return err
 	}
 	if response.Errors {
-		return createBulkError(response)
+		return createBulkError("PutAll", response)
 	}
 	return nil
 }
@@ -163,13 +303,19 @@ func (s *Elasticsearch) PutAll(kvs map[string][]byte) error {
 // It is implemented using the Elasticsearch Delete API.
 // See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-delete.html
 func (s *Elasticsearch) Delete(key string) error {
+	return s.DeleteContext(s.context, key)
+}
+
+// DeleteContext is like Delete, with a context that cancels the request.
+func (s *Elasticsearch) DeleteContext(ctx context.Context, key string) (err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.Delete", s.stats.now(), &err)
 	s.logger.Debugf("Elasticsearch Delete: %s/%s/%s", s.indexName, s.typeName, key)
 	s.deleteCounter.Inc(s.labelValues...)
-	_, err := s.client.Delete().
+	_, err = s.client.Delete().
 		Index(s.indexName).
 		Type(s.typeName).
 		Id(key).
-		Do(s.context)
+		Do(ctx)
 
 	if err != nil && err.(*elastic.Error).Status == 404 {
 		return nil
@@ -178,25 +324,259 @@ func (s *Elasticsearch) Delete(key string) error {
 	return err
 }
 
+// DeleteAll removes multiple documents from the store.
+// It does not return an error if documents were not present.
+// It is implemented using the Elasticsearch Bulk and Delete APIs.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-bulk.html
+func (s *Elasticsearch) DeleteAll(keys []string) (err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.DeleteAll", s.stats.now(), &err)
+	s.logger.Debugf("Elasticsearch DeleteAll of %d keys", len(keys))
+	s.deleteCounter.Add(float64(len(keys)), s.labelValues...)
+	if len(keys) == 0 {
+		return nil
+	}
+	bulk := s.client.Bulk()
+	for _, key := range keys {
+		bulk.Add(elastic.NewBulkDeleteRequest().
+			Index(s.indexName).
+			Type(s.typeName).
+			Id(key),
+		)
+	}
+	response, err := bulk.Do(s.context)
+	if err != nil {
+		return err
+	}
+	if response.Errors {
+		return createBulkError("DeleteAll", response)
+	}
+	return nil
+}
+
 // Flush flushes the Elasticsearch translog to disk.
 // It is implemented using the Elasticsearch Flush API.
 // See https://www.elastic.co/guide/en/elasticsearch/reference/current/indices-flush.html
 func (s *Elasticsearch) Flush() error {
+	return s.FlushContext(s.context)
+}
+
+// FlushContext is like Flush, with a context that cancels the request.
+func (s *Elasticsearch) FlushContext(ctx context.Context) (err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.Flush", s.stats.now(), &err)
 	s.logger.Info("Elasticsearch Flush...")
 	s.flushCounter.Inc(s.labelValues...)
-	_, err := s.client.Flush("_all").
+	_, err = s.client.Flush("_all").
 		WaitIfOngoing(true).
-		Do(s.context)
+		Do(ctx)
 	s.logger.Info("Elasticsearch Flush complete")
 	return err
 }
 
+// Stats returns the number of documents of the type, using the Elasticsearch Count API.
+func (s *Elasticsearch) Stats() (_ StoreStats, err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.Stats", s.stats.now(), &err)
+	count, err := s.client.Count(s.indexName).
+		Type(s.typeName).
+		Do(s.context)
+	if err != nil {
+		return StoreStats{}, err
+	}
+	return StoreStats{Keys: count}, nil
+}
+
+// Increment adds delta to the counter stored at key and returns the new value.
+// Counters are stored as documents of the form {"count": 42}. The count of an existing document without a count
+// field starts at 0.
+// It is implemented using the Elasticsearch Update API with a Painless script and an upsert document.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-update.html
+func (s *Elasticsearch) Increment(key string, delta int64) (_ int64, err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.Increment", s.stats.now(), &err)
+	s.logger.Debugf("Elasticsearch Increment: %s/%s/%s %d", s.indexName, s.typeName, key, delta)
+	response, err := s.client.Update().
+		Index(s.indexName).
+		Type(s.typeName).
+		Id(key).
+		Script(elastic.NewScript(elasticsearchIncrementScript).Lang("painless").Param("delta", delta)).
+		Upsert(map[string]interface{}{"count": delta}).
+		RetryOnConflict(elasticsearchRetryOnConflict).
+		Fields("_source").
+		Do(s.context)
+	if err != nil {
+		return 0, err
+	}
+	if response.GetResult == nil || response.GetResult.Source == nil {
+		return 0, fmt.Errorf("Elasticsearch Increment: no document returned for %s", key)
+	}
+	var counter struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.Unmarshal(*response.GetResult.Source, &counter); err != nil {
+		return 0, err
+	}
+	return counter.Count, nil
+}
+
+// PutIfAbsent inserts a document if the key does not exist.
+// It is implemented using the Elasticsearch Index API with op_type=create.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-index_.html#operation-type
+func (s *Elasticsearch) PutIfAbsent(key string, value []byte) (_ bool, err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.PutIfAbsent", s.stats.now(), &err)
+	s.logger.Debugf("Elasticsearch PutIfAbsent: %s/%s/%s %#v", s.indexName, s.typeName, key, value)
+	s.putCounter.Inc(s.labelValues...)
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	_, err = s.client.Index().
+		Index(s.indexName).
+		Type(s.typeName).
+		Id(key).
+		OpType("create").
+		BodyString(string(value)).
+		Do(s.context)
+	if elastic.IsConflict(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CompareAndSet updates a document if its current source is equal to expected.
+// It reads the document with the Get API, then writes it with the Index API and the version that was read,
+// so that the write fails if the document was updated in between. It uses PutIfAbsent if expected is nil.
+// Documents without a _source never match.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-index_.html#index-versioning
+func (s *Elasticsearch) CompareAndSet(key string, expected, value []byte) (_ bool, err error) {
+	if expected == nil {
+		return s.PutIfAbsent(key, value)
+	}
+	defer s.stats.observeStoreOperation("Elasticsearch.CompareAndSet", s.stats.now(), &err)
+	s.logger.Debugf("Elasticsearch CompareAndSet: %s/%s/%s %#v", s.indexName, s.typeName, key, value)
+	s.putCounter.Inc(s.labelValues...)
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	current, err := s.client.Get().
+		Index(s.indexName).
+		Type(s.typeName).
+		Id(key).
+		Do(s.context)
+	if elastic.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// Without a _source (e.g. when it is disabled in the mapping) the document cannot be compared
+	if !current.Found || current.Version == nil || current.Source == nil || !bytes.Equal(*current.Source, expected) {
+		return false, nil
+	}
+	_, err = s.client.Index().
+		Index(s.indexName).
+		Type(s.typeName).
+		Id(key).
+		Version(*current.Version).
+		BodyString(string(value)).
+		Do(s.context)
+	if elastic.IsConflict(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Iterate calls fn for each document whose key starts with prefix until fn returns false, skipping expired documents.
+// It is implemented using the Elasticsearch Scroll API with a prefix query on the _uid field.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/search-request-scroll.html
+func (s *Elasticsearch) Iterate(prefix string, fn func(KeyValue) bool) (err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.Iterate", s.stats.now(), &err)
+	s.logger.Debugf("Elasticsearch Iterate: %s/%s/%s", s.indexName, s.typeName, prefix)
+	scroll := s.client.Scroll(s.indexName).
+		Type(s.typeName).
+		Query(elastic.NewPrefixQuery("_uid", s.typeName+"#"+prefix)).
+		Size(elasticsearchScrollSize).
+		Scroll(elasticsearchScrollKeepAlive)
+	defer scroll.Clear(s.context)
+	now := s.clock.Now()
+	for {
+		result, err := scroll.Do(s.context)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if result == nil || result.Hits == nil || len(result.Hits.Hits) == 0 {
+			return nil
+		}
+		for _, hit := range result.Hits.Hits {
+			if hit.Source == nil || s.expired(*hit.Source, now) {
+				continue
+			}
+			if !fn(KeyValue{hit.Id, *hit.Source}) {
+				return nil
+			}
+		}
+	}
+}
+
+// PutWithTTL inserts or updates a document that expires after ttl, which must be positive.
+// The value must be a JSON object: its expiry time is added to it, in milliseconds since the epoch, as the
+// kasper_expires_at field, which should be mapped as a date or a long. Expired documents are hidden from Get, GetAll
+// and Iterate, and deleted by Sweep.
+func (s *Elasticsearch) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid TTL for key %s: %s (must be positive)", key, ttl)
+	}
+	document, err := withElasticsearchExpiry(value, s.clock.Now().Add(ttl))
+	if err != nil {
+		return fmt.Errorf("value of %s: %s", key, err)
+	}
+	return s.Put(key, document)
+}
+
+// withElasticsearchExpiry returns a copy of the JSON object document with its expiry time set to expiry.
+func withElasticsearchExpiry(document []byte, expiry time.Time) ([]byte, error) {
+	var fields map[string]*json.RawMessage
+	if err := json.Unmarshal(document, &fields); err != nil || fields == nil {
+		return nil, fmt.Errorf("document is not a JSON object")
+	}
+	millis := json.RawMessage(fmt.Sprintf("%d", expiry.UnixNano()/int64(time.Millisecond)))
+	fields[elasticsearchExpiryField] = &millis
+	return json.Marshal(fields)
+}
+
+// expired returns true if document was written by PutWithTTL and has expired at now.
+func (s *Elasticsearch) expired(document []byte, now time.Time) bool {
+	if !bytes.Contains(document, []byte(elasticsearchExpiryField)) {
+		return false
+	}
+	var expiry struct {
+		ExpiresAt *int64 `json:"kasper_expires_at"`
+	}
+	if err := json.Unmarshal(document, &expiry); err != nil || expiry.ExpiresAt == nil {
+		return false
+	}
+	return *expiry.ExpiresAt <= now.UnixNano()/int64(time.Millisecond)
+}
+
+// Sweep deletes the expired documents of the type and returns their number. Run it periodically, e.g. only in the
+// leader (see LeaderElection). It is implemented using the Elasticsearch Delete By Query API with a range query on the
+// kasper_expires_at field, so it only deletes documents that are visible to searches (see the Refresh API).
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-delete-by-query.html
+func (s *Elasticsearch) Sweep() (_ int, err error) {
+	defer s.stats.observeStoreOperation("Elasticsearch.Sweep", s.stats.now(), &err)
+	now := s.clock.Now().UnixNano() / int64(time.Millisecond)
+	s.logger.Debugf("Elasticsearch Sweep: %s/%s expired at %d", s.indexName, s.typeName, now)
+	response, err := s.client.DeleteByQuery(s.indexName).
+		Type(s.typeName).
+		Query(elastic.NewRangeQuery(elasticsearchExpiryField).Lte(now)).
+		Do(s.context)
+	if err != nil {
+		return 0, err
+	}
+	return int(response.Deleted), nil
+}
+
 // GetClient returns the underlying elastic.Client
 func (s *Elasticsearch) GetClient() *elastic.Client {
 	return s.client
 }
 
-func createBulkError(response *elastic.BulkResponse) error {
+func createBulkError(operation string, response *elastic.BulkResponse) error {
 	reasons := []string{}
 	failed := response.Failed()
 	for i, item := range failed {
@@ -210,6 +590,6 @@ func createBulkError(response *elastic.BulkResponse) error {
 			break
 		}
 	}
-	err := fmt.Errorf("PutAll failed for some requests:\n%s", strings.Join(reasons, ""))
+	err := fmt.Errorf("%s failed for some requests:\n%s", operation, strings.Join(reasons, ""))
 	return err
 }
@@ -3,6 +3,7 @@ package kasper
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	elastic "gopkg.in/olivere/elastic.v5"
@@ -143,18 +144,170 @@ func TestElasticsearch_GetAll_PutAll(t *testing.T) {
 	assert.Nil(t, err)
 }
 
-func TestElasticsearch_Flush(t *testing.T) {
+func TestElasticsearch_Increment(t *testing.T) {
 	if testing.Short() {
 		t.Skip()
 	}
-	err := store.Flush()
+	counter, err := store.Increment("hatchlings", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), counter)
+	counter, err = store.Increment("hatchlings", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), counter)
+}
+
+func TestElasticsearch_Increment_NoCount(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	assert.Nil(t, store.Put("eggs", falkor))
+	counter, err := store.Increment("eggs", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), counter)
+	document, err := store.Get("eggs")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"color": "white", "name": "Falkor", "count": 3}`, string(document))
+}
+
+func TestElasticsearch_PutIfAbsent_CompareAndSet(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	inserted, err := store.PutIfAbsent("smaug", saphira)
+	assert.Nil(t, err)
+	assert.True(t, inserted)
+	inserted, err = store.PutIfAbsent("smaug", falkor)
+	assert.Nil(t, err)
+	assert.False(t, inserted)
+
+	updated, err := store.CompareAndSet("smaug", falkor, mushu)
+	assert.Nil(t, err)
+	assert.False(t, updated)
+	updated, err = store.CompareAndSet("smaug", saphira, mushu)
+	assert.Nil(t, err)
+	assert.True(t, updated)
+	dragon, err := store.Get("smaug")
+	assert.Nil(t, err)
+	assert.Equal(t, mushu, dragon)
+}
+
+func TestElasticsearch_Iterate(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	err := store.PutAll(map[string][]byte{"iterate-saphira": saphira, "iterate-mushu": mushu, "falkor": falkor})
+	assert.Nil(t, err)
+	_, err = store.client.Refresh("kasper").Do(store.context)
+	assert.Nil(t, err)
+	kvs := map[string][]byte{}
+	err = store.Iterate("iterate-", func(kv KeyValue) bool {
+		kvs[kv.Key] = kv.Value
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"iterate-saphira": saphira, "iterate-mushu": mushu}, kvs)
+}
+
+func TestElasticsearch_PutWithTTL(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	clock := &fixedClock{now: time.Now()}
+	store.clock = clock
+	defer func() { store.clock = systemClock{} }()
+	assert.Nil(t, store.PutWithTTL("ttl-saphira", saphira, time.Minute))
+	assert.Nil(t, store.PutWithTTL("ttl-mushu", mushu, time.Hour))
+	assert.Nil(t, store.Put("ttl-falkor", falkor))
+	kvs, err := store.GetAll([]string{"ttl-saphira", "ttl-mushu", "ttl-falkor"})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(kvs))
+
+	clock.now = clock.now.Add(time.Minute)
+	dragon, err := store.Get("ttl-saphira")
+	assert.Nil(t, err)
+	assert.Nil(t, dragon)
+	_, err = store.client.Refresh("kasper").Do(store.context)
+	assert.Nil(t, err)
+	deleted, err := store.Sweep()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	kvs, err = store.GetAll([]string{"ttl-saphira", "ttl-mushu", "ttl-falkor"})
 	assert.Nil(t, err)
+	assert.Equal(t, falkor, kvs["ttl-falkor"])
+	assert.Equal(t, 2, len(kvs))
 }
 
-func init() {
+func TestElasticsearch_Expiry(t *testing.T) {
+	expiry := time.Unix(1000, 0)
+	document, err := withElasticsearchExpiry(falkor, expiry)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"color":"white","kasper_expires_at":1000000,"name":"Falkor"}`, string(document))
+	s := &Elasticsearch{}
+	assert.False(t, s.expired(document, expiry.Add(-time.Millisecond)))
+	assert.True(t, s.expired(document, expiry))
+	assert.False(t, s.expired(falkor, expiry))
+
+	_, err = withElasticsearchExpiry([]byte(`["Falkor"]`), expiry)
+	assert.EqualError(t, err, "document is not a JSON object")
+	assert.EqualError(t, s.PutWithTTL("falkor", falkor, 0), "invalid TTL for key falkor: 0s (must be positive)")
+}
+
+func TestElasticsearch_GetAll_Chunks(t *testing.T) {
 	if testing.Short() {
-		return
+		t.Skip()
 	}
+	defer func(size int) {
+		elasticsearchMultiGetSize = size
+	}(elasticsearchMultiGetSize)
+	elasticsearchMultiGetSize = 2
+	// Not refreshed: multi get is real-time
+	expected := map[string][]byte{"chunk-saphira": saphira, "chunk-mushu": mushu, "chunk-falkor": falkor}
+	err := store.PutAll(expected)
+	assert.Nil(t, err)
+	kvs, err := store.GetAll([]string{"chunk-saphira", "chunk-mushu", "chunk-falkor", "chunk-draco"})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, kvs)
+}
+
+func TestElasticsearch_GetAll_Search(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	defer func(minKeys, size int) {
+		elasticsearchSearchGetAllMinKeys, elasticsearchSearchGetAllSize = minKeys, size
+	}(elasticsearchSearchGetAllMinKeys, elasticsearchSearchGetAllSize)
+	elasticsearchSearchGetAllMinKeys, elasticsearchSearchGetAllSize = 2, 2
+	// Not refreshed: GetAll refreshes the index before searching
+	expected := map[string][]byte{"search-saphira": saphira, "search-mushu": mushu, "search-falkor": falkor}
+	err := store.PutAll(expected)
+	assert.Nil(t, err)
+	kvs, err := store.GetAll([]string{"search-saphira", "search-mushu", "search-falkor", "search-draco"})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, kvs)
+}
+
+func TestElasticsearch_DeleteAll(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	err := store.PutAll(map[string][]byte{"saphira": saphira, "mushu": mushu})
+	assert.Nil(t, err)
+	err = store.DeleteAll([]string{"saphira", "mushu", "nessie"})
+	assert.Nil(t, err)
+	kvs, err := store.GetAll([]string{"saphira", "mushu"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(kvs))
+}
+
+func TestElasticsearch_Flush(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	err := store.Flush()
+	assert.Nil(t, err)
+}
+
+func setupElasticsearch() {
 	config := &Config{
 		TopicProcessorName: "test",
 		Logger:             &noopLogger{},
@@ -0,0 +1,270 @@
+package kasper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// KeyProvider wraps the data keys of an EncryptedStore with key-encryption keys, e.g. keys held by a KMS
+// (envelope encryption). Key-encryption keys are identified by an ID stored with each value, so they can be rotated:
+// new data keys are wrapped with the current key, while old ones are unwrapped with the key they were wrapped with.
+type KeyProvider interface {
+	// WrapKey encrypts a data key with the current key-encryption key, and returns the ID of that key.
+	WrapKey(dataKey []byte) (id string, wrappedKey []byte, err error)
+	// UnwrapKey decrypts a data key that was wrapped with the key-encryption key id.
+	UnwrapKey(id string, wrappedKey []byte) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider with a fixed set of AES key-encryption keys.
+type StaticKeyProvider struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+// WrapKey encrypts a data key with the key whose ID is Current, using AES-GCM.
+func (p *StaticKeyProvider) WrapKey(dataKey []byte) (string, []byte, error) {
+	key, err := p.Key(p.Current)
+	if err != nil {
+		return "", nil, err
+	}
+	wrappedKey, err := wrapKey(p.Current, key, dataKey)
+	return p.Current, wrappedKey, err
+}
+
+// UnwrapKey decrypts a data key with the key whose ID is id.
+func (p *StaticKeyProvider) UnwrapKey(id string, wrappedKey []byte) ([]byte, error) {
+	key, err := p.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapKey(id, key, wrappedKey)
+}
+
+// Key returns a key-encryption key by ID.
+func (p *StaticKeyProvider) Key(id string) ([]byte, error) {
+	key, found := p.Keys[id]
+	if !found {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+// SecretsKeyProvider is a KeyProvider that reads base64-encoded AES key-encryption keys from a SecretsProvider,
+// using the key IDs as secret names.
+type SecretsKeyProvider struct {
+	Secrets SecretsProvider
+	Current string
+}
+
+// WrapKey encrypts a data key with the key whose ID is Current, using AES-GCM.
+func (p *SecretsKeyProvider) WrapKey(dataKey []byte) (string, []byte, error) {
+	key, err := p.Key(p.Current)
+	if err != nil {
+		return "", nil, err
+	}
+	wrappedKey, err := wrapKey(p.Current, key, dataKey)
+	return p.Current, wrappedKey, err
+}
+
+// UnwrapKey decrypts a data key with the key whose ID is id.
+func (p *SecretsKeyProvider) UnwrapKey(id string, wrappedKey []byte) ([]byte, error) {
+	key, err := p.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapKey(id, key, wrappedKey)
+}
+
+// Key reads a key-encryption key from the secret with the same name.
+func (p *SecretsKeyProvider) Key(id string) ([]byte, error) {
+	secret, err := p.Secrets.Secret(id)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key %s is not valid base64: %s", id, err)
+	}
+	return key, nil
+}
+
+// wrapKey encrypts dataKey with AES-GCM and the key-encryption key id, and prefixes it with its nonce.
+func wrapKey(id string, key, dataKey []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomNonce(gcm)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, dataKey, []byte(id)), nil
+}
+
+// unwrapKey decrypts a data key encrypted by wrapKey.
+func unwrapKey(id string, key, wrappedKey []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrappedKey) < gcm.NonceSize() {
+		return nil, fmt.Errorf("data key wrapped with %s is truncated", id)
+	}
+	dataKey, err := gcm.Open(nil, wrappedKey[:gcm.NonceSize()], wrappedKey[gcm.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("cannot unwrap data key with %s: %s", id, err)
+	}
+	return dataKey, nil
+}
+
+// Size of the AES-256 data keys generated for each value
+const dataKeySize = 32
+
+// encryptedValue is the JSON document that EncryptedStore stores for each value. Byte slices are base64-encoded.
+type encryptedValue struct {
+	KeyID      string `json:"kid"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedStore wraps a Store and encrypts values with envelope encryption, so that sensitive state can be kept
+// in shared Redis or Elasticsearch clusters. Each value is encrypted with AES-256-GCM and a random data key, which
+// is wrapped by the KeyProvider and stored with it in a JSON document of the form
+//
+//	{"kid": "2017-01", "wrapped_key": "...", "nonce": "...", "ciphertext": "..."}
+//
+// so that EncryptedStore also works on Elasticsearch. The store key is authenticated with the value, so a value
+// copied to another key cannot be decrypted. Store keys are not encrypted.
+type EncryptedStore struct {
+	store Store
+	keys  KeyProvider
+}
+
+// NewEncryptedStore creates EncryptedStore instances.
+func NewEncryptedStore(store Store, keys KeyProvider) *EncryptedStore {
+	return &EncryptedStore{store, keys}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomNonce(gcm cipher.AEAD) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+func (s *EncryptedStore) encrypt(key string, value []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	id, wrappedKey, err := s.keys.WrapKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomNonce(gcm)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&encryptedValue{id, wrappedKey, nonce, gcm.Seal(nil, nonce, value, []byte(key))})
+}
+
+func (s *EncryptedStore) decrypt(key string, value []byte) ([]byte, error) {
+	var encrypted encryptedValue
+	if err := json.Unmarshal(value, &encrypted); err != nil || encrypted.KeyID == "" || encrypted.Ciphertext == nil {
+		return nil, fmt.Errorf("value of %s is not encrypted", key)
+	}
+	dataKey, err := s.keys.UnwrapKey(encrypted.KeyID, encrypted.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("value of %s has an invalid nonce", key)
+	}
+	plaintext, err := gcm.Open(nil, encrypted.Nonce, encrypted.Ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt value of %s: %s", key, err)
+	}
+	return plaintext, nil
+}
+
+// Get gets a value by key from the underlying store and decrypts it.
+func (s *EncryptedStore) Get(key string) ([]byte, error) {
+	value, err := s.store.Get(key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return s.decrypt(key, value)
+}
+
+// GetAll gets multiple values by key from the underlying store and decrypts them.
+func (s *EncryptedStore) GetAll(keys []string) (map[string][]byte, error) {
+	kvs, err := s.store.GetAll(keys)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range kvs {
+		if kvs[key], err = s.decrypt(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return kvs, nil
+}
+
+// Put encrypts a value and inserts or updates it in the underlying store.
+func (s *EncryptedStore) Put(key string, value []byte) error {
+	encrypted, err := s.encrypt(key, value)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(key, encrypted)
+}
+
+// PutAll encrypts multiple values and inserts or updates them in the underlying store.
+func (s *EncryptedStore) PutAll(kvs map[string][]byte) error {
+	encrypted := make(map[string][]byte, len(kvs))
+	for key, value := range kvs {
+		var err error
+		if encrypted[key], err = s.encrypt(key, value); err != nil {
+			return err
+		}
+	}
+	return s.store.PutAll(encrypted)
+}
+
+// Delete deletes a key from the underlying store.
+func (s *EncryptedStore) Delete(key string) error {
+	return s.store.Delete(key)
+}
+
+// Flush flushes the underlying store.
+func (s *EncryptedStore) Flush() error {
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *EncryptedStore) GetStore() Store {
+	return s.store
+}
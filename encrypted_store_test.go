@@ -0,0 +1,71 @@
+package kasper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	keys := &StaticKeyProvider{"2017-01", map[string][]byte{"2017-01": bytes.Repeat([]byte{1}, 32)}}
+	s := NewMap(10)
+	store := NewEncryptedStore(s, keys)
+
+	assert.Nil(t, store.Put("mars", mars))
+	assert.Nil(t, store.PutAll(map[string][]byte{"venus": venus}))
+	encrypted, _ := s.Get("mars")
+	var envelope, otherEnvelope map[string]string
+	assert.Nil(t, json.Unmarshal(encrypted, &envelope))
+	assert.Equal(t, "2017-01", envelope["kid"])
+	assert.Equal(t, 4, len(envelope))
+	assert.NotEmpty(t, envelope["wrapped_key"])
+	assert.NotEmpty(t, envelope["nonce"])
+	assert.NotEmpty(t, envelope["ciphertext"])
+	assert.False(t, bytes.Contains(encrypted, mars))
+
+	// Each value has its own data key
+	other, _ := s.Get("venus")
+	assert.Nil(t, json.Unmarshal(other, &otherEnvelope))
+	assert.NotEqual(t, envelope["wrapped_key"], otherEnvelope["wrapped_key"])
+
+	// Rotate the key, values encrypted with the previous key can still be read
+	keys.Keys["2017-02"] = bytes.Repeat([]byte{2}, 16)
+	keys.Current = "2017-02"
+	assert.Nil(t, store.Put("earth", earth))
+	kvs, err := store.GetAll([]string{"mars", "venus", "earth", "pluto"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"mars": mars, "venus": venus, "earth": earth}, kvs)
+
+	// Values cannot be moved to another key
+	s.Put("jupiter", encrypted)
+	_, err = store.Get("jupiter")
+	assert.EqualError(t, err, "cannot decrypt value of jupiter: cipher: message authentication failed")
+	s.Put("saturn", saturn)
+	_, err = store.Get("saturn")
+	assert.EqualError(t, err, "value of saturn is not encrypted")
+	delete(keys.Keys, "2017-01")
+	_, err = store.Get("mars")
+	assert.EqualError(t, err, `unknown encryption key "2017-01"`)
+	keys.Keys["2017-01"] = bytes.Repeat([]byte{3}, 32)
+	_, err = store.Get("mars")
+	assert.EqualError(t, err, "cannot unwrap data key with 2017-01: cipher: message authentication failed")
+}
+
+func TestSecretsKeyProvider(t *testing.T) {
+	os.Setenv("KASPER_TEST_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{3}, 32)))
+	defer os.Unsetenv("KASPER_TEST_KEY")
+	provider := &SecretsKeyProvider{EnvSecrets{}, "KASPER_TEST_KEY"}
+	key, err := provider.Key("KASPER_TEST_KEY")
+	assert.Nil(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{3}, 32), key)
+	id, wrappedKey, err := provider.WrapKey(mars)
+	assert.Nil(t, err)
+	assert.Equal(t, "KASPER_TEST_KEY", id)
+	dataKey, err := provider.UnwrapKey(id, wrappedKey)
+	assert.Nil(t, err)
+	assert.Equal(t, mars, dataKey)
+}
@@ -0,0 +1,224 @@
+package kasper
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"golang.org/x/net/context"
+)
+
+// ErrEnrichmentCircuitOpen is returned by Enricher for the messages it does not enrich while its circuit is open.
+var ErrEnrichmentCircuitOpen = errors.New("kasper: enrichment circuit breaker is open")
+
+// EnrichFunc calls an external service for a message, e.g. with a gRPC client, and returns the response.
+// It must return when ctx is done and be safe for concurrent use.
+type EnrichFunc func(ctx context.Context, message *sarama.ConsumerMessage) (interface{}, error)
+
+// EnrichmentPolicy configures an Enricher.
+type EnrichmentPolicy struct {
+	// Maximum number of calls in flight, defaults to 1
+	Concurrency int
+	// Timeout of each attempt (0 means no timeout)
+	Timeout time.Duration
+	// Retries of the failed calls. The budget is shared by all calls. Defaults to no retries.
+	Retry RetryPolicy
+	// The circuit opens after this many consecutive failed calls (0 disables the circuit breaker), and calls fail
+	// with ErrEnrichmentCircuitOpen while it is open
+	CircuitFailures int
+	// A call is let through to probe the service every CircuitOpenDelay while the circuit is open
+	CircuitOpenDelay time.Duration
+}
+
+// Enricher calls an external service for each message of a batch, with bounded concurrency, timeouts, retries and
+// a circuit breaker, so that MessageProcessors can fold the responses into their output:
+//
+//	responses, errs := p.enricher.Enrich(messages)
+//	for i, message := range messages {
+//		if errs != nil && errs[i] != nil {
+//			... // e.g. send the message to a dead letter topic
+//			continue
+//		}
+//		profile := responses[i].(*pb.Profile)
+//		...
+//	}
+//
+// The calls, retries and failures are counted in the enrichment_call_count, enrichment_retry_count and
+// enrichment_error_count metrics. Enricher is safe for concurrent use.
+type Enricher struct {
+	name         string
+	fn           EnrichFunc
+	policy       EnrichmentPolicy
+	clock        Clock
+	logger       Logger
+	callCounter  Counter
+	retryCounter Counter
+	errorCounter Counter
+	sleep        func(time.Duration)
+
+	mutex    sync.Mutex
+	tokens   float64
+	failed   int
+	open     bool
+	probing  bool
+	openedAt time.Time
+}
+
+// NewEnricher creates Enricher instances. The name is used as the value of the "service" label.
+func NewEnricher(config *Config, name string, fn EnrichFunc, policy EnrichmentPolicy) *Enricher {
+	if policy.Concurrency <= 0 {
+		policy.Concurrency = 1
+	}
+	if policy.Retry.Retryable == nil {
+		policy.Retry.Retryable = IsRetryable
+	}
+	metrics := config.metricsProvider()
+	return &Enricher{
+		name,
+		fn,
+		policy,
+		config.clock(),
+		WithFields(config.logger(), Field{"service", name}),
+		metrics.NewCounter("enrichment_call_count", "Number of enrichment calls", "service"),
+		metrics.NewCounter("enrichment_retry_count", "Number of retried enrichment calls", "service"),
+		metrics.NewCounter("enrichment_error_count", "Number of failed enrichment calls", "service"),
+		time.Sleep,
+		sync.Mutex{},
+		maxRetryTokens,
+		0,
+		false,
+		false,
+		time.Time{},
+	}
+}
+
+// Enrich calls the service for each message and returns the responses in the order of messages. errs is nil if all
+// calls succeeded, and otherwise holds the error of each message (nil for the messages that were enriched).
+func (e *Enricher) Enrich(messages []*sarama.ConsumerMessage) (responses []interface{}, errs []error) {
+	responses = make([]interface{}, len(messages))
+	results := make([]error, len(messages))
+	slots := make(chan struct{}, e.policy.Concurrency)
+	var wg sync.WaitGroup
+	for i, message := range messages {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, message *sarama.ConsumerMessage) {
+			defer wg.Done()
+			responses[i], results[i] = e.call(message)
+			<-slots
+		}(i, message)
+	}
+	wg.Wait()
+	for _, err := range results {
+		if err != nil {
+			return responses, results
+		}
+	}
+	return responses, nil
+}
+
+func (e *Enricher) call(message *sarama.ConsumerMessage) (interface{}, error) {
+	if err := e.allow(); err != nil {
+		e.errorCounter.Inc(e.name)
+		return nil, err
+	}
+	response, err := e.retry(message)
+	e.done(err)
+	if err != nil {
+		e.errorCounter.Inc(e.name)
+	}
+	return response, err
+}
+
+func (e *Enricher) attempt(message *sarama.ConsumerMessage) (interface{}, error) {
+	e.callCounter.Inc(e.name)
+	ctx := context.Background()
+	if e.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.policy.Timeout)
+		defer cancel()
+	}
+	return e.fn(ctx, message)
+}
+
+// retry is like RetryingStore.retry, with a retry budget shared by the concurrent calls.
+func (e *Enricher) retry(message *sarama.ConsumerMessage) (interface{}, error) {
+	policy := e.policy.Retry
+	if policy.Budget > 0 {
+		e.mutex.Lock()
+		e.tokens += policy.Budget
+		if e.tokens > maxRetryTokens {
+			e.tokens = maxRetryTokens
+		}
+		e.mutex.Unlock()
+	}
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		response, err := e.attempt(message)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.Retryable(err) {
+			return response, err
+		}
+		if policy.Budget > 0 && !e.spendRetryToken() {
+			e.logger.Debugf("Not retrying enrichment, retry budget exhausted: %s", err)
+			return response, err
+		}
+		e.logger.Infof("Enrichment failed, retrying in %s: %s", backoff, err)
+		e.retryCounter.Inc(e.name)
+		e.sleep(backoff)
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+func (e *Enricher) spendRetryToken() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.tokens < 1 {
+		return false
+	}
+	e.tokens--
+	return true
+}
+
+// allow and done implement the circuit breaker like CircuitBreakerStore.
+func (e *Enricher) allow() error {
+	if e.policy.CircuitFailures <= 0 {
+		return nil
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if !e.open {
+		return nil
+	}
+	if e.probing || e.clock.Now().Sub(e.openedAt) < e.policy.CircuitOpenDelay {
+		return ErrEnrichmentCircuitOpen
+	}
+	e.probing = true
+	return nil
+}
+
+func (e *Enricher) done(err error) {
+	if e.policy.CircuitFailures <= 0 {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if err == nil || !e.policy.Retry.Retryable(err) {
+		if e.open {
+			e.logger.Info("Enrichment circuit breaker closed")
+		}
+		e.failed, e.open, e.probing = 0, false, false
+		return
+	}
+	e.failed++
+	if e.probing || e.failed >= e.policy.CircuitFailures {
+		if !e.open {
+			e.logger.Errorf("Enrichment circuit breaker open after %d failures: %s", e.failed, err)
+		}
+		e.open, e.probing = true, false
+		e.openedAt = e.clock.Now()
+	}
+}
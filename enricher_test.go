@@ -0,0 +1,106 @@
+package kasper
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func enricherMessages(values ...string) []*sarama.ConsumerMessage {
+	messages := make([]*sarama.ConsumerMessage, len(values))
+	for i, value := range values {
+		messages[i] = &sarama.ConsumerMessage{Value: []byte(value)}
+	}
+	return messages
+}
+
+func TestEnricher(t *testing.T) {
+	var inFlight, maxInFlight, attempts int32
+	fn := func(ctx context.Context, message *sarama.ConsumerMessage) (interface{}, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		value := string(message.Value)
+		if value == "pluto" {
+			return nil, errors.New("not a planet")
+		}
+		if value == "mars" && atomic.AddInt32(&attempts, 1) == 1 {
+			return nil, errors.New("timeout")
+		}
+		return len(value), nil
+	}
+	enricher := NewEnricher(&Config{Logger: &noopLogger{}}, "planets", fn, EnrichmentPolicy{Concurrency: 2, Retry: RetryPolicy{MaxAttempts: 2}})
+	enricher.sleep = func(time.Duration) {}
+
+	responses, errs := enricher.Enrich(enricherMessages("venus", "mars", "earth", "pluto", "saturn"))
+	assert.Equal(t, []interface{}{5, 4, 5, nil, 6}, responses)
+	assert.Equal(t, []error{nil, nil, nil, errors.New("not a planet"), nil}, errs)
+	assert.Equal(t, int32(2), maxInFlight)
+
+	responses, errs = enricher.Enrich(enricherMessages("venus"))
+	assert.Equal(t, []interface{}{5}, responses)
+	assert.Nil(t, errs)
+}
+
+func TestEnricher_Metrics(t *testing.T) {
+	fn := func(ctx context.Context, message *sarama.ConsumerMessage) (interface{}, error) {
+		if string(message.Value) == "pluto" {
+			return nil, errors.New("not a planet")
+		}
+		return nil, nil
+	}
+	provider := newRecordingMetricsProvider()
+	config := &Config{ContainerID: "c0", MetricsProvider: provider, Logger: &noopLogger{}}
+	enricher := NewEnricher(config, "planets", fn, EnrichmentPolicy{Retry: RetryPolicy{MaxAttempts: 3}})
+	enricher.sleep = func(time.Duration) {}
+	enricher.Enrich(enricherMessages("mars", "pluto", "venus"))
+	assert.Equal(t, 5.0, provider.values["enrichment_call_count{planets,c0,}"])
+	assert.Equal(t, 2.0, provider.values["enrichment_retry_count{planets,c0,}"])
+	assert.Equal(t, 1.0, provider.values["enrichment_error_count{planets,c0,}"])
+}
+
+func TestEnricher_Timeout(t *testing.T) {
+	fn := func(ctx context.Context, message *sarama.ConsumerMessage) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	enricher := NewEnricher(&Config{Logger: &noopLogger{}}, "planets", fn, EnrichmentPolicy{Timeout: time.Millisecond})
+	_, errs := enricher.Enrich(enricherMessages("mars"))
+	assert.Equal(t, []error{context.DeadlineExceeded}, errs)
+}
+
+func TestEnricher_CircuitBreaker(t *testing.T) {
+	var calls int32
+	failing := true
+	fn := func(ctx context.Context, message *sarama.ConsumerMessage) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		if failing {
+			return nil, errors.New("unavailable")
+		}
+		return "ok", nil
+	}
+	config := &Config{Logger: &noopLogger{}, Clock: fixedClock{now: time.Unix(0, 0)}}
+	policy := EnrichmentPolicy{CircuitFailures: 2, CircuitOpenDelay: time.Minute}
+	enricher := NewEnricher(config, "planets", fn, policy)
+
+	_, errs := enricher.Enrich(enricherMessages("mercury", "venus", "earth", "mars"))
+	assert.Equal(t, []error{errors.New("unavailable"), errors.New("unavailable"), ErrEnrichmentCircuitOpen, ErrEnrichmentCircuitOpen}, errs)
+	assert.Equal(t, int32(2), calls)
+
+	enricher.clock = fixedClock{now: time.Unix(60, 0)}
+	failing = false
+	responses, errs := enricher.Enrich(enricherMessages("mars", "jupiter"))
+	assert.Equal(t, []interface{}{"ok", "ok"}, responses)
+	assert.Nil(t, errs)
+}
@@ -1,11 +1,11 @@
+//go:build ignore
+// +build ignore
+
 package main
 
 import (
 	"fmt"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
 
 	"github.com/Shopify/sarama"
 	"github.com/movio/kasper"
@@ -42,16 +42,7 @@ func main() {
 	}
 	messageProcessors := map[int]kasper.MessageProcessor{0: &HelloWorldExample{}}
 	tp := kasper.NewTopicProcessor(config, messageProcessors)
-	go func() {
-		signals := make(chan os.Signal, 1)
-		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-		log.Println("Topic processor is running...")
-		for range signals {
-			signal.Stop(signals)
-			tp.Close()
-			return
-		}
-	}()
-	err := tp.RunLoop()
+	log.Println("Topic processor is running...")
+	err := tp.RunUntilSignalled()
 	log.Printf("Topic processor finished with err = %s\n", err)
 }
@@ -1,13 +1,13 @@
+//go:build ignore
+// +build ignore
+
 package main
 
 import (
 	"fmt"
 	"log"
-	"os"
-	"os/signal"
 	"strconv"
 	"strings"
-	"syscall"
 
 	"github.com/Shopify/sarama"
 	"github.com/movio/kasper"
@@ -77,16 +77,7 @@ func main() {
 	store := kasper.NewMap(10000)
 	messageProcessors := map[int]kasper.MessageProcessor{0: &WordCountExample{store}}
 	tp := kasper.NewTopicProcessor(&config, messageProcessors)
-	go func() {
-		signals := make(chan os.Signal, 1)
-		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-		log.Println("Topic processor is running...")
-		for range signals {
-			signal.Stop(signals)
-			tp.Close()
-			break
-		}
-	}()
-	err := tp.RunLoop()
+	log.Println("Topic processor is running...")
+	err := tp.RunUntilSignalled()
 	log.Printf("Topic processor finished with err = %s\n", err)
 }
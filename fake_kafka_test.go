@@ -0,0 +1,147 @@
+package kasper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Fakes for running a TopicProcessor without Kafka in unit tests
+
+func (p *recordingSyncProducer) Close() error {
+	return nil
+}
+
+type fakeOffsetManager struct {
+	sarama.PartitionOffsetManager
+	mutex sync.Mutex
+	next  int64
+}
+
+func (m *fakeOffsetManager) NextOffset() (int64, string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.next, ""
+}
+
+func (m *fakeOffsetManager) MarkOffset(offset int64, metadata string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.next = offset
+}
+
+func (m *fakeOffsetManager) Close() error {
+	return nil
+}
+
+type fakePartitionConsumer struct {
+	sarama.PartitionConsumer
+	messages chan *sarama.ConsumerMessage
+	once     sync.Once
+}
+
+func (c *fakePartitionConsumer) Messages() <-chan *sarama.ConsumerMessage {
+	return c.messages
+}
+
+func (c *fakePartitionConsumer) Close() error {
+	c.once.Do(func() { close(c.messages) })
+	return nil
+}
+
+type fakeConsumer struct {
+	sarama.Consumer
+}
+
+func (c *fakeConsumer) HighWaterMarks() map[string]map[int32]int64 {
+	return map[string]map[int32]int64{}
+}
+
+func (c *fakeConsumer) Close() error {
+	return nil
+}
+
+type processorFunc func([]*sarama.ConsumerMessage, Sender) error
+
+func (f processorFunc) Process(messages []*sarama.ConsumerMessage, sender Sender) error {
+	return f(messages, sender)
+}
+
+// fakeTopicProcessor is a TopicProcessor consuming a single topic from in-memory partition consumers.
+type fakeTopicProcessor struct {
+	*TopicProcessor
+	producer  *recordingSyncProducer
+	consumers map[int]*fakePartitionConsumer
+	offsets   map[int]*fakeOffsetManager
+}
+
+func newFakeTopicProcessor(config *Config, processor MessageProcessor) *fakeTopicProcessor {
+	if config.TopicProcessorName == "" {
+		config.TopicProcessorName = "fake"
+	}
+	if len(config.InputTopics) == 0 {
+		config.InputTopics = []string{"input"}
+	}
+	if len(config.InputPartitions) == 0 {
+		config.InputPartitions = []int{0}
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = 1000
+	}
+	if config.BatchWaitDuration == 0 {
+		config.BatchWaitDuration = time.Hour
+	}
+	if config.MetricsUpdateInterval == 0 {
+		config.MetricsUpdateInterval = time.Hour
+	}
+	if config.Logger == nil {
+		config.Logger = &noopLogger{}
+	}
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+	producer := &recordingSyncProducer{}
+	tp := newTopicProcessor(config, producer, nil)
+	fake := &fakeTopicProcessor{tp, producer, make(map[int]*fakePartitionConsumer), make(map[int]*fakeOffsetManager)}
+	topic := config.InputTopics[0]
+	for _, partition := range config.InputPartitions {
+		consumer := &fakePartitionConsumer{messages: make(chan *sarama.ConsumerMessage, 100)}
+		offsetManager := &fakeOffsetManager{}
+		fake.consumers[partition] = consumer
+		fake.offsets[partition] = offsetManager
+		tp.partitionProcessors[int32(partition)] = &partitionProcessor{
+			topicProcessor:     tp,
+			consumer:           &fakeConsumer{},
+			partitionConsumers: []sarama.PartitionConsumer{consumer},
+			offsetManagers:     map[string]sarama.PartitionOffsetManager{topic: offsetManager},
+			messageProcessor:   processor,
+			inputTopics:        config.InputTopics,
+			partition:          partition,
+			logger:             tp.logger,
+		}
+	}
+	return fake
+}
+
+// send delivers a message to the TopicProcessor on the first input topic.
+func (f *fakeTopicProcessor) send(partition int, offset int64, value string) {
+	f.consumers[partition].messages <- &sarama.ConsumerMessage{
+		Topic:     f.inputTopics[0],
+		Partition: int32(partition),
+		Offset:    offset,
+		Value:     []byte(value),
+	}
+}
+
+// start runs RunLoop in a goroutine and waits until it is running.
+func (f *fakeTopicProcessor) start() chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- f.RunLoop()
+	}()
+	for !f.IsRunning() {
+		time.Sleep(time.Millisecond)
+	}
+	return done
+}
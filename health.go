@@ -0,0 +1,190 @@
+package kasper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// HealthCheckKey is read by the checks created with StoreHealthCheck.
+const HealthCheckKey = "__kasper_health_check__"
+
+// Health serves liveness and readiness probes for a TopicProcessor, e.g. for Kubernetes:
+//
+//	health := kasper.NewHealth(topicProcessor)
+//	health.AddStoreCheck("redis", store)
+//	health.SetMaxLag(10000)
+//	go http.ListenAndServe(":8080", health)
+//
+// /healthz reports whether Kafka and the stores are reachable. /readyz additionally reports whether the
+// TopicProcessor is running (i.e. all partitions have been assigned) and whether the number of messages behind
+// the high water mark is below the maximum lag, as of the last metrics update.
+// Both respond with 200 if all checks pass and 503 otherwise, and a JSON body describing each check.
+type Health struct {
+	topicProcessor *TopicProcessor
+	mutex          sync.Mutex
+	checks         map[string]func() error
+	readyChecks    map[string]func() error
+	maxLag         int64
+}
+
+// HealthReport is the body of the /healthz and /readyz responses.
+type HealthReport struct {
+	OK bool `json:"ok"`
+	// "ok" or the error message, by check name
+	Checks map[string]string `json:"checks"`
+}
+
+// NewHealth creates a Health with a "kafka" liveness check, and "running" and "lag" readiness checks.
+func NewHealth(topicProcessor *TopicProcessor) *Health {
+	h := &Health{
+		topicProcessor: topicProcessor,
+		checks:         make(map[string]func() error),
+		readyChecks:    make(map[string]func() error),
+	}
+	h.checks["kafka"] = h.checkKafka
+	h.readyChecks["running"] = h.checkRunning
+	h.readyChecks["lag"] = h.checkLag
+	return h
+}
+
+// AddCheck adds a liveness check, e.g. StoreHealthCheck. Liveness checks are also readiness checks.
+func (h *Health) AddCheck(name string, check func() error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checks[name] = check
+}
+
+// AddReadinessCheck adds a check that only affects readiness, e.g. whether state has been restored.
+func (h *Health) AddReadinessCheck(name string, check func() error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.readyChecks[name] = check
+}
+
+// SetMaxLag sets the maximum number of messages behind the high water mark for the TopicProcessor to be ready
+// (0 disables the lag check).
+func (h *Health) SetMaxLag(maxLag int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.maxLag = maxLag
+}
+
+// AddStoreCheck adds a liveness check that reads HealthCheckKey from a store used by the MessageProcessors.
+// Stores such as Map and Redis are not safe for concurrent use, so the check runs in RunLoop between batches
+// and waits for the current batch to be processed. The check is skipped while RunLoop is not running.
+func (h *Health) AddStoreCheck(name string, store Store) {
+	check := StoreHealthCheck(store)
+	h.AddCheck(name, func() error {
+		err := h.topicProcessor.runInLoop(check)
+		if err == ErrNotRunning {
+			return nil
+		}
+		return err
+	})
+}
+
+// StoreHealthCheck returns a check that reads HealthCheckKey from store. The check runs in the probe goroutine,
+// so the store must be safe for concurrent use or dedicated to the check (e.g. a separate Redis connection).
+// Use AddStoreCheck for the stores used by the MessageProcessors.
+func StoreHealthCheck(store Store) func() error {
+	return func() error {
+		_, err := store.Get(HealthCheckKey)
+		return err
+	}
+}
+
+// Live runs the liveness checks.
+func (h *Health) Live() HealthReport {
+	return h.run(false)
+}
+
+// Ready runs the liveness and readiness checks.
+func (h *Health) Ready() HealthReport {
+	return h.run(true)
+}
+
+func (h *Health) run(ready bool) HealthReport {
+	h.mutex.Lock()
+	checks := make(map[string]func() error, len(h.checks)+len(h.readyChecks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	if ready {
+		for name, check := range h.readyChecks {
+			checks[name] = check
+		}
+	}
+	h.mutex.Unlock()
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	report := HealthReport{true, make(map[string]string, len(checks))}
+	for _, name := range names {
+		if err := checks[name](); err != nil {
+			report.OK = false
+			report.Checks[name] = err.Error()
+		} else {
+			report.Checks[name] = "ok"
+		}
+	}
+	return report
+}
+
+func (h *Health) checkKafka() error {
+	client := h.topicProcessor.config.Client
+	if client.Closed() {
+		return errors.New("client is closed")
+	}
+	return client.RefreshMetadata(h.topicProcessor.inputTopics...)
+}
+
+func (h *Health) checkRunning() error {
+	if !h.topicProcessor.IsRunning() {
+		return errors.New("not running")
+	}
+	return nil
+}
+
+func (h *Health) checkLag() error {
+	h.mutex.Lock()
+	maxLag := h.maxLag
+	h.mutex.Unlock()
+	if maxLag <= 0 {
+		return nil
+	}
+	lag := totalMessagesBehindHighWaterMark(h.topicProcessor.Metrics())
+	if lag > maxLag {
+		return fmt.Errorf("%d messages behind high water mark (maximum %d)", lag, maxLag)
+	}
+	return nil
+}
+
+// ServeHTTP serves /healthz and /readyz.
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveHealth(w, r, h.Live, h.Ready)
+}
+
+func serveHealth(w http.ResponseWriter, r *http.Request, live, ready func() HealthReport) {
+	var report HealthReport
+	switch r.URL.Path {
+	case "/healthz":
+		report = live()
+	case "/readyz":
+		report = ready()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !report.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
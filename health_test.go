@@ -0,0 +1,80 @@
+package kasper
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (c *metadataClient) Closed() bool {
+	return false
+}
+
+func newTestHealth() (*Health, *TopicProcessor) {
+	config := &Config{Client: &metadataClient{}}
+	tp := &TopicProcessor{config: config, inputTopics: []string{"tweets"}, stats: config.stats()}
+	return NewHealth(tp), tp
+}
+
+func TestHealth(t *testing.T) {
+	health, tp := newTestHealth()
+	health.AddCheck("redis", StoreHealthCheck(NewMap(10)))
+	health.AddReadinessCheck("restored", func() error { return errors.New("restoring state") })
+	health.SetMaxLag(100)
+	tp.stats.setMessagesBehindHighWaterMark("tweets", 0, 150)
+
+	assert.Equal(t, HealthReport{true, map[string]string{"kafka": "ok", "redis": "ok"}}, health.Live())
+	assert.Equal(t, HealthReport{false, map[string]string{
+		"kafka":    "ok",
+		"redis":    "ok",
+		"running":  "not running",
+		"lag":      "150 messages behind high water mark (maximum 100)",
+		"restored": "restoring state",
+	}}, health.Ready())
+
+	tp.running = 1
+	tp.stats.setMessagesBehindHighWaterMark("tweets", 0, 50)
+	health.AddReadinessCheck("restored", func() error { return nil })
+	assert.True(t, health.Ready().OK)
+}
+
+func TestHealth_ServeHTTP(t *testing.T) {
+	health, _ := newTestHealth()
+
+	recorder := httptest.NewRecorder()
+	health.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"ok\":true,\"checks\":{\"kafka\":\"ok\"}}\n", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	health.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	health.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+type downStore struct {
+	Store
+}
+
+func (downStore) Get(key string) ([]byte, error) {
+	return nil, errors.New("store is down")
+}
+
+func TestHealth_AddStoreCheck(t *testing.T) {
+	tp := newFakeTopicProcessor(&Config{Client: &metadataClient{}}, &countingProcessor{})
+	health := NewHealth(tp.TopicProcessor)
+	health.AddStoreCheck("redis", downStore{NewMap(10)})
+	assert.Equal(t, "ok", health.Live().Checks["redis"])
+
+	done := tp.start()
+	assert.Equal(t, "store is down", health.Live().Checks["redis"])
+	tp.Close()
+	assert.Nil(t, <-done)
+}
@@ -0,0 +1,127 @@
+package kasper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// HTTPSink posts the results of a MessageProcessor to an HTTP endpoint, e.g. to push materialized updates to a SaaS
+// API. Values are JSON documents, posted in batches as a JSON array:
+//
+//	p.sink = kasper.NewHTTPSink(config, "crm", "https://crm.example.com/api/contacts/bulk")
+//	p.sink.DeadLetterTopic = "crm-dead-letters"
+//	...
+//	return p.sink.Send(contacts, sender)
+//
+// Batches that fail with a network error or a 408, 429 or 5xx status are retried with exponential backoff.
+// Batches that still fail, or fail with another status, are sent to DeadLetterTopic if it is set. Requests and dead
+// letters are counted in the http_sink_request_count and http_sink_dead_letter_count metrics.
+type HTTPSink struct {
+	// Maximum number of values posted in a single HTTP request
+	BatchSize int
+	// Number of times a failed batch is retried
+	MaxRetries int
+	// Time to wait before the first retry, doubled on each following retry
+	RetryBackoff time.Duration
+	// Used for all requests made to the endpoint
+	HTTPClient *http.Client
+	// Topic the values of the batches that cannot be posted are sent to, one message per value. If it is empty,
+	// Send returns an error instead.
+	DeadLetterTopic string
+
+	name              string
+	url               string
+	logger            Logger
+	requestCounter    Counter
+	deadLetterCounter Counter
+}
+
+// NewHTTPSink creates HTTPSink instances posting to url. The name is used as the value of the "sink" label.
+func NewHTTPSink(config *Config, name, url string) *HTTPSink {
+	metrics := config.metricsProvider()
+	return &HTTPSink{
+		BatchSize:    500,
+		MaxRetries:   3,
+		RetryBackoff: 500 * time.Millisecond,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+		name:         name,
+		url:          url,
+		logger:       WithFields(config.logger(), Field{"sink", name}),
+		requestCounter: metrics.NewCounter("http_sink_request_count",
+			"Number of HTTP requests made by the sink", "sink", "status"),
+		deadLetterCounter: metrics.NewCounter("http_sink_dead_letter_count",
+			"Number of values sent to the dead letter topic", "sink"),
+	}
+}
+
+// Send posts values in batches of at most BatchSize. Dead letters are sent with sender, so they are produced
+// with the output of the MessageProcessor.
+func (s *HTTPSink) Send(values [][]byte, sender Sender) error {
+	for start := 0; start < len(values); start += s.BatchSize {
+		end := start + s.BatchSize
+		if end > len(values) {
+			end = len(values)
+		}
+		err := s.postBatch(values[start:end])
+		if err == nil {
+			continue
+		}
+		if s.DeadLetterTopic == "" {
+			return err
+		}
+		s.logger.Errorf("Sending %d values to %s: %s", end-start, s.DeadLetterTopic, err)
+		for _, value := range values[start:end] {
+			sender.Send(&sarama.ProducerMessage{Topic: s.DeadLetterTopic, Value: sarama.ByteEncoder(value)})
+		}
+		s.deadLetterCounter.Add(float64(end-start), s.name)
+	}
+	return nil
+}
+
+func (s *HTTPSink) postBatch(values [][]byte) error {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	body.Write(bytes.Join(values, []byte{','}))
+	body.WriteByte(']')
+	backoff := s.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= s.MaxRetries; attempt++ {
+		if attempt > 0 {
+			s.logger.Infof("HTTP sink request failed, retrying in %s: %s", backoff, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var retryable bool
+		retryable, err = s.post(body.Bytes())
+		if err == nil || !retryable {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *HTTPSink) post(body []byte) (retryable bool, err error) {
+	response, err := s.HTTPClient.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		s.requestCounter.Inc(s.name, "error")
+		return true, err
+	}
+	defer response.Body.Close()
+	s.requestCounter.Inc(s.name, strconv.Itoa(response.StatusCode))
+	if response.StatusCode/100 == 2 {
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		return false, nil
+	}
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+	err = fmt.Errorf("HTTP sink request failed with status %d: %s", response.StatusCode, strings.TrimSpace(string(message)))
+	status := response.StatusCode
+	return status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests, err
+}
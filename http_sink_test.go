@@ -0,0 +1,80 @@
+package kasper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type httpSinkTestServer struct {
+	mutex    sync.Mutex
+	statuses []int
+	requests []string
+}
+
+func (s *httpSinkTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	s.requests = append(s.requests, r.Header.Get("Content-Type")+" "+string(body))
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestHTTPSink(handler http.Handler) (*HTTPSink, *httptest.Server, *recordingMetricsProvider) {
+	server := httptest.NewServer(handler)
+	provider := newRecordingMetricsProvider()
+	sink := NewHTTPSink(&Config{ContainerID: "c0", MetricsProvider: provider, Logger: &noopLogger{}}, "planets", server.URL)
+	sink.BatchSize = 2
+	sink.RetryBackoff = 0
+	return sink, server, provider
+}
+
+func TestHTTPSink_Send(t *testing.T) {
+	handler := &httpSinkTestServer{statuses: []int{http.StatusTooManyRequests}}
+	sink, server, provider := newTestHTTPSink(handler)
+	defer server.Close()
+
+	err := sink.Send([][]byte{[]byte(`{"name":"mars"}`), []byte(`{"name":"venus"}`), []byte(`"earth"`)}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		`application/json [{"name":"mars"},{"name":"venus"}]`,
+		`application/json [{"name":"mars"},{"name":"venus"}]`,
+		`application/json ["earth"]`,
+	}, handler.requests)
+	assert.Equal(t, 2.0, provider.values["http_sink_request_count{planets,200,c0,}"])
+	assert.Equal(t, 1.0, provider.values["http_sink_request_count{planets,429,c0,}"])
+}
+
+func TestHTTPSink_DeadLetters(t *testing.T) {
+	handler := &httpSinkTestServer{statuses: []int{http.StatusBadRequest, 500, 500, 500, 500}}
+	sink, server, provider := newTestHTTPSink(handler)
+	defer server.Close()
+
+	values := [][]byte{[]byte(`"mars"`), []byte(`"venus"`), []byte(`"earth"`)}
+	err := sink.Send(values, nil)
+	assert.EqualError(t, err, "HTTP sink request failed with status 400: ")
+	assert.Equal(t, 1, len(handler.requests))
+
+	tp := newFakeTopicProcessor(&Config{}, nil)
+	sender := newSender(tp.partitionProcessors[0])
+	sink.DeadLetterTopic = "dead-planets"
+	handler.statuses = []int{http.StatusBadRequest, 500, 500, 500, 500}
+	err = sink.Send(values, sender)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(handler.requests))
+	assert.Equal(t, 3, len(sender.producerMessages))
+	for i, message := range sender.producerMessages {
+		assert.Equal(t, "dead-planets", message.Topic)
+		value, _ := message.Value.Encode()
+		assert.Equal(t, values[i], value)
+	}
+	assert.Equal(t, 3.0, provider.values["http_sink_dead_letter_count{planets,c0,}"])
+}
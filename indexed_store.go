@@ -0,0 +1,121 @@
+package kasper
+
+import "fmt"
+
+// IndexFunc extracts the value of a secondary index from a stored value. It returns "" if the value is not
+// indexed.
+type IndexFunc func(value []byte) string
+
+// IndexedStore wraps a Store and maintains secondary index entries on Put and Delete, so that values can be
+// looked up by attribute with any backend:
+//
+//	users := kasper.NewIndexedStore(store, map[string]kasper.IndexFunc{
+//		"byEmail": func(value []byte) string { return decodeUser(value).Email },
+//	})
+//	user, err := users.GetByIndex("byEmail", "hari@trantor.gov")
+//
+// The entry of index value v in index name is stored at key "name:v", with the primary key as its value, so
+// primary keys must not look like index keys. Indexes are unique: the last value written wins.
+// Writes use Mutate, so index entries are updated atomically with the values if the store is transactional.
+// Otherwise, GetByIndex ignores index entries that are out of date.
+type IndexedStore struct {
+	store   Store
+	indexes map[string]IndexFunc
+}
+
+// NewIndexedStore creates IndexedStore instances.
+func NewIndexedStore(store Store, indexes map[string]IndexFunc) *IndexedStore {
+	return &IndexedStore{store, indexes}
+}
+
+func indexKey(name, value string) string {
+	return fmt.Sprintf("%s:%s", name, value)
+}
+
+// GetByIndex gets the value whose index name is equal to value. It returns nil if there is none.
+func (s *IndexedStore) GetByIndex(name, value string) ([]byte, error) {
+	index, found := s.indexes[name]
+	if !found {
+		return nil, fmt.Errorf("unknown index %s", name)
+	}
+	key, err := s.store.Get(indexKey(name, value))
+	if err != nil || key == nil {
+		return nil, err
+	}
+	stored, err := s.store.Get(string(key))
+	if err != nil || stored == nil || index(stored) != value {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// Get gets a value by key from the underlying store.
+func (s *IndexedStore) Get(key string) ([]byte, error) {
+	return s.store.Get(key)
+}
+
+// GetAll gets multiple values by key from the underlying store.
+func (s *IndexedStore) GetAll(keys []string) (map[string][]byte, error) {
+	return s.store.GetAll(keys)
+}
+
+// Put inserts or updates a value by key and its index entries in the underlying store.
+func (s *IndexedStore) Put(key string, value []byte) error {
+	return s.PutAll(map[string][]byte{key: value})
+}
+
+// PutAll inserts or updates multiple key-value pairs and their index entries in the underlying store.
+func (s *IndexedStore) PutAll(kvs map[string][]byte) error {
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	previous, err := s.store.GetAll(keys)
+	if err != nil {
+		return err
+	}
+	var puts []KeyValue
+	var deletes []string
+	for key, value := range kvs {
+		puts = append(puts, KeyValue{key, value})
+		for name, index := range s.indexes {
+			indexValue := index(value)
+			if old, found := previous[key]; found {
+				if oldValue := index(old); oldValue != "" && oldValue != indexValue {
+					deletes = append(deletes, indexKey(name, oldValue))
+				}
+			}
+			if indexValue != "" {
+				puts = append(puts, KeyValue{indexKey(name, indexValue), []byte(key)})
+			}
+		}
+	}
+	return Mutate(s.store, puts, deletes)
+}
+
+// Delete deletes a key and its index entries from the underlying store.
+func (s *IndexedStore) Delete(key string) error {
+	old, err := s.store.Get(key)
+	if err != nil {
+		return err
+	}
+	deletes := []string{key}
+	if old != nil {
+		for name, index := range s.indexes {
+			if oldValue := index(old); oldValue != "" {
+				deletes = append(deletes, indexKey(name, oldValue))
+			}
+		}
+	}
+	return Mutate(s.store, nil, deletes)
+}
+
+// Flush flushes the underlying store.
+func (s *IndexedStore) Flush() error {
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *IndexedStore) GetStore() Store {
+	return s.store
+}
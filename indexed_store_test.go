@@ -0,0 +1,44 @@
+package kasper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexedStore(t *testing.T) {
+	s := NewMap(10)
+	store := NewIndexedStore(s, map[string]IndexFunc{
+		"byInitial": func(value []byte) string { return strings.ToUpper(string(value[:1])) },
+	})
+
+	assert.Nil(t, store.Put("third", earth))
+	assert.Nil(t, store.PutAll(map[string][]byte{"fourth": mars, "fifth": jupiter}))
+	assert.Equal(t, []byte("third"), s.GetMap()["byInitial:E"])
+
+	value, err := store.GetByIndex("byInitial", "M")
+	assert.Nil(t, err)
+	assert.Equal(t, mars, value)
+	value, err = store.GetByIndex("byInitial", "S")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	_, err = store.GetByIndex("byName", "mars")
+	assert.EqualError(t, err, "unknown index byName")
+
+	assert.Nil(t, store.Put("fourth", saturn))
+	value, _ = store.GetByIndex("byInitial", "M")
+	assert.Nil(t, value)
+	value, _ = store.GetByIndex("byInitial", "S")
+	assert.Equal(t, saturn, value)
+
+	assert.Nil(t, store.Delete("third"))
+	value, _ = store.GetByIndex("byInitial", "E")
+	assert.Nil(t, value)
+	assert.Equal(t, map[string][]byte{
+		"fourth":      saturn,
+		"fifth":       jupiter,
+		"byInitial:S": []byte("fourth"),
+		"byInitial:J": []byte("fifth"),
+	}, s.GetMap())
+}
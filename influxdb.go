@@ -0,0 +1,317 @@
+package kasper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type influxDBSeries struct {
+	name  string
+	kind  string
+	tags  string
+	value float64
+	count int64
+	sum   float64
+	min   float64
+	max   float64
+	// Observations made since the points were last collected, which become the summary once the points are written
+	recentCount int64
+	recentMin   float64
+	recentMax   float64
+}
+
+type influxDBTag struct {
+	key   string
+	value string
+}
+
+type influxDBTagsByKey []influxDBTag
+
+func (tags influxDBTagsByKey) Len() int           { return len(tags) }
+func (tags influxDBTagsByKey) Swap(i, j int)      { tags[i], tags[j] = tags[j], tags[i] }
+func (tags influxDBTagsByKey) Less(i, j int) bool { return tags[i].key < tags[j].key }
+
+type influxDBMetric struct {
+	provider   *InfluxDB
+	name       string
+	kind       string
+	labelNames []string
+}
+
+func (metric *influxDBMetric) Inc(labelValues ...string) {
+	metric.Add(1, labelValues...)
+}
+
+func (metric *influxDBMetric) Add(value float64, labelValues ...string) {
+	metric.provider.update(metric, labelValues, func(series *influxDBSeries) {
+		series.value += value
+	})
+}
+
+func (metric *influxDBMetric) Set(value float64, labelValues ...string) {
+	metric.provider.update(metric, labelValues, func(series *influxDBSeries) {
+		series.value = value
+	})
+}
+
+func (metric *influxDBMetric) Observe(value float64, labelValues ...string) {
+	metric.provider.update(metric, labelValues, func(series *influxDBSeries) {
+		if series.count == 0 || value < series.min {
+			series.min = value
+		}
+		if series.count == 0 || value > series.max {
+			series.max = value
+		}
+		series.count++
+		series.sum += value
+		if series.recentCount == 0 || value < series.recentMin {
+			series.recentMin = value
+		}
+		if series.recentCount == 0 || value > series.recentMax {
+			series.recentMax = value
+		}
+		series.recentCount++
+	})
+}
+
+// InfluxDB is an implementation of MetricsProvider that writes metrics to InfluxDB using the line protocol.
+// Metric values are aggregated in memory and written in batches every time Push is called.
+// TopicProcessor calls Push on every Config.MetricsUpdateInterval tick.
+// Counters and gauges are written as a single "value" field.
+// Summaries are written as "count", "sum", "min" and "max" fields and are reset once they have been written.
+// See https://docs.influxdata.com/influxdb/v1.2/write_protocols/line_protocol_reference/
+type InfluxDB struct {
+	// Maximum number of points written in a single HTTP request, all points are written at once when not positive
+	BatchSize int
+	// Number of times a failed batch is retried before Push gives up
+	MaxRetries int
+	// Time to wait before the first retry, doubled on each following retry
+	RetryBackoff time.Duration
+	// Used for all requests made to InfluxDB
+	HTTPClient *http.Client
+
+	label    string
+	writeURL string
+	mutex    sync.Mutex
+	series   map[string]*influxDBSeries
+	ordering []string
+}
+
+// NewInfluxDB creates new InfluxDB instance.
+// serverURL is the base URL of the InfluxDB HTTP API (e.g. http://localhost:8086) and database is the name of
+// the database the points are written to.
+func NewInfluxDB(label string, serverURL string, database string) *InfluxDB {
+	query := url.Values{}
+	query.Set("db", database)
+	query.Set("precision", "ns")
+	return &InfluxDB{
+		BatchSize:    5000,
+		MaxRetries:   3,
+		RetryBackoff: 500 * time.Millisecond,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+		label:        label,
+		writeURL:     fmt.Sprintf("%s/write?%s", strings.TrimRight(serverURL, "/"), query.Encode()),
+		series:       make(map[string]*influxDBSeries),
+	}
+}
+
+// NewCounter creates a new InfluxDB counter
+func (provider *InfluxDB) NewCounter(name string, help string, labelNames ...string) Counter {
+	return &influxDBMetric{provider, name, "counter", labelNames}
+}
+
+// NewGauge creates a new InfluxDB gauge
+func (provider *InfluxDB) NewGauge(name string, help string, labelNames ...string) Gauge {
+	return &influxDBMetric{provider, name, "gauge", labelNames}
+}
+
+// NewSummary creates a new InfluxDB summary
+func (provider *InfluxDB) NewSummary(name string, help string, labelNames ...string) Summary {
+	return &influxDBMetric{provider, name, "summary", labelNames}
+}
+
+// Push writes the current value of all metrics to InfluxDB.
+// Points are sent in batches of at most BatchSize points. Batches that fail with a network error
+// or a 5xx status code are retried up to MaxRetries times with exponential backoff.
+// If any batch fails, Push returns a *MetricsPushError with the number of points dropped and the last error
+// encountered. The summaries written by the failed batches are kept until the next Push, while the ones
+// written by successful batches are reset so that they are never sent twice.
+// TopicProcessor calls Push from a separate goroutine so that slow or failed pushes do not delay processing.
+func (provider *InfluxDB) Push() error {
+	points := provider.collectPoints(time.Now())
+	batchSize := provider.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(points)
+	}
+	var lastErr error
+	dropped := 0
+	for start := 0; start < len(points); start += batchSize {
+		end := start + batchSize
+		if end > len(points) {
+			end = len(points)
+		}
+		err := provider.writeBatch(points[start:end])
+		if err != nil {
+			lastErr = err
+			dropped += end - start
+			continue
+		}
+		provider.resetSummaries(points[start:end])
+	}
+	if lastErr != nil {
+		return &MetricsPushError{dropped, lastErr}
+	}
+	return nil
+}
+
+func (provider *InfluxDB) update(metric *influxDBMetric, labelValues []string, fn func(*influxDBSeries)) {
+	key := metric.name + "\x00" + strings.Join(labelValues, "\x00")
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	series, found := provider.series[key]
+	if !found {
+		series = &influxDBSeries{
+			name: metric.name,
+			kind: metric.kind,
+			tags: formatInfluxDBTags(metric.labelNames, labelValues, provider.label),
+		}
+		provider.series[key] = series
+		provider.ordering = append(provider.ordering, key)
+	}
+	fn(series)
+}
+
+// influxDBPoint is a line written to InfluxDB, with the summary values it contains
+type influxDBPoint struct {
+	key   string
+	line  string
+	count int64
+	sum   float64
+}
+
+func (provider *InfluxDB) collectPoints(now time.Time) []influxDBPoint {
+	timestamp := strconv.FormatInt(now.UnixNano(), 10)
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	points := make([]influxDBPoint, 0, len(provider.ordering))
+	for _, key := range provider.ordering {
+		series := provider.series[key]
+		var fields string
+		if series.kind == "summary" {
+			if series.count == 0 {
+				continue
+			}
+			fields = fmt.Sprintf("count=%di,sum=%s,min=%s,max=%s",
+				series.count,
+				formatInfluxDBFloat(series.sum),
+				formatInfluxDBFloat(series.min),
+				formatInfluxDBFloat(series.max),
+			)
+			series.recentCount = 0
+		} else {
+			fields = "value=" + formatInfluxDBFloat(series.value)
+		}
+		line := fmt.Sprintf("%s%s %s %s", escapeInfluxDBMeasurement("kasper_"+series.name), series.tags, fields, timestamp)
+		points = append(points, influxDBPoint{key, line, series.count, series.sum})
+	}
+	return points
+}
+
+// resetSummaries removes the written observations from the summaries, keeping the ones made since the points
+// were collected, whose minimum and maximum become those of the summaries.
+func (provider *InfluxDB) resetSummaries(points []influxDBPoint) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	for _, point := range points {
+		series := provider.series[point.key]
+		if series.kind == "summary" {
+			series.count -= point.count
+			series.sum -= point.sum
+			series.min = series.recentMin
+			series.max = series.recentMax
+		}
+	}
+}
+
+func (provider *InfluxDB) writeBatch(points []influxDBPoint) error {
+	lines := make([]string, len(points))
+	for i, point := range points {
+		lines[i] = point.line
+	}
+	body := []byte(strings.Join(lines, "\n"))
+	backoff := provider.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= provider.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var retryable bool
+		retryable, err = provider.post(body)
+		if err == nil || !retryable {
+			return err
+		}
+	}
+	return err
+}
+
+func (provider *InfluxDB) post(body []byte) (retryable bool, err error) {
+	response, err := provider.HTTPClient.Post(provider.writeURL, "text/plain; charset=utf-8", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 == 2 {
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		return false, nil
+	}
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+	err = fmt.Errorf("InfluxDB write failed with status %d: %s", response.StatusCode, strings.TrimSpace(string(message)))
+	return response.StatusCode >= 500, err
+}
+
+func formatInfluxDBTags(labelNames []string, labelValues []string, label string) string {
+	tags := make([]influxDBTag, 0, len(labelNames)+1)
+	for i, name := range labelNames {
+		if i < len(labelValues) && labelValues[i] != "" {
+			tags = append(tags, influxDBTag{name, labelValues[i]})
+		}
+	}
+	if label != "" {
+		tags = append(tags, influxDBTag{"label", label})
+	}
+	sort.Sort(influxDBTagsByKey(tags))
+	var buffer bytes.Buffer
+	for _, tag := range tags {
+		buffer.WriteByte(',')
+		buffer.WriteString(escapeInfluxDBKey(tag.key))
+		buffer.WriteByte('=')
+		buffer.WriteString(escapeInfluxDBKey(tag.value))
+	}
+	return buffer.String()
+}
+
+var influxDBMeasurementEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, " ", `\ `)
+
+var influxDBKeyEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `)
+
+func escapeInfluxDBMeasurement(s string) string {
+	return influxDBMeasurementEscaper.Replace(s)
+}
+
+func escapeInfluxDBKey(s string) string {
+	return influxDBKeyEscaper.Replace(s)
+}
+
+func formatInfluxDBFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,211 @@
+package kasper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type influxDBTestServer struct {
+	mutex    sync.Mutex
+	failures int
+	requests []string
+	queries  []string
+}
+
+func (s *influxDBTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	s.queries = append(s.queries, r.URL.RawQuery)
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	s.requests = append(s.requests, string(body))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTestInfluxDB(handler http.Handler) (*InfluxDB, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	provider := NewInfluxDB("test", server.URL, "kasper")
+	provider.RetryBackoff = 0
+	return provider, server
+}
+
+func TestInfluxDB_Push(t *testing.T) {
+	handler := &influxDBTestServer{}
+	provider, server := newTestInfluxDB(handler)
+	defer server.Close()
+
+	counter := provider.NewCounter("test_counter", "A test counter", "topic", "partition")
+	gauge := provider.NewGauge("test_gauge", "A test gauge", "topic", "partition")
+	summary := provider.NewSummary("test_summary", "A test summary", "topic", "partition")
+
+	counter.Inc("words", "0")
+	counter.Add(2, "words", "0")
+	gauge.Set(42, "words", "1")
+	summary.Observe(3, "words", "0")
+	summary.Observe(5, "words", "0")
+
+	err := provider.Push()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"db=kasper&precision=ns"}, handler.queries)
+	assert.Equal(t, 1, len(handler.requests))
+	lines := strings.Split(handler.requests[0], "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "kasper_test_counter,label=test,partition=0,topic=words value=3 "))
+	assert.True(t, strings.HasPrefix(lines[1], "kasper_test_gauge,label=test,partition=1,topic=words value=42 "))
+	assert.True(t, strings.HasPrefix(lines[2], "kasper_test_summary,label=test,partition=0,topic=words count=2i,sum=8,min=3,max=5 "))
+
+	// Summaries are reset after a successful push, counters and gauges are not
+	err = provider.Push()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(strings.Split(handler.requests[1], "\n")))
+}
+
+func TestInfluxDB_Push_SummaryInterval(t *testing.T) {
+	handler := &influxDBTestServer{}
+	provider, server := newTestInfluxDB(handler)
+	defer server.Close()
+	summary := provider.NewSummary("test_summary", "A test summary")
+
+	summary.Observe(1)
+	summary.Observe(100)
+	assert.Nil(t, provider.Push())
+	summary.Observe(5)
+	assert.Nil(t, provider.Push())
+	assert.True(t, strings.HasPrefix(handler.requests[1], "kasper_test_summary,label=test count=1i,sum=5,min=5,max=5 "))
+
+	// Observations made while the points are written belong to the next interval
+	summary.Observe(7)
+	points := provider.collectPoints(time.Now())
+	summary.Observe(2)
+	summary.Observe(3)
+	provider.resetSummaries(points)
+	assert.Nil(t, provider.Push())
+	assert.True(t, strings.HasPrefix(handler.requests[2], "kasper_test_summary,label=test count=2i,sum=5,min=2,max=3 "))
+}
+
+func TestInfluxDB_Push_Batches(t *testing.T) {
+	handler := &influxDBTestServer{}
+	provider, server := newTestInfluxDB(handler)
+	defer server.Close()
+	provider.BatchSize = 2
+
+	gauge := provider.NewGauge("test_gauge", "A test gauge", "partition")
+	for _, partition := range []string{"0", "1", "2", "3", "4"} {
+		gauge.Set(1, partition)
+	}
+
+	err := provider.Push()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(handler.requests))
+}
+
+func TestInfluxDB_Push_NoBatchSize(t *testing.T) {
+	handler := &influxDBTestServer{}
+	provider, server := newTestInfluxDB(handler)
+	defer server.Close()
+	provider.BatchSize = 0
+
+	gauge := provider.NewGauge("test_gauge", "A test gauge", "partition")
+	for _, partition := range []string{"0", "1", "2"} {
+		gauge.Set(1, partition)
+	}
+
+	err := provider.Push()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(handler.requests))
+}
+
+func TestInfluxDB_Push_Retry(t *testing.T) {
+	handler := &influxDBTestServer{failures: 2}
+	provider, server := newTestInfluxDB(handler)
+	defer server.Close()
+
+	provider.NewCounter("test_counter", "A test counter").Inc()
+
+	err := provider.Push()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(handler.queries))
+	assert.Equal(t, 1, len(handler.requests))
+}
+
+func TestInfluxDB_Push_RetriesExhausted(t *testing.T) {
+	handler := &influxDBTestServer{failures: 10}
+	provider, server := newTestInfluxDB(handler)
+	defer server.Close()
+	provider.MaxRetries = 1
+
+	provider.NewSummary("test_summary", "A test summary").Observe(1)
+
+	err := provider.Push()
+	pushErr, ok := err.(*MetricsPushError)
+	assert.True(t, ok)
+	assert.Equal(t, 1, pushErr.Dropped)
+	assert.Equal(t, 2, len(handler.queries))
+
+	// Summary observations are kept until they are successfully written
+	handler.failures = 0
+	err = provider.Push()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(handler.requests[0], "kasper_test_summary,label=test count=1i"))
+}
+
+func TestInfluxDB_Push_Escaping(t *testing.T) {
+	handler := &influxDBTestServer{}
+	provider, server := newTestInfluxDB(handler)
+	defer server.Close()
+
+	provider.NewGauge("test_gauge", "A test gauge", "topic").Set(1, `a topic,with=chars\`)
+
+	err := provider.Push()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(handler.requests[0], `kasper_test_gauge,label=test,topic=a\ topic\,with\=chars\\ value=1 `))
+}
+
+type failingBatchServer struct {
+	influxDBTestServer
+}
+
+func (s *failingBatchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if strings.Contains(string(body), "second") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, string(body))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestInfluxDB_Push_PartialFailure(t *testing.T) {
+	handler := &failingBatchServer{}
+	provider, server := newTestInfluxDB(handler)
+	defer server.Close()
+	provider.BatchSize = 1
+
+	provider.NewSummary("first", "A summary").Observe(1)
+	provider.NewSummary("second", "A summary").Observe(2)
+
+	err := provider.Push()
+	assert.Equal(t, 1, err.(*MetricsPushError).Dropped)
+	assert.Equal(t, 1, len(handler.requests))
+
+	// Only the summary of the failed batch is written again
+	provider.Push()
+	assert.Equal(t, 1, len(handler.requests))
+	provider.NewSummary("first", "A summary").Observe(3)
+	provider.Push()
+	assert.Equal(t, 2, len(handler.requests))
+	assert.True(t, strings.HasPrefix(handler.requests[1], "kasper_first,label=test count=1i,sum=3,min=3,max=3 "))
+}
@@ -0,0 +1,152 @@
+package kasper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// InputSource is an input other than Kafka, such as a Redis stream, which an InputLoop feeds to a MessageProcessor.
+// Its messages are presented as sarama.ConsumerMessages, so that the same MessageProcessors, stores and serdes can be
+// used with Kafka and other inputs. Sources that have several independent inputs (e.g. MQTT topics) can map them to
+// pseudo-partitions with ConsumerMessage.Partition.
+type InputSource interface {
+	// Read returns up to max messages. If none is available, it waits for up to wait and may return no messages.
+	Read(max int, wait time.Duration) ([]*sarama.ConsumerMessage, error)
+	// Ack acknowledges messages returned by Read after they are processed and their output is produced.
+	// Messages that are not acknowledged are read again after a restart.
+	Ack(messages []*sarama.ConsumerMessage) error
+	// Close releases the resources of the source.
+	Close() error
+}
+
+// InputLoop reads batches of up to Config.BatchSize messages from an InputSource, waiting for up to
+// Config.BatchWaitDuration, and processes them like a TopicProcessor processes a partition: it calls
+// MessageProcessor.Process, produces the messages sent to the Sender to Kafka, flushes Config.Stores and then
+// acknowledges the messages. Messages are processed at least once.
+type InputLoop struct {
+	config    *Config
+	source    InputSource
+	processor MessageProcessor
+	producer  sarama.SyncProducer
+	logger    Logger
+	close     chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+
+	incomingMessageCount Counter
+	outgoingMessageCount Counter
+}
+
+// NewInputLoop creates an InputLoop. Output messages are produced with a producer created from config.Client.
+func NewInputLoop(config *Config, source InputSource, processor MessageProcessor) (*InputLoop, error) {
+	config.setDefaults()
+	producer, err := sarama.NewSyncProducerFromClient(config.Client)
+	if err != nil {
+		return nil, err
+	}
+	return newInputLoop(config, producer, source, processor), nil
+}
+
+func newInputLoop(config *Config, producer sarama.SyncProducer, source InputSource, processor MessageProcessor) *InputLoop {
+	metrics := config.metricsProvider()
+	return &InputLoop{
+		config,
+		source,
+		processor,
+		producer,
+		config.logger(),
+		make(chan struct{}),
+		sync.Once{},
+		make(chan struct{}),
+		metrics.NewCounter("input_incoming_message_count", "Number of messages read from the input source"),
+		metrics.NewCounter("input_outgoing_message_count", "Number of messages produced by the input loop"),
+	}
+}
+
+// Run processes messages until Close is called or an error occurs, then closes the source and the producer.
+// It returns the first error reading, processing or acknowledging messages.
+func (l *InputLoop) Run() (err error) {
+	defer close(l.done)
+	defer func() {
+		if closeErr := l.source.Close(); err == nil {
+			err = closeErr
+		}
+		if closeErr := l.producer.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	l.logger.Info("Entering input loop")
+	for {
+		select {
+		case <-l.close:
+			return nil
+		default:
+		}
+		messages, err := l.source.Read(l.config.BatchSize, l.config.BatchWaitDuration)
+		if err != nil {
+			l.logger.Errorf("Failed to read input: %s", err)
+			return err
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		if err := l.process(messages); err != nil {
+			return err
+		}
+	}
+}
+
+func (l *InputLoop) process(messages []*sarama.ConsumerMessage) error {
+	l.incomingMessageCount.Add(float64(len(messages)))
+	sender := &inputSender{l.producer, nil, 0}
+	if err := l.processor.Process(messages, sender); err != nil {
+		l.logger.Errorf("Message processor returned error: %s", err)
+		return err
+	}
+	if err := sender.Flush(); err != nil {
+		l.logger.Errorf("Failed to produce messages: %s", err)
+		return err
+	}
+	l.outgoingMessageCount.Add(float64(sender.sent))
+	if err := l.config.flushStores(); err != nil {
+		l.logger.Errorf("Failed to flush stores: %s", err)
+		return err
+	}
+	if err := l.source.Ack(messages); err != nil {
+		l.logger.Errorf("Failed to acknowledge messages: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Close stops Run after the batch being processed and waits for it to return. It must only be called after Run was
+// started.
+func (l *InputLoop) Close() {
+	l.closeOnce.Do(func() { close(l.close) })
+	<-l.done
+}
+
+// inputSender is the Sender given to the MessageProcessor of an InputLoop.
+type inputSender struct {
+	producer         sarama.SyncProducer
+	producerMessages []*sarama.ProducerMessage
+	sent             int
+}
+
+func (sender *inputSender) Send(msg *sarama.ProducerMessage) {
+	sender.producerMessages = append(sender.producerMessages, msg)
+}
+
+func (sender *inputSender) Flush() error {
+	if len(sender.producerMessages) == 0 {
+		return nil
+	}
+	if err := sender.producer.SendMessages(sender.producerMessages); err != nil {
+		return err
+	}
+	sender.sent += len(sender.producerMessages)
+	sender.producerMessages = nil
+	return nil
+}
@@ -0,0 +1,91 @@
+package kasper
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// sliceSource is an InputSource returning the messages of a slice
+type sliceSource struct {
+	mutex    sync.Mutex
+	messages []*sarama.ConsumerMessage
+	acked    []*sarama.ConsumerMessage
+	closed   bool
+}
+
+func (s *sliceSource) Read(max int, wait time.Duration) ([]*sarama.ConsumerMessage, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.messages) == 0 {
+		return nil, nil
+	}
+	if max > len(s.messages) {
+		max = len(s.messages)
+	}
+	messages := s.messages[:max]
+	s.messages = s.messages[max:]
+	return messages, nil
+}
+
+func (s *sliceSource) Ack(messages []*sarama.ConsumerMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.acked = append(s.acked, messages...)
+	return nil
+}
+
+func (s *sliceSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *sliceSource) ackedCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.acked)
+}
+
+func TestInputLoop(t *testing.T) {
+	source := &sliceSource{}
+	for _, planet := range []string{"mercury", "venus", "earth", "pluto"} {
+		source.messages = append(source.messages, &sarama.ConsumerMessage{Topic: "planets", Value: []byte(planet)})
+	}
+	var batches []int
+	processor := processorFunc(func(messages []*sarama.ConsumerMessage, sender Sender) error {
+		batches = append(batches, len(messages))
+		for _, message := range messages {
+			if string(message.Value) == "pluto" {
+				return errors.New("not a planet")
+			}
+			sender.Send(&sarama.ProducerMessage{Topic: "output", Value: sarama.ByteEncoder(message.Value)})
+		}
+		return nil
+	})
+	producer := &recordingSyncProducer{}
+	config := &Config{BatchSize: 3, Logger: &noopLogger{}}
+	loop := newInputLoop(config, producer, source, processor)
+	assert.EqualError(t, loop.Run(), "not a planet")
+	assert.Equal(t, []int{3, 1}, batches)
+	assert.Equal(t, 3, len(producer.messages))
+	assert.Equal(t, 3, len(source.acked))
+	assert.True(t, source.closed)
+}
+
+func TestInputLoop_Close(t *testing.T) {
+	source := &sliceSource{messages: []*sarama.ConsumerMessage{{Topic: "planets", Value: []byte("mars")}}}
+	processor := processorFunc(func(messages []*sarama.ConsumerMessage, sender Sender) error {
+		return nil
+	})
+	loop := newInputLoop(&Config{BatchSize: 10, Logger: &noopLogger{}}, &recordingSyncProducer{}, source, processor)
+	done := make(chan error)
+	go func() { done <- loop.Run() }()
+	waitFor(t, func() bool { return source.ackedCount() == 1 })
+	loop.Close()
+	assert.Nil(t, <-done)
+	assert.True(t, source.closed)
+}
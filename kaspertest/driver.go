@@ -0,0 +1,216 @@
+package kaspertest
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+)
+
+// Driver runs MessageProcessors without Kafka, in the spirit of Kafka Streams' TopologyTestDriver.
+// Messages piped into the Driver are passed to the MessageProcessor of their partition, and messages sent to the
+// Sender are captured and can be inspected with Output. Stores created with Driver.Store are shared across calls
+// and can be passed to the MessageProcessors, which makes it easy to inspect their contents after processing.
+// Partition assignments and revocations can be simulated with Assign, Revoke and Rebalance to test
+// MessageProcessors implementing kasper.PartitionListener.
+//
+//	driver := kaspertest.NewDriver(map[int]kasper.MessageProcessor{0: &WordCount{store}})
+//	err := driver.Pipe("words", 0, nil, []byte("hello world"))
+//	output := driver.OutputForTopic("word-counts")
+type Driver struct {
+	messageProcessors map[int]kasper.MessageProcessor
+	offsets           map[string]map[int32]int64
+	output            []*sarama.ProducerMessage
+	stores            map[string]*InMemoryStore
+	now               time.Time
+	assigned          map[int]bool
+	revoked           map[int]bool
+}
+
+// NewDriver creates a new Driver. messageProcessors is the same map that would be passed to
+// kasper.NewTopicProcessor.
+func NewDriver(messageProcessors map[int]kasper.MessageProcessor) *Driver {
+	return &Driver{
+		messageProcessors,
+		make(map[string]map[int32]int64),
+		nil,
+		make(map[string]*InMemoryStore),
+		time.Unix(0, 0).UTC(),
+		make(map[int]bool),
+		make(map[int]bool),
+	}
+}
+
+// Store returns the InMemoryStore with the given name, creating it on first use.
+func (d *Driver) Store(name string) *InMemoryStore {
+	store, found := d.stores[name]
+	if !found {
+		store = NewInMemoryStore()
+		d.stores[name] = store
+	}
+	return store
+}
+
+// Pipe processes a single message on the given topic and partition.
+func (d *Driver) Pipe(topic string, partition int, key []byte, value []byte) error {
+	return d.PipeMessages([]*sarama.ConsumerMessage{{
+		Topic:     topic,
+		Partition: int32(partition),
+		Key:       key,
+		Value:     value,
+	}})
+}
+
+// PipeMessages processes messages as a single batch per partition, in the order in which the partitions first
+// appear in messages. Partitions are assigned on their first message.
+// If any message is for a partition without MessageProcessor or for a revoked partition, an error is returned
+// before anything is processed and no offsets are assigned.
+// Otherwise, offsets are assigned sequentially per topic and partition to all messages, and timestamps are set to a
+// deterministic clock advancing by one millisecond per message unless they are already set.
+// If a MessageProcessor returns an error, the error is returned immediately: the messages sent for the partitions
+// processed before are kept in Output, the messages sent by the failing MessageProcessor are discarded, and the
+// remaining partitions are not processed.
+func (d *Driver) PipeMessages(messages []*sarama.ConsumerMessage) error {
+	for _, message := range messages {
+		partition := int(message.Partition)
+		if _, found := d.messageProcessors[partition]; !found {
+			return fmt.Errorf("no MessageProcessor for partition %d", partition)
+		}
+		if d.revoked[partition] {
+			return fmt.Errorf("partition %d is revoked", partition)
+		}
+	}
+	batches := make(map[int][]*sarama.ConsumerMessage)
+	var partitions []int
+	for _, message := range messages {
+		partition := int(message.Partition)
+		if _, found := batches[partition]; !found {
+			partitions = append(partitions, partition)
+		}
+		d.assignOffset(message)
+		batches[partition] = append(batches[partition], message)
+	}
+	for _, partition := range partitions {
+		if !d.assigned[partition] {
+			err := d.Assign(partition)
+			if err != nil {
+				return err
+			}
+		}
+		sender := NewRecordingSender()
+		err := d.messageProcessors[partition].Process(batches[partition], sender)
+		if err != nil {
+			return err
+		}
+		d.output = append(d.output, sender.Messages()...)
+	}
+	return nil
+}
+
+func (d *Driver) assignOffset(message *sarama.ConsumerMessage) {
+	offsets, found := d.offsets[message.Topic]
+	if !found {
+		offsets = make(map[int32]int64)
+		d.offsets[message.Topic] = offsets
+	}
+	message.Offset = offsets[message.Partition]
+	offsets[message.Partition]++
+	d.now = d.now.Add(time.Millisecond)
+	if message.Timestamp.IsZero() {
+		message.Timestamp = d.now
+	}
+}
+
+// Assign simulates the assignment of partitions, calling PartitionAssigned on MessageProcessors that implement
+// kasper.PartitionListener. Partitions that are already assigned are skipped.
+func (d *Driver) Assign(partitions ...int) error {
+	for _, partition := range partitions {
+		messageProcessor, found := d.messageProcessors[partition]
+		if !found {
+			return fmt.Errorf("no MessageProcessor for partition %d", partition)
+		}
+		if d.assigned[partition] {
+			continue
+		}
+		if listener, ok := messageProcessor.(kasper.PartitionListener); ok {
+			err := listener.PartitionAssigned(partition)
+			if err != nil {
+				return err
+			}
+		}
+		d.assigned[partition] = true
+		delete(d.revoked, partition)
+	}
+	return nil
+}
+
+// Revoke simulates the revocation of partitions, calling PartitionRevoked on MessageProcessors that implement
+// kasper.PartitionListener. Messages piped to revoked partitions are rejected until they are assigned again.
+// Partitions that are not assigned are skipped.
+func (d *Driver) Revoke(partitions ...int) error {
+	for _, partition := range partitions {
+		if !d.assigned[partition] {
+			continue
+		}
+		delete(d.assigned, partition)
+		d.revoked[partition] = true
+		if listener, ok := d.messageProcessors[partition].(kasper.PartitionListener); ok {
+			err := listener.PartitionRevoked(partition)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Rebalance simulates a consumer group rebalance: assigned partitions that are not in partitions are revoked,
+// then the partitions that are not assigned yet are assigned.
+func (d *Driver) Rebalance(partitions ...int) error {
+	keep := make(map[int]bool, len(partitions))
+	for _, partition := range partitions {
+		keep[partition] = true
+	}
+	for _, partition := range d.Assigned() {
+		if !keep[partition] {
+			err := d.Revoke(partition)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return d.Assign(partitions...)
+}
+
+// Assigned returns the sorted list of assigned partitions.
+func (d *Driver) Assigned() []int {
+	partitions := make([]int, 0, len(d.assigned))
+	for partition := range d.assigned {
+		partitions = append(partitions, partition)
+	}
+	sort.Ints(partitions)
+	return partitions
+}
+
+// Output returns all messages sent so far, in order.
+func (d *Driver) Output() []*sarama.ProducerMessage {
+	return append([]*sarama.ProducerMessage{}, d.output...)
+}
+
+// OutputForTopic returns the messages sent so far to the given topic, in order.
+func (d *Driver) OutputForTopic(topic string) []*sarama.ProducerMessage {
+	var messages []*sarama.ProducerMessage
+	for _, message := range d.output {
+		if message.Topic == topic {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// ClearOutput discards all messages sent so far.
+func (d *Driver) ClearOutput() {
+	d.output = nil
+}
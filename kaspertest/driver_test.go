@@ -0,0 +1,138 @@
+package kaspertest
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+	"github.com/stretchr/testify/assert"
+)
+
+type wordCountProcessor struct {
+	store kasper.Store
+}
+
+func (processor *wordCountProcessor) Process(messages []*sarama.ConsumerMessage, sender kasper.Sender) error {
+	for _, message := range messages {
+		for _, word := range strings.Split(string(message.Value), " ") {
+			if word == "error" {
+				return errors.New("error")
+			}
+			data, err := processor.store.Get(word)
+			if err != nil {
+				return err
+			}
+			count, _ := strconv.Atoi(string(data))
+			count++
+			err = processor.store.Put(word, []byte(strconv.Itoa(count)))
+			if err != nil {
+				return err
+			}
+			sender.Send(&sarama.ProducerMessage{
+				Topic: "word-counts",
+				Key:   sarama.StringEncoder(word),
+				Value: sarama.StringEncoder(strconv.Itoa(count)),
+			})
+		}
+	}
+	return nil
+}
+
+func TestDriver(t *testing.T) {
+	messageProcessors := map[int]kasper.MessageProcessor{}
+	driver := NewDriver(messageProcessors)
+	messageProcessors[0] = &wordCountProcessor{driver.Store("counts")}
+
+	assert.Nil(t, driver.Pipe("words", 0, nil, []byte("hello world")))
+	messages := []*sarama.ConsumerMessage{
+		{Topic: "words", Partition: 0, Value: []byte("hello")},
+	}
+	assert.Nil(t, driver.PipeMessages(messages))
+	assert.Equal(t, int64(1), messages[0].Offset)
+	assert.False(t, messages[0].Timestamp.IsZero())
+
+	output := driver.OutputForTopic("word-counts")
+	assert.Equal(t, 3, len(output))
+	assert.Equal(t, sarama.StringEncoder("hello"), output[2].Key)
+	assert.Equal(t, sarama.StringEncoder("2"), output[2].Value)
+	assert.Equal(t, map[string][]byte{"hello": []byte("2"), "world": []byte("1")}, driver.Store("counts").Data())
+
+	assert.NotNil(t, driver.Pipe("words", 0, nil, []byte("world error")))
+	assert.Equal(t, 3, len(driver.Output()))
+	assert.NotNil(t, driver.Pipe("words", 1, nil, []byte("hello")))
+
+	driver.ClearOutput()
+	assert.Equal(t, 0, len(driver.Output()))
+}
+
+func TestDriver_PipeMessagesErrors(t *testing.T) {
+	messageProcessors := map[int]kasper.MessageProcessor{}
+	driver := NewDriver(messageProcessors)
+	messageProcessors[0] = &wordCountProcessor{driver.Store("counts-0")}
+	messageProcessors[1] = &wordCountProcessor{driver.Store("counts-1")}
+
+	// Nothing is processed and no offsets are assigned if a partition is unknown
+	messages := []*sarama.ConsumerMessage{
+		{Topic: "words", Partition: 0, Value: []byte("hello")},
+		{Topic: "words", Partition: 2, Value: []byte("hello")},
+	}
+	assert.EqualError(t, driver.PipeMessages(messages), "no MessageProcessor for partition 2")
+	assert.Empty(t, driver.Output())
+	assert.Empty(t, driver.Assigned())
+
+	// The output of the partitions processed before the failing one is kept
+	messages = []*sarama.ConsumerMessage{
+		{Topic: "words", Partition: 0, Value: []byte("hello")},
+		{Topic: "words", Partition: 1, Value: []byte("world error")},
+	}
+	assert.EqualError(t, driver.PipeMessages(messages), "error")
+	assert.Equal(t, int64(0), messages[0].Offset)
+	assert.Equal(t, 1, len(driver.Output()))
+	assert.Equal(t, sarama.StringEncoder("hello"), driver.Output()[0].Key)
+}
+
+type listeningProcessor struct {
+	events []string
+}
+
+func (processor *listeningProcessor) Process(messages []*sarama.ConsumerMessage, sender kasper.Sender) error {
+	for _, message := range messages {
+		processor.events = append(processor.events, "process "+strconv.Itoa(int(message.Partition)))
+	}
+	return nil
+}
+
+func (processor *listeningProcessor) PartitionAssigned(partition int) error {
+	processor.events = append(processor.events, "assigned "+strconv.Itoa(partition))
+	return nil
+}
+
+func (processor *listeningProcessor) PartitionRevoked(partition int) error {
+	processor.events = append(processor.events, "revoked "+strconv.Itoa(partition))
+	return nil
+}
+
+func TestDriver_Rebalance(t *testing.T) {
+	processor := &listeningProcessor{}
+	driver := NewDriver(map[int]kasper.MessageProcessor{0: processor, 1: processor, 2: processor})
+
+	assert.Nil(t, driver.Pipe("words", 0, nil, []byte("hello")))
+	assert.Nil(t, driver.Assign(1))
+	assert.Nil(t, driver.Rebalance(1, 2))
+	assert.Equal(t, []int{1, 2}, driver.Assigned())
+	assert.NotNil(t, driver.Pipe("words", 0, nil, []byte("hello")))
+	assert.Nil(t, driver.Pipe("words", 2, nil, []byte("hello")))
+	assert.NotNil(t, driver.Assign(3))
+
+	assert.Equal(t, []string{
+		"assigned 0",
+		"process 0",
+		"assigned 1",
+		"revoked 0",
+		"assigned 2",
+		"process 2",
+	}, processor.events)
+}
@@ -0,0 +1,86 @@
+package kaspertest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/movio/kasper"
+)
+
+// FakeClock is an implementation of kasper.Clock whose time only moves when Advance is called.
+// Set it as kasper.Config.Clock to test time-dependent logic without sleeping.
+// FakeClock is safe for concurrent use.
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock creates a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// NewTicker creates a Ticker that ticks every time the clock is advanced past its next tick.
+// Like time.Ticker, the channel has a buffer of one tick and ticks are dropped for slow receivers.
+func (c *FakeClock) NewTicker(d time.Duration) kasper.Ticker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ticker := &fakeTicker{
+		clock:    c,
+		c:        make(chan time.Time, 1),
+		period:   d,
+		nextTick: c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, ticker)
+	return ticker
+}
+
+// Advance moves the clock forward by d and fires the tickers that are due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	for _, ticker := range c.tickers {
+		for !ticker.nextTick.After(c.now) {
+			select {
+			case ticker.c <- ticker.nextTick:
+			default:
+			}
+			ticker.nextTick = ticker.nextTick.Add(ticker.period)
+		}
+	}
+}
+
+func (c *FakeClock) removeTicker(ticker *fakeTicker) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, t := range c.tickers {
+		if t == ticker {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock    *FakeClock
+	c        chan time.Time
+	period   time.Duration
+	nextTick time.Time
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.removeTicker(t)
+}
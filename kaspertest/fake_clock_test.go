@@ -0,0 +1,38 @@
+package kaspertest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	ticker := clock.NewTicker(10 * time.Second)
+
+	clock.Advance(9 * time.Second)
+	assert.Equal(t, start.Add(9*time.Second), clock.Now())
+	select {
+	case <-ticker.Chan():
+		t.Fatal("unexpected tick")
+	default:
+	}
+
+	clock.Advance(25 * time.Second)
+	assert.Equal(t, start.Add(10*time.Second), <-ticker.Chan())
+	select {
+	case <-ticker.Chan():
+		t.Fatal("ticks should be dropped when the channel is full")
+	default:
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Minute)
+	select {
+	case <-ticker.Chan():
+		t.Fatal("unexpected tick after Stop")
+	default:
+	}
+}
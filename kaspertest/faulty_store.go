@@ -0,0 +1,138 @@
+package kaspertest
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/movio/kasper"
+)
+
+// ErrInjectedFault is returned by FaultyStore for calls failed according to Faults.ErrorRate.
+var ErrInjectedFault = errors.New("kaspertest: injected fault")
+
+// ErrInjectedTimeout is returned by FaultyStore for calls timed out according to Faults.TimeoutRate.
+var ErrInjectedTimeout = errors.New("kaspertest: injected timeout")
+
+// Faults describes the degradation injected by a FaultyStore.
+type Faults struct {
+	// Latency added to every call
+	Latency time.Duration
+	// Maximum random latency added to every call on top of Latency
+	Jitter time.Duration
+	// Probability (between 0 and 1) for a call to fail with ErrInjectedFault
+	ErrorRate float64
+	// Probability (between 0 and 1) for a call to block for Timeout and then fail with ErrInjectedTimeout
+	TimeoutRate float64
+	// Duration of injected timeouts
+	Timeout time.Duration
+	// Seed of the random number generator, so that runs are reproducible
+	Seed int64
+}
+
+// FaultyStore wraps a kasper.Store and injects latency, timeouts and errors, in order to verify how processors
+// behave when their store degrades. Failed calls are not passed to the underlying store.
+// FaultyStore is safe for concurrent use if the underlying store is.
+type FaultyStore struct {
+	store  kasper.Store
+	mutex  sync.Mutex
+	faults Faults
+	rand   *rand.Rand
+	sleep  func(time.Duration)
+}
+
+// NewFaultyStore creates a new FaultyStore.
+func NewFaultyStore(store kasper.Store, faults Faults) *FaultyStore {
+	return &FaultyStore{
+		store:  store,
+		faults: faults,
+		rand:   rand.New(rand.NewSource(faults.Seed)),
+		sleep:  time.Sleep,
+	}
+}
+
+// SetFaults changes the injected faults, e.g. to simulate a store recovering from an outage.
+// The random number generator is not reseeded.
+func (s *FaultyStore) SetFaults(faults Faults) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.faults = faults
+}
+
+// Get gets a value by key from the underlying store, unless a fault is injected.
+func (s *FaultyStore) Get(key string) ([]byte, error) {
+	if err := s.inject(); err != nil {
+		return nil, err
+	}
+	return s.store.Get(key)
+}
+
+// GetAll gets multiple values by key from the underlying store, unless a fault is injected.
+func (s *FaultyStore) GetAll(keys []string) (map[string][]byte, error) {
+	if err := s.inject(); err != nil {
+		return nil, err
+	}
+	return s.store.GetAll(keys)
+}
+
+// Put inserts or updates a value by key in the underlying store, unless a fault is injected.
+func (s *FaultyStore) Put(key string, value []byte) error {
+	if err := s.inject(); err != nil {
+		return err
+	}
+	return s.store.Put(key, value)
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store, unless a fault is injected.
+func (s *FaultyStore) PutAll(kvs map[string][]byte) error {
+	if err := s.inject(); err != nil {
+		return err
+	}
+	return s.store.PutAll(kvs)
+}
+
+// Delete deletes a key from the underlying store, unless a fault is injected.
+func (s *FaultyStore) Delete(key string) error {
+	if err := s.inject(); err != nil {
+		return err
+	}
+	return s.store.Delete(key)
+}
+
+// Flush flushes the underlying store, unless a fault is injected.
+func (s *FaultyStore) Flush() error {
+	if err := s.inject(); err != nil {
+		return err
+	}
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *FaultyStore) GetStore() kasper.Store {
+	return s.store
+}
+
+func (s *FaultyStore) inject() error {
+	s.mutex.Lock()
+	faults := s.faults
+	latency := faults.Latency
+	if faults.Jitter > 0 {
+		latency += time.Duration(s.rand.Int63n(int64(faults.Jitter)))
+	}
+	timeout := s.rand.Float64() < faults.TimeoutRate
+	fail := s.rand.Float64() < faults.ErrorRate
+	s.mutex.Unlock()
+
+	if timeout {
+		s.sleep(faults.Timeout)
+		return ErrInjectedTimeout
+	}
+	if latency > 0 {
+		s.sleep(latency)
+	}
+	if fail {
+		return ErrInjectedFault
+	}
+	return nil
+}
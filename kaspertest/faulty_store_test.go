@@ -0,0 +1,56 @@
+package kaspertest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFaultyStore(faults Faults) (*FaultyStore, *InMemoryStore, *time.Duration) {
+	inner := NewInMemoryStore()
+	s := NewFaultyStore(inner, faults)
+	slept := new(time.Duration)
+	s.sleep = func(d time.Duration) {
+		*slept += d
+	}
+	return s, inner, slept
+}
+
+func TestFaultyStore_Latency(t *testing.T) {
+	s, inner, slept := newTestFaultyStore(Faults{Latency: 10 * time.Millisecond, Jitter: 5 * time.Millisecond})
+	assert.Nil(t, s.Put("earth", []byte("earth")))
+	_, err := s.Get("earth")
+	assert.Nil(t, err)
+	assert.True(t, *slept >= 20*time.Millisecond)
+	assert.True(t, *slept < 30*time.Millisecond)
+	assert.Equal(t, 2, inner.CallCount(""))
+}
+
+func TestFaultyStore_Errors(t *testing.T) {
+	s, inner, _ := newTestFaultyStore(Faults{ErrorRate: 1})
+	assert.Equal(t, ErrInjectedFault, s.Put("earth", []byte("earth")))
+	assert.Equal(t, 0, inner.CallCount(""))
+
+	s.SetFaults(Faults{})
+	assert.Nil(t, s.Put("earth", []byte("earth")))
+	assert.Equal(t, 1, inner.CallCount(""))
+}
+
+func TestFaultyStore_Timeouts(t *testing.T) {
+	s, _, slept := newTestFaultyStore(Faults{TimeoutRate: 1, Timeout: time.Second})
+	_, err := s.GetAll([]string{"earth"})
+	assert.Equal(t, ErrInjectedTimeout, err)
+	assert.Equal(t, time.Second, *slept)
+}
+
+func TestFaultyStore_ErrorRate(t *testing.T) {
+	s, _, _ := newTestFaultyStore(Faults{ErrorRate: 0.5, Seed: 42})
+	failures := 0
+	for i := 0; i < 1000; i++ {
+		if s.Delete("earth") != nil {
+			failures++
+		}
+	}
+	assert.True(t, failures > 400 && failures < 600)
+}
@@ -0,0 +1,169 @@
+package kaspertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"unicode/utf8"
+)
+
+// Store fixtures are JSON objects mapping keys to values, with keys sorted so that fixtures diff well.
+// Values that are compact JSON documents are embedded as is, other UTF-8 values are written as JSON strings
+// and binary values are written as {"$base64": "..."}.
+
+// WriteJSON writes the contents of the store as a JSON fixture. Calls are not recorded.
+func (s *InMemoryStore) WriteJSON(w io.Writer) error {
+	fixture := make(map[string]json.RawMessage)
+	for key, value := range s.Data() {
+		encoded, err := encodeFixtureValue(value)
+		if err != nil {
+			return fmt.Errorf("key %s: %s", key, err)
+		}
+		fixture[key] = encoded
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadJSON adds the contents of a JSON fixture to the store. Calls are not recorded.
+func (s *InMemoryStore) ReadJSON(r io.Reader) error {
+	var fixture map[string]json.RawMessage
+	err := json.NewDecoder(r).Decode(&fixture)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, encoded := range fixture {
+		value, err := decodeFixtureValue(encoded)
+		if err != nil {
+			return fmt.Errorf("key %s: %s", key, err)
+		}
+		s.data[key] = value
+	}
+	return nil
+}
+
+// LoadStoreFixture creates an InMemoryStore from a JSON fixture file.
+func LoadStoreFixture(path string) (*InMemoryStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	s := NewInMemoryStore()
+	err = s.ReadJSON(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return s, nil
+}
+
+// SaveStoreFixture writes the contents of a store to a JSON fixture file.
+func SaveStoreFixture(s *InMemoryStore, path string) error {
+	var buffer bytes.Buffer
+	err := s.WriteJSON(&buffer)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+// AssertStoreFixture checks that the contents of the store are equal to the expected JSON fixture, and reports
+// missing, unexpected and different keys. When UpdateGoldenEnv is set, the fixture is rewritten instead.
+// Returns true if the contents are equal.
+func AssertStoreFixture(t testing.TB, s *InMemoryStore, path string) bool {
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		err := SaveStoreFixture(s, path)
+		if err != nil {
+			t.Errorf("Failed to write store fixture %s: %s", path, err)
+			return false
+		}
+		t.Logf("Updated store fixture %s", path)
+		return true
+	}
+	expected, err := LoadStoreFixture(path)
+	if err != nil {
+		t.Errorf("Failed to load store fixture (run the tests with %s=1 to create it): %s", UpdateGoldenEnv, err)
+		return false
+	}
+	differences := diffData(expected.Data(), s.Data())
+	if len(differences) == 0 {
+		return true
+	}
+	var buffer bytes.Buffer
+	for _, difference := range differences {
+		buffer.WriteString("\n")
+		buffer.WriteString(difference)
+	}
+	t.Errorf("Store does not match fixture %s:%s", path, buffer.String())
+	return false
+}
+
+func diffData(expected map[string][]byte, actual map[string][]byte) []string {
+	var differences []string
+	for key, expectedValue := range expected {
+		actualValue, found := actual[key]
+		if !found {
+			differences = append(differences, fmt.Sprintf("missing key %s: expected %q", key, expectedValue))
+		} else if !bytes.Equal(expectedValue, actualValue) {
+			differences = append(differences, fmt.Sprintf("different key %s: expected %q, actual %q", key, expectedValue, actualValue))
+		}
+	}
+	for key, actualValue := range actual {
+		if _, found := expected[key]; !found {
+			differences = append(differences, fmt.Sprintf("unexpected key %s: actual %q", key, actualValue))
+		}
+	}
+	sort.Strings(differences)
+	return differences
+}
+
+type base64FixtureValue struct {
+	Base64 []byte `json:"$base64"`
+}
+
+func encodeFixtureValue(value []byte) (json.RawMessage, error) {
+	if len(value) > 0 && value[0] != '"' {
+		var compacted bytes.Buffer
+		if json.Compact(&compacted, value) == nil && bytes.Equal(compacted.Bytes(), value) {
+			return json.RawMessage(value), nil
+		}
+	}
+	if utf8.Valid(value) {
+		return json.Marshal(string(value))
+	}
+	return json.Marshal(&base64FixtureValue{value})
+}
+
+func decodeFixtureValue(encoded json.RawMessage) ([]byte, error) {
+	trimmed := bytes.TrimSpace(encoded)
+	if len(trimmed) > 0 && trimmed[0] == '"' {
+		var value string
+		err := json.Unmarshal(trimmed, &value)
+		return []byte(value), err
+	}
+	var binary map[string][]byte
+	if json.Unmarshal(trimmed, &binary) == nil && len(binary) == 1 {
+		if value, found := binary["$base64"]; found {
+			return value, nil
+		}
+	}
+	var compacted bytes.Buffer
+	err := json.Compact(&compacted, trimmed)
+	return compacted.Bytes(), err
+}
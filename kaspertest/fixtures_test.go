@@ -0,0 +1,52 @@
+package kaspertest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryStore_WriteJSON_ReadJSON(t *testing.T) {
+	s := NewInMemoryStore()
+	s.Put("earth", []byte(`{"planet":"earth","moons":1}`))
+	s.Put("mars", []byte("red planet"))
+	s.Put("quoted", []byte(`"quoted"`))
+	s.Put("indented", []byte(`{ "planet": "venus" }`))
+	s.Put("binary", []byte{0, 1, 255})
+
+	var buffer bytes.Buffer
+	assert.Nil(t, s.WriteJSON(&buffer))
+	loaded := NewInMemoryStore()
+	assert.Nil(t, loaded.ReadJSON(&buffer))
+	assert.Equal(t, s.Data(), loaded.Data())
+	assert.Equal(t, 0, loaded.CallCount(""))
+}
+
+func TestAssertStoreFixture(t *testing.T) {
+	s, err := LoadStoreFixture("testdata/planets.json")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(`{"moons":1,"planet":"earth"}`), s.Data()["earth"])
+	assert.Equal(t, []byte{0, 1, 255}, s.Data()["binary"])
+	assert.True(t, AssertStoreFixture(t, s, "testdata/planets.json"))
+
+	s.Put("mars", []byte("blue planet"))
+	s.Delete("earth")
+	s.Put("venus", []byte("hot"))
+	countingT := &errorCountingT{TB: t}
+	assert.False(t, AssertStoreFixture(countingT, s, "testdata/planets.json"))
+	assert.Equal(t, 1, countingT.errors)
+	assert.Equal(t, []string{
+		`different key mars: expected "red planet", actual "blue planet"`,
+		`missing key earth: expected "{\"moons\":1,\"planet\":\"earth\"}"`,
+		`unexpected key venus: actual "hot"`,
+	}, diffData(mustLoadStoreFixture(t, "testdata/planets.json").Data(), s.Data()))
+}
+
+func mustLoadStoreFixture(t *testing.T, path string) *InMemoryStore {
+	s, err := LoadStoreFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+package kaspertest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/movio/kasper"
+)
+
+// UpdateGoldenEnv is the environment variable that makes AssertGolden rewrite golden files instead of
+// comparing against them, e.g. KASPER_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "KASPER_UPDATE_GOLDEN"
+
+// AssertGolden checks that the wire format of a Serde has not changed.
+// value is serialized and compared byte for byte with testdata/<name>.golden, and the golden file is deserialized
+// and compared with value, so that data written by previous versions can still be read.
+// When UpdateGoldenEnv is set, golden files are (re)written from value instead, whether they exist or not,
+// and should be committed.
+// Returns true if the checks passed.
+func AssertGolden(t testing.TB, serde kasper.Serde, name string, value interface{}) bool {
+	path := filepath.Join("testdata", name+".golden")
+	data, err := serde.Serialize(value)
+	if err != nil {
+		t.Errorf("Failed to serialize %s: %s", name, err)
+		return false
+	}
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, data, 0644)
+		}
+		if err != nil {
+			t.Errorf("Failed to write golden file %s: %s", path, err)
+			return false
+		}
+		t.Logf("Updated golden file %s", path)
+		return true
+	}
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Errorf("Failed to read golden file %s (run the tests with %s=1 to create it): %s", path, UpdateGoldenEnv, err)
+		return false
+	}
+	ok := true
+	if !bytes.Equal(golden, data) {
+		t.Errorf("Wire format of %s has changed.\ngolden:  %q\nactual:  %q\nRun the tests with %s=1 if the change is intended.", name, golden, data, UpdateGoldenEnv)
+		ok = false
+	}
+	decoded, err := serde.Deserialize(golden)
+	if err != nil {
+		t.Errorf("Failed to deserialize golden file %s: %s", path, err)
+		return false
+	}
+	if !reflect.DeepEqual(value, decoded) {
+		t.Errorf("Golden file %s does not deserialize to the expected value.\nexpected: %#v\nactual:   %#v", path, value, decoded)
+		ok = false
+	}
+	return ok
+}
@@ -0,0 +1,34 @@
+package kaspertest
+
+import (
+	"testing"
+
+	"github.com/movio/kasper"
+	"github.com/stretchr/testify/assert"
+)
+
+type goldenTestTweet struct {
+	Text     string `json:"text"`
+	Retweets int    `json:"retweets"`
+}
+
+func TestAssertGolden(t *testing.T) {
+	serde := kasper.NewJSONSerde(func() interface{} { return &goldenTestTweet{} })
+	AssertGolden(t, serde, "tweet", &goldenTestTweet{"hello world", 3})
+}
+
+type errorCountingT struct {
+	testing.TB
+	errors int
+}
+
+func (t *errorCountingT) Errorf(format string, args ...interface{}) {
+	t.errors++
+}
+
+func TestAssertGolden_Changed(t *testing.T) {
+	serde := kasper.NewJSONSerde(func() interface{} { return &goldenTestTweet{} })
+	countingT := &errorCountingT{TB: t}
+	assert.False(t, AssertGolden(countingT, serde, "tweet", &goldenTestTweet{"hello world", 4}))
+	assert.Equal(t, 2, countingT.errors)
+}
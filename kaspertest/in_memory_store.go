@@ -0,0 +1,200 @@
+/*
+Package kaspertest provides utilities for unit testing Kasper applications without Kafka or a key-value store.
+*/
+package kaspertest
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/movio/kasper"
+)
+
+// Call records a single call made to an InMemoryStore.
+type Call struct {
+	// Name of the Store method, e.g. "GetAll"
+	Method string
+	// Keys passed to the method, sorted for PutAll. Empty for Flush.
+	Keys []string
+	// Error returned by the method
+	Err error
+}
+
+type injectedFailure struct {
+	method string
+	n      int
+	err    error
+}
+
+// InMemoryStore is an implementation of kasper.Store for unit tests.
+// Values are copied on the way in and out, so tests are not affected by callers reusing byte slices.
+// All calls are recorded, and errors can be injected on the Nth call to the store or to a given method.
+// InMemoryStore is safe for concurrent use.
+type InMemoryStore struct {
+	mutex       sync.Mutex
+	data        map[string][]byte
+	calls       []Call
+	methodCalls map[string]int
+	failures    []injectedFailure
+}
+
+// NewInMemoryStore creates a new, empty InMemoryStore.
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		data:        make(map[string][]byte),
+		methodCalls: make(map[string]int),
+	}
+}
+
+// FailOnCall makes the Nth call (starting at 1) to method return err instead of accessing the data.
+// If method is empty, the Nth call to any method fails.
+func (s *InMemoryStore) FailOnCall(method string, n int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = append(s.failures, injectedFailure{method, n, err})
+}
+
+// Get gets a value by key. Returns (nil, nil) if the key is not present.
+func (s *InMemoryStore) Get(key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.record("Get", []string{key}); err != nil {
+		return nil, err
+	}
+	return copyBytes(s.data[key]), nil
+}
+
+// GetAll gets multiple values by key. The returned map does not contain entries for missing keys.
+func (s *InMemoryStore) GetAll(keys []string) (map[string][]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.record("GetAll", append([]string{}, keys...)); err != nil {
+		return nil, err
+	}
+	kvs := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if value, found := s.data[key]; found {
+			kvs[key] = copyBytes(value)
+		}
+	}
+	return kvs, nil
+}
+
+// Put inserts or updates a value by key.
+func (s *InMemoryStore) Put(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.record("Put", []string{key}); err != nil {
+		return err
+	}
+	s.data[key] = copyBytes(value)
+	return nil
+}
+
+// PutAll inserts or updates multiple key-value pairs.
+func (s *InMemoryStore) PutAll(kvs map[string][]byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.record("PutAll", sortedKeys(kvs)); err != nil {
+		return err
+	}
+	for key, value := range kvs {
+		s.data[key] = copyBytes(value)
+	}
+	return nil
+}
+
+// Delete deletes a key. Does not return an error if the key is not present.
+func (s *InMemoryStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.record("Delete", []string{key}); err != nil {
+		return err
+	}
+	delete(s.data, key)
+	return nil
+}
+
+// Flush does nothing apart from recording the call.
+func (s *InMemoryStore) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.record("Flush", nil)
+}
+
+// Calls returns all calls made to the store so far, in order.
+func (s *InMemoryStore) Calls() []Call {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Call{}, s.calls...)
+}
+
+// CallCount returns the number of calls made to method, or to any method if method is empty.
+func (s *InMemoryStore) CallCount(method string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if method == "" {
+		return len(s.calls)
+	}
+	return s.methodCalls[method]
+}
+
+// Data returns a copy of the contents of the store. Calls are not recorded.
+func (s *InMemoryStore) Data() map[string][]byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data := make(map[string][]byte, len(s.data))
+	for key, value := range s.data {
+		data[key] = copyBytes(value)
+	}
+	return data
+}
+
+// Keys returns the sorted keys of the store. Calls are not recorded.
+func (s *InMemoryStore) Keys() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return sortedKeys(s.data)
+}
+
+// Reset removes all data, recorded calls and injected failures.
+func (s *InMemoryStore) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data = make(map[string][]byte)
+	s.calls = nil
+	s.methodCalls = make(map[string]int)
+	s.failures = nil
+}
+
+func (s *InMemoryStore) record(method string, keys []string) error {
+	s.methodCalls[method]++
+	n := len(s.calls) + 1
+	var err error
+	for _, failure := range s.failures {
+		if failure.method == "" && failure.n == n || failure.method == method && failure.n == s.methodCalls[method] {
+			err = failure.err
+			break
+		}
+	}
+	s.calls = append(s.calls, Call{method, keys, err})
+	return err
+}
+
+func copyBytes(value []byte) []byte {
+	if value == nil {
+		return nil
+	}
+	return append([]byte{}, value...)
+}
+
+func sortedKeys(kvs map[string][]byte) []string {
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var _ kasper.Store = (*InMemoryStore)(nil)
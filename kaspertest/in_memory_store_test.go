@@ -0,0 +1,59 @@
+package kaspertest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryStore(t *testing.T) {
+	s := NewInMemoryStore()
+	value := []byte("earth")
+	assert.Nil(t, s.Put("earth", value))
+	value[0] = 'E'
+	assert.Nil(t, s.PutAll(map[string][]byte{"mars": []byte("mars"), "venus": []byte("venus")}))
+	assert.Nil(t, s.Delete("venus"))
+
+	actual, err := s.Get("earth")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("earth"), actual)
+
+	kvs, err := s.GetAll([]string{"earth", "venus"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"earth": []byte("earth")}, kvs)
+
+	assert.Nil(t, s.Flush())
+	assert.Equal(t, []string{"earth", "mars"}, s.Keys())
+	assert.Equal(t, []Call{
+		{"Put", []string{"earth"}, nil},
+		{"PutAll", []string{"mars", "venus"}, nil},
+		{"Delete", []string{"venus"}, nil},
+		{"Get", []string{"earth"}, nil},
+		{"GetAll", []string{"earth", "venus"}, nil},
+		{"Flush", nil, nil},
+	}, s.Calls())
+}
+
+func TestInMemoryStore_FailOnCall(t *testing.T) {
+	s := NewInMemoryStore()
+	boom := errors.New("boom")
+	s.FailOnCall("Put", 2, boom)
+	s.FailOnCall("", 4, boom)
+
+	assert.Nil(t, s.Put("earth", []byte("earth")))
+	assert.Equal(t, boom, s.Put("mars", []byte("mars")))
+	_, err := s.Get("earth")
+	assert.Nil(t, err)
+	_, err = s.Get("earth")
+	assert.Equal(t, boom, err)
+
+	assert.Equal(t, []string{"earth"}, s.Keys())
+	assert.Equal(t, 2, s.CallCount("Put"))
+	assert.Equal(t, 4, s.CallCount(""))
+	assert.Equal(t, boom, s.Calls()[1].Err)
+
+	s.Reset()
+	assert.Equal(t, 0, s.CallCount(""))
+	assert.Equal(t, 0, len(s.Data()))
+}
@@ -0,0 +1,158 @@
+package kaspertest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+)
+
+// KafkaCluster helps writing integration tests against a real Kafka cluster, such as the one started by
+// ci/docker-compose.yml. It creates topics, produces fixtures and runs TopicProcessors until they have
+// consumed all their input.
+type KafkaCluster struct {
+	Brokers  []string
+	Client   sarama.Client
+	producer sarama.SyncProducer
+}
+
+// KafkaFromEnv connects to the Kafka broker listening on port 9092 of the host given by the KASPER_CI_HOST
+// environment variable. Tests should be skipped when it returns an error.
+func KafkaFromEnv(timeout time.Duration) (*KafkaCluster, error) {
+	host := os.Getenv("KASPER_CI_HOST")
+	if host == "" {
+		return nil, errors.New("the environment variable KASPER_CI_HOST is not set")
+	}
+	return ConnectKafka([]string{fmt.Sprintf("%s:9092", host)}, timeout)
+}
+
+// ConnectKafka connects to a Kafka cluster, retrying until the brokers are reachable or the timeout expires.
+// This gives freshly started containers time to come up.
+func ConnectKafka(brokers []string, timeout time.Duration) (*KafkaCluster, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	deadline := time.Now().Add(timeout)
+	for {
+		client, err := sarama.NewClient(brokers, saramaConfig)
+		if err == nil {
+			return &KafkaCluster{Brokers: brokers, Client: client}, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// CreateTopic makes sure a topic exists and waits until its partitions have leaders.
+// CreateTopic does not send a CreateTopics request, which requires Kafka 0.10.1, so it relies on the broker
+// auto-creating the topic (auto.create.topics.enable, the default) with its default number of partitions.
+// Use ci/create_topics.sh to create topics with a specific number of partitions.
+func (k *KafkaCluster) CreateTopic(topic string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := k.Client.RefreshMetadata(topic)
+		if err == nil {
+			var partitions []int32
+			partitions, err = k.Client.WritablePartitions(topic)
+			if err == nil && len(partitions) > 0 {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("topic %s was not created within %s: %v", topic, timeout, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// Produce sends messages synchronously.
+func (k *KafkaCluster) Produce(messages ...*sarama.ProducerMessage) error {
+	if k.producer == nil {
+		producer, err := sarama.NewSyncProducerFromClient(k.Client)
+		if err != nil {
+			return err
+		}
+		k.producer = producer
+	}
+	return k.producer.SendMessages(messages)
+}
+
+// Consume reads count messages from a partition, starting at the given offset (e.g. sarama.OffsetOldest).
+func (k *KafkaCluster) Consume(topic string, partition int, offset int64, count int, timeout time.Duration) ([]*sarama.ConsumerMessage, error) {
+	consumer, err := sarama.NewConsumerFromClient(k.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+	partitionConsumer, err := consumer.ConsumePartition(topic, int32(partition), offset)
+	if err != nil {
+		return nil, err
+	}
+	defer partitionConsumer.Close()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var messages []*sarama.ConsumerMessage
+	for len(messages) < count {
+		select {
+		case message := <-partitionConsumer.Messages():
+			messages = append(messages, message)
+		case <-timer.C:
+			return messages, fmt.Errorf("received %d of %d messages from %s/%d within %s", len(messages), count, topic, partition, timeout)
+		}
+	}
+	return messages, nil
+}
+
+// RunUntilCaughtUp creates a TopicProcessor and runs it until it has consumed all messages of its input topics
+// (see TopicProcessor.HasConsumedAllMessages), then closes it.
+// config.Client defaults to the cluster's Client. Returns the error returned by OpenTopicProcessor or RunLoop, if any,
+// or an error if the TopicProcessor has not caught up before the timeout expires.
+func (k *KafkaCluster) RunUntilCaughtUp(config *kasper.Config, messageProcessors map[int]kasper.MessageProcessor, timeout time.Duration) error {
+	if config.Client == nil {
+		config.Client = k.Client
+	}
+	topicProcessor, err := kasper.OpenTopicProcessor(config, messageProcessors)
+	if err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- topicProcessor.RunLoop()
+	}()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.Now().Add(timeout)
+	for {
+		select {
+		case err := <-done:
+			topicProcessor.Close()
+			return err
+		case <-ticker.C:
+			if topicProcessor.HasConsumedAllMessages() {
+				topicProcessor.Close()
+				return <-done
+			}
+			if time.Now().After(deadline) {
+				topicProcessor.Close()
+				<-done
+				return fmt.Errorf("topic processor %s did not catch up within %s", config.TopicProcessorName, timeout)
+			}
+		}
+	}
+}
+
+// Close closes the producer and the client.
+func (k *KafkaCluster) Close() error {
+	if k.producer != nil {
+		if err := k.producer.Close(); err != nil {
+			return err
+		}
+	}
+	return k.Client.Close()
+}
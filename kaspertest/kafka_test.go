@@ -0,0 +1,57 @@
+package kaspertest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+	"github.com/stretchr/testify/assert"
+)
+
+type prefixProcessor struct{}
+
+func (*prefixProcessor) Process(messages []*sarama.ConsumerMessage, sender kasper.Sender) error {
+	for _, message := range messages {
+		sender.Send(&sarama.ProducerMessage{
+			Topic: "kaspertest-output",
+			Key:   sarama.ByteEncoder(message.Key),
+			Value: sarama.ByteEncoder(append([]byte("processed "), message.Value...)),
+		})
+	}
+	return nil
+}
+
+func TestKafkaCluster(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	kafka, err := KafkaFromEnv(30 * time.Second)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer kafka.Close()
+	assert.Nil(t, kafka.CreateTopic("kaspertest-input", 30*time.Second))
+	assert.Nil(t, kafka.CreateTopic("kaspertest-output", 30*time.Second))
+	offset, err := kafka.Client.GetOffset("kaspertest-output", 0, sarama.OffsetNewest)
+	assert.Nil(t, err)
+
+	assert.Nil(t, kafka.Produce(&sarama.ProducerMessage{
+		Topic:     "kaspertest-input",
+		Partition: 0,
+		Value:     sarama.StringEncoder("hello"),
+	}))
+	config := &kasper.Config{
+		TopicProcessorName: fmt.Sprintf("kaspertest-%d", time.Now().UnixNano()),
+		InputTopics:        []string{"kaspertest-input"},
+		InputPartitions:    []int{0},
+		BatchWaitDuration:  100 * time.Millisecond,
+	}
+	err = kafka.RunUntilCaughtUp(config, map[int]kasper.MessageProcessor{0: &prefixProcessor{}}, time.Minute)
+	assert.Nil(t, err)
+
+	messages, err := kafka.Consume("kaspertest-output", 0, offset, 1, 30*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("processed hello"), messages[len(messages)-1].Value)
+}
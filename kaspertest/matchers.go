@@ -0,0 +1,184 @@
+package kaspertest
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+)
+
+// MessageMatcher describes an expected produced message, for use with AssertMessages and AssertContainsMessages.
+type MessageMatcher struct {
+	// Expected topic, any topic if empty
+	Topic string
+	// Expected key as a string or []byte, any key if nil
+	Key interface{}
+	// Expected value as a string or []byte, or the expected decoded value if Serde is set. Any value if nil.
+	Value interface{}
+	// Used to decode message values before comparing them with Value
+	Serde kasper.Serde
+}
+
+// Message creates a MessageMatcher comparing raw keys and values. key and value can be strings, byte slices or nil.
+func Message(topic string, key interface{}, value interface{}) MessageMatcher {
+	return MessageMatcher{Topic: topic, Key: key, Value: value}
+}
+
+// DecodedMessage creates a MessageMatcher comparing the value decoded with serde to value.
+func DecodedMessage(topic string, key interface{}, value interface{}, serde kasper.Serde) MessageMatcher {
+	return MessageMatcher{Topic: topic, Key: key, Value: value, Serde: serde}
+}
+
+// Matches returns true if message matches.
+func (m MessageMatcher) Matches(message *sarama.ProducerMessage) bool {
+	if m.Topic != "" && m.Topic != message.Topic {
+		return false
+	}
+	if m.Key != nil && !matchesBytes(m.Key, message.Key) {
+		return false
+	}
+	if m.Value == nil {
+		return true
+	}
+	if m.Serde == nil {
+		return matchesBytes(m.Value, message.Value)
+	}
+	data, err := encode(message.Value)
+	if err != nil {
+		return false
+	}
+	decoded, err := m.Serde.Deserialize(data)
+	return err == nil && reflect.DeepEqual(m.Value, decoded)
+}
+
+func (m MessageMatcher) String() string {
+	format := func(value interface{}) string {
+		switch value := value.(type) {
+		case nil:
+			return "*"
+		case []byte:
+			return fmt.Sprintf("%q", value)
+		case string:
+			return fmt.Sprintf("%q", value)
+		default:
+			return fmt.Sprintf("%+v", value)
+		}
+	}
+	topic := m.Topic
+	if topic == "" {
+		topic = "*"
+	}
+	return fmt.Sprintf("topic=%s key=%s value=%s", topic, format(m.Key), format(m.Value))
+}
+
+func matchesBytes(expected interface{}, encoder sarama.Encoder) bool {
+	actual, err := encode(encoder)
+	if err != nil {
+		return false
+	}
+	switch expected := expected.(type) {
+	case string:
+		return string(actual) == expected
+	case []byte:
+		return bytes.Equal(actual, expected)
+	default:
+		return false
+	}
+}
+
+func encode(encoder sarama.Encoder) ([]byte, error) {
+	if encoder == nil {
+		return nil, nil
+	}
+	return encoder.Encode()
+}
+
+func formatMessage(message *sarama.ProducerMessage) string {
+	key, _ := encode(message.Key)
+	value, _ := encode(message.Value)
+	return fmt.Sprintf("topic=%s key=%q value=%q", message.Topic, key, value)
+}
+
+// AssertMessages checks that each message matches exactly one matcher and each matcher matches exactly one message,
+// regardless of ordering. Returns true if the messages match.
+func AssertMessages(t testing.TB, messages []*sarama.ProducerMessage, expected ...MessageMatcher) bool {
+	unmatchedExpected, unmatchedMessages := matchMessages(messages, expected)
+	if len(unmatchedExpected) == 0 && len(unmatchedMessages) == 0 {
+		return true
+	}
+	t.Errorf("Messages do not match:%s", describeMismatches(unmatchedExpected, unmatchedMessages))
+	return false
+}
+
+// AssertContainsMessages checks that each matcher matches a different message, regardless of ordering.
+// Other messages are ignored. Returns true if all matchers are matched.
+func AssertContainsMessages(t testing.TB, messages []*sarama.ProducerMessage, expected ...MessageMatcher) bool {
+	unmatchedExpected, _ := matchMessages(messages, expected)
+	if len(unmatchedExpected) == 0 {
+		return true
+	}
+	t.Errorf("Messages not found:%s", describeMismatches(unmatchedExpected, nil))
+	return false
+}
+
+func describeMismatches(unmatchedExpected []MessageMatcher, unmatchedMessages []*sarama.ProducerMessage) string {
+	var buffer bytes.Buffer
+	for _, matcher := range unmatchedExpected {
+		fmt.Fprintf(&buffer, "\nmissing:    %s", matcher)
+	}
+	for _, message := range unmatchedMessages {
+		fmt.Fprintf(&buffer, "\nunexpected: %s", formatMessage(message))
+	}
+	return buffer.String()
+}
+
+// matchMessages computes a maximum matching between matchers and messages, so that wildcard matchers
+// do not steal the messages needed by more specific ones.
+func matchMessages(messages []*sarama.ProducerMessage, expected []MessageMatcher) ([]MessageMatcher, []*sarama.ProducerMessage) {
+	candidates := make([][]int, len(expected))
+	for i, matcher := range expected {
+		for j, message := range messages {
+			if matcher.Matches(message) {
+				candidates[i] = append(candidates[i], j)
+			}
+		}
+	}
+	matchedBy := make([]int, len(messages))
+	for j := range matchedBy {
+		matchedBy[j] = -1
+	}
+	var augment func(i int, visited []bool) bool
+	augment = func(i int, visited []bool) bool {
+		for _, j := range candidates[i] {
+			if visited[j] {
+				continue
+			}
+			visited[j] = true
+			if matchedBy[j] == -1 || augment(matchedBy[j], visited) {
+				matchedBy[j] = i
+				return true
+			}
+		}
+		return false
+	}
+	matched := make([]bool, len(expected))
+	for i := range expected {
+		matched[i] = augment(i, make([]bool, len(messages)))
+	}
+	var unmatchedExpected []MessageMatcher
+	for i, matcher := range expected {
+		if !matched[i] {
+			unmatchedExpected = append(unmatchedExpected, matcher)
+		}
+	}
+	var unmatchedMessages []*sarama.ProducerMessage
+	for j, message := range messages {
+		if matchedBy[j] == -1 {
+			unmatchedMessages = append(unmatchedMessages, message)
+		}
+	}
+	return unmatchedExpected, unmatchedMessages
+}
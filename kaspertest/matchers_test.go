@@ -0,0 +1,55 @@
+package kaspertest
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+	"github.com/stretchr/testify/assert"
+)
+
+type matchersTestCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+func matchersTestMessages() []*sarama.ProducerMessage {
+	return []*sarama.ProducerMessage{
+		{Topic: "counts", Key: sarama.StringEncoder("hello"), Value: sarama.StringEncoder(`{"word":"hello","count":2}`)},
+		{Topic: "counts", Key: sarama.StringEncoder("world"), Value: sarama.StringEncoder(`{"word":"world","count":1}`)},
+		{Topic: "audit", Value: sarama.ByteEncoder("processed")},
+	}
+}
+
+func TestAssertMessages(t *testing.T) {
+	serde := kasper.NewJSONSerde(func() interface{} { return &matchersTestCount{} })
+	messages := matchersTestMessages()
+
+	assert.True(t, AssertMessages(t, messages,
+		Message("audit", nil, []byte("processed")),
+		Message("counts", nil, nil),
+		DecodedMessage("counts", "hello", &matchersTestCount{"hello", 2}, serde),
+	))
+	assert.True(t, AssertContainsMessages(t, messages,
+		DecodedMessage("", "world", &matchersTestCount{"world", 1}, serde),
+	))
+}
+
+func TestAssertMessages_Mismatch(t *testing.T) {
+	messages := matchersTestMessages()
+	countingT := &errorCountingT{TB: t}
+
+	assert.False(t, AssertMessages(countingT, messages,
+		Message("counts", "hello", nil),
+		Message("counts", "hello", nil),
+	))
+	unmatchedExpected, unmatchedMessages := matchMessages(messages, []MessageMatcher{
+		Message("counts", "hello", nil),
+		Message("counts", "hello", nil),
+	})
+	assert.Equal(t, 1, len(unmatchedExpected))
+	assert.Equal(t, 2, len(unmatchedMessages))
+
+	assert.False(t, AssertContainsMessages(countingT, messages, Message("audit", nil, "skipped")))
+	assert.Equal(t, 2, countingT.errors)
+}
@@ -0,0 +1,69 @@
+package kaspertest
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+)
+
+// RecordingSender is an implementation of kasper.Sender for MessageProcessor unit tests.
+// It records all messages passed to Send, and errors can be injected on the Nth call to Flush
+// to simulate a failure to produce messages.
+type RecordingSender struct {
+	flushed    []*sarama.ProducerMessage
+	pending    []*sarama.ProducerMessage
+	flushCount int
+	failures   map[int]error
+}
+
+// NewRecordingSender creates a new RecordingSender.
+func NewRecordingSender() *RecordingSender {
+	return &RecordingSender{
+		failures: make(map[int]error),
+	}
+}
+
+// FailOnFlush makes the Nth call (starting at 1) to Flush return err. The pending messages are kept, as they would
+// be by the TopicProcessor's Sender.
+func (s *RecordingSender) FailOnFlush(n int, err error) {
+	s.failures[n] = err
+}
+
+// Send appends a message to the pending messages.
+func (s *RecordingSender) Send(msg *sarama.ProducerMessage) {
+	s.pending = append(s.pending, msg)
+}
+
+// Flush moves the pending messages to the flushed messages, unless an error was injected for this call.
+func (s *RecordingSender) Flush() error {
+	s.flushCount++
+	if err, found := s.failures[s.flushCount]; found {
+		return err
+	}
+	s.flushed = append(s.flushed, s.pending...)
+	s.pending = nil
+	return nil
+}
+
+// Messages returns all messages passed to Send, flushed or not, in order.
+func (s *RecordingSender) Messages() []*sarama.ProducerMessage {
+	messages := make([]*sarama.ProducerMessage, 0, len(s.flushed)+len(s.pending))
+	messages = append(messages, s.flushed...)
+	return append(messages, s.pending...)
+}
+
+// Flushed returns the messages that were successfully flushed.
+func (s *RecordingSender) Flushed() []*sarama.ProducerMessage {
+	return append([]*sarama.ProducerMessage{}, s.flushed...)
+}
+
+// Pending returns the messages passed to Send since the last successful Flush.
+func (s *RecordingSender) Pending() []*sarama.ProducerMessage {
+	return append([]*sarama.ProducerMessage{}, s.pending...)
+}
+
+// FlushCount returns the number of calls to Flush.
+func (s *RecordingSender) FlushCount() int {
+	return s.flushCount
+}
+
+var _ kasper.Sender = (*RecordingSender)(nil)
@@ -0,0 +1,29 @@
+package kaspertest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordingSender(t *testing.T) {
+	s := NewRecordingSender()
+	boom := errors.New("boom")
+	s.FailOnFlush(2, boom)
+	earth := &sarama.ProducerMessage{Topic: "planets", Value: sarama.StringEncoder("earth")}
+	mars := &sarama.ProducerMessage{Topic: "planets", Value: sarama.StringEncoder("mars")}
+
+	s.Send(earth)
+	assert.Nil(t, s.Flush())
+	s.Send(mars)
+	assert.Equal(t, boom, s.Flush())
+	assert.Equal(t, []*sarama.ProducerMessage{earth}, s.Flushed())
+	assert.Equal(t, []*sarama.ProducerMessage{mars}, s.Pending())
+	assert.Equal(t, []*sarama.ProducerMessage{earth, mars}, s.Messages())
+
+	assert.Nil(t, s.Flush())
+	assert.Equal(t, 0, len(s.Pending()))
+	assert.Equal(t, 3, s.FlushCount())
+}
@@ -0,0 +1,159 @@
+package kasper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeySchema describes keys made of named fields joined by a separator, such as the {tenant}/{keyPrefix}/{key}
+// keys of MultiRedis:
+//
+//	schema := kasper.NewKeySchema("/", "tenant", "table", "id")
+//	key, err := schema.Compose("movio", "users", "42")
+//
+// Fields cannot be empty or contain the separator, so that keys can be parsed back into their fields.
+type KeySchema struct {
+	Separator string
+	Fields    []string
+}
+
+// NewKeySchema creates KeySchema instances.
+func NewKeySchema(separator string, fields ...string) *KeySchema {
+	return &KeySchema{separator, fields}
+}
+
+// String returns the schema in the form {a}/{b}/{c}.
+func (schema *KeySchema) String() string {
+	fields := make([]string, len(schema.Fields))
+	for i, field := range schema.Fields {
+		fields[i] = fmt.Sprintf("{%s}", field)
+	}
+	return strings.Join(fields, schema.Separator)
+}
+
+// Compose returns the key made of values, which must be given in the order of the fields of the schema.
+func (schema *KeySchema) Compose(values ...string) (string, error) {
+	key := strings.Join(values, schema.Separator)
+	if len(values) != len(schema.Fields) {
+		return "", fmt.Errorf("invalid key %q: expected %d fields (%s)", key, len(schema.Fields), schema)
+	}
+	for i, value := range values {
+		if err := schema.validateField(key, i, value); err != nil {
+			return "", err
+		}
+	}
+	return key, nil
+}
+
+// Parse returns the values of the fields of key, in the order of the schema.
+func (schema *KeySchema) Parse(key string) ([]string, error) {
+	values := strings.SplitN(key, schema.Separator, len(schema.Fields))
+	if len(values) != len(schema.Fields) {
+		return nil, fmt.Errorf("invalid key %q: expected %d fields (%s)", key, len(schema.Fields), schema)
+	}
+	for i, value := range values {
+		if err := schema.validateField(key, i, value); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Validate returns an error if key does not follow the schema.
+func (schema *KeySchema) Validate(key string) error {
+	_, err := schema.Parse(key)
+	return err
+}
+
+func (schema *KeySchema) validateField(key string, i int, value string) error {
+	if value == "" {
+		return fmt.Errorf("invalid key %q: %s is empty", key, schema.Fields[i])
+	}
+	if strings.Contains(value, schema.Separator) {
+		return fmt.Errorf("invalid key %q: %s contains %q", key, schema.Fields[i], schema.Separator)
+	}
+	return nil
+}
+
+// SchemaStore is a Store that advertises the schema of its keys, see NewSchemaStore.
+type SchemaStore interface {
+	Store
+	KeySchema() *KeySchema
+}
+
+// ValidateKey returns an error if store implements SchemaStore and key does not follow its schema.
+func ValidateKey(store Store, key string) error {
+	if schemaStore, ok := store.(SchemaStore); ok {
+		return schemaStore.KeySchema().Validate(key)
+	}
+	return nil
+}
+
+// NewSchemaStore wraps store so that all the operations return an error, without calling store, if a key does
+// not follow schema.
+func NewSchemaStore(store Store, schema *KeySchema) SchemaStore {
+	return &schemaStore{store, schema}
+}
+
+type schemaStore struct {
+	store  Store
+	schema *KeySchema
+}
+
+func (s *schemaStore) KeySchema() *KeySchema {
+	return s.schema
+}
+
+func (s *schemaStore) validate(keys []string) error {
+	for _, key := range keys {
+		if err := s.schema.Validate(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *schemaStore) Get(key string) ([]byte, error) {
+	if err := s.schema.Validate(key); err != nil {
+		return nil, err
+	}
+	return s.store.Get(key)
+}
+
+func (s *schemaStore) GetAll(keys []string) (map[string][]byte, error) {
+	if err := s.validate(keys); err != nil {
+		return nil, err
+	}
+	return s.store.GetAll(keys)
+}
+
+func (s *schemaStore) Put(key string, value []byte) error {
+	if err := s.schema.Validate(key); err != nil {
+		return err
+	}
+	return s.store.Put(key, value)
+}
+
+func (s *schemaStore) PutAll(kvs map[string][]byte) error {
+	for key := range kvs {
+		if err := s.schema.Validate(key); err != nil {
+			return err
+		}
+	}
+	return s.store.PutAll(kvs)
+}
+
+func (s *schemaStore) Delete(key string) error {
+	if err := s.schema.Validate(key); err != nil {
+		return err
+	}
+	return s.store.Delete(key)
+}
+
+func (s *schemaStore) Flush() error {
+	return s.store.Flush()
+}
+
+func (s *schemaStore) GetStore() Store {
+	return s.store
+}
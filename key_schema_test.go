@@ -0,0 +1,43 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySchema(t *testing.T) {
+	schema := NewKeySchema("/", "tenant", "table", "id")
+	assert.Equal(t, "{tenant}/{table}/{id}", schema.String())
+
+	key, err := schema.Compose("movio", "users", "42")
+	assert.Nil(t, err)
+	assert.Equal(t, "movio/users/42", key)
+	_, err = schema.Compose("movio", "users")
+	assert.EqualError(t, err, `invalid key "movio/users": expected 3 fields ({tenant}/{table}/{id})`)
+	_, err = schema.Compose("movio", "", "42")
+	assert.EqualError(t, err, `invalid key "movio//42": table is empty`)
+
+	values, err := schema.Parse("movio/users/42")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"movio", "users", "42"}, values)
+	_, err = schema.Parse("movio/users/42/43")
+	assert.EqualError(t, err, `invalid key "movio/users/42/43": id contains "/"`)
+	assert.NotNil(t, schema.Validate("42"))
+}
+
+func TestSchemaStore(t *testing.T) {
+	s := NewMap(10)
+	store := NewSchemaStore(s, NewKeySchema("/", "system", "planet"))
+
+	assert.Nil(t, store.Put("sol/mars", mars))
+	assert.EqualError(t, store.Put("mars", mars), `invalid key "mars": expected 2 fields ({system}/{planet})`)
+	assert.NotNil(t, store.PutAll(map[string][]byte{"sol/earth": earth, "sol/": venus}))
+	_, err := store.GetAll([]string{"sol/mars", "/mars"})
+	assert.EqualError(t, err, `invalid key "/mars": system is empty`)
+	assert.Equal(t, map[string][]byte{"sol/mars": mars}, s.GetMap())
+
+	assert.Nil(t, ValidateKey(store, "sol/mars"))
+	assert.NotNil(t, ValidateKey(store, "sol"))
+	assert.Nil(t, ValidateKey(s, "sol"))
+}
@@ -0,0 +1,170 @@
+package kasper
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+// reservedMetricsLabels are the label names used by Kasper's own metrics, which Config.MetricsLabels cannot
+// override.
+var reservedMetricsLabels = []string{
+	"topicProcessor", "containerID", "topic", "partition", "store", "keyPrefix", "index", "type", "indexAndType",
+}
+
+// validateMetricsLabels reports the labels of Config.MetricsLabels that would clash with Kasper's own labels.
+func validateMetricsLabels(labels map[string]string) []string {
+	var problems []string
+	for _, name := range reservedMetricsLabels {
+		if _, found := labels[name]; found {
+			problems = append(problems, fmt.Sprintf("metrics label %s is reserved", name))
+		}
+	}
+	for name := range labels {
+		if name == "" {
+			problems = append(problems, "metrics label names cannot be empty")
+		}
+	}
+	return problems
+}
+
+// labeledMetricsProvider wraps a MetricsProvider and appends a fixed set of labels to every metric.
+// Config uses it to attach the TopicProcessor name, the container ID and Config.MetricsLabels to all metrics.
+type labeledMetricsProvider struct {
+	provider    MetricsProvider
+	labelNames  []string
+	labelValues []string
+}
+
+func newLabeledMetricsProvider(provider MetricsProvider, labels map[string]string) *labeledMetricsProvider {
+	labelNames := make([]string, 0, len(labels))
+	for name := range labels {
+		labelNames = append(labelNames, name)
+	}
+	sort.Strings(labelNames)
+	labelValues := make([]string, len(labelNames))
+	for i, name := range labelNames {
+		labelValues[i] = labels[name]
+	}
+	return &labeledMetricsProvider{provider, labelNames, labelValues}
+}
+
+func (p *labeledMetricsProvider) withLabelNames(labelNames []string) []string {
+	names := make([]string, 0, len(labelNames)+len(p.labelNames))
+	names = append(names, labelNames...)
+	return append(names, p.labelNames...)
+}
+
+// NewCounter creates a new Counter with the static labels appended to labelNames
+func (p *labeledMetricsProvider) NewCounter(name string, help string, labelNames ...string) Counter {
+	return &labeledMetric{
+		counter:     p.provider.NewCounter(name, help, p.withLabelNames(labelNames)...),
+		labelValues: p.labelValues,
+	}
+}
+
+// NewGauge creates a new Gauge with the static labels appended to labelNames
+func (p *labeledMetricsProvider) NewGauge(name string, help string, labelNames ...string) Gauge {
+	return &labeledMetric{
+		gauge:       p.provider.NewGauge(name, help, p.withLabelNames(labelNames)...),
+		labelValues: p.labelValues,
+	}
+}
+
+// NewSummary creates a new Summary with the static labels appended to labelNames
+func (p *labeledMetricsProvider) NewSummary(name string, help string, labelNames ...string) Summary {
+	return &labeledMetric{
+		summary:     p.provider.NewSummary(name, help, p.withLabelNames(labelNames)...),
+		labelValues: p.labelValues,
+	}
+}
+
+type labeledMetric struct {
+	counter     Counter
+	gauge       Gauge
+	summary     Summary
+	labelValues []string
+}
+
+func (m *labeledMetric) withLabelValues(labelValues []string) []string {
+	values := make([]string, 0, len(labelValues)+len(m.labelValues))
+	values = append(values, labelValues...)
+	return append(values, m.labelValues...)
+}
+
+func (m *labeledMetric) Inc(labelValues ...string) {
+	m.counter.Inc(m.withLabelValues(labelValues)...)
+}
+
+func (m *labeledMetric) Add(value float64, labelValues ...string) {
+	m.counter.Add(value, m.withLabelValues(labelValues)...)
+}
+
+func (m *labeledMetric) Set(value float64, labelValues ...string) {
+	m.gauge.Set(value, m.withLabelValues(labelValues)...)
+}
+
+func (m *labeledMetric) Observe(value float64, labelValues ...string) {
+	m.summary.Observe(value, m.withLabelValues(labelValues)...)
+}
+
+// partitionMetricsProvider appends a "partition" label to every metric, whose value is the partition RunLoop is
+// processing when the metric is updated (or an empty string outside of processing). Config uses it for the metrics
+// of the stores, which are shared by all partitions.
+type partitionMetricsProvider struct {
+	provider MetricsProvider
+	config   *Config
+}
+
+func (p *partitionMetricsProvider) NewCounter(name string, help string, labelNames ...string) Counter {
+	return &partitionMetric{labeledMetric{counter: p.provider.NewCounter(name, help, append(labelNames, "partition")...)}, p.config}
+}
+
+func (p *partitionMetricsProvider) NewGauge(name string, help string, labelNames ...string) Gauge {
+	return &partitionMetric{labeledMetric{gauge: p.provider.NewGauge(name, help, append(labelNames, "partition")...)}, p.config}
+}
+
+func (p *partitionMetricsProvider) NewSummary(name string, help string, labelNames ...string) Summary {
+	return &partitionMetric{labeledMetric{summary: p.provider.NewSummary(name, help, append(labelNames, "partition")...)}, p.config}
+}
+
+type partitionMetric struct {
+	labeledMetric
+	config *Config
+}
+
+func (m *partitionMetric) withPartition(labelValues []string) []string {
+	values := make([]string, 0, len(labelValues)+1)
+	values = append(values, labelValues...)
+	return append(values, m.config.activePartitionLabel())
+}
+
+func (m *partitionMetric) Inc(labelValues ...string) {
+	m.labeledMetric.Inc(m.withPartition(labelValues)...)
+}
+
+func (m *partitionMetric) Add(value float64, labelValues ...string) {
+	m.labeledMetric.Add(value, m.withPartition(labelValues)...)
+}
+
+func (m *partitionMetric) Set(value float64, labelValues ...string) {
+	m.labeledMetric.Set(value, m.withPartition(labelValues)...)
+}
+
+func (m *partitionMetric) Observe(value float64, labelValues ...string) {
+	m.labeledMetric.Observe(value, m.withPartition(labelValues)...)
+}
+
+// setActivePartition is called by RunLoop around the processing of a batch (-1 when no batch is being processed).
+func (config *Config) setActivePartition(partition int) {
+	atomic.StoreInt32(&config.activePartition, int32(partition+1))
+}
+
+func (config *Config) activePartitionLabel() string {
+	partition := atomic.LoadInt32(&config.activePartition)
+	if partition == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(partition - 1))
+}
@@ -0,0 +1,131 @@
+package kasper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingMetricsProvider struct {
+	labelNames map[string][]string
+	kinds      map[string]string
+	values     map[string]float64
+}
+
+func newRecordingMetricsProvider() *recordingMetricsProvider {
+	return &recordingMetricsProvider{
+		make(map[string][]string),
+		make(map[string]string),
+		make(map[string]float64),
+	}
+}
+
+type recordingMetric struct {
+	provider *recordingMetricsProvider
+	name     string
+}
+
+func (m *recordingMetric) key(labelValues []string) string {
+	return m.name + "{" + strings.Join(labelValues, ",") + "}"
+}
+
+func (m *recordingMetric) Inc(labelValues ...string) {
+	m.provider.values[m.key(labelValues)]++
+}
+
+func (m *recordingMetric) Add(value float64, labelValues ...string) {
+	m.provider.values[m.key(labelValues)] += value
+}
+
+func (m *recordingMetric) Set(value float64, labelValues ...string) {
+	m.provider.values[m.key(labelValues)] = value
+}
+
+func (m *recordingMetric) Observe(value float64, labelValues ...string) {
+	m.provider.values[m.key(labelValues)] += value
+}
+
+func (p *recordingMetricsProvider) NewCounter(name string, help string, labelNames ...string) Counter {
+	p.labelNames[name] = labelNames
+	p.kinds[name] = "counter"
+	return &recordingMetric{p, name}
+}
+
+func (p *recordingMetricsProvider) NewGauge(name string, help string, labelNames ...string) Gauge {
+	p.labelNames[name] = labelNames
+	p.kinds[name] = "gauge"
+	return &recordingMetric{p, name}
+}
+
+func (p *recordingMetricsProvider) NewSummary(name string, help string, labelNames ...string) Summary {
+	p.labelNames[name] = labelNames
+	p.kinds[name] = "summary"
+	return &recordingMetric{p, name}
+}
+
+func TestLabeledMetricsProvider(t *testing.T) {
+	inner := newRecordingMetricsProvider()
+	provider := newLabeledMetricsProvider(inner, map[string]string{"tenant": "acme", "environment": "test"})
+
+	provider.NewCounter("counter", "A counter", "topic").Inc("words")
+	provider.NewGauge("gauge", "A gauge", "topic", "partition").Set(42, "words", "3")
+	provider.NewSummary("summary", "A summary").Observe(7)
+
+	assert.Equal(t, []string{"topic", "environment", "tenant"}, inner.labelNames["counter"])
+	assert.Equal(t, []string{"topic", "partition", "environment", "tenant"}, inner.labelNames["gauge"])
+	assert.Equal(t, []string{"environment", "tenant"}, inner.labelNames["summary"])
+	assert.Equal(t, 1.0, inner.values["counter{words,test,acme}"])
+	assert.Equal(t, 42.0, inner.values["gauge{words,3,test,acme}"])
+	assert.Equal(t, 7.0, inner.values["summary{test,acme}"])
+}
+
+func TestConfig_metricsProvider(t *testing.T) {
+	inner := newRecordingMetricsProvider()
+	config := &Config{
+		TopicProcessorName: "hari-seldon",
+		ContainerID:        "container-1",
+		MetricsProvider:    inner,
+		MetricsLabels:      map[string]string{"environment": "test"},
+	}
+	config.metricsProvider().NewCounter("counter", "A counter", "topic", "partition").Inc("words", "0")
+
+	assert.Equal(t, []string{"topic", "partition", "containerID", "environment", "topicProcessor"}, inner.labelNames["counter"])
+	assert.Equal(t, 1.0, inner.values["counter{words,0,container-1,test,hari-seldon}"])
+	assert.True(t, config.metricsProvider() == config.metricsProvider())
+}
+
+func TestConfig_metricsProvider_Defaults(t *testing.T) {
+	config := &Config{TopicProcessorName: "ford-prefect"}
+	config.metricsProvider().NewGauge("gauge", "A gauge").Set(1)
+	assert.NotEqual(t, "", config.ContainerID)
+	assert.NotNil(t, config.MetricsProvider)
+}
+
+// uncomparableMetricsProvider cannot be compared with ==
+type uncomparableMetricsProvider struct {
+	*recordingMetricsProvider
+	names []string
+}
+
+func TestConfig_metricsProvider_Uncomparable(t *testing.T) {
+	config := &Config{MetricsProvider: uncomparableMetricsProvider{newRecordingMetricsProvider(), nil}}
+	provider := config.metricsProvider()
+	assert.True(t, provider == config.metricsProvider())
+	config.MetricsProvider = newRecordingMetricsProvider()
+	assert.False(t, provider == config.metricsProvider())
+}
+
+func TestConfig_metricsProvider_ReservedLabels(t *testing.T) {
+	inner := newRecordingMetricsProvider()
+	config := &Config{
+		TopicProcessorName: "hari-seldon",
+		ContainerID:        "container-1",
+		MetricsProvider:    inner,
+		MetricsLabels:      map[string]string{"containerID": "spoofed", "partition": "7", "tenant": "acme"},
+		Logger:             &noopLogger{},
+	}
+	config.metricsProvider().NewCounter("counter", "A counter", "topic", "partition").Inc("words", "0")
+	assert.Equal(t, []string{"topic", "partition", "containerID", "tenant", "topicProcessor"}, inner.labelNames["counter"])
+	assert.Equal(t, []string{"metrics label containerID is reserved", "metrics label partition is reserved"}, validateMetricsLabels(config.MetricsLabels))
+}
@@ -0,0 +1,166 @@
+package kasper
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Lease is a lock with an expiry, held by at most one holder at a time. It is used by LeaderElection.
+type Lease interface {
+	// TryAcquire acquires the lease for holder, or renews it if holder already owns it, for the given duration.
+	// It returns false if another holder owns the lease.
+	TryAcquire(holder string, duration time.Duration) (bool, error)
+	// Release gives up the lease if holder owns it.
+	Release(holder string) error
+}
+
+const redisAcquireScript = `
+local current = redis.call("GET", KEYS[1])
+if current == false or current == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0`
+
+const redisReleaseScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+// RedisLease is a Lease stored in a single Redis key, see NewRedisLease.
+type RedisLease struct {
+	conn redis.Conn
+	key  string
+}
+
+// NewRedisLease creates a Lease stored in the given Redis key. LeaderElection uses the lease from its own
+// goroutine, so conn must not be shared with the stores used by the MessageProcessors.
+func NewRedisLease(conn redis.Conn, key string) *RedisLease {
+	return &RedisLease{conn, key}
+}
+
+// TryAcquire acquires or renews the lease atomically with a Lua script.
+func (l *RedisLease) TryAcquire(holder string, duration time.Duration) (bool, error) {
+	acquired, err := redis.Int(l.conn.Do("EVAL", redisAcquireScript, 1, l.key, holder, int64(duration/time.Millisecond)))
+	return acquired == 1, err
+}
+
+// Release deletes the key if holder owns the lease.
+func (l *RedisLease) Release(holder string) error {
+	_, err := l.conn.Do("EVAL", redisReleaseScript, 1, l.key, holder)
+	return err
+}
+
+// LeaderElection elects a single leader among the containers sharing a Lease, so that singleton chores
+// (e.g. global expiry sweeps or index alias flips) run in only one container while all of them process
+// partitions. Containers are identified by Config.ContainerID, which must be unique.
+//
+//	election := kasper.NewLeaderElection(config, kasper.NewRedisLease(conn, "twitter-reach/leader"), 30*time.Second)
+//	go election.Run()
+//	defer election.Close()
+//	...
+//	if election.IsLeader() {
+//		sweepExpiredKeys()
+//	}
+//
+// The leader renews the lease every third of the lease duration. It considers itself leader only until the
+// lease would expire, so a leader that cannot reach the lease store steps down before another one is elected.
+type LeaderElection struct {
+	config   *Config
+	lease    Lease
+	duration time.Duration
+	logger   Logger
+	gauge    Gauge
+	mutex    sync.Mutex
+	leader   bool
+	expiry   time.Time
+	running  int32
+	close    chan struct{}
+	done     chan struct{}
+}
+
+// NewLeaderElection creates a LeaderElection. Call Run to take part in the election.
+func NewLeaderElection(config *Config, lease Lease, duration time.Duration) *LeaderElection {
+	return &LeaderElection{
+		config,
+		lease,
+		duration,
+		WithFields(config.logger(), Field{"topicProcessor", config.TopicProcessorName}),
+		config.metricsProvider().NewGauge("leader", "1 if this container is the leader, 0 otherwise"),
+		sync.Mutex{},
+		false,
+		time.Time{},
+		0,
+		make(chan struct{}),
+		make(chan struct{}),
+	}
+}
+
+// Run tries to acquire or renew the lease every third of the lease duration until Close is called.
+func (e *LeaderElection) Run() {
+	atomic.StoreInt32(&e.running, 1)
+	defer close(e.done)
+	ticker := e.config.clock().NewTicker(e.duration / 3)
+	defer ticker.Stop()
+	for {
+		e.campaign()
+		select {
+		case <-ticker.Chan():
+		case <-e.close:
+			return
+		}
+	}
+}
+
+func (e *LeaderElection) campaign() {
+	start := e.config.clock().Now()
+	acquired, err := e.lease.TryAcquire(e.config.ContainerID, e.duration)
+	if err != nil {
+		e.logger.Errorf("Cannot acquire leader lease: %s", err)
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if acquired != e.leader {
+		if acquired {
+			e.logger.Info("Elected leader")
+		} else {
+			e.logger.Info("Lost leadership")
+		}
+	}
+	e.leader = acquired
+	e.expiry = start.Add(e.duration)
+	if acquired {
+		e.gauge.Set(1)
+	} else {
+		e.gauge.Set(0)
+	}
+}
+
+// IsLeader returns true if this container holds the lease. It is safe to call IsLeader from any goroutine.
+func (e *LeaderElection) IsLeader() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.leader && e.config.clock().Now().Before(e.expiry)
+}
+
+// Close stops Run and releases the lease if this container holds it, so that another one is elected immediately.
+func (e *LeaderElection) Close() error {
+	close(e.close)
+	if atomic.LoadInt32(&e.running) == 1 {
+		<-e.done
+	}
+	e.mutex.Lock()
+	leader := e.leader
+	e.leader = false
+	e.mutex.Unlock()
+	e.gauge.Set(0)
+	if !leader {
+		return nil
+	}
+	return e.lease.Release(e.config.ContainerID)
+}
@@ -0,0 +1,71 @@
+package kasper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryLease struct {
+	clock  *fixedClock
+	holder string
+	expiry time.Time
+	err    error
+}
+
+func (l *memoryLease) TryAcquire(holder string, duration time.Duration) (bool, error) {
+	if l.err != nil {
+		return false, l.err
+	}
+	if l.holder != "" && l.holder != holder && l.clock.now.Before(l.expiry) {
+		return false, nil
+	}
+	l.holder = holder
+	l.expiry = l.clock.now.Add(duration)
+	return true, nil
+}
+
+func (l *memoryLease) Release(holder string) error {
+	if l.holder == holder {
+		l.holder = ""
+	}
+	return nil
+}
+
+func TestLeaderElection(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	lease := &memoryLease{clock: clock}
+	first := NewLeaderElection(&Config{ContainerID: "first", Clock: clock, Logger: &noopLogger{}}, lease, 30*time.Second)
+	second := NewLeaderElection(&Config{ContainerID: "second", Clock: clock, Logger: &noopLogger{}}, lease, 30*time.Second)
+
+	first.campaign()
+	second.campaign()
+	assert.True(t, first.IsLeader())
+	assert.False(t, second.IsLeader())
+
+	// The leader cannot renew the lease and steps down when it expires
+	lease.err = errors.New("connection refused")
+	clock.now = clock.now.Add(20 * time.Second)
+	first.campaign()
+	assert.True(t, first.IsLeader())
+	clock.now = clock.now.Add(10 * time.Second)
+	assert.False(t, first.IsLeader())
+
+	lease.err = nil
+	second.campaign()
+	assert.True(t, second.IsLeader())
+	assert.Nil(t, second.Close())
+	assert.Equal(t, "", lease.holder)
+	assert.False(t, second.IsLeader())
+}
+
+func TestLeaderElection_Run(t *testing.T) {
+	lease := &memoryLease{clock: &fixedClock{now: time.Unix(1000, 0)}}
+	election := NewLeaderElection(&Config{ContainerID: "first", Logger: &noopLogger{}}, lease, time.Hour)
+	go election.Run()
+	waitFor(t, election.IsLeader)
+	assert.Nil(t, election.Close())
+	assert.Equal(t, "", lease.holder)
+}
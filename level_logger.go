@@ -0,0 +1,106 @@
+package kasper
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// LevelSetter is implemented by loggers whose level can be changed at runtime (see Admin).
+// Levels are "debug", "info" and "error".
+type LevelSetter interface {
+	SetLevel(level string) error
+}
+
+const (
+	levelDebug int32 = iota
+	levelInfo
+	levelError
+)
+
+func parseLevel(level string) (int32, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return levelDebug, nil
+	case "info":
+		return levelInfo, nil
+	case "error":
+		return levelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (expected debug, info or error)", level)
+}
+
+// NewLevelLogger wraps a Logger so that entries below level are discarded. The level can be changed at runtime
+// with SetLevel, which also applies to the loggers derived with With. Panics are always logged.
+func NewLevelLogger(logger Logger, level string) (*LevelLogger, error) {
+	parsed, err := parseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return &LevelLogger{logger, &parsed}, nil
+}
+
+// LevelLogger is a Logger whose level can be changed at runtime, see NewLevelLogger.
+type LevelLogger struct {
+	logger Logger
+	level  *int32
+}
+
+// SetLevel changes the level of the logger and of all loggers derived from it.
+func (l *LevelLogger) SetLevel(level string) error {
+	parsed, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(l.level, parsed)
+	return nil
+}
+
+func (l *LevelLogger) enabled(level int32) bool {
+	return atomic.LoadInt32(l.level) <= level
+}
+
+// With returns a logger with the fields attached, sharing the level of this logger.
+func (l *LevelLogger) With(fields ...Field) StructuredLogger {
+	return &LevelLogger{WithFields(l.logger, fields...), l.level}
+}
+
+func (l *LevelLogger) Debug(vs ...interface{}) {
+	if l.enabled(levelDebug) {
+		l.logger.Debug(vs...)
+	}
+}
+
+func (l *LevelLogger) Debugf(format string, vs ...interface{}) {
+	if l.enabled(levelDebug) {
+		l.logger.Debugf(format, vs...)
+	}
+}
+
+func (l *LevelLogger) Info(vs ...interface{}) {
+	if l.enabled(levelInfo) {
+		l.logger.Info(vs...)
+	}
+}
+
+func (l *LevelLogger) Infof(format string, vs ...interface{}) {
+	if l.enabled(levelInfo) {
+		l.logger.Infof(format, vs...)
+	}
+}
+
+func (l *LevelLogger) Error(vs ...interface{}) {
+	l.logger.Error(vs...)
+}
+
+func (l *LevelLogger) Errorf(format string, vs ...interface{}) {
+	l.logger.Errorf(format, vs...)
+}
+
+func (l *LevelLogger) Panic(vs ...interface{}) {
+	l.logger.Panic(vs...)
+}
+
+func (l *LevelLogger) Panicf(format string, vs ...interface{}) {
+	l.logger.Panicf(format, vs...)
+}
@@ -0,0 +1,38 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelLogger(t *testing.T) {
+	zap := &recordingZapLogger{}
+	logger, err := NewLevelLogger(NewZapLogger(zap), "info")
+	assert.Nil(t, err)
+	child := logger.With(Field{"partition", 3})
+
+	logger.Debug("hidden")
+	child.Infof("processed %d messages", 10)
+	assert.Nil(t, logger.SetLevel("DEBUG"))
+	child.Debugf("offset %d", 42)
+	assert.Nil(t, logger.SetLevel("error"))
+	logger.Info("hidden")
+	child.Error("failed")
+
+	assert.Equal(t, []string{
+		"INFO processed 10 messages [partition 3]",
+		"DEBUG offset 42 [partition 3]",
+		"ERROR failed [partition 3]",
+	}, zap.entries)
+}
+
+func TestLevelLogger_InvalidLevel(t *testing.T) {
+	_, err := NewLevelLogger(&noopLogger{}, "verbose")
+	assert.EqualError(t, err, `unknown log level "verbose" (expected debug, info or error)`)
+
+	logger, _ := NewLevelLogger(&noopLogger{}, "info")
+	assert.NotNil(t, logger.SetLevel("warn"))
+	assert.True(t, logger.enabled(levelInfo))
+	assert.False(t, logger.enabled(levelDebug))
+}
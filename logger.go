@@ -1,6 +1,7 @@
 package kasper
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	stdlibLog "log"
@@ -22,6 +23,98 @@ type Logger interface {
 	Panicf(string, ...interface{})
 }
 
+// Field is a key-value pair attached to log entries, such as the topic or the partition being processed.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// StructuredLogger is a Logger that attaches fields to log entries.
+// All Kasper loggers implement it, and adapters are available for zap (NewZapLogger) and slog (NewSlogLogger).
+type StructuredLogger interface {
+	Logger
+	// With returns a logger that attaches the given fields to all entries, in addition to the fields
+	// already attached to this logger.
+	With(fields ...Field) StructuredLogger
+}
+
+// WithFields returns a logger that attaches fields to all entries.
+// If logger does not implement StructuredLogger, the fields are appended to the messages as key=value pairs.
+func WithFields(logger Logger, fields ...Field) Logger {
+	if logger == nil {
+		return nil
+	}
+	if structured, ok := logger.(StructuredLogger); ok {
+		return structured.With(fields...)
+	}
+	return &fieldsLogger{logger, fields}
+}
+
+func formatFields(fields []Field) string {
+	var buffer bytes.Buffer
+	for _, field := range fields {
+		fmt.Fprintf(&buffer, " %s=%v", field.Key, field.Value)
+	}
+	return buffer.String()
+}
+
+// fieldsLogger appends fields to the messages of a Logger that does not support fields.
+type fieldsLogger struct {
+	logger Logger
+	fields []Field
+}
+
+func (l *fieldsLogger) With(fields ...Field) StructuredLogger {
+	return &fieldsLogger{l.logger, append(append([]Field{}, l.fields...), fields...)}
+}
+
+// Debug messages are only formatted if the wrapped logger writes them, since RunLoop logs every message at debug level.
+func (l *fieldsLogger) Debug(vs ...interface{}) {
+	l.logger.Debug(&lazyMessage{"", vs, l.fields})
+}
+
+func (l *fieldsLogger) Debugf(format string, vs ...interface{}) {
+	l.logger.Debug(&lazyMessage{format, vs, l.fields})
+}
+
+func (l *fieldsLogger) Info(vs ...interface{}) {
+	l.logger.Info(fmt.Sprint(vs...) + formatFields(l.fields))
+}
+
+func (l *fieldsLogger) Infof(format string, vs ...interface{}) {
+	l.logger.Info(fmt.Sprintf(format, vs...) + formatFields(l.fields))
+}
+
+func (l *fieldsLogger) Error(vs ...interface{}) {
+	l.logger.Error(fmt.Sprint(vs...) + formatFields(l.fields))
+}
+
+func (l *fieldsLogger) Errorf(format string, vs ...interface{}) {
+	l.logger.Error(fmt.Sprintf(format, vs...) + formatFields(l.fields))
+}
+
+func (l *fieldsLogger) Panic(vs ...interface{}) {
+	l.logger.Panic(fmt.Sprint(vs...) + formatFields(l.fields))
+}
+
+func (l *fieldsLogger) Panicf(format string, vs ...interface{}) {
+	l.logger.Panic(fmt.Sprintf(format, vs...) + formatFields(l.fields))
+}
+
+// lazyMessage formats a message with fields when it is printed.
+type lazyMessage struct {
+	format string
+	vs     []interface{}
+	fields []Field
+}
+
+func (m *lazyMessage) String() string {
+	if m.format == "" {
+		return fmt.Sprint(m.vs...) + formatFields(m.fields)
+	}
+	return fmt.Sprintf(m.format, m.vs...) + formatFields(m.fields)
+}
+
 // NewJSONLogger uses the logrus JSON formatter.
 // See https://github.com/sirupsen/logrus
 func NewJSONLogger(label string, debug bool) Logger {
@@ -42,64 +135,85 @@ func newLogrus(label string, debug bool, formatter logrus.Formatter) Logger {
 	} else {
 		logger.Level = logrus.InfoLevel
 	}
-	return logger.
+	return &logrusLogger{logger.
 		WithField("type", "kasper").
-		WithField("label", label)
+		WithField("label", label)}
+}
+
+// NewLogrusLogger adapts a logrus entry (or logger, with logrus.NewEntry) to StructuredLogger.
+// See https://github.com/sirupsen/logrus
+func NewLogrusLogger(entry *logrus.Entry) StructuredLogger {
+	return &logrusLogger{entry}
+}
+
+type logrusLogger struct {
+	*logrus.Entry
+}
+
+func (l *logrusLogger) With(fields ...Field) StructuredLogger {
+	logrusFields := make(logrus.Fields, len(fields))
+	for _, field := range fields {
+		logrusFields[field.Key] = field.Value
+	}
+	return &logrusLogger{l.Entry.WithFields(logrusFields)}
 }
 
 type stdlibLogger struct {
-	log   *stdlibLog.Logger
-	debug bool
+	log    *stdlibLog.Logger
+	debug  bool
+	fields string
+}
+
+func (l *stdlibLogger) With(fields ...Field) StructuredLogger {
+	return &stdlibLogger{l.log, l.debug, l.fields + formatFields(fields)}
 }
 
 func (l *stdlibLogger) Debug(vs ...interface{}) {
 	if l.debug {
-		vs = append([]interface{}{"DEBUG "}, vs...)
-		l.log.Print(vs...)
+		l.log.Print("DEBUG ", fmt.Sprint(vs...), l.fields)
 	}
 }
 
 func (l *stdlibLogger) Debugf(format string, vs ...interface{}) {
 	if l.debug {
-		l.log.Printf(fmt.Sprintf("DEBUG %s", format), vs...)
+		l.log.Printf("DEBUG %s%s", fmt.Sprintf(format, vs...), l.fields)
 	}
 }
 
 func (l *stdlibLogger) Info(vs ...interface{}) {
-	vs = append([]interface{}{"INFO "}, vs...)
-	l.log.Print(vs...)
+	l.log.Print("INFO ", fmt.Sprint(vs...), l.fields)
 }
 
 func (l *stdlibLogger) Infof(format string, vs ...interface{}) {
-	l.log.Printf(fmt.Sprintf("INFO %s", format), vs...)
+	l.log.Printf("INFO %s%s", fmt.Sprintf(format, vs...), l.fields)
 }
 
 func (l *stdlibLogger) Error(vs ...interface{}) {
-	vs = append([]interface{}{"ERROR "}, vs...)
-	l.log.Print(vs...)
+	l.log.Print("ERROR ", fmt.Sprint(vs...), l.fields)
 }
 
 func (l *stdlibLogger) Errorf(format string, vs ...interface{}) {
-	l.log.Printf(fmt.Sprintf("ERROR %s", format), vs...)
+	l.log.Printf("ERROR %s%s", fmt.Sprintf(format, vs...), l.fields)
 }
 
 func (l *stdlibLogger) Panic(vs ...interface{}) {
-	vs = append([]interface{}{"PANIC "}, vs...)
-	l.log.Panic(vs...)
+	l.log.Panic("PANIC ", fmt.Sprint(vs...), l.fields)
 }
 
 func (l *stdlibLogger) Panicf(format string, vs ...interface{}) {
-	l.log.Panicf(fmt.Sprintf("PANIC %s", format), vs...)
+	l.log.Panicf("PANIC %s%s", fmt.Sprintf(format, vs...), l.fields)
 }
 
 // NewBasicLogger uses the Go standard library logger.
 // See https://golang.org/pkg/log/
 func NewBasicLogger(debug bool) Logger {
-	return &stdlibLogger{stdlibLog.New(os.Stderr, "(KASPER) ", stdlibLog.LstdFlags), debug}
+	return &stdlibLogger{stdlibLog.New(os.Stderr, "(KASPER) ", stdlibLog.LstdFlags), debug, ""}
 }
 
 type noopLogger struct{}
 
+func (l noopLogger) With(...Field) StructuredLogger { return l }
+
 func (noopLogger) Debug(...interface{}) {}
 
 func (noopLogger) Debugf(string, ...interface{}) {}
@@ -1,6 +1,9 @@
 package kasper
 
 import (
+	"bytes"
+	"fmt"
+	stdlibLog "log"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,3 +31,112 @@ func TestLogger(t *testing.T) {
 	testLogger(t, NewTextLogger("test", true))
 	testLogger(t, NewJSONLogger("test", false))
 }
+
+type recordingZapLogger struct {
+	entries []string
+}
+
+func (l *recordingZapLogger) record(level string, msg string, keysAndValues []interface{}) {
+	l.entries = append(l.entries, fmt.Sprint(level, " ", msg, " ", keysAndValues))
+}
+
+func (l *recordingZapLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.record("DEBUG", msg, keysAndValues)
+}
+
+func (l *recordingZapLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.record("INFO", msg, keysAndValues)
+}
+
+func (l *recordingZapLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.record("ERROR", msg, keysAndValues)
+}
+
+func (l *recordingZapLogger) Panicw(msg string, keysAndValues ...interface{}) {
+	l.record("PANIC", msg, keysAndValues)
+	panic(msg)
+}
+
+func TestStructuredLogger(t *testing.T) {
+	testLogger(t, NewZapLogger(&recordingZapLogger{}).With(Field{"topic", "words"}))
+	testLogger(t, NewBasicLogger(false).(StructuredLogger).With(Field{"topic", "words"}))
+	testLogger(t, WithFields(&recordingLogger{noopLogger{}, nil}, Field{"topic", "words"}))
+	assert.Nil(t, WithFields(nil, Field{"topic", "words"}))
+}
+
+func TestZapLogger_With(t *testing.T) {
+	zap := &recordingZapLogger{}
+	logger := NewZapLogger(zap).With(Field{"topic", "words"})
+	logger.With(Field{"partition", 3}).Infof("processed %d messages", 10)
+	logger.Error("failed")
+	assert.Equal(t, []string{
+		"INFO processed 10 messages [topic words partition 3]",
+		"ERROR failed [topic words]",
+	}, zap.entries)
+}
+
+func TestZapLogger_DebugDisabled(t *testing.T) {
+	zap := &recordingZapLogger{}
+	debugEnabled := false
+	logger := NewZapLoggerWithLevel(zap, func() bool { return debugEnabled }).With(Field{"topic", "words"})
+	arg := &countingStringer{}
+	logger.Debug(arg)
+	logger.Debugf("offset %s", arg)
+	logger.Infof("offset %s", arg)
+	assert.Equal(t, 1, arg.count)
+	debugEnabled = true
+	logger.Debugf("offset %s", arg)
+	assert.Equal(t, 2, arg.count)
+	assert.Equal(t, []string{
+		"INFO offset message [topic words]",
+		"DEBUG offset message [topic words]",
+	}, zap.entries)
+}
+
+func TestStdlibLogger_With(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &stdlibLogger{stdlibLog.New(&buffer, "", 0), false, ""}
+	logger.With(Field{"topic", "words"}, Field{"partition", 3}).Info("processed ", 10, " messages")
+	logger.With(Field{"store", "Redis"}).Debug("not logged")
+	assert.Equal(t, "INFO processed 10 messages topic=words partition=3\n", buffer.String())
+}
+
+// recordingLogger does not implement StructuredLogger
+type recordingLogger struct {
+	Logger
+	messages []string
+}
+
+func (l *recordingLogger) Info(vs ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprint(vs...))
+}
+
+func TestWithFields(t *testing.T) {
+	inner := &recordingLogger{noopLogger{}, nil}
+	logger := WithFields(inner, Field{"topic", "words"})
+	logger.Infof("processed %d messages", 10)
+	WithFields(logger, Field{"partition", 3}).Info("done")
+	assert.Equal(t, []string{
+		"processed 10 messages topic=words",
+		"done topic=words partition=3",
+	}, inner.messages)
+}
+
+type countingStringer struct {
+	count int
+}
+
+func (s *countingStringer) String() string {
+	s.count++
+	return "message"
+}
+
+func TestWithFields_DebugFormattedLazily(t *testing.T) {
+	stringer := &countingStringer{}
+	WithFields(NewBasicLogger(false), Field{"topic", "words"}).Debugf("Received: %s", stringer)
+	assert.Equal(t, 0, stringer.count)
+
+	inner := &recordingLogger{NewBasicLogger(true), nil}
+	WithFields(inner, Field{"topic", "words"}).Debugf("Received: %s", stringer)
+	assert.Equal(t, 1, stringer.count)
+}
@@ -0,0 +1,99 @@
+package kasper
+
+import "errors"
+
+// ErrNotRunning is returned by TopicProcessor.Pause, Resume, Flush, Offsets and Reconfigure when RunLoop is not running.
+var ErrNotRunning = errors.New("topic processor is not running")
+
+type loopCommand int
+
+const (
+	commandPause loopCommand = iota
+	commandResume
+	commandFlush
+	commandOffsets
+	commandCall
+	commandReconfigure
+)
+
+// loopRequest is handled by RunLoop between batches, so that runtime operations never run concurrently
+// with message processing.
+type loopRequest struct {
+	command loopCommand
+	offsets map[string]map[int]int64
+	update  RuntimeConfig
+	call    func() error
+	callErr error
+	done    chan error
+}
+
+func (tp *TopicProcessor) request(command loopCommand) (*loopRequest, error) {
+	return tp.send(&loopRequest{command: command, done: make(chan error, 1)})
+}
+
+func (tp *TopicProcessor) send(request *loopRequest) (*loopRequest, error) {
+	if !tp.IsRunning() {
+		return nil, ErrNotRunning
+	}
+	select {
+	case tp.requests <- request:
+		return request, <-request.done
+	case <-tp.loopDone:
+		return nil, ErrNotRunning
+	}
+}
+
+// Pause stops consuming messages until Resume is called. Partitions stay assigned, and the messages already
+// received remain in their batches until the next batch wait duration or call to Flush.
+// It is safe to call Pause from any goroutine.
+func (tp *TopicProcessor) Pause() error {
+	_, err := tp.request(commandPause)
+	return err
+}
+
+// Resume resumes consuming messages after Pause. It is safe to call Resume from any goroutine.
+func (tp *TopicProcessor) Resume() error {
+	_, err := tp.request(commandResume)
+	return err
+}
+
+// Flush immediately processes the messages received so far, as if the batch wait duration had elapsed, and
+// returns the processing error, if any, in which case RunLoop also returns it.
+// It is safe to call Flush from any goroutine.
+func (tp *TopicProcessor) Flush() error {
+	_, err := tp.request(commandFlush)
+	return err
+}
+
+// Offsets returns the next offset to consume (i.e. the last marked offset) by topic and partition.
+// It is safe to call Offsets from any goroutine.
+func (tp *TopicProcessor) Offsets() (map[string]map[int]int64, error) {
+	request, err := tp.request(commandOffsets)
+	if err != nil {
+		return nil, err
+	}
+	return request.offsets, nil
+}
+
+func (tp *TopicProcessor) offsets() map[string]map[int]int64 {
+	offsets := make(map[string]map[int]int64, len(tp.inputTopics))
+	for _, topic := range tp.inputTopics {
+		offsets[topic] = make(map[int]int64, len(tp.partitionProcessors))
+	}
+	for partition, pp := range tp.partitionProcessors {
+		for topic, pom := range pp.offsetManagers {
+			offset, _ := pom.NextOffset()
+			offsets[topic][int(partition)] = offset
+		}
+	}
+	return offsets
+}
+
+// runInLoop runs call in RunLoop between batches, e.g. to use a store that is not safe for concurrent use.
+func (tp *TopicProcessor) runInLoop(call func() error) error {
+	request, err := tp.send(&loopRequest{command: commandCall, call: call, done: make(chan error, 1)})
+	if err != nil {
+		return err
+	}
+	return request.callErr
+}
@@ -0,0 +1,93 @@
+package kasper
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingProcessor struct {
+	mutex sync.Mutex
+	count int
+	err   error
+}
+
+func (p *countingProcessor) Process(messages []*sarama.ConsumerMessage, sender Sender) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.count += len(messages)
+	return p.err
+}
+
+func (p *countingProcessor) processed() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.count
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 5s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// flushUntil flushes until the messages forwarded by the partition consumers have reached RunLoop and been processed.
+func flushUntil(t *testing.T, tp *fakeTopicProcessor, processor *countingProcessor, count int) {
+	waitFor(t, func() bool {
+		assert.Nil(t, tp.Flush())
+		return processor.processed() == count
+	})
+}
+
+func TestTopicProcessor_PauseResumeFlush(t *testing.T) {
+	processor := &countingProcessor{}
+	tp := newFakeTopicProcessor(&Config{InputPartitions: []int{0, 1}}, processor)
+	assert.Equal(t, ErrNotRunning, tp.Flush())
+	done := tp.start()
+
+	tp.send(0, 10, "a")
+	tp.send(1, 20, "b")
+	flushUntil(t, tp, processor, 2)
+
+	offsets, err := tp.Offsets()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]map[int]int64{"input": {0: 11, 1: 21}}, offsets)
+
+	assert.Nil(t, tp.Pause())
+	tp.send(0, 11, "c")
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, tp.Flush())
+	assert.Equal(t, 2, processor.processed())
+
+	assert.Nil(t, tp.Resume())
+	flushUntil(t, tp, processor, 3)
+
+	tp.Close()
+	assert.Nil(t, <-done)
+	assert.Equal(t, ErrNotRunning, tp.Pause())
+	_, err = tp.Offsets()
+	assert.Equal(t, ErrNotRunning, err)
+}
+
+func TestTopicProcessor_FlushError(t *testing.T) {
+	processor := &countingProcessor{err: errors.New("boom")}
+	tp := newFakeTopicProcessor(&Config{}, processor)
+	done := tp.start()
+	tp.send(0, 0, "a")
+	var err error
+	waitFor(t, func() bool {
+		err = tp.Flush()
+		return err != nil
+	})
+	assert.EqualError(t, err, "boom")
+	assert.EqualError(t, <-done, "boom")
+	assert.False(t, tp.IsRunning())
+}
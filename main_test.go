@@ -0,0 +1,19 @@
+package kasper
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+// TestMain connects to the Redis and Elasticsearch of the CI environment (see getCIHost), unless -short is set.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !testing.Short() {
+		setupElasticsearch()
+		setupMultiElasticsearch()
+		setupMultiRedis()
+		setupRedis()
+	}
+	os.Exit(m.Run())
+}
@@ -53,6 +53,19 @@ func (s *Map) Delete(key string) error {
 	return nil
 }
 
+// DeleteAll removes multiple values by key. Does not return an error if keys are not present.
+func (s *Map) DeleteAll(keys []string) error {
+	for _, key := range keys {
+		delete(s.m, key)
+	}
+	return nil
+}
+
+// Mutate inserts or updates the puts, then deletes the keys in deletes, see TransactionalStore.
+func (s *Map) Mutate(puts []KeyValue, deletes []string) error {
+	return mutateValues(s, puts, deletes)
+}
+
 // Flush does nothing.
 func (s *Map) Flush() error {
 	return nil
@@ -62,3 +75,18 @@ func (s *Map) Flush() error {
 func (s *Map) GetMap() map[string][]byte {
 	return s.m
 }
+
+// Increment adds delta to the counter stored at key, see CounterStore.
+func (s *Map) Increment(key string, delta int64) (int64, error) {
+	return incrementValue(s, key, delta)
+}
+
+// PutIfAbsent inserts a value if the key does not exist, see ConditionalStore.
+func (s *Map) PutIfAbsent(key string, value []byte) (bool, error) {
+	return compareAndSetValue(s, key, nil, value)
+}
+
+// CompareAndSet updates a value if the current value is equal to expected, see ConditionalStore.
+func (s *Map) CompareAndSet(key string, expected, value []byte) (bool, error) {
+	return compareAndSetValue(s, key, expected, value)
+}
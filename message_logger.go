@@ -0,0 +1,57 @@
+package kasper
+
+import (
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// messageLoggerProvider is implemented by the Sender given to MessageProcessor.Process by the TopicProcessor.
+type messageLoggerProvider interface {
+	MessageLogger(message *sarama.ConsumerMessage) Logger
+}
+
+// MessageLogger returns a logger for processing message, so that logs from MessageProcessor.Process can be
+// correlated with Kasper's own logs. It carries the same topicProcessor and partition fields as Kasper's logs,
+// plus the topic and offset of message and its trace ID, if any (see Config.TraceIDHeader and Config.MessageTraceID).
+// sender must be the Sender given to Process; for any other Sender (e.g. in unit tests) a no-op logger is returned.
+//
+//	func (*TweetProcessor) Process(messages []*sarama.ConsumerMessage, sender kasper.Sender) error {
+//		for _, message := range messages {
+//			kasper.MessageLogger(sender, message).Debug("Processing tweet")
+//		}
+//		...
+//	}
+func MessageLogger(sender Sender, message *sarama.ConsumerMessage) Logger {
+	provider, ok := sender.(messageLoggerProvider)
+	if !ok {
+		return noopLogger{}
+	}
+	return provider.MessageLogger(message)
+}
+
+// headerTraceID returns the value of the header of message, or the trace ID if it is a W3C traceparent value
+// ("version-traceid-parentid-flags").
+func headerTraceID(message *sarama.ConsumerMessage, header string) string {
+	for _, recordHeader := range message.Headers {
+		if recordHeader == nil || string(recordHeader.Key) != header {
+			continue
+		}
+		value := string(recordHeader.Value)
+		if parts := strings.Split(value, "-"); len(parts) == 4 && len(parts[1]) == 32 {
+			return parts[1]
+		}
+		return value
+	}
+	return ""
+}
+
+func newMessageLogger(logger Logger, traceID func(*sarama.ConsumerMessage) string, message *sarama.ConsumerMessage) Logger {
+	fields := []Field{{"topic", message.Topic}, {"offset", message.Offset}}
+	if traceID != nil {
+		if id := traceID(message); id != "" {
+			fields = append(fields, Field{"traceID", id})
+		}
+	}
+	return WithFields(logger, fields...)
+}
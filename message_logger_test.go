@@ -0,0 +1,57 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageLogger(t *testing.T) {
+	zap := &recordingZapLogger{}
+	config := &Config{
+		MessageTraceID: func(message *sarama.ConsumerMessage) string {
+			return string(message.Key)
+		},
+	}
+	pp := &partitionProcessor{
+		topicProcessor: &TopicProcessor{config: config},
+		logger:         NewZapLogger(zap).With(Field{"partition", 3}),
+	}
+	sender := newSender(pp)
+
+	MessageLogger(sender, &sarama.ConsumerMessage{Topic: "tweets", Offset: 42, Key: []byte("trace-1")}).Info("processing")
+	MessageLogger(sender, &sarama.ConsumerMessage{Topic: "tweets", Offset: 43}).Info("processing")
+	assert.Equal(t, []string{
+		"INFO processing [partition 3 topic tweets offset 42 traceID trace-1]",
+		"INFO processing [partition 3 topic tweets offset 43]",
+	}, zap.entries)
+}
+
+func TestMessageLogger_TraceIDHeader(t *testing.T) {
+	zap := &recordingZapLogger{}
+	pp := &partitionProcessor{
+		topicProcessor: &TopicProcessor{config: &Config{}},
+		logger:         NewZapLogger(zap),
+	}
+	sender := newSender(pp)
+	traceparent := &sarama.RecordHeader{
+		Key:   []byte("traceparent"),
+		Value: []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+	}
+	MessageLogger(sender, &sarama.ConsumerMessage{Topic: "tweets", Offset: 42, Headers: []*sarama.RecordHeader{traceparent}}).Info("processing")
+
+	// Other headers are used as they are
+	pp.topicProcessor.config.TraceIDHeader = "x-request-id"
+	requestID := &sarama.RecordHeader{Key: []byte("x-request-id"), Value: []byte("req-1")}
+	MessageLogger(sender, &sarama.ConsumerMessage{Topic: "tweets", Offset: 43, Headers: []*sarama.RecordHeader{traceparent, requestID}}).Info("processing")
+	assert.Equal(t, []string{
+		"INFO processing [topic tweets offset 42 traceID 4bf92f3577b34da6a3ce929d0e0e4736]",
+		"INFO processing [topic tweets offset 43 traceID req-1]",
+	}, zap.entries)
+}
+
+func TestMessageLogger_OtherSender(t *testing.T) {
+	logger := MessageLogger(nil, &sarama.ConsumerMessage{Topic: "tweets"})
+	assert.Equal(t, noopLogger{}, logger)
+}
@@ -1,5 +1,7 @@
 package kasper
 
+import "fmt"
+
 // Counter is a single float metric that can be incremented by one or added to.
 type Counter interface {
 	Inc(labelValues ...string)
@@ -22,3 +24,21 @@ type MetricsProvider interface {
 	NewGauge(name string, help string, labelNames ...string) Gauge
 	NewSummary(name string, help string, labelNames ...string) Summary
 }
+
+// MetricsPusher is implemented by MetricsProviders that push metrics to a remote server (e.g. InfluxDB)
+// rather than having them scraped. TopicProcessor calls Push every Config.MetricsUpdateInterval.
+type MetricsPusher interface {
+	Push() error
+}
+
+// MetricsPushError is returned by MetricsPusher.Push when some metrics could not be delivered.
+type MetricsPushError struct {
+	// Number of data points that were dropped
+	Dropped int
+	// Last error encountered while pushing
+	Err error
+}
+
+func (err *MetricsPushError) Error() string {
+	return fmt.Sprintf("%d metrics dropped: %s", err.Dropped, err.Err)
+}
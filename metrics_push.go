@@ -0,0 +1,77 @@
+package kasper
+
+// metricsPushMonitor pushes metrics when the MetricsProvider is a MetricsPusher and keeps track of failures,
+// so that losing metrics is visible in the logs, in the metrics themselves and in TopicProcessor.Metrics().
+// Pushes run in a separate goroutine so that RunLoop is never blocked by the metrics server. At most one push is
+// queued while another one is in progress; ticks that arrive when the queue is full are skipped, which only delays
+// the metrics since the pushed values are cumulative.
+type metricsPushMonitor struct {
+	pusher         MetricsPusher
+	logger         Logger
+	stats          *runtimeStats
+	errorCount     int64
+	droppedCount   int64
+	errorCounter   Counter
+	droppedCounter Counter
+	queue          chan struct{}
+	started        bool
+}
+
+func newMetricsPushMonitor(config *Config) *metricsPushMonitor {
+	provider := config.metricsProvider()
+	pusher, _ := config.MetricsProvider.(MetricsPusher)
+	return &metricsPushMonitor{
+		pusher,
+		config.logger(),
+		config.stats(),
+		0,
+		0,
+		provider.NewCounter("metrics_push_error_count", "Number of failed metrics pushes"),
+		provider.NewCounter("metrics_dropped_count", "Number of metrics data points that could not be pushed"),
+		make(chan struct{}, 1),
+		false,
+	}
+}
+
+// push queues a push, starting the pushing goroutine on first use. It must be called from RunLoop.
+func (m *metricsPushMonitor) push() {
+	if m.pusher == nil {
+		return
+	}
+	if !m.started {
+		m.started = true
+		go func(queue chan struct{}) {
+			for range queue {
+				m.pushMetrics()
+			}
+		}(m.queue)
+	}
+	select {
+	case m.queue <- struct{}{}:
+	default:
+		m.logger.Debug("Skipping metrics push, the previous one is still in progress")
+	}
+}
+
+// close stops the pushing goroutine once the queued push, if any, is complete. Later pushes are skipped.
+func (m *metricsPushMonitor) close() {
+	if m.queue != nil {
+		close(m.queue)
+		m.queue = nil
+	}
+}
+
+func (m *metricsPushMonitor) pushMetrics() {
+	err := m.pusher.Push()
+	if err == nil {
+		return
+	}
+	m.errorCount++
+	m.errorCounter.Inc()
+	if pushErr, ok := err.(*MetricsPushError); ok {
+		m.droppedCount += int64(pushErr.Dropped)
+		m.droppedCounter.Add(float64(pushErr.Dropped))
+	}
+	m.stats.addError("metrics")
+	m.logger.Errorf("Failed to push metrics (%d failed pushes and %d dropped data points so far): %s", m.errorCount, m.droppedCount, err)
+}
@@ -0,0 +1,84 @@
+package kasper
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingMetricsPusher struct {
+	*recordingMetricsProvider
+	err error
+}
+
+func (p *failingMetricsPusher) Push() error {
+	return p.err
+}
+
+func TestMetricsPushMonitor(t *testing.T) {
+	provider := &failingMetricsPusher{newRecordingMetricsProvider(), nil}
+	config := &Config{
+		TopicProcessorName: "hari-seldon",
+		ContainerID:        "container-1",
+		MetricsProvider:    provider,
+		Logger:             NewBasicLogger(false),
+	}
+	monitor := newMetricsPushMonitor(config)
+
+	monitor.pushMetrics()
+	assert.Equal(t, 0.0, provider.values["metrics_push_error_count{container-1,hari-seldon}"])
+
+	provider.err = &MetricsPushError{3, errors.New("connection refused")}
+	monitor.pushMetrics()
+	provider.err = errors.New("timeout")
+	monitor.pushMetrics()
+	assert.Equal(t, 2.0, provider.values["metrics_push_error_count{container-1,hari-seldon}"])
+	assert.Equal(t, 3.0, provider.values["metrics_dropped_count{container-1,hari-seldon}"])
+	assert.Equal(t, "counter", provider.kinds["metrics_push_error_count"])
+	assert.Equal(t, "counter", provider.kinds["metrics_dropped_count"])
+	assert.Equal(t, int64(2), config.stats().snapshot(time.Now()).ErrorCounts["metrics"])
+}
+
+type blockingMetricsPusher struct {
+	*recordingMetricsProvider
+	mutex   sync.Mutex
+	pushes  int
+	release chan struct{}
+}
+
+func (p *blockingMetricsPusher) Push() error {
+	<-p.release
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pushes++
+	return nil
+}
+
+func (p *blockingMetricsPusher) count() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.pushes
+}
+
+func TestMetricsPushMonitor_Async(t *testing.T) {
+	provider := &blockingMetricsPusher{recordingMetricsProvider: newRecordingMetricsProvider(), release: make(chan struct{})}
+	monitor := newMetricsPushMonitor(&Config{MetricsProvider: provider, Logger: &noopLogger{}})
+
+	// The first push blocks in the goroutine, the second one is queued and the others are skipped
+	monitor.push()
+	waitFor(t, func() bool { return len(monitor.queue) == 0 })
+	monitor.push()
+	monitor.push()
+	monitor.push()
+	provider.release <- struct{}{}
+	provider.release <- struct{}{}
+	waitFor(t, func() bool { return provider.count() == 2 })
+
+	monitor.close()
+	monitor.push()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 2, provider.count())
+}
@@ -0,0 +1,93 @@
+package kasper
+
+// MigrationOptions configures MigrateStore.
+type MigrationOptions struct {
+	// Only the keys starting with Prefix are migrated (all keys if empty)
+	Prefix string
+	// Number of keys written to the destination with each PutAll (defaults to 1000)
+	BatchSize int
+	// Keys that already exist in the destination are skipped, so that an interrupted migration can be resumed
+	// by running it again
+	SkipExisting bool
+	// Progress is called after each batch has been written
+	Progress func(MigrationProgress)
+}
+
+// MigrationProgress reports the progress of MigrateStore.
+type MigrationProgress struct {
+	// Number of keys read from the source
+	Read int
+	// Number of keys written to the destination
+	Written int
+	// Number of keys dropped by the transform function or skipped because they already exist
+	Skipped int
+}
+
+const defaultMigrationBatchSize = 1000
+
+// MigrateStore copies the keys of src to dst, e.g. to move state to another backend or key schema. src must
+// implement IterableStore. Each key-value pair is passed to transform, which returns the pair to write and
+// false to drop it (transform can be nil to copy pairs unchanged). MigrateStore returns the progress made when it
+// stops, and flushes dst once all the keys have been written. Writes are not atomic: see
+// MigrationOptions.SkipExisting to resume an interrupted migration.
+func MigrateStore(src, dst Store, transform func(KeyValue) (KeyValue, bool), options MigrationOptions) (MigrationProgress, error) {
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultMigrationBatchSize
+	}
+	var progress MigrationProgress
+	batch := make(map[string][]byte, batchSize)
+	writeBatch := func() error {
+		if options.SkipExisting {
+			keys := make([]string, 0, len(batch))
+			for key := range batch {
+				keys = append(keys, key)
+			}
+			existing, err := dst.GetAll(keys)
+			if err != nil {
+				return err
+			}
+			for key := range existing {
+				delete(batch, key)
+				progress.Skipped++
+			}
+		}
+		if err := dst.PutAll(batch); err != nil {
+			return err
+		}
+		progress.Written += len(batch)
+		batch = make(map[string][]byte, batchSize)
+		if options.Progress != nil {
+			options.Progress(progress)
+		}
+		return nil
+	}
+	var err error
+	iterateErr := Iterate(src, options.Prefix, func(kv KeyValue) bool {
+		progress.Read++
+		if transform != nil {
+			var keep bool
+			if kv, keep = transform(kv); !keep {
+				progress.Skipped++
+				return true
+			}
+		}
+		batch[kv.Key] = kv.Value
+		if len(batch) >= batchSize {
+			err = writeBatch()
+		}
+		return err == nil
+	})
+	if iterateErr != nil {
+		return progress, iterateErr
+	}
+	if err != nil {
+		return progress, err
+	}
+	if len(batch) > 0 {
+		if err := writeBatch(); err != nil {
+			return progress, err
+		}
+	}
+	return progress, dst.Flush()
+}
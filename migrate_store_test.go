@@ -0,0 +1,32 @@
+package kasper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateStore(t *testing.T) {
+	src := NewMap(10)
+	src.PutAll(map[string][]byte{"planet/mercury": mercury, "planet/venus": venus, "planet/earth": earth, "moon/luna": nil})
+	dst := NewMap(10)
+	dst.Put("mercury", []byte("already migrated"))
+
+	var reports []MigrationProgress
+	progress, err := MigrateStore(src, dst, func(kv KeyValue) (KeyValue, bool) {
+		return KeyValue{strings.TrimPrefix(kv.Key, "planet/"), kv.Value}, kv.Key != "planet/venus"
+	}, MigrationOptions{
+		Prefix:       "planet/",
+		BatchSize:    1,
+		SkipExisting: true,
+		Progress:     func(progress MigrationProgress) { reports = append(reports, progress) },
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, MigrationProgress{Read: 3, Written: 1, Skipped: 2}, progress)
+	assert.Equal(t, 2, len(reports))
+	assert.Equal(t, map[string][]byte{"mercury": []byte("already migrated"), "earth": earth}, dst.GetMap())
+
+	_, err = MigrateStore(NewAuditStore(&Config{}, src, "planets", nil), dst, nil, MigrationOptions{})
+	assert.EqualError(t, err, "*kasper.AuditStore does not support iteration")
+}
@@ -0,0 +1,142 @@
+package kasper
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// Monitor observes the consumer group and the stores of a TopicProcessor without processing any messages, and
+// reports them as metrics on every Config.MetricsUpdateInterval. It can be run as a separate process next to an
+// existing deployment, see cmd/kasper-monitor. Config.TopicProcessorName, Client, InputTopics, Stores and OffsetStore
+// are those of the observed TopicProcessor; all the partitions of the input topics are observed if InputPartitions is empty.
+//
+// The number of messages behind the high water mark is reported like a TopicProcessor does, in the
+// messages_behind_high_water_mark_count metric. The committed offsets and high water marks are reported in
+// committed_offset and high_water_mark, from which the consumption and production rates can be computed.
+// The statistics of the stores are reported like a TopicProcessor does, and store_up is 1 if StoreHealthCheck
+// succeeds for a store and 0 otherwise.
+type Monitor struct {
+	config        *Config
+	offsetManager sarama.OffsetManager
+	logger        Logger
+	stats         *storeStatsReporter
+	close         chan struct{}
+	closeOnce     sync.Once
+	done          chan struct{}
+
+	committedOffset             Gauge
+	highWaterMark               Gauge
+	messagesBehindHighWaterMark Gauge
+	storeUp                     Gauge
+	updateErrorCount            Counter
+}
+
+// NewMonitor creates a Monitor.
+func NewMonitor(config *Config) (*Monitor, error) {
+	config.setDefaults()
+	offsetManager, err := config.offsetManager()
+	if err != nil {
+		return nil, err
+	}
+	return newMonitor(config, offsetManager), nil
+}
+
+func newMonitor(config *Config, offsetManager sarama.OffsetManager) *Monitor {
+	metrics := config.metricsProvider()
+	return &Monitor{
+		config,
+		offsetManager,
+		WithFields(config.logger(), Field{"topicProcessor", config.TopicProcessorName}),
+		newStoreStatsReporter(config),
+		make(chan struct{}),
+		sync.Once{},
+		make(chan struct{}),
+		metrics.NewGauge("committed_offset", "Next offset to consume committed by the consumer group", "topic", "partition"),
+		metrics.NewGauge("high_water_mark", "Offset of the next message produced to the topic/partition", "topic", "partition"),
+		metrics.NewGauge("messages_behind_high_water_mark_count", "Number of messages remaining to consume on the topic/partition", "topic", "partition"),
+		metrics.NewGauge("store_up", "Whether the store passes its health check", "store"),
+		metrics.NewCounter("monitor_update_error_count", "Number of metrics updates that failed"),
+	}
+}
+
+// Run updates the metrics until Close is called, then closes the offset manager.
+func (m *Monitor) Run() error {
+	defer close(m.done)
+	defer m.offsetManager.Close()
+	ticker := m.config.clock().NewTicker(m.config.MetricsUpdateInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.Update(); err != nil {
+			m.logger.Errorf("Failed to update metrics: %s", err)
+			m.updateErrorCount.Inc()
+		}
+		select {
+		case <-ticker.Chan():
+		case <-m.close:
+			return nil
+		}
+	}
+}
+
+// Update updates the metrics once. Store metrics are updated even if the offsets cannot be read.
+func (m *Monitor) Update() error {
+	err := m.updateOffsets()
+	m.stats.report()
+	for _, store := range m.config.Stores {
+		up := 1.0
+		if checkErr := StoreHealthCheck(store.Store)(); checkErr != nil {
+			m.logger.Errorf("Health check of store %s failed: %s", store.Name, checkErr)
+			up = 0
+		}
+		m.storeUp.Set(up, store.Name)
+	}
+	return err
+}
+
+func (m *Monitor) updateOffsets() error {
+	for _, topic := range m.config.InputTopics {
+		partitions, err := m.partitions(topic)
+		if err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			offset, _, err := nextOffset(m.offsetManager, topic, partition)
+			if err != nil {
+				return err
+			}
+			highWaterMark, err := m.config.Client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return err
+			}
+			partitionLabel := strconv.Itoa(int(partition))
+			m.highWaterMark.Set(float64(highWaterMark), topic, partitionLabel)
+			// Without a committed offset, NextOffset returns Consumer.Offsets.Initial of the sarama config
+			if offset == sarama.OffsetNewest {
+				m.messagesBehindHighWaterMark.Set(0, topic, partitionLabel)
+			} else if offset != sarama.OffsetOldest {
+				m.committedOffset.Set(float64(offset), topic, partitionLabel)
+				m.messagesBehindHighWaterMark.Set(float64(highWaterMark-offset), topic, partitionLabel)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Monitor) partitions(topic string) ([]int32, error) {
+	if len(m.config.InputPartitions) == 0 {
+		return m.config.Client.Partitions(topic)
+	}
+	partitions := make([]int32, len(m.config.InputPartitions))
+	for i, partition := range m.config.InputPartitions {
+		partitions[i] = int32(partition)
+	}
+	return partitions, nil
+}
+
+// Close stops Run and waits for it to return. It must only be called after Run was started.
+func (m *Monitor) Close() {
+	m.closeOnce.Do(func() { close(m.close) })
+	<-m.done
+}
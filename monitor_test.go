@@ -0,0 +1,59 @@
+package kasper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type highWaterMarkClient struct {
+	metadataClient
+	highWaterMarks map[int32]int64
+}
+
+func (c *highWaterMarkClient) GetOffset(topic string, partition int32, time int64) (int64, error) {
+	highWaterMark, found := c.highWaterMarks[partition]
+	if !found {
+		return 0, errors.New("unknown partition")
+	}
+	return highWaterMark, nil
+}
+
+func TestMonitor_Update(t *testing.T) {
+	provider := newRecordingMetricsProvider()
+	store := NewMap(10)
+	store.Put("earth", earth)
+	config := &Config{
+		Client: &highWaterMarkClient{
+			metadataClient{partitions: map[string]int{"tweets": 3}},
+			map[int32]int64{0: 150, 1: 80, 2: 10},
+		},
+		InputTopics:     []string{"tweets"},
+		ContainerID:     "c0",
+		Logger:          &noopLogger{},
+		MetricsProvider: provider,
+		Stores:          []NamedStore{{"planets", store}, {"moons", downStore{NewMap(10)}}},
+	}
+	offsetManager := &fakeGroupOffsetManager{map[string]map[int32]*fakeOffsetManager{
+		"tweets": {0: {next: 120}, 1: {next: 80}, 2: {next: sarama.OffsetNewest}},
+	}}
+	monitor := newMonitor(config, offsetManager)
+
+	assert.Nil(t, monitor.Update())
+	assert.Equal(t, 150.0, provider.values["high_water_mark{tweets,0,c0,}"])
+	assert.Equal(t, 120.0, provider.values["committed_offset{tweets,0,c0,}"])
+	assert.Equal(t, 30.0, provider.values["messages_behind_high_water_mark_count{tweets,0,c0,}"])
+	assert.Equal(t, 0.0, provider.values["messages_behind_high_water_mark_count{tweets,1,c0,}"])
+	assert.Equal(t, 10.0, provider.values["high_water_mark{tweets,2,c0,}"])
+	_, found := provider.values["committed_offset{tweets,2,c0,}"]
+	assert.False(t, found)
+	assert.Equal(t, 0.0, provider.values["messages_behind_high_water_mark_count{tweets,2,c0,}"])
+	assert.Equal(t, 1.0, provider.values["store_key_count{planets,c0,}"])
+	assert.Equal(t, 1.0, provider.values["store_up{planets,c0,}"])
+	assert.Equal(t, 0.0, provider.values["store_up{moons,c0,}"])
+
+	config.InputPartitions = []int{0, 3}
+	assert.EqualError(t, monitor.Update(), "unknown partition")
+}
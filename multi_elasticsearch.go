@@ -21,6 +21,7 @@ type MultiElasticsearch struct {
 	tenancy ElasticsearchTenancy
 
 	logger            Logger
+	stats             *runtimeStats
 	labelValues       []string
 	pushSummary       Summary
 	fetchSummary      Summary
@@ -45,17 +46,19 @@ type ElasticsearchTenancy interface {
 //	 https://{cluster}:9092/{indexName}/{typeName}/{key}
 // where indexName and typeName depend on the tenant and the tenancy instance.
 func NewMultiElasticsearch(config *Config, client *elastic.Client, tenancy ElasticsearchTenancy) *MultiElasticsearch {
+	config.stats().addStore("MultiElasticsearch")
 	indexName, typeName := tenancy.TenantIndexAndType("tenant")
-	metrics := config.MetricsProvider
-	labelNames := []string{"topicProcessor", "indexAndType"}
-	labelValues := []string{config.TopicProcessorName, fmt.Sprintf("%s/%s", indexName, typeName)}
+	metrics := config.storeMetricsProvider()
+	labelNames := []string{"indexAndType"}
+	labelValues := []string{fmt.Sprintf("%s/%s", indexName, typeName)}
 	s := &MultiElasticsearch{
 		config,
 		client,
 		context.Background(),
 		make(map[string]Store),
 		tenancy,
-		config.Logger,
+		WithFields(config.logger(), Field{"store", "MultiElasticsearch"}),
+		config.stats(),
 		labelValues,
 		metrics.NewSummary("MultiElasticsearch_Push", "Summary of Push() calls", labelNames...),
 		metrics.NewSummary("MultiElasticsearch_Fetch", "Summary of Fetch() calls", labelNames...),
@@ -90,7 +93,8 @@ func (s *MultiElasticsearch) AllTenants() []string {
 }
 
 // Fetch performs a single MultiGet operation on the Elasticsearch cluster across multiple tenants (i.e. indexes).
-func (s *MultiElasticsearch) Fetch(keys []TenantKey) (*MultiMap, error) {
+func (s *MultiElasticsearch) Fetch(keys []TenantKey) (_ *MultiMap, err error) {
+	defer s.stats.observeStoreOperation("MultiElasticsearch.Fetch", s.stats.now(), &err)
 	s.fetchSummary.Observe(float64(len(keys)), s.labelValues...)
 	res := NewMultiMap(len(keys) / 10)
 	if len(keys) == 0 {
@@ -130,7 +134,8 @@ func (s *MultiElasticsearch) Fetch(keys []TenantKey) (*MultiMap, error) {
 
 // Push performs a single Bulk index request with all documents provided.
 // It returns an error if any operation fails.
-func (s *MultiElasticsearch) Push(m *MultiMap) error {
+func (s *MultiElasticsearch) Push(m *MultiMap) (err error) {
+	defer s.stats.observeStoreOperation("MultiElasticsearch.Push", s.stats.now(), &err)
 	for _, tenant := range m.AllTenants() {
 		s.Tenant(tenant) // force creation of index & mappings if they don't exist
 	}
@@ -161,7 +166,7 @@ func (s *MultiElasticsearch) Push(m *MultiMap) error {
 		return err
 	}
 	if response.Errors {
-		return createBulkError(response)
+		return createBulkError("PutAll", response)
 	}
 	return nil
 }
@@ -17,6 +17,9 @@ func (HeroTenancy) TenantIndexAndType(tenant string) (indexName, typeName string
 }
 
 func TestMultiElasticsearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
 	config := &Config{
 		TopicProcessorName: "test",
 		Logger:             &noopLogger{},
@@ -35,6 +38,9 @@ func TestMultiElasticsearch(t *testing.T) {
 }
 
 func TestMultiElasticsearch_PutAll_GetAll(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
 	config := &Config{
 		TopicProcessorName: "test",
 		Logger:             &noopLogger{},
@@ -86,7 +92,7 @@ func TestMultiElasticsearch_PutAll_GetAll(t *testing.T) {
 	assert.Equal(t, superman, hero)
 }
 
-func init() {
+func setupMultiElasticsearch() {
 	store.client.DeleteIndex("marvel").Do(store.context)
 	store.client.DeleteIndex("dc").Do(store.context)
 	store.client.CreateIndex("marvel").Do(store.context)
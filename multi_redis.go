@@ -17,6 +17,7 @@ type MultiRedis struct {
 	keyPrefix string
 
 	logger       Logger
+	stats        *runtimeStats
 	labelValues  []string
 	pushCounter  Counter
 	fetchCounter Counter
@@ -26,15 +27,17 @@ type MultiRedis struct {
 // All keys read and written will be of the form:
 //	{tenant}/{keyPrefix}/{key}
 func NewMultiRedis(config *Config, conn redis.Conn, keyPrefix string) *MultiRedis {
-	metrics := config.MetricsProvider
-	labelNames := []string{"topicProcessor", "keyPrefix"}
+	config.stats().addStore("MultiRedis")
+	metrics := config.storeMetricsProvider()
+	labelNames := []string{"keyPrefix"}
 	s := &MultiRedis{
 		config,
 		conn,
 		make(map[string]Store),
 		keyPrefix,
-		config.Logger,
-		[]string{config.TopicProcessorName, keyPrefix},
+		WithFields(config.logger(), Field{"store", "MultiRedis"}, Field{"keyPrefix", keyPrefix}),
+		config.stats(),
+		[]string{keyPrefix},
 		metrics.NewCounter("MultiRedis_Push", "Counter of Push() calls", labelNames...),
 		metrics.NewCounter("MultiRedis_Fetch", "Counter of Fetch() calls", labelNames...),
 	}
@@ -70,13 +73,14 @@ func (s *MultiRedis) AllTenants() []string {
 }
 
 // Fetch performs a single MULTI GET Redis command across multiple tenants.
-func (s *MultiRedis) Fetch(keys []TenantKey) (*MultiMap, error) {
+func (s *MultiRedis) Fetch(keys []TenantKey) (_ *MultiMap, err error) {
+	defer s.stats.observeStoreOperation("MultiRedis.Fetch", s.stats.now(), &err)
 	s.fetchCounter.Inc(s.labelValues...)
 	res := NewMultiMap(len(keys) / 10)
 	if len(keys) == 0 {
 		return res, nil
 	}
-	err := s.conn.Send("MULTI")
+	err = s.conn.Send("MULTI")
 	if err != nil {
 		return nil, err
 	}
@@ -104,9 +108,10 @@ func (s *MultiRedis) Fetch(keys []TenantKey) (*MultiMap, error) {
 }
 
 // Fetch performs a single MULTI SET Redis command across multiple tenants.
-func (s *MultiRedis) Push(entries *MultiMap) error {
+func (s *MultiRedis) Push(entries *MultiMap) (err error) {
+	defer s.stats.observeStoreOperation("MultiRedis.Push", s.stats.now(), &err)
 	s.pushCounter.Inc(s.labelValues...)
-	err := s.conn.Send("MULTI")
+	err = s.conn.Send("MULTI")
 	if err != nil {
 		return err
 	}
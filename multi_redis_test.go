@@ -9,6 +9,9 @@ import (
 )
 
 func TestMultiRedis(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
 	config := &Config{
 		TopicProcessorName: "test",
 		Logger:             &noopLogger{},
@@ -24,6 +27,9 @@ func TestMultiRedis(t *testing.T) {
 }
 
 func TestMultiRedis_PutAll_GetAll(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
 	config := &Config{
 		TopicProcessorName: "test",
 		Logger:             &noopLogger{},
@@ -72,7 +78,7 @@ func TestMultiRedis_PutAll_GetAll(t *testing.T) {
 	assert.Equal(t, superman, hero)
 }
 
-func init() {
+func setupMultiRedis() {
 	url := fmt.Sprintf("redis://%s:6379", getCIHost())
 	conn, err := redis.DialURL(url)
 	if err != nil {
@@ -0,0 +1,74 @@
+package kasper
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// CopyOffsets copies the committed offsets of the input topics from the Kafka consumer group of one
+// TopicProcessorName to the group of another, so that a processor can be renamed, or a rewrite deployed
+// side by side, without reprocessing the topics from the beginning. It returns the copied offsets by topic and
+// partition. Partitions without a committed offset are skipped.
+// Both topic processors must be stopped. Offsets are only moved forward: partitions for which the destination
+// group has already committed a later offset keep it.
+func CopyOffsets(client sarama.Client, fromTopicProcessorName, toTopicProcessorName string, topics []string) (map[string]map[int]int64, error) {
+	partitions := make(map[string][]int32, len(topics))
+	for _, topic := range topics {
+		topicPartitions, err := client.Partitions(topic)
+		if err != nil {
+			return nil, err
+		}
+		partitions[topic] = topicPartitions
+	}
+	from := &Config{TopicProcessorName: fromTopicProcessorName}
+	source, err := sarama.NewOffsetManagerFromClient(from.kafkaConsumerGroup(), client)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+	to := &Config{TopicProcessorName: toTopicProcessorName}
+	destination, err := sarama.NewOffsetManagerFromClient(to.kafkaConsumerGroup(), client)
+	if err != nil {
+		return nil, err
+	}
+	defer destination.Close()
+	return copyOffsets(source, destination, partitions)
+}
+
+func copyOffsets(source, destination sarama.OffsetManager, partitions map[string][]int32) (map[string]map[int]int64, error) {
+	copied := make(map[string]map[int]int64, len(partitions))
+	for topic, topicPartitions := range partitions {
+		copied[topic] = make(map[int]int64, len(topicPartitions))
+		for _, partition := range topicPartitions {
+			offset, metadata, err := nextOffset(source, topic, partition)
+			if err != nil {
+				return nil, err
+			}
+			if offset < 0 {
+				continue
+			}
+			pom, err := destination.ManagePartition(topic, partition)
+			if err != nil {
+				return nil, err
+			}
+			pom.MarkOffset(offset, metadata)
+			// Close waits until the offset has been committed
+			if err := pom.Close(); err != nil {
+				return nil, fmt.Errorf("cannot commit offset of %s-%d: %s", topic, partition, err)
+			}
+			copied[topic][int(partition)] = offset
+		}
+	}
+	return copied, nil
+}
+
+func nextOffset(offsetManager sarama.OffsetManager, topic string, partition int32) (int64, string, error) {
+	pom, err := offsetManager.ManagePartition(topic, partition)
+	if err != nil {
+		return 0, "", err
+	}
+	defer pom.Close()
+	offset, metadata := pom.NextOffset()
+	return offset, metadata, nil
+}
@@ -0,0 +1,43 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeGroupOffsetManager struct {
+	partitions map[string]map[int32]*fakeOffsetManager
+}
+
+func (m *fakeGroupOffsetManager) ManagePartition(topic string, partition int32) (sarama.PartitionOffsetManager, error) {
+	if m.partitions[topic] == nil {
+		m.partitions[topic] = make(map[int32]*fakeOffsetManager)
+	}
+	if m.partitions[topic][partition] == nil {
+		m.partitions[topic][partition] = &fakeOffsetManager{next: sarama.OffsetNewest}
+	}
+	return m.partitions[topic][partition], nil
+}
+
+func (m *fakeGroupOffsetManager) Commit() {
+}
+
+func (m *fakeGroupOffsetManager) Close() error {
+	return nil
+}
+
+func TestCopyOffsets(t *testing.T) {
+	source := &fakeGroupOffsetManager{map[string]map[int32]*fakeOffsetManager{
+		"tweets": {0: {next: 120}, 1: {next: 80}},
+	}}
+	destination := &fakeGroupOffsetManager{make(map[string]map[int32]*fakeOffsetManager)}
+
+	copied, err := copyOffsets(source, destination, map[string][]int32{"tweets": {0, 1, 2}})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]map[int]int64{"tweets": {0: 120, 1: 80}}, copied)
+	offset, _ := destination.partitions["tweets"][1].NextOffset()
+	assert.Equal(t, int64(80), offset)
+	assert.Nil(t, destination.partitions["tweets"][2])
+}
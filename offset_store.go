@@ -0,0 +1,91 @@
+package kasper
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+// storeOffsetManager is the sarama.OffsetManager of Config.OffsetStore. The next offset of each topic partition is
+// kept as a decimal string under offsetStoreKey.
+type storeOffsetManager struct {
+	store   Store
+	group   string
+	initial int64
+}
+
+func newStoreOffsetManager(store Store, group string, initial int64) *storeOffsetManager {
+	return &storeOffsetManager{store, group, initial}
+}
+
+func offsetStoreKey(group, topic string, partition int32) string {
+	return fmt.Sprintf("__kasper_offset__/%s/%s/%d", group, topic, partition)
+}
+
+func (m *storeOffsetManager) ManagePartition(topic string, partition int32) (sarama.PartitionOffsetManager, error) {
+	key := offsetStoreKey(m.group, topic, partition)
+	value, err := m.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	next := m.initial
+	if value != nil {
+		next, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset stored under %s: %s", key, err)
+		}
+	}
+	return &storePartitionOffsetManager{m.store, key, next, make(chan *sarama.ConsumerError)}, nil
+}
+
+// Commit does nothing: offsets are written to the store when they are marked.
+func (m *storeOffsetManager) Commit() {
+}
+
+func (m *storeOffsetManager) Close() error {
+	return nil
+}
+
+// offsetWriter is implemented by the PartitionOffsetManagers whose writes can fail, see partitionProcessor.markOffsets.
+type offsetWriter interface {
+	writeOffset(offset int64) error
+}
+
+type storePartitionOffsetManager struct {
+	store  Store
+	key    string
+	next   int64
+	errors chan *sarama.ConsumerError
+}
+
+func (pom *storePartitionOffsetManager) NextOffset() (int64, string) {
+	return pom.next, ""
+}
+
+func (pom *storePartitionOffsetManager) MarkOffset(offset int64, metadata string) {
+	pom.writeOffset(offset)
+}
+
+func (pom *storePartitionOffsetManager) ResetOffset(offset int64, metadata string) {
+	pom.writeOffset(offset)
+}
+
+func (pom *storePartitionOffsetManager) writeOffset(offset int64) error {
+	if err := pom.store.Put(pom.key, []byte(strconv.FormatInt(offset, 10))); err != nil {
+		return err
+	}
+	pom.next = offset
+	return nil
+}
+
+func (pom *storePartitionOffsetManager) Errors() <-chan *sarama.ConsumerError {
+	return pom.errors
+}
+
+func (pom *storePartitionOffsetManager) AsyncClose() {
+}
+
+func (pom *storePartitionOffsetManager) Close() error {
+	return nil
+}
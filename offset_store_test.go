@@ -0,0 +1,58 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreOffsetManager(t *testing.T) {
+	store := NewMap(10)
+	offsetManager := newStoreOffsetManager(store, "kasper-topic-processor-reach", sarama.OffsetOldest)
+	pom, err := offsetManager.ManagePartition("tweets", 3)
+	assert.Nil(t, err)
+	offset, _ := pom.NextOffset()
+	assert.Equal(t, sarama.OffsetOldest, offset)
+
+	pom.MarkOffset(42, "")
+	offset, _ = pom.NextOffset()
+	assert.Equal(t, int64(42), offset)
+	value, _ := store.Get("__kasper_offset__/kasper-topic-processor-reach/tweets/3")
+	assert.Equal(t, "42", string(value))
+	assert.Nil(t, pom.Close())
+
+	pom, err = offsetManager.ManagePartition("tweets", 3)
+	assert.Nil(t, err)
+	offset, _ = pom.NextOffset()
+	assert.Equal(t, int64(42), offset)
+
+	store.Put("__kasper_offset__/kasper-topic-processor-reach/tweets/4", []byte("mars"))
+	_, err = offsetManager.ManagePartition("tweets", 4)
+	assert.EqualError(t, err, `invalid offset stored under __kasper_offset__/kasper-topic-processor-reach/tweets/4: strconv.ParseInt: parsing "mars": invalid syntax`)
+}
+
+func TestTopicProcessor_OffsetStore(t *testing.T) {
+	var flushes []string
+	offsets := NewMap(10)
+	config := &Config{
+		Stores:      []NamedStore{{"words", &flushRecordingStore{NewMap(10), "words", &flushes}}},
+		OffsetStore: &flushRecordingStore{offsets, "offsets", &flushes},
+	}
+	tp := newFakeTopicProcessor(config, &countingProcessor{})
+	pom, err := newStoreOffsetManager(config.OffsetStore, config.kafkaConsumerGroup(), sarama.OffsetNewest).ManagePartition("input", 0)
+	assert.Nil(t, err)
+	tp.partitionProcessors[0].offsetManagers["input"] = pom
+	done := tp.start()
+	tp.send(0, 7, "a")
+	waitFor(t, func() bool {
+		assert.Nil(t, tp.Flush())
+		offset, _ := pom.NextOffset()
+		return offset == 8
+	})
+	value, _ := offsets.Get("__kasper_offset__/kasper-topic-processor-fake/input/0")
+	assert.Equal(t, "8", string(value))
+	assert.Equal(t, []string{"words", "offsets"}, flushes)
+	tp.Close()
+	assert.Nil(t, <-done)
+}
@@ -0,0 +1,155 @@
+package kasper
+
+import "sync"
+
+// ParallelStore splits large GetAll and PutAll operations into chunks executed concurrently over several
+// connections to the same backend, so that the latency of a batch decreases with the number of connections:
+//
+//	var stores []kasper.Store
+//	for i := 0; i < 4; i++ {
+//		stores = append(stores, kasper.NewRedis(config, pool.Get(), "users"))
+//	}
+//	users := kasper.NewParallelStore(stores, 500)
+//
+// Each store is used by a single goroutine at a time, so stores that are not safe for concurrent use, such as
+// Redis, can be used. The other operations use the first store. PutAll is not atomic across chunks.
+type ParallelStore struct {
+	stores    []Store
+	chunkSize int
+	free      chan Store
+}
+
+// NewParallelStore creates ParallelStore instances. GetAll and PutAll operations on more than chunkSize keys are
+// split into chunks of chunkSize keys, executed concurrently on up to len(stores) stores. chunkSize defaults to
+// 1000 if it is not positive. It panics if stores is empty.
+func NewParallelStore(stores []Store, chunkSize int) *ParallelStore {
+	if len(stores) == 0 {
+		panic("kasper: NewParallelStore requires at least one store")
+	}
+	if chunkSize <= 0 {
+		chunkSize = 1000
+	}
+	free := make(chan Store, len(stores))
+	for _, store := range stores {
+		free <- store
+	}
+	return &ParallelStore{stores, chunkSize, free}
+}
+
+// run calls fn for each chunk on a free store, and returns the first error.
+func (s *ParallelStore) run(chunks int, fn func(store Store, chunk int) error) error {
+	var waitGroup sync.WaitGroup
+	var mutex sync.Mutex
+	var firstErr error
+	for chunk := 0; chunk < chunks; chunk++ {
+		store := <-s.free
+		waitGroup.Add(1)
+		go func(chunk int) {
+			defer waitGroup.Done()
+			err := fn(store, chunk)
+			s.free <- store
+			if err != nil {
+				mutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mutex.Unlock()
+			}
+		}(chunk)
+	}
+	waitGroup.Wait()
+	return firstErr
+}
+
+func (s *ParallelStore) chunks(n int) int {
+	return (n + s.chunkSize - 1) / s.chunkSize
+}
+
+// Get gets a value by key from the first store.
+func (s *ParallelStore) Get(key string) ([]byte, error) {
+	return s.stores[0].Get(key)
+}
+
+// GetAll gets multiple values by key, in concurrent chunks.
+func (s *ParallelStore) GetAll(keys []string) (map[string][]byte, error) {
+	if len(keys) <= s.chunkSize || len(s.stores) == 1 {
+		return s.stores[0].GetAll(keys)
+	}
+	var mutex sync.Mutex
+	kvs := make(map[string][]byte, len(keys))
+	err := s.run(s.chunks(len(keys)), func(store Store, chunk int) error {
+		end := (chunk + 1) * s.chunkSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunkKVs, err := store.GetAll(keys[chunk*s.chunkSize : end])
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for key, value := range chunkKVs {
+			kvs[key] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kvs, nil
+}
+
+// Put inserts or updates a value by key in the first store.
+func (s *ParallelStore) Put(key string, value []byte) error {
+	return s.stores[0].Put(key, value)
+}
+
+// PutAll inserts or updates multiple key-value pairs, in concurrent chunks.
+func (s *ParallelStore) PutAll(kvs map[string][]byte) error {
+	if len(kvs) <= s.chunkSize || len(s.stores) == 1 {
+		return s.stores[0].PutAll(kvs)
+	}
+	chunks := make([]map[string][]byte, s.chunks(len(kvs)))
+	i := 0
+	for key, value := range kvs {
+		chunk := i / s.chunkSize
+		if chunks[chunk] == nil {
+			chunks[chunk] = make(map[string][]byte, s.chunkSize)
+		}
+		chunks[chunk][key] = value
+		i++
+	}
+	return s.run(len(chunks), func(store Store, chunk int) error {
+		return store.PutAll(chunks[chunk])
+	})
+}
+
+// Delete deletes a key from the first store.
+func (s *ParallelStore) Delete(key string) error {
+	return s.stores[0].Delete(key)
+}
+
+// Flush flushes all the stores concurrently and returns the first error.
+func (s *ParallelStore) Flush() error {
+	errs := make([]error, len(s.stores))
+	var waitGroup sync.WaitGroup
+	for i, store := range s.stores {
+		waitGroup.Add(1)
+		go func(i int, store Store) {
+			defer waitGroup.Done()
+			errs[i] = store.Flush()
+		}(i, store)
+	}
+	waitGroup.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStore returns the first store
+func (s *ParallelStore) GetStore() Store {
+	return s.stores[0]
+}
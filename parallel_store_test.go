@@ -0,0 +1,54 @@
+package kasper
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelStore(t *testing.T) {
+	s := NewSynchronizedStore(NewMap(10))
+	connections := []*getAllRecordingStore{{Store: s}, {Store: s}, {Store: s}}
+	store := NewParallelStore([]Store{connections[0], connections[1], connections[2]}, 2)
+
+	kvs := make(map[string][]byte)
+	var keys []string
+	for i := 0; i < 9; i++ {
+		key := fmt.Sprintf("planet-%d", i)
+		kvs[key] = []byte(key)
+		keys = append(keys, key)
+	}
+	assert.Nil(t, store.PutAll(kvs))
+	assert.Nil(t, store.Flush())
+
+	actual, err := store.GetAll(append(keys, "pluto"))
+	assert.Nil(t, err)
+	assert.Equal(t, kvs, actual)
+	requests := 0
+	for _, connection := range connections {
+		for _, request := range connection.requests {
+			assert.True(t, len(request) <= 2)
+			requests++
+		}
+	}
+	assert.Equal(t, 5, requests)
+
+	actual, _ = store.GetAll([]string{"planet-1", "pluto"})
+	assert.Equal(t, map[string][]byte{"planet-1": []byte("planet-1")}, actual)
+	assert.Equal(t, []string{"planet-1", "pluto"}, connections[0].requests[len(connections[0].requests)-1])
+}
+
+func TestNewParallelStore_InvalidArguments(t *testing.T) {
+	assert.PanicsWithValue(t, "kasper: NewParallelStore requires at least one store", func() {
+		NewParallelStore(nil, 2)
+	})
+
+	s := NewSynchronizedStore(NewMap(10))
+	store := NewParallelStore([]Store{s, s}, 0)
+	assert.Equal(t, 1000, store.chunkSize)
+	assert.Nil(t, store.PutAll(map[string][]byte{"mercury": mercury, "venus": venus}))
+	kvs, err := store.GetAll([]string{"mercury", "venus"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"mercury": mercury, "venus": venus}, kvs)
+}
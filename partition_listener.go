@@ -0,0 +1,15 @@
+package kasper
+
+// PartitionListener can be implemented by a MessageProcessor that needs to set up or tear down state for the
+// partitions it processes, e.g. to warm a local cache from a Store.
+// Kasper assigns partitions statically: PartitionAssigned is called for every input partition when RunLoop starts,
+// before any message is processed, and PartitionRevoked is called when the TopicProcessor closes,
+// after the last batch has been processed.
+type PartitionListener interface {
+	// PartitionAssigned is called before the first message of the partition is processed.
+	// If it returns an error, RunLoop returns it without processing any message.
+	PartitionAssigned(partition int) error
+	// PartitionRevoked is called after the last message of the partition has been processed.
+	// Errors are logged.
+	PartitionRevoked(partition int) error
+}
@@ -1,7 +1,9 @@
 package kasper
 
 import (
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/Shopify/sarama"
 )
@@ -15,6 +17,9 @@ type partitionProcessor struct {
 	inputTopics        []string
 	partition          int
 	logger             Logger
+	assigned           bool
+	streamTimes        map[string]time.Time
+	progressPublished  time.Time
 }
 
 func (pp *partitionProcessor) consumerMessageChannels() []<-chan *sarama.ConsumerMessage {
@@ -25,65 +30,75 @@ func (pp *partitionProcessor) consumerMessageChannels() []<-chan *sarama.Consume
 	return chans
 }
 
-func getPartitionOffsetManager(tp *TopicProcessor, topic string, partition int) sarama.PartitionOffsetManager {
-	pom, err := tp.offsetManager.ManagePartition(topic, int32(partition))
-	if err != nil {
-		tp.logger.Panic(err)
-	}
-	return pom
-}
-
-func getPartitionConsumer(tp *TopicProcessor, consumer sarama.Consumer, pom sarama.PartitionOffsetManager, topic string, partition int) sarama.PartitionConsumer {
+func getPartitionConsumer(tp *TopicProcessor, consumer sarama.Consumer, pom sarama.PartitionOffsetManager, topic string, partition int) (sarama.PartitionConsumer, error) {
 	newestOffset, err := tp.config.Client.GetOffset(topic, int32(partition), sarama.OffsetNewest)
 	if err != nil {
-		tp.logger.Panic(err)
+		return nil, err
 	}
 	nextOffset, _ := pom.NextOffset()
 	if nextOffset > newestOffset {
 		nextOffset = sarama.OffsetNewest
 	}
 	tp.logger.Infof("Consuming topic partition %s-%d from offset '%s' (newest offset is '%s')", topic, partition, offsetToString(nextOffset), offsetToString(newestOffset))
-	c, err := consumer.ConsumePartition(topic, int32(partition), nextOffset)
-	if err != nil {
-		tp.logger.Panic(err)
-	}
-	return c
+	return consumer.ConsumePartition(topic, int32(partition), nextOffset)
 }
 
-func newPartitionProcessor(tp *TopicProcessor, mp MessageProcessor, partition int) *partitionProcessor {
+// newPartitionProcessor closes the consumers and offset managers it has already opened if it returns an error.
+func newPartitionProcessor(tp *TopicProcessor, mp MessageProcessor, partition int) (*partitionProcessor, error) {
 	consumer, err := sarama.NewConsumerFromClient(tp.config.Client)
 	if err != nil {
-		tp.logger.Panic(err)
-	}
-	partitionConsumers := make([]sarama.PartitionConsumer, len(tp.inputTopics))
-	partitionOffsetManagers := make(map[string]sarama.PartitionOffsetManager)
-	for i, topic := range tp.inputTopics {
-		partitionOffsetManager := getPartitionOffsetManager(tp, topic, partition)
-		partitionConsumer := getPartitionConsumer(tp, consumer, partitionOffsetManager, topic, partition)
-		partitionConsumers[i] = partitionConsumer
-		partitionOffsetManagers[topic] = partitionOffsetManager
+		return nil, err
 	}
 	pp := &partitionProcessor{
 		tp,
 		consumer,
-		partitionConsumers,
-		partitionOffsetManagers,
+		make([]sarama.PartitionConsumer, 0, len(tp.inputTopics)),
+		make(map[string]sarama.PartitionOffsetManager),
 		mp,
 		tp.inputTopics,
 		partition,
-		tp.logger,
+		WithFields(tp.logger, Field{"partition", partition}),
+		false,
+		nil,
+		time.Time{},
 	}
-	return pp
+	for _, topic := range tp.inputTopics {
+		partitionOffsetManager, err := tp.offsetManager.ManagePartition(topic, int32(partition))
+		if err != nil {
+			pp.onClose()
+			return nil, err
+		}
+		pp.offsetManagers[topic] = partitionOffsetManager
+		partitionConsumer, err := getPartitionConsumer(tp, consumer, partitionOffsetManager, topic, partition)
+		if err != nil {
+			pp.onClose()
+			return nil, err
+		}
+		pp.partitionConsumers = append(pp.partitionConsumers, partitionConsumer)
+	}
+	return pp, nil
 }
 
-func (pp *partitionProcessor) process(msgs []*sarama.ConsumerMessage) ([]*sarama.ProducerMessage, error) {
+// process returns the sender holding the messages to produce, whose buffers must be released once produced.
+func (pp *partitionProcessor) process(msgs []*sarama.ConsumerMessage, span Span) (*sender, error) {
+	sampler := pp.topicProcessor.payloadSampler
+	for _, msg := range msgs {
+		if sampler.sample() {
+			sampler.log(newMessageLogger(pp.logger, pp.topicProcessor.config.messageTraceID(), msg), msg)
+		}
+	}
 	sender := newSender(pp)
+	sender.span = span
+	if config := pp.topicProcessor.config; config.ProvenanceHeaders {
+		sender.headers = provenanceHeaders(config.TopicProcessorName, pp.partition, msgs, config.clock().Now())
+	}
 	err := pp.messageProcessor.Process(msgs, sender)
 	if err != nil {
 		pp.logger.Errorf("Message processor returned error: %s", err)
+		sender.releaseBuffers()
 		return nil, err
 	}
-	return sender.producerMessages, nil
+	return sender, nil
 }
 
 func (pp *partitionProcessor) countMessagesBehindHighWaterMark() {
@@ -95,9 +110,11 @@ func (pp *partitionProcessor) countMessagesBehindHighWaterMark() {
 		highWaterMark := highWaterMarks[topic][int32(pp.partition)]
 		if currentOffset == sarama.OffsetNewest {
 			pp.topicProcessor.messagesBehindHighWaterMark.Set(0, topic, partition)
+			pp.topicProcessor.stats.setMessagesBehindHighWaterMark(topic, pp.partition, 0)
 		} else if currentOffset != sarama.OffsetOldest {
 			messagesBehindHighWaterMark := highWaterMark - currentOffset
 			pp.topicProcessor.messagesBehindHighWaterMark.Set(float64(messagesBehindHighWaterMark), topic, partition)
+			pp.topicProcessor.stats.setMessagesBehindHighWaterMark(topic, pp.partition, messagesBehindHighWaterMark)
 		}
 	}
 }
@@ -109,11 +126,11 @@ func (pp *partitionProcessor) hasConsumedAllMessages() bool {
 		currentOffset, _ := offsetManager.NextOffset()
 		highWaterMark := highWaterMarks[topic][int32(pp.partition)]
 		if highWaterMark != currentOffset {
-			pp.logger.Debugf("Topic %s partition %d has messages remaining to consume (offset = %s, high water mark = %d)", topic, pp.partition, currentOffset, highWaterMark)
+			pp.logger.Debugf("Topic %s partition %d has messages remaining to consume (offset = %d, high water mark = %d)", topic, pp.partition, currentOffset, highWaterMark)
 			return false
 		}
 	}
-	pp.logger.Debug("Partitions %d of all input topics have been consumed", pp.partition)
+	pp.logger.Debugf("Partitions %d of all input topics have been consumed", pp.partition)
 	return true
 }
 
@@ -121,37 +138,81 @@ func (pp *partitionProcessor) onMetricsTick() {
 	pp.countMessagesBehindHighWaterMark()
 }
 
-func (pp *partitionProcessor) markOffsets(messages []*sarama.ConsumerMessage) {
+// markOffsets returns the first error writing the offsets to Config.OffsetStore. Offsets marked in Kafka are
+// committed periodically, and their errors are not reported.
+func (pp *partitionProcessor) markOffsets(messages []*sarama.ConsumerMessage) error {
 	latestOffset := make(map[string]int64)
 	for _, message := range messages {
 		latestOffset[message.Topic] = message.Offset
 	}
 	for topic, offset := range latestOffset {
 		pp.logger.Debugf("Marking offset %s:%d", topic, offset+1)
-		pp.offsetManagers[topic].MarkOffset(offset+1, "")
+		pom := pp.offsetManagers[topic]
+		if writer, ok := pom.(offsetWriter); ok {
+			if err := writer.writeOffset(offset + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		pom.MarkOffset(offset+1, "")
+	}
+	return nil
+}
+
+func (pp *partitionProcessor) onAssigned() error {
+	listener, ok := pp.messageProcessor.(PartitionListener)
+	if !ok {
+		return nil
+	}
+	err := listener.PartitionAssigned(pp.partition)
+	if err != nil {
+		return err
 	}
+	pp.assigned = true
+	return nil
 }
 
-func (pp *partitionProcessor) onClose() {
-	var err error
+func (pp *partitionProcessor) onRevoked() {
+	if !pp.assigned {
+		return
+	}
+	pp.assigned = false
+	err := pp.messageProcessor.(PartitionListener).PartitionRevoked(pp.partition)
+	if err != nil {
+		pp.logger.Errorf("Partition %d was not revoked cleanly: %s", pp.partition, err)
+	}
+}
+
+// onClose releases the consumers and offset managers of the partition. All errors are logged and the first one
+// is returned.
+func (pp *partitionProcessor) onClose() error {
+	pp.onRevoked()
+	var firstErr error
+	onError := func(err error) {
+		pp.logger.Error(err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
 	for topic, pom := range pp.offsetManagers {
 		offset, _ := pom.NextOffset()
 		pp.logger.Infof("Stopping consumption of topic partition %s-%d (last offset read was '%s')", topic, pp.partition, offsetToString(offset))
-		err = pom.Close()
+		err := pom.Close()
 		if err != nil {
-			pp.logger.Panicf("Cannot close offset manager: %s", err)
+			onError(fmt.Errorf("cannot close offset manager: %s", err))
 		}
 	}
 	for _, pc := range pp.partitionConsumers {
-		err = pc.Close()
+		err := pc.Close()
 		if err != nil {
-			pp.logger.Panicf("Cannot close partition consumer: %s", err)
+			onError(fmt.Errorf("cannot close partition consumer: %s", err))
 		}
 	}
-	err = pp.consumer.Close()
+	err := pp.consumer.Close()
 	if err != nil {
-		pp.logger.Panic(err)
+		onError(fmt.Errorf("cannot close consumer: %s", err))
 	}
+	return firstErr
 }
 
 func offsetToString(offset int64) string {
@@ -0,0 +1,98 @@
+package kasper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Shopify/sarama"
+)
+
+const defaultPayloadSampleMaxBytes = 1024
+
+// payloadSampler logs one in every Config.PayloadSampleRate incoming messages at debug level.
+type payloadSampler struct {
+	rate     int
+	maxBytes int
+	redacted map[string]bool
+	count    int
+}
+
+func newPayloadSampler(config *Config) *payloadSampler {
+	maxBytes := config.PayloadSampleMaxBytes
+	if maxBytes == 0 {
+		maxBytes = defaultPayloadSampleMaxBytes
+	}
+	redacted := make(map[string]bool, len(config.PayloadRedactedFields))
+	for _, field := range config.PayloadRedactedFields {
+		redacted[field] = true
+	}
+	return &payloadSampler{config.PayloadSampleRate, maxBytes, redacted, 0}
+}
+
+// sample returns true for one in every rate calls.
+func (s *payloadSampler) sample() bool {
+	if s.rate <= 0 {
+		return false
+	}
+	s.count++
+	return s.count%s.rate == 0
+}
+
+func (s *payloadSampler) log(logger Logger, message *sarama.ConsumerMessage) {
+	logger.Debugf("Sampled message: key=%s value=%s", s.format(message.Key), s.format(message.Value))
+}
+
+// format redacts JSON payloads and truncates payloads longer than maxBytes.
+func (s *payloadSampler) format(payload []byte) string {
+	if len(s.redacted) > 0 {
+		payload = s.redact(payload)
+	}
+	if s.maxBytes > 0 && len(payload) > s.maxBytes {
+		return fmt.Sprintf("%q... (%d bytes)", payload[:s.maxBytes], len(payload))
+	}
+	return fmt.Sprintf("%q", payload)
+}
+
+// redact replaces the values of redacted fields at any depth of a JSON payload. Payloads that are not a single
+// valid JSON value (e.g. plain text, truncated or concatenated JSON) cannot be redacted and are replaced with a
+// placeholder, so that they never leak the redacted fields.
+func (s *payloadSampler) redact(payload []byte) []byte {
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return payload
+	}
+	unparseable := []byte(fmt.Sprintf("[unparseable, %d bytes]", len(payload)))
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return unparseable
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return unparseable
+	}
+	redacted, err := json.Marshal(s.redactValue(decoded))
+	if err != nil {
+		return unparseable
+	}
+	return redacted
+}
+
+func (s *payloadSampler) redactValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for field, fieldValue := range value {
+			if s.redacted[field] {
+				value[field] = "[REDACTED]"
+			} else {
+				value[field] = s.redactValue(fieldValue)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = s.redactValue(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,53 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayloadSampler(t *testing.T) {
+	zap := &recordingZapLogger{}
+	sampler := newPayloadSampler(&Config{
+		PayloadSampleRate:     2,
+		PayloadRedactedFields: []string{"email"},
+	})
+	logger := NewZapLogger(zap)
+	values := []string{
+		`{"id":1,"email":"arthur@example.com"}`,
+		`{"id":2,"email":"ford@example.com","friends":[{"id":1,"email":"arthur@example.com"}]}`,
+		`not json`,
+		`[{"email":"zaphod@example.com","score":1.50}]`,
+		`{"id":5,"email":"trillian@example.com"`,
+		`{"id":6}{"email":"marvin@example.com"}`,
+	}
+	for _, value := range values {
+		if sampler.sample() {
+			sampler.log(logger, &sarama.ConsumerMessage{Key: []byte("42"), Value: []byte(value)})
+		}
+	}
+	assert.Equal(t, []string{
+		`DEBUG Sampled message: key="42" value="{\"email\":\"[REDACTED]\",\"friends\":[{\"email\":\"[REDACTED]\",\"id\":1}],\"id\":2}" []`,
+		`DEBUG Sampled message: key="42" value="[{\"email\":\"[REDACTED]\",\"score\":1.50}]" []`,
+		`DEBUG Sampled message: key="42" value="[unparseable, 38 bytes]" []`,
+	}, zap.entries)
+
+	sampler = newPayloadSampler(&Config{PayloadRedactedFields: []string{"email"}})
+	assert.Equal(t, `"[unparseable, 8 bytes]"`, sampler.format([]byte("not json")))
+	assert.Equal(t, `"[unparseable, 38 bytes]"`, sampler.format([]byte(`{"id":5,"email":"trillian@example.com"`)))
+}
+
+func TestPayloadSampler_Disabled(t *testing.T) {
+	sampler := newPayloadSampler(&Config{})
+	for i := 0; i < 10; i++ {
+		assert.False(t, sampler.sample())
+	}
+}
+
+func TestPayloadSampler_format(t *testing.T) {
+	sampler := newPayloadSampler(&Config{PayloadSampleRate: 1, PayloadSampleMaxBytes: 5})
+	assert.Equal(t, `"hello"`, sampler.format([]byte("hello")))
+	assert.Equal(t, `"hello"... (11 bytes)`, sampler.format([]byte("hello world")))
+	assert.Equal(t, `""`, sampler.format(nil))
+}
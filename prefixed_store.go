@@ -0,0 +1,119 @@
+package kasper
+
+import (
+	"strings"
+	"time"
+)
+
+// PrefixedStore wraps a Store and prepends a prefix to all keys, so that several processors, or several logical
+// tables of one processor, can share one Redis database or Elasticsearch index:
+//
+//	users := kasper.NewPrefixedStore(store, "users/")
+//	sessions := kasper.NewPrefixedStore(store, "sessions/")
+//
+// The prefix is removed from the keys returned by GetAll and Iterate.
+type PrefixedStore struct {
+	store  Store
+	prefix string
+}
+
+// NewPrefixedStore creates PrefixedStore instances.
+func NewPrefixedStore(store Store, prefix string) *PrefixedStore {
+	return &PrefixedStore{store, prefix}
+}
+
+func (s *PrefixedStore) prefixKeys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = s.prefix + key
+	}
+	return prefixed
+}
+
+// Get gets a value by key from the underlying store.
+func (s *PrefixedStore) Get(key string) ([]byte, error) {
+	return s.store.Get(s.prefix + key)
+}
+
+// GetAll gets multiple values by key from the underlying store.
+func (s *PrefixedStore) GetAll(keys []string) (map[string][]byte, error) {
+	prefixed, err := s.store.GetAll(s.prefixKeys(keys))
+	if err != nil {
+		return nil, err
+	}
+	kvs := make(map[string][]byte, len(prefixed))
+	for key, value := range prefixed {
+		kvs[strings.TrimPrefix(key, s.prefix)] = value
+	}
+	return kvs, nil
+}
+
+// Put inserts or updates a value by key in the underlying store.
+func (s *PrefixedStore) Put(key string, value []byte) error {
+	return s.store.Put(s.prefix+key, value)
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store.
+func (s *PrefixedStore) PutAll(kvs map[string][]byte) error {
+	prefixed := make(map[string][]byte, len(kvs))
+	for key, value := range kvs {
+		prefixed[s.prefix+key] = value
+	}
+	return s.store.PutAll(prefixed)
+}
+
+// Delete deletes a key from the underlying store.
+func (s *PrefixedStore) Delete(key string) error {
+	return s.store.Delete(s.prefix + key)
+}
+
+// DeleteAll deletes multiple keys from the underlying store, see the DeleteAll function.
+func (s *PrefixedStore) DeleteAll(keys []string) error {
+	return DeleteAll(s.store, s.prefixKeys(keys))
+}
+
+// Mutate applies writes to the underlying store, see the Mutate function.
+func (s *PrefixedStore) Mutate(puts []KeyValue, deletes []string) error {
+	prefixed := make([]KeyValue, len(puts))
+	for i, kv := range puts {
+		prefixed[i] = KeyValue{s.prefix + kv.Key, kv.Value}
+	}
+	return Mutate(s.store, prefixed, s.prefixKeys(deletes))
+}
+
+// Increment adds delta to the counter stored at key in the underlying store, see the Increment function.
+func (s *PrefixedStore) Increment(key string, delta int64) (int64, error) {
+	return Increment(s.store, s.prefix+key, delta)
+}
+
+// PutIfAbsent inserts a value in the underlying store if the key does not exist, see the PutIfAbsent function.
+func (s *PrefixedStore) PutIfAbsent(key string, value []byte) (bool, error) {
+	return PutIfAbsent(s.store, s.prefix+key, value)
+}
+
+// CompareAndSet updates a value in the underlying store if it is equal to expected, see the CompareAndSet function.
+func (s *PrefixedStore) CompareAndSet(key string, expected, value []byte) (bool, error) {
+	return CompareAndSet(s.store, s.prefix+key, expected, value)
+}
+
+// PutWithTTL inserts or updates a value that expires after ttl in the underlying store, see the PutWithTTL function.
+func (s *PrefixedStore) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return PutWithTTL(s.store, s.prefix+key, value, ttl)
+}
+
+// Iterate iterates the keys of the underlying store starting with the prefix, see the Iterate function.
+func (s *PrefixedStore) Iterate(prefix string, fn func(KeyValue) bool) error {
+	return Iterate(s.store, s.prefix+prefix, func(kv KeyValue) bool {
+		return fn(KeyValue{strings.TrimPrefix(kv.Key, s.prefix), kv.Value})
+	})
+}
+
+// Flush flushes the underlying store.
+func (s *PrefixedStore) Flush() error {
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *PrefixedStore) GetStore() Store {
+	return s.store
+}
@@ -0,0 +1,42 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixedStore(t *testing.T) {
+	s := NewMap(10)
+	planets := NewPrefixedStore(s, "planets/")
+	moons := NewPrefixedStore(s, "moons/")
+
+	assert.Nil(t, planets.Put("mars", mars))
+	assert.Nil(t, planets.PutAll(map[string][]byte{"earth": earth, "venus": venus}))
+	assert.Nil(t, moons.Put("mars", []byte("phobos")))
+	assert.Equal(t, 4, len(s.GetMap()))
+
+	value, _ := planets.Get("mars")
+	assert.Equal(t, mars, value)
+	value, _ = moons.Get("mars")
+	assert.Equal(t, []byte("phobos"), value)
+
+	kvs, err := planets.GetAll([]string{"mars", "earth", "pluto"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"mars": mars, "earth": earth}, kvs)
+
+	var keys []string
+	assert.Nil(t, planets.Iterate("", func(kv KeyValue) bool {
+		keys = append(keys, kv.Key)
+		return true
+	}))
+	assert.Equal(t, []string{"earth", "mars", "venus"}, keys)
+
+	assert.True(t, IsTransactional(planets))
+	assert.Nil(t, Mutate(planets, []KeyValue{{"jupiter", jupiter}}, []string{"earth", "venus"}))
+	counter, err := Increment(moons, "jupiter", 79)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(79), counter)
+	assert.Nil(t, DeleteAll(moons, []string{"mars"}))
+	assert.Equal(t, map[string][]byte{"planets/mars": mars, "planets/jupiter": jupiter, "moons/jupiter": []byte("79")}, s.GetMap())
+}
@@ -0,0 +1,68 @@
+package kasper
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const profilingPath = "/debug/pprof/"
+
+// ProfilingHandler serves the runtime profiles for go tool pprof under /debug/pprof/, like net/http/pprof,
+// but without registering anything on http.DefaultServeMux:
+//
+//	/debug/pprof/                      lists the profiles
+//	/debug/pprof/profile?seconds=30    CPU profile
+//	/debug/pprof/heap, goroutine, ...  profiles from runtime/pprof, in text form with ?debug=1
+//
+// Profiles expose the internals of the process, so serve them on an internal port only.
+func ProfilingHandler() http.Handler {
+	return http.HandlerFunc(serveProfile)
+}
+
+func serveProfile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, profilingPath)
+	switch {
+	case !strings.HasPrefix(r.URL.Path, profilingPath):
+		http.NotFound(w, r)
+	case name == "":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, profile := range pprof.Profiles() {
+			fmt.Fprintf(w, "%s (%d)\n", profile.Name(), profile.Count())
+		}
+		fmt.Fprintln(w, "profile (CPU)")
+	case name == "profile":
+		serveCPUProfile(w, r)
+	default:
+		profile := pprof.Lookup(name)
+		if profile == nil {
+			http.NotFound(w, r)
+			return
+		}
+		debug, _ := strconv.Atoi(r.FormValue("debug"))
+		if debug > 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		profile.WriteTo(w, debug)
+	}
+}
+
+func serveCPUProfile(w http.ResponseWriter, r *http.Request) {
+	seconds, err := strconv.Atoi(r.FormValue("seconds"))
+	if err != nil || seconds <= 0 {
+		seconds = 30
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := pprof.StartCPUProfile(w); err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, fmt.Sprintf("cannot start CPU profile: %s", err), http.StatusInternalServerError)
+		return
+	}
+	time.Sleep(time.Duration(seconds) * time.Second)
+	pprof.StopCPUProfile()
+}
@@ -0,0 +1,84 @@
+package kasper
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner_EnableProfiling(t *testing.T) {
+	runner := NewRunner(&metadataClient{}, nil)
+	recorder := httptest.NewRecorder()
+	runner.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	assert.Equal(t, 404, recorder.Code)
+
+	runner.EnableProfiling()
+	recorder = httptest.NewRecorder()
+	runner.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	assert.Equal(t, 200, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "goroutine (")
+
+	recorder = httptest.NewRecorder()
+	runner.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/pprof/goroutine?debug=1", nil))
+	assert.Equal(t, 200, recorder.Code)
+	assert.True(t, strings.HasPrefix(recorder.Body.String(), "goroutine profile:"))
+
+	recorder = httptest.NewRecorder()
+	runner.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/pprof/pluto", nil))
+	assert.Equal(t, 404, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	runner.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, 200, recorder.Code)
+}
+
+// BenchmarkTopicProcessor_Pipeline measures a batch going through the TopicProcessor: deserializing the input,
+// reading and writing a store, serializing and producing the output, and marking the offsets.
+func BenchmarkTopicProcessor_Pipeline(b *testing.B) {
+	serde := NewJSONSerde(func() interface{} { return &serdeTestPlanet{} })
+	store := NewMap(1000)
+	processor := processorFunc(func(messages []*sarama.ConsumerMessage, sender Sender) error {
+		keys := make([]string, len(messages))
+		for i, message := range messages {
+			keys[i] = string(message.Key)
+		}
+		if _, err := store.GetAll(keys); err != nil {
+			return err
+		}
+		kvs := make(map[string][]byte, len(messages))
+		for _, message := range messages {
+			value, err := serde.Deserialize(message.Value)
+			if err != nil {
+				return err
+			}
+			planet := value.(*serdeTestPlanet)
+			planet.Moons++
+			data, err := serde.Serialize(planet)
+			if err != nil {
+				return err
+			}
+			kvs[string(message.Key)] = data
+			sender.Send(&sarama.ProducerMessage{Topic: "output", Key: sarama.ByteEncoder(message.Key), Value: sarama.ByteEncoder(data)})
+		}
+		return store.PutAll(kvs)
+	})
+	tp := newFakeTopicProcessor(&Config{}, processor)
+	batch := make([]*sarama.ConsumerMessage, 100)
+	for i := range batch {
+		name := fmt.Sprintf("planet-%d", i)
+		value := fmt.Sprintf(`{"name":%q,"moons":%d}`, name, i)
+		batch[i] = &sarama.ConsumerMessage{Topic: "input", Offset: int64(i), Key: []byte(name), Value: []byte(value)}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := tp.processConsumerMessages(batch, 0); err != nil {
+			b.Fatal(err)
+		}
+		tp.producer.messages = tp.producer.messages[:0]
+	}
+}
@@ -0,0 +1,86 @@
+package kasper
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Progress is published to Config.ProgressTopic as JSON after a batch of a partition has been processed and its
+// output produced, its stores flushed and its offsets committed, so that downstream pipelines and dashboards can
+// tell how complete the views materialized from the partition are. Its key is "<TopicProcessorName>/<partition>",
+// so the topic should be compacted to keep the latest progress of each partition.
+type Progress struct {
+	TopicProcessorName string `json:"topicProcessor"`
+	Partition          int    `json:"partition"`
+	// Next offset to process, by input topic
+	Offsets map[string]int64 `json:"offsets"`
+	// Latest timestamp of the messages processed from any input topic
+	StreamTime time.Time `json:"streamTime"`
+	// Earliest of the latest timestamps of the messages processed from each input topic: the messages of all input
+	// topics up to this time have been processed, assuming their timestamps increase within a partition.
+	// Topics from which no message has been processed yet are ignored.
+	Watermark time.Time `json:"watermark"`
+}
+
+// progressKey returns the key of the messages published to Config.ProgressTopic for a partition.
+func progressKey(topicProcessorName string, partition int) string {
+	return topicProcessorName + "/" + strconv.Itoa(partition)
+}
+
+// updateStreamTimes keeps the latest timestamp of the messages of each input topic.
+func (pp *partitionProcessor) updateStreamTimes(messages []*sarama.ConsumerMessage) {
+	if pp.streamTimes == nil {
+		pp.streamTimes = make(map[string]time.Time, len(pp.inputTopics))
+	}
+	for _, message := range messages {
+		if message.Timestamp.After(pp.streamTimes[message.Topic]) {
+			pp.streamTimes[message.Topic] = message.Timestamp
+		}
+	}
+}
+
+func (pp *partitionProcessor) progress() Progress {
+	progress := Progress{
+		TopicProcessorName: pp.topicProcessor.config.TopicProcessorName,
+		Partition:          pp.partition,
+		Offsets:            make(map[string]int64, len(pp.offsetManagers)),
+	}
+	for topic, pom := range pp.offsetManagers {
+		progress.Offsets[topic], _ = pom.NextOffset()
+	}
+	for _, streamTime := range pp.streamTimes {
+		if streamTime.After(progress.StreamTime) {
+			progress.StreamTime = streamTime
+		}
+		if progress.Watermark.IsZero() || streamTime.Before(progress.Watermark) {
+			progress.Watermark = streamTime
+		}
+	}
+	return progress
+}
+
+// publishProgress publishes the progress of the partition if Config.ProgressInterval has elapsed since it was last
+// published. Errors are logged, as they do not affect processing.
+func (tp *TopicProcessor) publishProgress(pp *partitionProcessor) {
+	now := tp.config.clock().Now()
+	if now.Sub(pp.progressPublished) < tp.config.ProgressInterval {
+		return
+	}
+	value, err := json.Marshal(pp.progress())
+	if err == nil {
+		err = tp.producer.SendMessages([]*sarama.ProducerMessage{{
+			Topic: tp.config.ProgressTopic,
+			Key:   sarama.StringEncoder(progressKey(tp.config.TopicProcessorName, pp.partition)),
+			Value: sarama.ByteEncoder(value),
+		}})
+	}
+	if err != nil {
+		tp.logger.Errorf("Failed to publish progress of partition %d: %s", pp.partition, err)
+		tp.stats.addError("produce")
+		return
+	}
+	pp.progressPublished = now
+}
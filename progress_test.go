@@ -0,0 +1,64 @@
+package kasper
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionProcessor_Progress(t *testing.T) {
+	tp := newFakeTopicProcessor(&Config{TopicProcessorName: "reach", InputTopics: []string{"tweets", "followers"}}, nil)
+	pp := tp.partitionProcessors[0]
+	pp.offsetManagers["followers"] = &fakeOffsetManager{next: 12}
+	start := time.Date(2017, 4, 1, 10, 0, 0, 0, time.UTC)
+	pp.updateStreamTimes([]*sarama.ConsumerMessage{
+		{Topic: "tweets", Timestamp: start.Add(3 * time.Second)},
+		{Topic: "followers", Timestamp: start.Add(time.Second)},
+		{Topic: "tweets", Timestamp: start.Add(2 * time.Second)},
+	})
+	assert.Equal(t, Progress{
+		TopicProcessorName: "reach",
+		Partition:          0,
+		Offsets:            map[string]int64{"tweets": 0, "followers": 12},
+		StreamTime:         start.Add(3 * time.Second),
+		Watermark:          start.Add(time.Second),
+	}, pp.progress())
+}
+
+func TestTopicProcessor_ProgressTopic(t *testing.T) {
+	start := time.Date(2017, 4, 1, 10, 0, 0, 0, time.UTC)
+	clock := &fixedClock{now: start}
+	config := &Config{
+		TopicProcessorName: "reach",
+		BatchSize:          1,
+		ProgressTopic:      "reach-progress",
+		ProgressInterval:   time.Minute,
+		Clock:              clock,
+	}
+	tp := newFakeTopicProcessor(config, &countingProcessor{})
+	done := tp.start()
+	for offset := int64(0); offset < 2; offset++ {
+		tp.consumers[0].messages <- &sarama.ConsumerMessage{Topic: "input", Offset: offset, Timestamp: start}
+	}
+	waitFor(t, func() bool {
+		assert.Nil(t, tp.Flush())
+		offset, _ := tp.offsets[0].NextOffset()
+		return offset == 2
+	})
+	tp.Close()
+	assert.Nil(t, <-done)
+
+	// The second batch is within ProgressInterval of the first
+	assert.Equal(t, 1, len(tp.producer.messages))
+	message := tp.producer.messages[0]
+	assert.Equal(t, "reach-progress", message.Topic)
+	assert.Equal(t, sarama.StringEncoder("reach/0"), message.Key)
+	var progress Progress
+	value, _ := message.Value.Encode()
+	assert.Nil(t, json.Unmarshal(value, &progress))
+	assert.Equal(t, map[string]int64{"input": 1}, progress.Offsets)
+	assert.True(t, start.Equal(progress.Watermark))
+}
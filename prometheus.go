@@ -1,7 +1,10 @@
 package kasper
 
 import (
+	"net/http"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
 )
 
 type prometheusCounter struct {
@@ -125,3 +128,20 @@ func (provider *Prometheus) NewSummary(name string, help string, labelNames ...s
 		summaryVec,
 	}
 }
+
+// ServeHTTP serves the metrics of Registry in the Prometheus exposition format, e.g. on /metrics.
+func (provider *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	families, err := provider.Registry.Gather()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	format := expfmt.Negotiate(r.Header)
+	w.Header().Set("Content-Type", string(format))
+	encoder := expfmt.NewEncoder(w, format)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package kasper
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Headers added to outgoing messages when Config.ProvenanceHeaders is set. Kasper cannot tell which incoming
+// message produced an outgoing message, so they describe the whole batch being processed.
+const (
+	// Config.TopicProcessorName
+	ProvenanceHeaderProcessor = "kasper-processor"
+	// Comma-separated input topics of the batch
+	ProvenanceHeaderSourceTopic = "kasper-source-topic"
+	// Input partition of the batch
+	ProvenanceHeaderSourcePartition = "kasper-source-partition"
+	// Comma-separated offset ranges of the batch by input topic, e.g. "tweets:100-199,followers:42-42"
+	ProvenanceHeaderSourceOffsets = "kasper-source-offsets"
+	// Time at which the batch was processed, in RFC 3339 format with nanoseconds
+	ProvenanceHeaderTimestamp = "kasper-timestamp"
+)
+
+// provenanceHeaders returns the provenance headers of a batch of messages from a single partition.
+func provenanceHeaders(topicProcessorName string, partition int, messages []*sarama.ConsumerMessage, now time.Time) []sarama.RecordHeader {
+	type offsetRange struct{ first, last int64 }
+	ranges := make(map[string]*offsetRange)
+	var topics []string
+	for _, message := range messages {
+		r, found := ranges[message.Topic]
+		if !found {
+			ranges[message.Topic] = &offsetRange{message.Offset, message.Offset}
+			topics = append(topics, message.Topic)
+			continue
+		}
+		if message.Offset < r.first {
+			r.first = message.Offset
+		}
+		if message.Offset > r.last {
+			r.last = message.Offset
+		}
+	}
+	sort.Strings(topics)
+	offsets := make([]string, len(topics))
+	for i, topic := range topics {
+		offsets[i] = fmt.Sprintf("%s:%d-%d", topic, ranges[topic].first, ranges[topic].last)
+	}
+	return []sarama.RecordHeader{
+		{Key: []byte(ProvenanceHeaderProcessor), Value: []byte(topicProcessorName)},
+		{Key: []byte(ProvenanceHeaderSourceTopic), Value: []byte(strings.Join(topics, ","))},
+		{Key: []byte(ProvenanceHeaderSourcePartition), Value: []byte(strconv.Itoa(partition))},
+		{Key: []byte(ProvenanceHeaderSourceOffsets), Value: []byte(strings.Join(offsets, ","))},
+		{Key: []byte(ProvenanceHeaderTimestamp), Value: []byte(now.UTC().Format(time.RFC3339Nano))},
+	}
+}
@@ -0,0 +1,56 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func headerMap(headers []sarama.RecordHeader) map[string]string {
+	values := make(map[string]string, len(headers))
+	for _, header := range headers {
+		values[string(header.Key)] = string(header.Value)
+	}
+	return values
+}
+
+func TestTopicProcessor_ProvenanceHeaders(t *testing.T) {
+	processor := processorFunc(func(messages []*sarama.ConsumerMessage, sender Sender) error {
+		sender.Send(&sarama.ProducerMessage{
+			Topic:   "reach",
+			Value:   sarama.StringEncoder("42"),
+			Headers: []sarama.RecordHeader{{Key: []byte("source"), Value: []byte("app")}},
+		})
+		return nil
+	})
+	config := &Config{
+		InputTopics:       []string{"tweets", "followers"},
+		InputPartitions:   []int{3},
+		ProvenanceHeaders: true,
+		Clock:             &fixedClock{now: time.Date(2017, 5, 1, 12, 0, 0, 0, time.FixedZone("NZST", 12*3600))},
+	}
+	tp := newFakeTopicProcessor(config, processor)
+	tp.partitionProcessors[3].offsetManagers["followers"] = &fakeOffsetManager{}
+	err := tp.processConsumerMessages([]*sarama.ConsumerMessage{
+		{Topic: "tweets", Partition: 3, Offset: 100},
+		{Topic: "followers", Partition: 3, Offset: 42},
+		{Topic: "tweets", Partition: 3, Offset: 199},
+	}, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(tp.producer.messages))
+	assert.Equal(t, map[string]string{
+		"source":                  "app",
+		"kasper-processor":        "fake",
+		"kasper-source-topic":     "followers,tweets",
+		"kasper-source-partition": "3",
+		"kasper-source-offsets":   "followers:42-42,tweets:100-199",
+		"kasper-timestamp":        "2017-05-01T00:00:00Z",
+	}, headerMap(tp.producer.messages[0].Headers))
+
+	// Headers are only added when enabled
+	tp = newFakeTopicProcessor(&Config{}, processor)
+	assert.Nil(t, tp.processConsumerMessages([]*sarama.ConsumerMessage{{Topic: "input", Offset: 1}}, 0))
+	assert.Equal(t, map[string]string{"source": "app"}, headerMap(tp.producer.messages[0].Headers))
+}
@@ -0,0 +1,224 @@
+package kasper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// queryGatewayForwardedHeader marks the requests forwarded by a QueryGateway, which are always served locally.
+const queryGatewayForwardedHeader = "X-Kasper-Forwarded"
+
+// QueryGateway serves the stores of Config.Stores over HTTP, so that other services can read materialized views
+// without a service layer of their own:
+//
+//	GET /stores                        returns the names of the stores
+//	GET /stores/{store}/{key}          returns the value of a key, or 404 if it is not found
+//	GET /stores/{store}?key=a&key=b    returns the values found as a JSON object, encoded like a map[string][]byte
+//
+// Values are returned as stored, typically serialized with a Serde. The stores are read concurrently with the
+// MessageProcessors, so they must be safe for concurrent use: centralized stores such as Redis are, and in-memory
+// stores can be wrapped with NewSynchronizedStore.
+//
+// When each container keeps the state of its own partitions in in-memory stores, set Peers so that the keys of the
+// partitions consumed by other containers are forwarded to their gateways. Requests for several keys fan out to
+// the containers of their partitions in parallel. Errors are returned as {"error": "..."}.
+type QueryGateway struct {
+	// Base URL of the gateway of the container consuming each input partition, e.g. {3: "http://reach-1:8080"}.
+	// Keys of Config.InputPartitions are always read locally. If nil, all keys are read locally.
+	Peers map[int]string
+	// Returns the input partition of a key, defaults to the partition chosen by sarama's hash partitioner
+	Partition func(key string) int
+	// Used for the requests forwarded to Peers
+	HTTPClient *http.Client
+
+	config *Config
+	local  map[int]bool
+	logger Logger
+}
+
+// NewQueryGateway creates a QueryGateway for the stores of config. partitions is the number of partitions of the
+// input topics, which is used to find the partition of a key.
+func NewQueryGateway(config *Config, partitions int) *QueryGateway {
+	local := make(map[int]bool, len(config.InputPartitions))
+	for _, partition := range config.InputPartitions {
+		local[partition] = true
+	}
+	return &QueryGateway{
+		Partition: func(key string) int {
+			// The hash partitioner is not safe for concurrent use
+			message := &sarama.ProducerMessage{Key: sarama.StringEncoder(key)}
+			partition, _ := sarama.NewHashPartitioner("").Partition(message, int32(partitions))
+			return int(partition)
+		},
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		config:     config,
+		local:      local,
+		logger:     config.logger(),
+	}
+}
+
+// ServeHTTP serves the stores.
+func (g *QueryGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/stores")
+	if len(path) == len(r.URL.Path) {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	if path == "" || path == "/" {
+		names := make([]string, 0, len(g.config.Stores))
+		for _, store := range g.config.Stores {
+			names = append(names, store.Name)
+		}
+		writeJSON(w, http.StatusOK, names)
+		return
+	}
+	parts := strings.SplitN(path[1:], "/", 2)
+	store := g.config.Store(parts[0])
+	if store == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("store %s not found", parts[0]))
+		return
+	}
+	forwarded := r.Header.Get(queryGatewayForwardedHeader) != ""
+	if len(parts) == 2 {
+		g.get(w, parts[0], store, parts[1], forwarded)
+		return
+	}
+	g.getAll(w, parts[0], store, r.URL.Query()["key"], forwarded)
+}
+
+// peer returns the base URL of the container the key must be read from, or "" if it is read locally.
+func (g *QueryGateway) peer(key string) string {
+	if g.Peers == nil {
+		return ""
+	}
+	partition := g.Partition(key)
+	if g.local[partition] {
+		return ""
+	}
+	return g.Peers[partition]
+}
+
+func (g *QueryGateway) get(w http.ResponseWriter, name string, store Store, key string, forwarded bool) {
+	if peer := g.peer(key); peer != "" && !forwarded {
+		g.forward(w, peer+(&url.URL{Path: "/stores/" + name + "/" + key}).String())
+		return
+	}
+	value, err := store.Get(key)
+	if err != nil {
+		g.logger.Errorf("Query gateway failed to get %s from %s: %s", key, name, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if value == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("key %s not found", key))
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(value)
+}
+
+func (g *QueryGateway) forward(w http.ResponseWriter, target string) {
+	response, err := g.request(target)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	defer response.Body.Close()
+	w.Header().Set("Content-Type", response.Header.Get("Content-Type"))
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, response.Body)
+}
+
+func (g *QueryGateway) request(target string) (*http.Response, error) {
+	request, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set(queryGatewayForwardedHeader, "1")
+	response, err := g.HTTPClient.Do(request)
+	if err != nil {
+		g.logger.Errorf("Query gateway failed to forward request to %s: %s", target, err)
+		return nil, err
+	}
+	return response, nil
+}
+
+func (g *QueryGateway) getAll(w http.ResponseWriter, name string, store Store, keys []string, forwarded bool) {
+	byPeer := make(map[string][]string)
+	for _, key := range keys {
+		peer := ""
+		if !forwarded {
+			peer = g.peer(key)
+		}
+		byPeer[peer] = append(byPeer[peer], key)
+	}
+	peers := make([]string, 0, len(byPeer))
+	for peer := range byPeer {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	results := make([]map[string][]byte, len(peers))
+	errs := make([]error, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, peer string) {
+			defer wg.Done()
+			if peer == "" {
+				results[i], errs[i] = store.GetAll(byPeer[peer])
+			} else {
+				results[i], errs[i] = g.getAllFrom(peer, name, byPeer[peer])
+			}
+		}(i, peer)
+	}
+	wg.Wait()
+	kvs := make(map[string][]byte, len(keys))
+	for i, peer := range peers {
+		if errs[i] != nil {
+			status := http.StatusBadGateway
+			if peer == "" {
+				g.logger.Errorf("Query gateway failed to get %d keys from %s: %s", len(byPeer[peer]), name, errs[i])
+				status = http.StatusInternalServerError
+			}
+			writeError(w, status, errs[i])
+			return
+		}
+		for key, value := range results[i] {
+			kvs[key] = value
+		}
+	}
+	writeJSON(w, http.StatusOK, kvs)
+}
+
+func (g *QueryGateway) getAllFrom(peer, name string, keys []string) (map[string][]byte, error) {
+	target := peer + (&url.URL{Path: "/stores/" + name, RawQuery: url.Values{"key": keys}.Encode()}).String()
+	response, err := g.request(target)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, fmt.Errorf("%s returned status %d: %s", peer, response.StatusCode, strings.TrimSpace(string(message)))
+	}
+	var kvs map[string][]byte
+	if err := json.NewDecoder(response.Body).Decode(&kvs); err != nil {
+		return nil, err
+	}
+	return kvs, nil
+}
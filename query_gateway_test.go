@@ -0,0 +1,102 @@
+package kasper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestQueryGateway(partition int, kvs map[string][]byte) *QueryGateway {
+	store := NewMap(10)
+	store.PutAll(kvs)
+	config := &Config{
+		InputPartitions: []int{partition},
+		Logger:          &noopLogger{},
+		Stores:          []NamedStore{{"planets", NewSynchronizedStore(store)}},
+	}
+	gateway := NewQueryGateway(config, 2)
+	// Keys starting with "m" are in partition 1
+	gateway.Partition = func(key string) int {
+		if key[0] == 'm' {
+			return 1
+		}
+		return 0
+	}
+	return gateway
+}
+
+func queryGatewayRequest(gateway *QueryGateway, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	gateway.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+	return recorder
+}
+
+func TestQueryGateway(t *testing.T) {
+	gateway := newTestQueryGateway(0, map[string][]byte{"earth": earth, "mars": mars})
+
+	recorder := queryGatewayRequest(gateway, "/stores")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "[\"planets\"]\n", recorder.Body.String())
+
+	recorder = queryGatewayRequest(gateway, "/stores/planets/earth")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, earth, recorder.Body.Bytes())
+
+	recorder = queryGatewayRequest(gateway, "/stores/planets/jupiter")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	recorder = queryGatewayRequest(gateway, "/stores/moons/earth")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = queryGatewayRequest(gateway, "/stores/planets?key=earth&key=mars&key=jupiter")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var kvs map[string][]byte
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &kvs))
+	assert.Equal(t, map[string][]byte{"earth": earth, "mars": mars}, kvs)
+
+	recorder = httptest.NewRecorder()
+	gateway.ServeHTTP(recorder, httptest.NewRequest("POST", "/stores/planets/earth", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestQueryGateway_Peers(t *testing.T) {
+	gateway0 := newTestQueryGateway(0, map[string][]byte{"earth": earth, "mars": earth})
+	gateway1 := newTestQueryGateway(1, map[string][]byte{"mars": mars, "mercury": mercury})
+	server0 := httptest.NewServer(gateway0)
+	defer server0.Close()
+	server1 := httptest.NewServer(gateway1)
+	defer server1.Close()
+	peers := map[int]string{0: server0.URL, 1: server1.URL}
+	gateway0.Peers = peers
+	gateway1.Peers = peers
+
+	recorder := queryGatewayRequest(gateway0, "/stores/planets/mars")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, mars, recorder.Body.Bytes())
+	recorder = queryGatewayRequest(gateway1, "/stores/planets/earth")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, earth, recorder.Body.Bytes())
+	recorder = queryGatewayRequest(gateway1, "/stores/planets/mercury")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, mercury, recorder.Body.Bytes())
+
+	recorder = queryGatewayRequest(gateway0, "/stores/planets?key=earth&key=mars&key=mercury&key=jupiter")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var kvs map[string][]byte
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &kvs))
+	assert.Equal(t, map[string][]byte{"earth": earth, "mars": mars, "mercury": mercury}, kvs)
+
+	server1.Close()
+	recorder = queryGatewayRequest(gateway0, "/stores/planets?key=earth&key=mars")
+	assert.Equal(t, http.StatusBadGateway, recorder.Code)
+	recorder = queryGatewayRequest(gateway0, "/stores/planets/mars")
+	assert.Equal(t, http.StatusBadGateway, recorder.Code)
+}
+
+func TestQueryGateway_Partition(t *testing.T) {
+	gateway := NewQueryGateway(&Config{}, 12)
+	assert.Equal(t, 3, gateway.Partition("earth"))
+	assert.Equal(t, 0, gateway.Partition("mars"))
+}
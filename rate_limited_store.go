@@ -0,0 +1,129 @@
+package kasper
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket holding up to one second worth of tokens.
+type rateLimiter struct {
+	perSecond float64
+	bucket    tokenBucket
+}
+
+// reserve takes n tokens and returns how long to wait until the bucket is no longer in debt.
+func (l *rateLimiter) reserve(n float64, now time.Time) time.Duration {
+	if l.perSecond <= 0 {
+		return 0
+	}
+	if l.bucket.updated.IsZero() {
+		l.bucket = tokenBucket{l.perSecond, now}
+	}
+	l.bucket.tokens += now.Sub(l.bucket.updated).Seconds() * l.perSecond
+	if l.bucket.tokens > l.perSecond {
+		l.bucket.tokens = l.perSecond
+	}
+	l.bucket.updated = now
+	l.bucket.tokens -= n
+	if l.bucket.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.bucket.tokens / l.perSecond * float64(time.Second))
+}
+
+// RateLimitedStore wraps a Store and limits the rate of operations and bytes sent to it, so that replays and
+// catch-up processing cannot overwhelm a database shared with online traffic. Calls block until they are within the
+// limits, with bursts of up to one second worth of operations or bytes. Operations are counted per key (e.g. a
+// PutAll of 10 keys counts as 10 operations), and bytes are the values written and read: since the size of a
+// read is only known once it has completed, it delays the next call. The time spent waiting is counted in the
+// store_rate_limit_wait_seconds metric. RateLimitedStore is safe for concurrent use if the underlying store is.
+type RateLimitedStore struct {
+	store       Store
+	name        string
+	clock       Clock
+	mutex       sync.Mutex
+	operations  rateLimiter
+	bytes       rateLimiter
+	waitCounter Counter
+	sleep       func(time.Duration)
+}
+
+// NewRateLimitedStore creates RateLimitedStore instances, allowing up to operationsPerSecond and bytesPerSecond
+// on average (0 disables the corresponding limit). The name is used as the value of the "store" label.
+func NewRateLimitedStore(config *Config, store Store, name string, operationsPerSecond, bytesPerSecond float64) *RateLimitedStore {
+	return &RateLimitedStore{
+		store,
+		name,
+		config.clock(),
+		sync.Mutex{},
+		rateLimiter{perSecond: operationsPerSecond},
+		rateLimiter{perSecond: bytesPerSecond},
+		config.storeMetricsProvider().NewCounter("store_rate_limit_wait_seconds",
+			"Time spent waiting for the store rate limits", "store"),
+		time.Sleep,
+	}
+}
+
+func (s *RateLimitedStore) wait(operations, bytes int) {
+	s.mutex.Lock()
+	now := s.clock.Now()
+	wait := s.operations.reserve(float64(operations), now)
+	if bytesWait := s.bytes.reserve(float64(bytes), now); bytesWait > wait {
+		wait = bytesWait
+	}
+	s.mutex.Unlock()
+	if wait > 0 {
+		s.waitCounter.Add(wait.Seconds(), s.name)
+		s.sleep(wait)
+	}
+}
+
+func (s *RateLimitedStore) charge(bytes int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.bytes.reserve(float64(bytes), s.clock.Now())
+}
+
+// Get gets a value by key from the underlying store.
+func (s *RateLimitedStore) Get(key string) ([]byte, error) {
+	s.wait(1, 0)
+	value, err := s.store.Get(key)
+	s.charge(len(value))
+	return value, err
+}
+
+// GetAll gets multiple values by key from the underlying store.
+func (s *RateLimitedStore) GetAll(keys []string) (map[string][]byte, error) {
+	s.wait(len(keys), 0)
+	kvs, err := s.store.GetAll(keys)
+	s.charge(countBytes(kvs))
+	return kvs, err
+}
+
+// Put inserts or updates a value by key in the underlying store.
+func (s *RateLimitedStore) Put(key string, value []byte) error {
+	s.wait(1, len(value))
+	return s.store.Put(key, value)
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store.
+func (s *RateLimitedStore) PutAll(kvs map[string][]byte) error {
+	s.wait(len(kvs), countBytes(kvs))
+	return s.store.PutAll(kvs)
+}
+
+// Delete deletes a key from the underlying store.
+func (s *RateLimitedStore) Delete(key string) error {
+	s.wait(1, 0)
+	return s.store.Delete(key)
+}
+
+// Flush flushes the underlying store. It is not rate limited.
+func (s *RateLimitedStore) Flush() error {
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *RateLimitedStore) GetStore() Store {
+	return s.store
+}
@@ -0,0 +1,38 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitedStore(t *testing.T) {
+	provider := newRecordingMetricsProvider()
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	config := &Config{TopicProcessorName: "hari-seldon", ContainerID: "container-1", MetricsProvider: provider, Clock: clock}
+	store := NewRateLimitedStore(config, NewMap(10), "planets", 10, 100)
+	var waits []time.Duration
+	store.sleep = func(d time.Duration) {
+		waits = append(waits, d)
+		clock.now = clock.now.Add(d)
+	}
+
+	// Bursts of up to one second are allowed
+	for i := 0; i < 10; i++ {
+		store.Get("mars")
+	}
+	assert.Equal(t, 0, len(waits))
+	store.Delete("mars")
+	assert.Equal(t, []time.Duration{100 * time.Millisecond}, waits)
+
+	// 10 keys of 20 bytes exceed the byte limit
+	clock.now = clock.now.Add(time.Second)
+	kvs := map[string][]byte{}
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		kvs[key] = []byte("01234567890123456789")
+	}
+	store.PutAll(kvs)
+	assert.Equal(t, 1*time.Second, waits[1])
+	assert.InDelta(t, 1.1, provider.values["store_rate_limit_wait_seconds{planets,,container-1,hari-seldon}"], 0.001)
+}
@@ -2,10 +2,24 @@ package kasper
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/garyburd/redigo/redis"
 )
 
+const redisCompareAndSetScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2])
+	return 1
+end
+return 0`
+
+// Number of keys requested from each SCAN and MGET command by Redis.Iterate
+const redisScanCount = 100
+
+var redisPatternEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
 // Redis is an implementation of Store that uses Redis.
 // Each instance provides key-value access to keys with a specific prefix.
 // This implementation uses Gary Burd's Go Redis client.
@@ -15,6 +29,7 @@ type Redis struct {
 	keyPrefix string
 
 	logger        Logger
+	stats         *runtimeStats
 	labelValues   []string
 	getCounter    Counter
 	getAllSummary Summary
@@ -22,24 +37,35 @@ type Redis struct {
 	putAllSummary Summary
 	deleteCounter Counter
 	flushCounter  Counter
+
+	getBytesSummary    Summary
+	getAllBytesSummary Summary
+	putBytesSummary    Summary
+	putAllBytesSummary Summary
 }
 
 // NewRedis creates Redis instances. All keys read and written in Redis are of the form:
 //	{keyPrefix}/{key}
 func NewRedis(config *Config, conn redis.Conn, keyPrefix string) *Redis {
-	metrics := config.MetricsProvider
-	labelNames := []string{"topicProcessor", "keyPrefix"}
+	config.stats().addStore("Redis")
+	metrics := config.storeMetricsProvider()
+	labelNames := []string{"keyPrefix"}
 	return &Redis{
 		conn,
 		keyPrefix,
-		config.Logger,
-		[]string{config.TopicProcessorName, keyPrefix},
+		WithFields(config.logger(), Field{"store", "Redis"}, Field{"keyPrefix", keyPrefix}),
+		config.stats(),
+		[]string{keyPrefix},
 		metrics.NewCounter("Redis_Get", "Number of Get() calls", labelNames...),
 		metrics.NewSummary("Redis_GetAll", "Summary of GetAll() calls", labelNames...),
 		metrics.NewCounter("Redis_Put", "Number of Put() calls", labelNames...),
 		metrics.NewSummary("Redis_PutAll", "Summary of PutAll() calls", labelNames...),
 		metrics.NewCounter("Redis_Delete", "Number of Delete() calls", labelNames...),
 		metrics.NewCounter("Redis_Flush", "Summary of Flush() calls", labelNames...),
+		metrics.NewSummary("Redis_Get_Bytes", "Summary of Get() bytes read", labelNames...),
+		metrics.NewSummary("Redis_GetAll_Bytes", "Summary of GetAll() bytes read", labelNames...),
+		metrics.NewSummary("Redis_Put_Bytes", "Summary of Put() bytes written", labelNames...),
+		metrics.NewSummary("Redis_PutAll_Bytes", "Summary of PutAll() bytes written", labelNames...),
 	}
 }
 
@@ -51,7 +77,8 @@ func (s *Redis) getPrefixedKey(key string) string {
 // Returns nil, nil if the key is missing.
 // It is implemented using the Redis GET command.
 // See https://redis.io/commands/get
-func (s *Redis) Get(key string) ([]byte, error) {
+func (s *Redis) Get(key string) (_ []byte, err error) {
+	defer s.stats.observeStoreOperation("Redis.Get", s.stats.now(), &err)
 	s.logger.Debug("Redis Get: ", key)
 	s.getCounter.Inc(s.labelValues...)
 	value, err := s.conn.Do("GET", s.getPrefixedKey(key))
@@ -65,6 +92,7 @@ func (s *Redis) Get(key string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.getBytesSummary.Observe(float64(len(bytes)), s.labelValues...)
 	return bytes, err
 }
 
@@ -72,56 +100,91 @@ func (s *Redis) Get(key string) ([]byte, error) {
 // It is implemented by using the MULTI and GET commands.
 // See https://redis.io/commands/multi
 func (s *Redis) GetAll(keys []string) (map[string][]byte, error) {
+	entries := make(map[string][]byte, len(keys))
+	if err := s.GetAllInto(keys, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetAllInto is like GetAll, but adds the values to entries, see ReusingStore.
+func (s *Redis) GetAllInto(keys []string, entries map[string][]byte) (err error) {
+	defer s.stats.observeStoreOperation("Redis.GetAll", s.stats.now(), &err)
 	s.getAllSummary.Observe(float64(len(keys)), s.labelValues...)
 	if len(keys) == 0 {
-		return map[string][]byte{}, nil
+		return nil
 	}
 	s.logger.Debug("Redis GetAll: ", keys)
-	err := s.conn.Send("MULTI")
+	err = s.conn.Send("MULTI")
 	if err != nil {
-		return nil, err
+		return err
 	}
 	for _, key := range keys {
 		err = s.conn.Send("GET", s.getPrefixedKey(key))
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 	values, err := redis.Values(s.conn.Do("EXEC"))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	entries := make(map[string][]byte, len(keys))
 	for i, value := range values {
 		if value == nil {
 			continue
 		}
 		bytes, err := redis.Bytes(value, err)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		entries[keys[i]] = bytes
 	}
-	return entries, nil
+	s.getAllBytesSummary.Observe(float64(countBytes(entries)), s.labelValues...)
+	return nil
 }
 
 // Puts inserts or updates a value by key.
 // It is implemented using the Redis SET command.
 // See https://redis.io/commands/set
-func (s *Redis) Put(key string, value []byte) error {
+func (s *Redis) Put(key string, value []byte) (err error) {
+	defer s.stats.observeStoreOperation("Redis.Put", s.stats.now(), &err)
 	s.logger.Debugf("Redis Put: %s %#v", s.getPrefixedKey(key), value)
 	s.putCounter.Inc(s.labelValues...)
-	_, err := s.conn.Do("SET", s.getPrefixedKey(key), value)
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	_, err = s.conn.Do("SET", s.getPrefixedKey(key), value)
 	return err
 }
 
+// PutWithTTL inserts or updates a value by key that expires after ttl (rounded up to milliseconds).
+// It returns an error if ttl is not positive.
+// It is implemented using the Redis SET command with the PX option.
+// See https://redis.io/commands/set
+func (s *Redis) PutWithTTL(key string, value []byte, ttl time.Duration) (err error) {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid TTL for key %s: %s (must be positive)", key, ttl)
+	}
+	defer s.stats.observeStoreOperation("Redis.PutWithTTL", s.stats.now(), &err)
+	s.logger.Debugf("Redis PutWithTTL: %s %#v %s", s.getPrefixedKey(key), value, ttl)
+	s.putCounter.Inc(s.labelValues...)
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	_, err = s.conn.Do("SET", s.getPrefixedKey(key), value, "PX", redisMilliseconds(ttl))
+	return err
+}
+
+// redisMilliseconds converts a positive duration to milliseconds, rounding up so that it is never 0.
+func redisMilliseconds(d time.Duration) int64 {
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
+
 // PutAll inserts or updates multiple values by key.
 // It is implemented by using the MULTI and SET commands.
 // See https://redis.io/commands/multi
-func (s *Redis) PutAll(entries map[string][]byte) error {
+func (s *Redis) PutAll(entries map[string][]byte) (err error) {
+	defer s.stats.observeStoreOperation("Redis.PutAll", s.stats.now(), &err)
 	s.logger.Debugf("Redis PutAll of %d keys", len(entries))
 	s.putAllSummary.Observe(float64(len(entries)), s.labelValues...)
-	err := s.conn.Send("MULTI")
+	s.putAllBytesSummary.Observe(float64(countBytes(entries)), s.labelValues...)
+	err = s.conn.Send("MULTI")
 	if err != nil {
 		return err
 	}
@@ -138,18 +201,151 @@ func (s *Redis) PutAll(entries map[string][]byte) error {
 // Delete deletes a value by key.
 // It is implemented using the Redis DEL command.
 // See https://redis.io/commands/del
-func (s *Redis) Delete(key string) error {
+func (s *Redis) Delete(key string) (err error) {
+	defer s.stats.observeStoreOperation("Redis.Delete", s.stats.now(), &err)
 	s.logger.Debugf("Redis Delete: %s", s.getPrefixedKey(key))
 	s.deleteCounter.Inc(s.labelValues...)
-	_, err := s.conn.Do("DEL", s.getPrefixedKey(key))
+	_, err = s.conn.Do("DEL", s.getPrefixedKey(key))
+	return err
+}
+
+// DeleteAll deletes multiple values by key.
+// It is implemented using the Redis DEL command with multiple keys.
+// See https://redis.io/commands/del
+func (s *Redis) DeleteAll(keys []string) (err error) {
+	defer s.stats.observeStoreOperation("Redis.DeleteAll", s.stats.now(), &err)
+	s.logger.Debugf("Redis DeleteAll of %d keys", len(keys))
+	s.deleteCounter.Add(float64(len(keys)), s.labelValues...)
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = s.getPrefixedKey(key)
+	}
+	_, err = s.conn.Do("DEL", args...)
+	return err
+}
+
+// Mutate inserts or updates the puts, then deletes the keys in deletes, atomically.
+// It is implemented by using the MULTI, SET and DEL commands.
+// See https://redis.io/topics/transactions
+func (s *Redis) Mutate(puts []KeyValue, deletes []string) (err error) {
+	defer s.stats.observeStoreOperation("Redis.Mutate", s.stats.now(), &err)
+	s.logger.Debugf("Redis Mutate of %d puts and %d deletes", len(puts), len(deletes))
+	s.putCounter.Add(float64(len(puts)), s.labelValues...)
+	s.deleteCounter.Add(float64(len(deletes)), s.labelValues...)
+	err = s.conn.Send("MULTI")
+	if err != nil {
+		return err
+	}
+	for _, kv := range puts {
+		err = s.conn.Send("SET", s.getPrefixedKey(kv.Key), kv.Value)
+		if err != nil {
+			return err
+		}
+	}
+	for _, key := range deletes {
+		err = s.conn.Send("DEL", s.getPrefixedKey(key))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = s.conn.Do("EXEC")
 	return err
 }
 
 // Flush executes the SAVE command.
 // See https://redis.io/commands/save
-func (s *Redis) Flush() error {
+func (s *Redis) Flush() (err error) {
+	defer s.stats.observeStoreOperation("Redis.Flush", s.stats.now(), &err)
 	s.logger.Info("Redis Flush...")
-	_, err := s.conn.Do("SAVE")
+	_, err = s.conn.Do("SAVE")
 	s.logger.Info("Redis Flush complete")
 	return err
 }
+
+// Increment adds delta to the counter stored at key and returns the new value.
+// It is implemented using the Redis INCRBY command.
+// See https://redis.io/commands/incrby
+func (s *Redis) Increment(key string, delta int64) (_ int64, err error) {
+	defer s.stats.observeStoreOperation("Redis.Increment", s.stats.now(), &err)
+	s.logger.Debugf("Redis Increment: %s %d", s.getPrefixedKey(key), delta)
+	return redis.Int64(s.conn.Do("INCRBY", s.getPrefixedKey(key), delta))
+}
+
+// PutIfAbsent inserts a value if the key does not exist.
+// It is implemented using the Redis SET command with the NX option.
+// See https://redis.io/commands/set
+func (s *Redis) PutIfAbsent(key string, value []byte) (_ bool, err error) {
+	defer s.stats.observeStoreOperation("Redis.PutIfAbsent", s.stats.now(), &err)
+	s.logger.Debugf("Redis PutIfAbsent: %s %#v", s.getPrefixedKey(key), value)
+	s.putCounter.Inc(s.labelValues...)
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	reply, err := s.conn.Do("SET", s.getPrefixedKey(key), value, "NX")
+	return reply != nil, err
+}
+
+// CompareAndSet updates a value if the current value is equal to expected.
+// It is implemented atomically with a Lua script, or with PutIfAbsent if expected is nil.
+// See https://redis.io/commands/eval
+func (s *Redis) CompareAndSet(key string, expected, value []byte) (_ bool, err error) {
+	if expected == nil {
+		return s.PutIfAbsent(key, value)
+	}
+	defer s.stats.observeStoreOperation("Redis.CompareAndSet", s.stats.now(), &err)
+	s.logger.Debugf("Redis CompareAndSet: %s %#v", s.getPrefixedKey(key), value)
+	s.putCounter.Inc(s.labelValues...)
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	updated, err := redis.Int(s.conn.Do("EVAL", redisCompareAndSetScript, 1, s.getPrefixedKey(key), expected, value))
+	return updated == 1, err
+}
+
+// Iterate calls fn for each key starting with prefix until fn returns false.
+// It is implemented using the Redis SCAN command with a MATCH pattern, and the MGET command for each page of keys.
+// Keys deleted between the two commands are skipped.
+// See https://redis.io/commands/scan
+func (s *Redis) Iterate(prefix string, fn func(KeyValue) bool) (err error) {
+	defer s.stats.observeStoreOperation("Redis.Iterate", s.stats.now(), &err)
+	s.logger.Debugf("Redis Iterate: %s", s.getPrefixedKey(prefix))
+	pattern := redisPatternEscaper.Replace(s.getPrefixedKey(prefix)) + "*"
+	cursor := "0"
+	for {
+		reply, err := redis.Values(s.conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", redisScanCount))
+		if err != nil {
+			return err
+		}
+		if len(reply) != 2 {
+			return fmt.Errorf("unexpected SCAN reply: %v", reply)
+		}
+		cursor, err = redis.String(reply[0], nil)
+		if err != nil {
+			return err
+		}
+		keys, err := redis.Strings(reply[1], nil)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			args := make([]interface{}, len(keys))
+			for i, key := range keys {
+				args[i] = key
+			}
+			values, err := redis.ByteSlices(s.conn.Do("MGET", args...))
+			if err != nil {
+				return err
+			}
+			for i, value := range values {
+				if value == nil {
+					continue
+				}
+				if !fn(KeyValue{strings.TrimPrefix(keys[i], s.keyPrefix+"/"), value}) {
+					return nil
+				}
+			}
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package kasper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/garyburd/redigo/redis"
+)
+
+// RedisStreamSource is an InputSource reading a Redis stream (Redis 5 or later) as a member of a consumer group,
+// so that several containers can share the entries of the stream. It is implemented using the XREADGROUP and XACK
+// commands. The key and the value of each message are read from the "key" and "value" fields of the entry, the
+// topic is the name of the stream and the timestamp is the time of the entry ID. Use RedisStreamID to get the ID.
+// See https://redis.io/topics/streams-intro
+type RedisStreamSource struct {
+	conn     redis.Conn
+	stream   string
+	group    string
+	consumer string
+	pending  bool
+	ids      map[*sarama.ConsumerMessage]string
+}
+
+// NewRedisStreamSource creates a RedisStreamSource. The consumer group is created if it does not exist, reading the
+// entries added after its creation. consumer names the container within the group, e.g. Config.ContainerID.
+// After a restart, the entries which were read but not acknowledged by consumer are read again first.
+func NewRedisStreamSource(conn redis.Conn, stream, group, consumer string) (*RedisStreamSource, error) {
+	_, err := conn.Do("XGROUP", "CREATE", stream, group, "$", "MKSTREAM")
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+	return &RedisStreamSource{conn, stream, group, consumer, true, make(map[*sarama.ConsumerMessage]string)}, nil
+}
+
+// RedisStreamID returns the ID of the stream entry of a message returned by Read, until the message is acknowledged.
+func (s *RedisStreamSource) RedisStreamID(message *sarama.ConsumerMessage) string {
+	return s.ids[message]
+}
+
+// Read reads up to max entries which were not delivered to another consumer of the group.
+func (s *RedisStreamSource) Read(max int, wait time.Duration) ([]*sarama.ConsumerMessage, error) {
+	if s.pending {
+		// Entries delivered to this consumer before a restart, which are not acknowledged yet
+		messages, err := s.read(max, 0, "0")
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+		s.pending = false
+	}
+	return s.read(max, wait, ">")
+}
+
+func (s *RedisStreamSource) read(max int, wait time.Duration, id string) ([]*sarama.ConsumerMessage, error) {
+	args := []interface{}{"GROUP", s.group, s.consumer, "COUNT", max}
+	if id == ">" {
+		// BLOCK 0 would block forever
+		block := int64(wait / time.Millisecond)
+		if block < 1 {
+			block = 1
+		}
+		args = append(args, "BLOCK", block)
+	}
+	args = append(args, "STREAMS", s.stream, id)
+	streams, err := redis.Values(s.conn.Do("XREADGROUP", args...))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 {
+		return nil, nil
+	}
+	stream, err := redis.Values(streams[0], nil)
+	if err != nil || len(stream) != 2 {
+		return nil, fmt.Errorf("unexpected XREADGROUP reply: %v", streams[0])
+	}
+	entries, err := redis.Values(stream[1], nil)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*sarama.ConsumerMessage, 0, len(entries))
+	for _, entry := range entries {
+		message, id, err := s.parseEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		s.ids[message] = id
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (s *RedisStreamSource) parseEntry(entry interface{}) (*sarama.ConsumerMessage, string, error) {
+	values, err := redis.Values(entry, nil)
+	if err != nil || len(values) != 2 {
+		return nil, "", fmt.Errorf("unexpected stream entry: %v", entry)
+	}
+	id, err := redis.String(values[0], nil)
+	if err != nil {
+		return nil, "", err
+	}
+	message := &sarama.ConsumerMessage{Topic: s.stream}
+	if millis, err := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64); err == nil {
+		message.Timestamp = time.Unix(0, millis*int64(time.Millisecond))
+	}
+	// The fields of deleted entries are nil
+	fields, _ := redis.ByteSlices(values[1], nil)
+	for i := 0; i+1 < len(fields); i += 2 {
+		switch string(fields[i]) {
+		case "key":
+			message.Key = fields[i+1]
+		case "value":
+			message.Value = fields[i+1]
+		}
+	}
+	return message, id, nil
+}
+
+// Ack acknowledges messages with XACK, so that they are not delivered again.
+func (s *RedisStreamSource) Ack(messages []*sarama.ConsumerMessage) error {
+	args := make([]interface{}, 0, len(messages)+2)
+	args = append(args, s.stream, s.group)
+	for _, message := range messages {
+		if id, found := s.ids[message]; found {
+			args = append(args, id)
+			delete(s.ids, message)
+		}
+	}
+	if len(args) == 2 {
+		return nil
+	}
+	_, err := s.conn.Do("XACK", args...)
+	return err
+}
+
+// Close closes the connection.
+func (s *RedisStreamSource) Close() error {
+	return s.conn.Close()
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/garyburd/redigo/redis"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 var redisStore *Redis
@@ -103,6 +104,115 @@ func TestRedis_GetAll_PutAll(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestRedis_Increment(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	counter, err := redisStore.Increment("hatchlings", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), counter)
+	counter, err = Increment(redisStore, "hatchlings", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), counter)
+	value, err := redisStore.Get("hatchlings")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1"), value)
+}
+
+func TestRedis_PutIfAbsent_CompareAndSet(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	inserted, err := redisStore.PutIfAbsent("smaug", saphira)
+	assert.Nil(t, err)
+	assert.True(t, inserted)
+	inserted, err = redisStore.PutIfAbsent("smaug", falkor)
+	assert.Nil(t, err)
+	assert.False(t, inserted)
+
+	updated, err := redisStore.CompareAndSet("smaug", falkor, mushu)
+	assert.Nil(t, err)
+	assert.False(t, updated)
+	updated, err = redisStore.CompareAndSet("smaug", saphira, mushu)
+	assert.Nil(t, err)
+	assert.True(t, updated)
+	dragon, err := redisStore.Get("smaug")
+	assert.Nil(t, err)
+	assert.Equal(t, mushu, dragon)
+}
+
+func TestRedis_Iterate(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	err := redisStore.PutAll(map[string][]byte{"iterate/saphira": saphira, "iterate/mushu": mushu, "iterate*": falkor})
+	assert.Nil(t, err)
+	kvs := map[string][]byte{}
+	err = redisStore.Iterate("iterate/", func(kv KeyValue) bool {
+		kvs[kv.Key] = kv.Value
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"iterate/saphira": saphira, "iterate/mushu": mushu}, kvs)
+}
+
+func TestRedis_PutWithTTL(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	err := redisStore.PutWithTTL("puff", falkor, time.Minute)
+	assert.Nil(t, err)
+	ttl, err := redis.Int64(redisStore.conn.Do("PTTL", "dragon/puff"))
+	assert.Nil(t, err)
+	assert.InDelta(t, 60000, ttl, 1000)
+}
+
+func TestRedis_PutWithTTL_Invalid(t *testing.T) {
+	err := (&Redis{}).PutWithTTL("puff", falkor, 0)
+	assert.EqualError(t, err, "invalid TTL for key puff: 0s (must be positive)")
+	assert.Equal(t, int64(1), redisMilliseconds(time.Microsecond))
+	assert.Equal(t, int64(2), redisMilliseconds(1500*time.Microsecond))
+	assert.Equal(t, int64(60000), redisMilliseconds(time.Minute))
+}
+
+func TestRedis_GetAllInto(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	err := redisStore.PutAll(map[string][]byte{"saphira": saphira, "mushu": mushu})
+	assert.Nil(t, err)
+	kvs := map[string][]byte{"nessie": falkor}
+	err = GetAllInto(redisStore, []string{"saphira", "mushu", "smaug"}, kvs)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"saphira": saphira, "mushu": mushu}, kvs)
+}
+
+func TestRedis_DeleteAll(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	err := redisStore.PutAll(map[string][]byte{"saphira": saphira, "mushu": mushu})
+	assert.Nil(t, err)
+	err = redisStore.DeleteAll([]string{"saphira", "mushu", "nessie"})
+	assert.Nil(t, err)
+	kvs, err := redisStore.GetAll([]string{"saphira", "mushu"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(kvs))
+}
+
+func TestRedis_Mutate(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	err := redisStore.Put("saphira", saphira)
+	assert.Nil(t, err)
+	err = redisStore.Mutate([]KeyValue{{"mushu", mushu}}, []string{"saphira"})
+	assert.Nil(t, err)
+	kvs, err := redisStore.GetAll([]string{"saphira", "mushu"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"mushu": mushu}, kvs)
+}
+
 func TestRedis_Flush(t *testing.T) {
 	if testing.Short() {
 		t.Skip()
@@ -111,10 +221,43 @@ func TestRedis_Flush(t *testing.T) {
 	assert.Nil(t, err)
 }
 
-func init() {
+func TestRedisStreamSource(t *testing.T) {
 	if testing.Short() {
-		return
+		t.Skip()
 	}
+	conn, err := redis.DialURL(fmt.Sprintf("redis://%s:6379", getCIHost()))
+	assert.Nil(t, err)
+	source, err := NewRedisStreamSource(conn, "dragons", "test", "c0")
+	assert.Nil(t, err)
+	defer source.Close()
+	_, err = conn.Do("XADD", "dragons", "*", "key", "saphira", "value", saphira)
+	assert.Nil(t, err)
+	_, err = conn.Do("XADD", "dragons", "*", "key", "mushu", "value", mushu)
+	assert.Nil(t, err)
+
+	messages, err := source.Read(10, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "saphira", string(messages[0].Key))
+	assert.Equal(t, saphira, messages[0].Value)
+	assert.Equal(t, "dragons", messages[0].Topic)
+	assert.NotEqual(t, "", source.RedisStreamID(messages[1]))
+	assert.Nil(t, source.Ack(messages[:1]))
+
+	// The unacknowledged entry is read again after a restart
+	source, err = NewRedisStreamSource(conn, "dragons", "test", "c0")
+	assert.Nil(t, err)
+	messages, err = source.Read(10, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "mushu", string(messages[0].Key))
+	assert.Nil(t, source.Ack(messages))
+	messages, err = source.Read(10, 10*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messages))
+}
+
+func setupRedis() {
 	config := &Config{
 		TopicProcessorName: "test",
 		Logger:             &noopLogger{},
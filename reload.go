@@ -0,0 +1,94 @@
+package kasper
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// RuntimeConfig is the subset of Config that can be changed while RunLoop is running, see
+// TopicProcessor.Reconfigure. Zero values leave the corresponding setting unchanged.
+type RuntimeConfig struct {
+	// Maximum number of messages processed in one go
+	BatchSize int `json:"batchSize"`
+	// Maximum amount of time spent waiting for a batch to be filled
+	BatchWaitDuration Duration `json:"batchWaitDuration"`
+	// "debug", "info" or "error", requires Config.Logger to implement LevelSetter (see NewLevelLogger)
+	LogLevel string `json:"logLevel"`
+}
+
+func (rc RuntimeConfig) validate(config *Config) error {
+	var problems []string
+	if rc.BatchSize < 0 {
+		problems = append(problems, "batch size cannot be negative")
+	}
+	if rc.BatchWaitDuration.Duration < 0 {
+		problems = append(problems, "batch wait duration cannot be negative")
+	}
+	if rc.LogLevel != "" {
+		if _, ok := config.Logger.(LevelSetter); !ok {
+			problems = append(problems, "Config.Logger does not support changing the level at runtime (see NewLevelLogger)")
+		} else if _, err := parseLevel(rc.LogLevel); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return &ConfigError{problems}
+	}
+	return nil
+}
+
+// Reconfigure changes the batch size, batch wait duration and log level of a running TopicProcessor without
+// reassigning its partitions. When the batch size changes, the messages received so far are processed first,
+// and a processing error is returned as with Flush. It is safe to call Reconfigure from any goroutine.
+func (tp *TopicProcessor) Reconfigure(update RuntimeConfig) error {
+	if err := update.validate(tp.config); err != nil {
+		return err
+	}
+	_, err := tp.send(&loopRequest{command: commandReconfigure, update: update, done: make(chan error, 1)})
+	if err != nil {
+		return err
+	}
+	if update.LogLevel != "" {
+		tp.config.Logger.(LevelSetter).SetLevel(update.LogLevel)
+	}
+	tp.logger.Infof("Reconfigured: batch size %d, batch wait duration %s", tp.config.BatchSize, tp.config.BatchWaitDuration)
+	return nil
+}
+
+// ReloadSettingsOnSIGHUP re-reads the settings file at path (and the environment, see Settings.ApplyEnv) whenever
+// the process receives SIGHUP, and applies the settings that can be changed at runtime with Reconfigure. Other
+// settings are ignored until the next restart. Errors are logged and leave the current configuration in place.
+// Call the returned function to stop reloading.
+func (tp *TopicProcessor) ReloadSettingsOnSIGHUP(path string) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if err := tp.reloadSettings(path); err != nil {
+					tp.logger.Errorf("Cannot reload %s: %s", path, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+func (tp *TopicProcessor) reloadSettings(path string) error {
+	settings, err := ReadSettings(path)
+	if err != nil {
+		return err
+	}
+	if err := settings.ApplyEnv(); err != nil {
+		return err
+	}
+	return tp.Reconfigure(settings.RuntimeConfig())
+}
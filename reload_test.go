@@ -0,0 +1,61 @@
+package kasper
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicProcessor_Reconfigure(t *testing.T) {
+	processor := &countingProcessor{}
+	logger, _ := NewLevelLogger(&noopLogger{}, "info")
+	tp := newFakeTopicProcessor(&Config{Logger: logger}, processor)
+	assert.Equal(t, ErrNotRunning, tp.Reconfigure(RuntimeConfig{BatchSize: 1}))
+	done := tp.start()
+
+	tp.send(0, 0, "a")
+	assert.Nil(t, tp.Reconfigure(RuntimeConfig{BatchSize: 1, LogLevel: "debug"}))
+	assert.True(t, logger.enabled(levelDebug))
+	tp.send(0, 1, "b")
+	waitFor(t, func() bool { return processor.processed() == 2 })
+
+	assert.Nil(t, tp.Reconfigure(RuntimeConfig{BatchSize: 10, BatchWaitDuration: Duration{10 * time.Millisecond}}))
+	tp.send(0, 2, "c")
+	waitFor(t, func() bool { return processor.processed() == 3 })
+
+	tp.Close()
+	assert.Nil(t, <-done)
+	assert.Equal(t, 10, tp.config.BatchSize)
+	assert.Equal(t, 10*time.Millisecond, tp.config.BatchWaitDuration)
+}
+
+func TestTopicProcessor_Reconfigure_Invalid(t *testing.T) {
+	tp := newFakeTopicProcessor(&Config{}, &countingProcessor{})
+	err := tp.Reconfigure(RuntimeConfig{BatchSize: -1, LogLevel: "debug"})
+	assert.EqualError(t, err, "invalid configuration: batch size cannot be negative; "+
+		"Config.Logger does not support changing the level at runtime (see NewLevelLogger)")
+}
+
+func TestTopicProcessor_ReloadSettings(t *testing.T) {
+	file, err := ioutil.TempFile("", "kasper-settings")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString(`{"topicProcessorName": "fake", "brokers": ["localhost:9092"], "inputTopics": ["input"],
+		"inputPartitions": [0], "batchSize": 1, "logLevel": "error"}`)
+	file.Close()
+
+	processor := &countingProcessor{}
+	logger, _ := NewLevelLogger(&noopLogger{}, "info")
+	tp := newFakeTopicProcessor(&Config{Logger: logger}, processor)
+	done := tp.start()
+	assert.Nil(t, tp.reloadSettings(file.Name()))
+	assert.False(t, logger.enabled(levelInfo))
+	tp.send(0, 0, "a")
+	waitFor(t, func() bool { return processor.processed() == 1 })
+
+	tp.Close()
+	assert.Nil(t, <-done)
+}
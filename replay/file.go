@@ -0,0 +1,62 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+type fileMessage struct {
+	Topic     string    `json:"topic"`
+	Partition int32     `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Timestamp time.Time `json:"timestamp"`
+	Key       []byte    `json:"key"`
+	Value     []byte    `json:"value"`
+}
+
+// WriteMessages writes messages as JSON lines, with keys and values encoded in base64.
+func WriteMessages(w io.Writer, messages []*sarama.ConsumerMessage) error {
+	encoder := json.NewEncoder(w)
+	for _, message := range messages {
+		err := encoder.Encode(&fileMessage{
+			message.Topic,
+			message.Partition,
+			message.Offset,
+			message.Timestamp,
+			message.Key,
+			message.Value,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadMessages reads messages written by WriteMessages.
+func ReadMessages(r io.Reader) ([]*sarama.ConsumerMessage, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	var messages []*sarama.ConsumerMessage
+	for {
+		var message fileMessage
+		err := decoder.Decode(&message)
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, &sarama.ConsumerMessage{
+			Topic:     message.Topic,
+			Partition: message.Partition,
+			Offset:    message.Offset,
+			Timestamp: message.Timestamp,
+			Key:       message.Key,
+			Value:     message.Value,
+		})
+	}
+}
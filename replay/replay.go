@@ -0,0 +1,171 @@
+/*
+Package replay runs a slice of a Kafka topic through a MessageProcessor offline, for debugging production incidents
+locally.
+
+Messages are read from Kafka with Fetch, or from a file written by the kasper-replay command with ReadMessages,
+and are then passed to Run. Output messages are captured instead of being produced, and stores can be
+sandboxed with NewSandboxStore so that production data is read but never modified.
+*/
+package replay
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+)
+
+// Range selects the messages of a topic partition to replay.
+type Range struct {
+	Topic     string
+	Partition int
+	// First offset to replay. Ignored when StartTime is set. Defaults to the oldest available offset.
+	StartOffset int64
+	// Offset at which to stop (exclusive). Defaults to the high water mark at the time of Fetch.
+	EndOffset int64
+	// Replays messages from the first offset whose timestamp is at or after StartTime, if set
+	StartTime time.Time
+	// Stops at the first message whose timestamp is after EndTime, if set. Message timestamps require
+	// sarama.Config.Version to be at least V0_10_0_0.
+	EndTime time.Time
+}
+
+// Fetch reads the messages in the given range.
+// timeout is the maximum amount of time spent waiting for a single message.
+func Fetch(client sarama.Client, r Range, timeout time.Duration) ([]*sarama.ConsumerMessage, error) {
+	partition := int32(r.Partition)
+	start, end, err := resolveOffsets(client, r)
+	if err != nil {
+		return nil, err
+	}
+	if start >= end {
+		return nil, nil
+	}
+	if !r.EndTime.IsZero() && !client.Config().Version.IsAtLeast(sarama.V0_10_0_0) {
+		return nil, fmt.Errorf("EndTime requires message timestamps, set sarama.Config.Version to V0_10_0_0 or later")
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+	partitionConsumer, err := consumer.ConsumePartition(r.Topic, partition, start)
+	if err != nil {
+		return nil, err
+	}
+	defer partitionConsumer.Close()
+	var messages []*sarama.ConsumerMessage
+	for {
+		select {
+		case message, ok := <-partitionConsumer.Messages():
+			if !ok {
+				return messages, fmt.Errorf("consumer of %s/%d closed before offset %d", r.Topic, r.Partition, end-1)
+			}
+			if !r.EndTime.IsZero() && message.Timestamp.After(r.EndTime) {
+				return messages, nil
+			}
+			messages = append(messages, message)
+			if message.Offset >= end-1 {
+				return messages, nil
+			}
+		case err := <-partitionConsumer.Errors():
+			return messages, err
+		case <-time.After(timeout):
+			return messages, fmt.Errorf("timed out waiting for offset %d of %s/%d", end-1, r.Topic, r.Partition)
+		}
+	}
+}
+
+func resolveOffsets(client sarama.Client, r Range) (start int64, end int64, err error) {
+	partition := int32(r.Partition)
+	oldest, err := client.GetOffset(r.Topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return 0, 0, err
+	}
+	newest, err := client.GetOffset(r.Topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, 0, err
+	}
+	start = r.StartOffset
+	if !r.StartTime.IsZero() {
+		start, err = client.GetOffset(r.Topic, partition, r.StartTime.UnixNano()/int64(time.Millisecond))
+		if err != nil {
+			return 0, 0, err
+		}
+		if start < 0 {
+			start = newest
+		}
+	}
+	if start < oldest {
+		start = oldest
+	}
+	end = r.EndOffset
+	if end == 0 || end > newest {
+		end = newest
+	}
+	return start, end, nil
+}
+
+// Result contains the outcome of Run.
+type Result struct {
+	// Number of messages passed to the MessageProcessors
+	Processed int
+	// Messages sent by the MessageProcessors, in order
+	Output []*sarama.ProducerMessage
+}
+
+// Run passes messages to the MessageProcessor of their partition in batches of at most batchSize messages,
+// preserving their original offsets. Messages sent by the MessageProcessors are captured in the Result.
+// Run stops at the first error returned by a MessageProcessor, in which case the Result covers the batches
+// processed successfully.
+func Run(messages []*sarama.ConsumerMessage, messageProcessors map[int]kasper.MessageProcessor, batchSize int) (*Result, error) {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	result := &Result{}
+	for start := 0; start < len(messages); start += batchSize {
+		end := start + batchSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+		batches := make(map[int][]*sarama.ConsumerMessage)
+		var partitions []int
+		for _, message := range messages[start:end] {
+			partition := int(message.Partition)
+			if _, found := batches[partition]; !found {
+				partitions = append(partitions, partition)
+			}
+			batches[partition] = append(batches[partition], message)
+		}
+		for _, partition := range partitions {
+			messageProcessor, found := messageProcessors[partition]
+			if !found {
+				return result, fmt.Errorf("no MessageProcessor for partition %d", partition)
+			}
+			sender := &capturingSender{}
+			err := messageProcessor.Process(batches[partition], sender)
+			if err != nil {
+				return result, fmt.Errorf("processing offsets %d to %d of %s/%d: %s",
+					batches[partition][0].Offset, batches[partition][len(batches[partition])-1].Offset,
+					batches[partition][0].Topic, partition, err)
+			}
+			result.Processed += len(batches[partition])
+			result.Output = append(result.Output, sender.messages...)
+		}
+	}
+	return result, nil
+}
+
+// capturingSender captures the messages sent by the MessageProcessors instead of producing them
+type capturingSender struct {
+	messages []*sarama.ProducerMessage
+}
+
+func (s *capturingSender) Send(message *sarama.ProducerMessage) {
+	s.messages = append(s.messages, message)
+}
+
+func (s *capturingSender) Flush() error {
+	return nil
+}
@@ -0,0 +1,114 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+	"github.com/movio/kasper/kaspertest"
+	"github.com/stretchr/testify/assert"
+)
+
+type echoProcessor struct {
+	store kasper.Store
+}
+
+func (processor *echoProcessor) Process(messages []*sarama.ConsumerMessage, sender kasper.Sender) error {
+	for _, message := range messages {
+		if string(message.Value) == "poison" {
+			return errors.New("poison message")
+		}
+		err := processor.store.Put(string(message.Key), message.Value)
+		if err != nil {
+			return err
+		}
+		sender.Send(&sarama.ProducerMessage{Topic: "echo", Value: sarama.ByteEncoder(message.Value)})
+	}
+	return nil
+}
+
+func testMessages() []*sarama.ConsumerMessage {
+	timestamp := time.Date(2017, 4, 1, 10, 0, 0, 0, time.UTC)
+	return []*sarama.ConsumerMessage{
+		{Topic: "planets", Partition: 0, Offset: 41, Timestamp: timestamp, Key: []byte("earth"), Value: []byte("blue")},
+		{Topic: "planets", Partition: 1, Offset: 7, Timestamp: timestamp, Key: []byte("mars"), Value: []byte("red")},
+		{Topic: "planets", Partition: 0, Offset: 42, Timestamp: timestamp, Key: []byte("venus"), Value: []byte("poison")},
+	}
+}
+
+func TestRun(t *testing.T) {
+	production := kaspertest.NewInMemoryStore()
+	sandbox := NewSandboxStore(production)
+	processor := &echoProcessor{sandbox}
+	messages := testMessages()
+
+	result, err := Run(messages[:2], map[int]kasper.MessageProcessor{0: processor, 1: processor}, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result.Processed)
+	assert.Equal(t, 2, len(result.Output))
+	assert.Equal(t, int64(41), messages[0].Offset)
+	assert.Equal(t, map[string][]byte{"earth": []byte("blue"), "mars": []byte("red")}, sandbox.Written())
+	assert.Equal(t, 0, len(production.Data()))
+
+	result, err = Run(messages, map[int]kasper.MessageProcessor{0: processor, 1: processor}, 1)
+	assert.Equal(t, "processing offsets 42 to 42 of planets/0: poison message", err.Error())
+	assert.Equal(t, 2, result.Processed)
+
+	_, err = Run(messages, map[int]kasper.MessageProcessor{0: processor}, 10)
+	assert.NotNil(t, err)
+}
+
+func TestWriteMessages_ReadMessages(t *testing.T) {
+	var buffer bytes.Buffer
+	messages := testMessages()
+	assert.Nil(t, WriteMessages(&buffer, messages))
+	actual, err := ReadMessages(&buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, messages, actual)
+}
+
+func TestSandboxStore(t *testing.T) {
+	production := kaspertest.NewInMemoryStore()
+	production.Put("earth", []byte("blue"))
+	production.Put("mars", []byte("red"))
+	sandbox := NewSandboxStore(production)
+
+	assert.Nil(t, sandbox.Put("earth", []byte("green")))
+	assert.Nil(t, sandbox.Delete("mars"))
+	kvs, err := sandbox.GetAll([]string{"earth", "mars"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"earth": []byte("green")}, kvs)
+	value, err := sandbox.Get("mars")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	assert.Equal(t, []string{"mars"}, sandbox.Deleted())
+
+	assert.Equal(t, map[string][]byte{"earth": []byte("blue"), "mars": []byte("red")}, production.Data())
+}
+
+type offsetsClient struct {
+	sarama.Client
+	config *sarama.Config
+}
+
+func (c *offsetsClient) Config() *sarama.Config {
+	return c.config
+}
+
+func (c *offsetsClient) GetOffset(topic string, partition int32, time int64) (int64, error) {
+	if time == sarama.OffsetNewest {
+		return 100, nil
+	}
+	return 0, nil
+}
+
+func TestFetch_EndTimeRequiresTimestamps(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_9_0_1
+	client := &offsetsClient{config: config}
+	_, err := Fetch(client, Range{Topic: "planets", EndTime: time.Now()}, time.Second)
+	assert.EqualError(t, err, "EndTime requires message timestamps, set sarama.Config.Version to V0_10_0_0 or later")
+}
@@ -0,0 +1,123 @@
+package replay
+
+import (
+	"sync"
+
+	"github.com/movio/kasper"
+)
+
+// SandboxStore wraps a kasper.Store so that it can be read but never modified.
+// Writes and deletes are kept in memory and take precedence over the underlying store when reading,
+// so a replayed MessageProcessor sees its own writes while production data is left untouched.
+type SandboxStore struct {
+	store   kasper.Store
+	mutex   sync.Mutex
+	written map[string][]byte
+	deleted map[string]bool
+}
+
+// NewSandboxStore creates a new SandboxStore.
+func NewSandboxStore(store kasper.Store) *SandboxStore {
+	return &SandboxStore{
+		store:   store,
+		written: make(map[string][]byte),
+		deleted: make(map[string]bool),
+	}
+}
+
+// Get gets a value by key from the sandbox, or from the underlying store if the key was not modified.
+func (s *SandboxStore) Get(key string) ([]byte, error) {
+	s.mutex.Lock()
+	value, written := s.written[key]
+	deleted := s.deleted[key]
+	s.mutex.Unlock()
+	if written {
+		return value, nil
+	}
+	if deleted {
+		return nil, nil
+	}
+	return s.store.Get(key)
+}
+
+// GetAll gets multiple values by key from the sandbox, or from the underlying store for keys that were not modified.
+func (s *SandboxStore) GetAll(keys []string) (map[string][]byte, error) {
+	kvs := make(map[string][]byte, len(keys))
+	var missing []string
+	s.mutex.Lock()
+	for _, key := range keys {
+		if value, written := s.written[key]; written {
+			kvs[key] = value
+		} else if !s.deleted[key] {
+			missing = append(missing, key)
+		}
+	}
+	s.mutex.Unlock()
+	if len(missing) == 0 {
+		return kvs, nil
+	}
+	stored, err := s.store.GetAll(missing)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range stored {
+		kvs[key] = value
+	}
+	return kvs, nil
+}
+
+// Put inserts or updates a value by key in the sandbox.
+func (s *SandboxStore) Put(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.written[key] = value
+	delete(s.deleted, key)
+	return nil
+}
+
+// PutAll inserts or updates multiple key-value pairs in the sandbox.
+func (s *SandboxStore) PutAll(kvs map[string][]byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, value := range kvs {
+		s.written[key] = value
+		delete(s.deleted, key)
+	}
+	return nil
+}
+
+// Delete hides a key of the underlying store.
+func (s *SandboxStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.written, key)
+	s.deleted[key] = true
+	return nil
+}
+
+// Flush does nothing.
+func (s *SandboxStore) Flush() error {
+	return nil
+}
+
+// Written returns the values written to the sandbox.
+func (s *SandboxStore) Written() map[string][]byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	written := make(map[string][]byte, len(s.written))
+	for key, value := range s.written {
+		written[key] = value
+	}
+	return written
+}
+
+// Deleted returns the keys deleted in the sandbox.
+func (s *SandboxStore) Deleted() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	deleted := make([]string, 0, len(s.deleted))
+	for key := range s.deleted {
+		deleted = append(deleted, key)
+	}
+	return deleted
+}
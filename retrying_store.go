@@ -0,0 +1,158 @@
+package kasper
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// RetryPolicy configures a RetryingStore.
+type RetryPolicy struct {
+	// Maximum number of attempts of each operation, including the first one
+	MaxAttempts int
+	// Time to wait before the first retry, doubled after each retry
+	InitialBackoff time.Duration
+	// Maximum time to wait between retries
+	MaxBackoff time.Duration
+	// Fraction of operations that can be retried (e.g. 0.1), so that retries do not multiply the load on a store
+	// that is failing for every operation. Each operation adds Budget tokens to a budget of up to 10 tokens and
+	// each retry spends one. 0 means unlimited.
+	Budget float64
+	// Retryable returns true if an operation that failed with err can be retried. Defaults to IsRetryable.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy makes up to 3 attempts, waiting 100ms then 200ms, with a retry budget of 10%.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	Budget:         0.1,
+}
+
+const maxRetryTokens = 10
+
+// IsRetryable returns false for errors that retrying cannot fix: errors returned by the Redis server (e.g.
+// WRONGTYPE) and Elasticsearch 4xx errors other than 408 and 429. It returns true for other errors, such as
+// network errors.
+func IsRetryable(err error) bool {
+	switch err := err.(type) {
+	case redis.Error:
+		return false
+	case *elastic.Error:
+		return err.Status < 400 || err.Status >= 500 || err.Status == 408 || err.Status == 429
+	}
+	return true
+}
+
+// RetryingStore wraps a Store and retries the operations that fail with a retryable error, waiting with
+// exponential backoff between attempts. The number of retries is counted in the store_retry_count metric.
+// Retries are only safe for idempotent operations, which all Store operations are.
+// RetryingStore is not safe for concurrent use.
+type RetryingStore struct {
+	store        Store
+	name         string
+	policy       RetryPolicy
+	logger       Logger
+	retryCounter Counter
+	tokens       float64
+	sleep        func(time.Duration)
+}
+
+// NewRetryingStore creates RetryingStore instances. The name is used as the value of the "store" label.
+func NewRetryingStore(config *Config, store Store, name string, policy RetryPolicy) *RetryingStore {
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+	return &RetryingStore{
+		store,
+		name,
+		policy,
+		WithFields(config.logger(), Field{"store", name}),
+		config.storeMetricsProvider().NewCounter("store_retry_count", "Number of retried store operations", "store"),
+		maxRetryTokens,
+		time.Sleep,
+	}
+}
+
+func (s *RetryingStore) retry(operation string, fn func() error) error {
+	if s.policy.Budget > 0 {
+		s.tokens += s.policy.Budget
+		if s.tokens > maxRetryTokens {
+			s.tokens = maxRetryTokens
+		}
+	}
+	backoff := s.policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= s.policy.MaxAttempts || !s.policy.Retryable(err) {
+			return err
+		}
+		if s.policy.Budget > 0 {
+			if s.tokens < 1 {
+				s.logger.Debugf("Not retrying %s, retry budget exhausted: %s", operation, err)
+				return err
+			}
+			s.tokens--
+		}
+		s.logger.Infof("%s failed, retrying in %s: %s", operation, backoff, err)
+		s.retryCounter.Inc(s.name)
+		s.sleep(backoff)
+		backoff *= 2
+		if s.policy.MaxBackoff > 0 && backoff > s.policy.MaxBackoff {
+			backoff = s.policy.MaxBackoff
+		}
+	}
+}
+
+// Get gets a value by key from the underlying store.
+func (s *RetryingStore) Get(key string) (value []byte, err error) {
+	err = s.retry("Get", func() error {
+		value, err = s.store.Get(key)
+		return err
+	})
+	return value, err
+}
+
+// GetAll gets multiple values by key from the underlying store.
+func (s *RetryingStore) GetAll(keys []string) (kvs map[string][]byte, err error) {
+	err = s.retry("GetAll", func() error {
+		kvs, err = s.store.GetAll(keys)
+		return err
+	})
+	return kvs, err
+}
+
+// Put inserts or updates a value by key in the underlying store.
+func (s *RetryingStore) Put(key string, value []byte) error {
+	return s.retry("Put", func() error {
+		return s.store.Put(key, value)
+	})
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store.
+func (s *RetryingStore) PutAll(kvs map[string][]byte) error {
+	return s.retry("PutAll", func() error {
+		return s.store.PutAll(kvs)
+	})
+}
+
+// Delete deletes a key from the underlying store.
+func (s *RetryingStore) Delete(key string) error {
+	return s.retry("Delete", func() error {
+		return s.store.Delete(key)
+	})
+}
+
+// Flush flushes the underlying store.
+func (s *RetryingStore) Flush() error {
+	return s.retry("Flush", func() error {
+		return s.store.Flush()
+	})
+}
+
+// GetStore returns the underlying Store
+func (s *RetryingStore) GetStore() Store {
+	return s.store
+}
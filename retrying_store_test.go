@@ -0,0 +1,91 @@
+package kasper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// flakyStore fails the first calls to Get, Put and Flush.
+type flakyStore struct {
+	Store
+	failures int
+	err      error
+	calls    int
+}
+
+func (s *flakyStore) fail() error {
+	s.calls++
+	if s.calls <= s.failures {
+		return s.err
+	}
+	return nil
+}
+
+func (s *flakyStore) Get(key string) ([]byte, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.Store.Get(key)
+}
+
+func (s *flakyStore) Put(key string, value []byte) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Put(key, value)
+}
+
+func (s *flakyStore) Flush() error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Store.Flush()
+}
+
+func TestRetryingStore(t *testing.T) {
+	provider := newRecordingMetricsProvider()
+	config := &Config{TopicProcessorName: "hari-seldon", ContainerID: "container-1", MetricsProvider: provider}
+	inner := &flakyStore{NewMap(10), 2, errors.New("connection reset"), 0}
+	store := NewRetryingStore(config, inner, "planets", RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	var sleeps []time.Duration
+	store.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	assert.Nil(t, store.Put("mars", mars))
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, sleeps)
+	assert.Equal(t, 2.0, provider.values["store_retry_count{planets,,container-1,hari-seldon}"])
+
+	inner.calls, inner.failures = 0, 3
+	_, err := store.Get("mars")
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, 3, inner.calls)
+
+	inner.calls, inner.err = 0, redis.Error("WRONGTYPE")
+	_, err = store.Get("mars")
+	assert.EqualError(t, err, "WRONGTYPE")
+	assert.Equal(t, 1, inner.calls)
+}
+
+func TestRetryingStore_Budget(t *testing.T) {
+	inner := &flakyStore{NewMap(10), 100, errors.New("connection reset"), 0}
+	store := NewRetryingStore(&Config{}, inner, "planets", RetryPolicy{MaxAttempts: 2, Budget: 0.5})
+	store.sleep = func(time.Duration) {}
+	for i := 0; i < 20; i++ {
+		store.Get("mars")
+	}
+	// Each retry spends one token of the initial 10, and each operation adds 0.5 token
+	retries := inner.calls - 20
+	assert.Equal(t, 19, retries)
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(errors.New("connection reset")))
+	assert.False(t, IsRetryable(redis.Error("WRONGTYPE")))
+	assert.False(t, IsRetryable(&elastic.Error{Status: 400}))
+	assert.True(t, IsRetryable(&elastic.Error{Status: 429}))
+	assert.True(t, IsRetryable(&elastic.Error{Status: 503}))
+}
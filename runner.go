@@ -0,0 +1,182 @@
+package kasper
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// Runner hosts several TopicProcessors in a single process. They share a sarama Client and a MetricsProvider,
+// are shut down together, and report their health through a single endpoint:
+//
+//	runner := kasper.NewRunner(client, kasper.NewPrometheus("my-app"))
+//	runner.Add(tweetsConfig, tweetsProcessors)
+//	runner.Add(followersConfig, followersProcessors)
+//	go http.ListenAndServe(":8080", runner)
+//	err := runner.Run()
+//
+// The Runner does not close the shared Client.
+type Runner struct {
+	client          sarama.Client
+	metricsProvider MetricsProvider
+	mutex           sync.Mutex
+	topicProcessors map[string]*TopicProcessor
+	health          map[string]*Health
+	closeOnce       sync.Once
+	profiling       bool
+}
+
+// NewRunner creates a Runner whose TopicProcessors use client and metricsProvider unless their Config sets
+// another Client or MetricsProvider. metricsProvider can be nil.
+func NewRunner(client sarama.Client, metricsProvider MetricsProvider) *Runner {
+	return &Runner{
+		client,
+		metricsProvider,
+		sync.Mutex{},
+		make(map[string]*TopicProcessor),
+		make(map[string]*Health),
+		sync.Once{},
+		false,
+	}
+}
+
+// Add creates a TopicProcessor hosted by the Runner (see OpenTopicProcessor). TopicProcessor names must be unique
+// within a Runner. Add must be called before Run.
+func (r *Runner) Add(config *Config, messageProcessors map[int]MessageProcessor) (*TopicProcessor, error) {
+	if config.Client == nil {
+		config.Client = r.client
+	}
+	if config.MetricsProvider == nil {
+		config.MetricsProvider = r.metricsProvider
+	}
+	if r.TopicProcessor(config.TopicProcessorName) != nil {
+		return nil, fmt.Errorf("topic processor %s is already running in this runner", config.TopicProcessorName)
+	}
+	topicProcessor, err := OpenTopicProcessor(config, messageProcessors)
+	if err != nil {
+		return nil, err
+	}
+	r.add(topicProcessor)
+	return topicProcessor, nil
+}
+
+func (r *Runner) add(topicProcessor *TopicProcessor) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	name := topicProcessor.config.TopicProcessorName
+	r.topicProcessors[name] = topicProcessor
+	r.health[name] = NewHealth(topicProcessor)
+}
+
+// TopicProcessor returns the TopicProcessor with the given name, or nil.
+func (r *Runner) TopicProcessor(name string) *TopicProcessor {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.topicProcessors[name]
+}
+
+// Health returns the Health of the TopicProcessor with the given name, e.g. to add store checks, or nil.
+func (r *Runner) Health(name string) *Health {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.health[name]
+}
+
+// Run runs the RunLoop of all TopicProcessors in separate goroutines. When one of them returns, for instance
+// because a MessageProcessor failed, the others are closed. Run returns when all of them have returned, with
+// the first error encountered.
+func (r *Runner) Run() error {
+	topicProcessors := r.all()
+	errs := make(chan error, len(topicProcessors))
+	for _, topicProcessor := range topicProcessors {
+		go func(tp *TopicProcessor) {
+			err := tp.RunLoop()
+			if err != nil {
+				tp.logger.Errorf("Run loop failed: %s", err)
+			}
+			errs <- err
+		}(topicProcessor)
+	}
+	var firstErr error
+	for i := range topicProcessors {
+		err := <-errs
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if i == 0 {
+			r.Close()
+		}
+	}
+	return firstErr
+}
+
+// Close closes all TopicProcessors, which makes Run return.
+func (r *Runner) Close() {
+	r.closeOnce.Do(func() {
+		for _, topicProcessor := range r.all() {
+			topicProcessor.Close()
+		}
+	})
+}
+
+func (r *Runner) all() []*TopicProcessor {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	topicProcessors := make([]*TopicProcessor, 0, len(r.topicProcessors))
+	for _, topicProcessor := range r.topicProcessors {
+		topicProcessors = append(topicProcessors, topicProcessor)
+	}
+	return topicProcessors
+}
+
+// Live runs the liveness checks of all TopicProcessors. Checks are named "<topic processor>/<check>".
+func (r *Runner) Live() HealthReport {
+	return r.report((*Health).Live)
+}
+
+// Ready runs the liveness and readiness checks of all TopicProcessors.
+func (r *Runner) Ready() HealthReport {
+	return r.report((*Health).Ready)
+}
+
+func (r *Runner) report(run func(*Health) HealthReport) HealthReport {
+	r.mutex.Lock()
+	health := make(map[string]*Health, len(r.health))
+	names := make([]string, 0, len(r.health))
+	for name, h := range r.health {
+		health[name] = h
+		names = append(names, name)
+	}
+	r.mutex.Unlock()
+	sort.Strings(names)
+
+	report := HealthReport{true, make(map[string]string)}
+	for _, name := range names {
+		processorReport := run(health[name])
+		report.OK = report.OK && processorReport.OK
+		for check, result := range processorReport.Checks {
+			report.Checks[name+"/"+check] = result
+		}
+	}
+	return report
+}
+
+// EnableProfiling makes ServeHTTP serve the runtime profiles under /debug/pprof/, see ProfilingHandler.
+// It must be called before the Runner serves HTTP requests.
+func (r *Runner) EnableProfiling() {
+	r.profiling = true
+}
+
+// ServeHTTP serves /healthz and /readyz for all TopicProcessors, see Health, and /debug/pprof/ if profiling
+// is enabled.
+func (r *Runner) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.profiling && strings.HasPrefix(req.URL.Path, profilingPath) {
+		serveProfile(w, req)
+		return
+	}
+	serveHealth(w, req, r.Live, r.Ready)
+}
@@ -0,0 +1,39 @@
+package kasper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner(t *testing.T) {
+	runner := NewRunner(&metadataClient{}, nil)
+	tweets := newFakeTopicProcessor(&Config{TopicProcessorName: "tweets", Client: &metadataClient{}}, &countingProcessor{})
+	followers := newFakeTopicProcessor(&Config{TopicProcessorName: "followers", Client: &metadataClient{}},
+		&countingProcessor{err: errors.New("boom")})
+	runner.add(tweets.TopicProcessor)
+	runner.add(followers.TopicProcessor)
+	assert.Equal(t, tweets.TopicProcessor, runner.TopicProcessor("tweets"))
+	assert.NotNil(t, runner.Health("followers"))
+
+	_, err := runner.Add(&Config{TopicProcessorName: "tweets"}, nil)
+	assert.EqualError(t, err, "topic processor tweets is already running in this runner")
+
+	assert.Equal(t, HealthReport{true, map[string]string{"followers/kafka": "ok", "tweets/kafka": "ok"}}, runner.Live())
+	assert.False(t, runner.Ready().OK)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- runner.Run()
+	}()
+	waitFor(t, func() bool {
+		return tweets.IsRunning() && followers.IsRunning()
+	})
+	followers.send(0, 0, "a")
+	waitFor(t, func() bool {
+		return followers.Flush() != nil
+	})
+	assert.EqualError(t, <-done, "boom")
+	assert.False(t, tweets.IsRunning())
+}
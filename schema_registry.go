@@ -0,0 +1,140 @@
+package kasper
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RegistrySchema is a version of a subject registered in a Schema Registry.
+type RegistrySchema struct {
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+	ID      int    `json:"id"`
+	Schema  string `json:"schema"`
+	// "AVRO", "PROTOBUF" or "JSON"
+	SchemaType string `json:"schemaType"`
+}
+
+// SchemaCodec builds the Serde of the values of a schema, which decodes into the values returned by newValue.
+type SchemaCodec func(schema *RegistrySchema, newValue func() interface{}) (Serde, error)
+
+// SchemaRegistry reads the schemas of topics from a Confluent Schema Registry to build Config.Serdes, so that the
+// serdes of a TopicProcessor follow the registry instead of being maintained by hand:
+//
+//	registry := kasper.NewSchemaRegistry("http://schema-registry:8081")
+//	serdes, err := registry.Serdes(map[string]func() interface{}{
+//		"tweets": func() interface{} { return &Tweet{} },
+//		"reach":  func() interface{} { return &Reach{} },
+//	})
+//
+// JSON schemas are supported by default: values are encoded with encoding/json, but are not validated against the
+// schema. Kasper does not depend on an Avro or Protobuf library, so Avro and Protobuf schemas require a SchemaCodec
+// in Codecs.
+type SchemaRegistry struct {
+	// Codecs by schema type, in addition to "JSON"
+	Codecs map[string]SchemaCodec
+	// Used for all requests made to the registry
+	HTTPClient *http.Client
+
+	url string
+}
+
+// NewSchemaRegistry creates a SchemaRegistry for the registry at registryURL.
+func NewSchemaRegistry(registryURL string) *SchemaRegistry {
+	return &SchemaRegistry{
+		Codecs:     make(map[string]SchemaCodec),
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		url:        strings.TrimSuffix(registryURL, "/"),
+	}
+}
+
+// LatestSchema returns the latest version of a subject.
+func (r *SchemaRegistry) LatestSchema(subject string) (*RegistrySchema, error) {
+	response, err := r.HTTPClient.Get(r.url + (&url.URL{Path: "/subjects/" + subject + "/versions/latest"}).String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, fmt.Errorf("schema registry returned status %d for subject %s: %s", response.StatusCode, subject, strings.TrimSpace(string(message)))
+	}
+	var schema RegistrySchema
+	if err := json.NewDecoder(response.Body).Decode(&schema); err != nil {
+		return nil, err
+	}
+	if schema.SchemaType == "" {
+		// The registry omits the type of Avro schemas
+		schema.SchemaType = "AVRO"
+	}
+	return &schema, nil
+}
+
+// Serdes returns the serdes of the values of topics, built from the latest version of their "<topic>-value"
+// subjects. newValues returns the value each topic decodes into, by topic. The serdes write the Confluent wire
+// format, with the ID of the latest version, and read values written with any version.
+func (r *SchemaRegistry) Serdes(newValues map[string]func() interface{}) (map[string]Serde, error) {
+	serdes := make(map[string]Serde, len(newValues))
+	for topic, newValue := range newValues {
+		schema, err := r.LatestSchema(topic + "-value")
+		if err != nil {
+			return nil, err
+		}
+		codec, found := r.Codecs[schema.SchemaType]
+		if !found && schema.SchemaType == "JSON" {
+			codec = jsonSchemaCodec
+		} else if !found {
+			return nil, fmt.Errorf("no codec for the %s schema of subject %s", schema.SchemaType, schema.Subject)
+		}
+		serde, err := codec(schema, newValue)
+		if err != nil {
+			return nil, err
+		}
+		serdes[topic] = NewSchemaRegistrySerde(schema.ID, serde)
+	}
+	return serdes, nil
+}
+
+func jsonSchemaCodec(schema *RegistrySchema, newValue func() interface{}) (Serde, error) {
+	return NewJSONSerde(newValue), nil
+}
+
+// SchemaRegistrySerde adds the header of the Confluent wire format, a zero byte followed by the schema ID, to the
+// values encoded by another Serde.
+type SchemaRegistrySerde struct {
+	id    int
+	serde Serde
+}
+
+// NewSchemaRegistrySerde creates a SchemaRegistrySerde writing the schema ID id.
+func NewSchemaRegistrySerde(id int, serde Serde) *SchemaRegistrySerde {
+	return &SchemaRegistrySerde{id, serde}
+}
+
+// Serialize encodes a value with the serde and prepends the header.
+func (serde *SchemaRegistrySerde) Serialize(value interface{}) ([]byte, error) {
+	data, err := serde.serde.Serialize(value)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([]byte, 5+len(data))
+	binary.BigEndian.PutUint32(encoded[1:5], uint32(serde.id))
+	copy(encoded[5:], data)
+	return encoded, nil
+}
+
+// Deserialize checks the header and decodes the rest of data with the serde.
+func (serde *SchemaRegistrySerde) Deserialize(data []byte) (interface{}, error) {
+	if len(data) < 5 || data[0] != 0 {
+		return nil, errors.New("value does not start with a schema registry header")
+	}
+	return serde.serde.Deserialize(data[5:])
+}
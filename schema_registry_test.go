@@ -0,0 +1,63 @@
+package kasper
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSchemaRegistry(schemas map[string]RegistrySchema) (*SchemaRegistry, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		schema, found := schemas[r.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40401,"message":"Subject not found."}`))
+			return
+		}
+		json.NewEncoder(w).Encode(schema)
+	}))
+	return NewSchemaRegistry(server.URL + "/"), server
+}
+
+func TestSchemaRegistry_Serdes(t *testing.T) {
+	registry, server := newTestSchemaRegistry(map[string]RegistrySchema{
+		"/subjects/planets-value/versions/latest": {"planets-value", 3, 258, `{"type":"object"}`, "JSON"},
+		"/subjects/moons-value/versions/latest":   {"moons-value", 1, 7, `{"type":"record"}`, ""},
+	})
+	defer server.Close()
+	newPlanet := func() interface{} { return &serdeTestPlanet{} }
+
+	_, err := registry.Serdes(map[string]func() interface{}{"moons": newPlanet})
+	assert.EqualError(t, err, "no codec for the AVRO schema of subject moons-value")
+	_, err = registry.Serdes(map[string]func() interface{}{"stars": newPlanet})
+	assert.EqualError(t, err, `schema registry returned status 404 for subject stars-value: {"error_code":40401,"message":"Subject not found."}`)
+
+	var codecSchema *RegistrySchema
+	registry.Codecs["AVRO"] = func(schema *RegistrySchema, newValue func() interface{}) (Serde, error) {
+		codecSchema = schema
+		return NewJSONSerde(newValue), nil
+	}
+	serdes, err := registry.Serdes(map[string]func() interface{}{"planets": newPlanet, "moons": newPlanet})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(serdes))
+	assert.Equal(t, &RegistrySchema{"moons-value", 1, 7, `{"type":"record"}`, "AVRO"}, codecSchema)
+
+	data, err := serdes["planets"].Serialize(&serdeTestPlanet{"Mars", 2})
+	assert.Nil(t, err)
+	assert.Equal(t, append([]byte{0, 0, 0, 1, 2}, `{"name":"Mars","moons":2}`...), data)
+	value, err := serdes["planets"].Deserialize(data)
+	assert.Nil(t, err)
+	assert.Equal(t, &serdeTestPlanet{"Mars", 2}, value)
+	_, err = serdes["planets"].Deserialize([]byte(`{"name":"Mars"}`))
+	assert.EqualError(t, err, "value does not start with a schema registry header")
+
+	registry.Codecs["AVRO"] = func(schema *RegistrySchema, newValue func() interface{}) (Serde, error) {
+		return nil, errors.New("invalid schema")
+	}
+	_, err = registry.Serdes(map[string]func() interface{}{"moons": newPlanet})
+	assert.EqualError(t, err, "invalid schema")
+}
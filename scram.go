@@ -0,0 +1,148 @@
+package kasper
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// scramClient implements the client side of SCRAM authentication (RFC 5802) for sarama, without channel
+// binding. User names and passwords are not normalized with SASLprep, so they should be ASCII.
+type scramClient struct {
+	newHash func() hash.Hash
+	nonce   func() (string, error)
+
+	step            int
+	gs2Header       string
+	clientFirstBare string
+	clientNonce     string
+	password        string
+	serverSignature []byte
+}
+
+func newSCRAMClientGenerator(newHash func() hash.Hash) func() sarama.SCRAMClient {
+	return func() sarama.SCRAMClient {
+		return &scramClient{newHash: newHash, nonce: randomSCRAMNonce}
+	}
+}
+
+var (
+	scramSHA256ClientGenerator = newSCRAMClientGenerator(sha256.New)
+	scramSHA512ClientGenerator = newSCRAMClientGenerator(sha512.New)
+)
+
+func randomSCRAMNonce() (string, error) {
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(nonce), nil
+}
+
+// scramName escapes the characters that cannot appear in a SCRAM attribute value.
+func scramName(name string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(name)
+}
+
+// Begin prepares the client-first message.
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	nonce, err := c.nonce()
+	if err != nil {
+		return err
+	}
+	c.step = 0
+	c.gs2Header = "n,,"
+	if authzID != "" {
+		c.gs2Header = "n,a=" + scramName(authzID) + ","
+	}
+	c.clientNonce = nonce
+	c.clientFirstBare = "n=" + scramName(userName) + ",r=" + nonce
+	c.password = password
+	return nil
+}
+
+// Step returns the client-first message, then the client-final message, then checks the server signature.
+func (c *scramClient) Step(challenge string) (string, error) {
+	c.step++
+	switch c.step {
+	case 1:
+		return c.gs2Header + c.clientFirstBare, nil
+	case 2:
+		return c.clientFinal(challenge)
+	case 3:
+		return "", c.verifyServerFinal(challenge)
+	}
+	return "", errors.New("scram: conversation is over")
+}
+
+// Done returns true once the server signature has been checked.
+func (c *scramClient) Done() bool {
+	return c.step >= 3
+}
+
+func (c *scramClient) hmac(key []byte, message string) []byte {
+	mac := hmac.New(c.newHash, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+func (c *scramClient) clientFinal(serverFirst string) (string, error) {
+	attributes := parseSCRAMAttributes(serverFirst)
+	nonce := attributes["r"]
+	if !strings.HasPrefix(nonce, c.clientNonce) || len(nonce) == len(c.clientNonce) {
+		return "", errors.New("scram: invalid server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attributes["s"])
+	if err != nil || len(salt) == 0 {
+		return "", errors.New("scram: invalid salt")
+	}
+	iterations, err := strconv.Atoi(attributes["i"])
+	if err != nil || iterations <= 0 {
+		return "", errors.New("scram: invalid iteration count")
+	}
+	saltedPassword := pbkdf2.Key([]byte(c.password), salt, iterations, c.newHash().Size(), c.newHash)
+	clientKey := c.hmac(saltedPassword, "Client Key")
+	storedKey := c.newHash()
+	storedKey.Write(clientKey)
+	clientFinalWithoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(c.gs2Header)) + ",r=" + nonce
+	authMessage := c.clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof
+	clientSignature := c.hmac(storedKey.Sum(nil), authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	c.serverSignature = c.hmac(c.hmac(saltedPassword, "Server Key"), authMessage)
+	return clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+func (c *scramClient) verifyServerFinal(serverFinal string) error {
+	attributes := parseSCRAMAttributes(serverFinal)
+	if message, found := attributes["e"]; found {
+		return fmt.Errorf("scram: authentication failed: %s", message)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attributes["v"])
+	if err != nil || !hmac.Equal(signature, c.serverSignature) {
+		return errors.New("scram: invalid server signature")
+	}
+	return nil
+}
+
+func parseSCRAMAttributes(message string) map[string]string {
+	attributes := make(map[string]string)
+	for _, attribute := range strings.Split(message, ",") {
+		if len(attribute) > 2 && attribute[1] == '=' {
+			attributes[attribute[:1]] = attribute[2:]
+		}
+	}
+	return attributes
+}
@@ -0,0 +1,49 @@
+package kasper
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test vector from RFC 7677, section 3.
+func TestSCRAMClient_SHA256(t *testing.T) {
+	client := &scramClient{newHash: sha256.New, nonce: func() (string, error) { return "rOprNGfwEbeRWgbNEkqO", nil }}
+	assert.Nil(t, client.Begin("user", "pencil", ""))
+
+	message, err := client.Step("")
+	assert.Nil(t, err)
+	assert.Equal(t, "n,,n=user,r=rOprNGfwEbeRWgbNEkqO", message)
+	assert.False(t, client.Done())
+
+	message, err = client.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.Nil(t, err)
+	assert.Equal(t, "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,"+
+		"p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", message)
+
+	message, err = client.Step("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")
+	assert.Nil(t, err)
+	assert.Equal(t, "", message)
+	assert.True(t, client.Done())
+}
+
+func TestSCRAMClient_Errors(t *testing.T) {
+	client := &scramClient{newHash: sha256.New, nonce: func() (string, error) { return "rOprNGfwEbeRWgbNEkqO", nil }}
+	assert.Nil(t, client.Begin("user", "pencil", ""))
+	client.Step("")
+	_, err := client.Step("r=forged,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.EqualError(t, err, "scram: invalid server nonce")
+
+	assert.Nil(t, client.Begin("user", "pencil", ""))
+	client.Step("")
+	client.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	_, err = client.Step("v=AAAA")
+	assert.EqualError(t, err, "scram: invalid server signature")
+
+	assert.Nil(t, client.Begin("user", "pencil", ""))
+	client.Step("")
+	client.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	_, err = client.Step("e=invalid-proof")
+	assert.EqualError(t, err, "scram: authentication failed: invalid-proof")
+}
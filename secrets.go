@@ -0,0 +1,122 @@
+package kasper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SecretsProvider resolves the secrets referenced by Settings, such as SASLSettings.PasswordSecret and
+// StoreSettings.PasswordSecret. Implement it to read secrets from Vault or AWS Secrets Manager.
+// Secrets are resolved every time a connection is opened, so rotated secrets are picked up without restarting
+// by the connections opened afterwards (see WatchSecret).
+type SecretsProvider interface {
+	Secret(name string) (string, error)
+}
+
+// SecretsSettings selects the SecretsProvider used by Settings.
+type SecretsSettings struct {
+	// "env" (the default) or "file"
+	Type string `json:"type"`
+	// Directory containing one file per secret, e.g. the mount point of Docker or Kubernetes secrets
+	Dir string `json:"dir"`
+}
+
+func (s *SecretsSettings) validate() []string {
+	switch s.Type {
+	case "", "env":
+	case "file":
+		if s.Dir == "" {
+			return []string{"secrets: dir is required"}
+		}
+	default:
+		return []string{fmt.Sprintf("secrets: unknown type %q (expected env or file)", s.Type)}
+	}
+	return nil
+}
+
+// EnvSecrets reads secrets from environment variables with the same name.
+type EnvSecrets struct{}
+
+// Secret returns the value of the environment variable name, which must be set.
+func (EnvSecrets) Secret(name string) (string, error) {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return "", fmt.Errorf("secret %s: environment variable is not set", name)
+	}
+	return value, nil
+}
+
+// FileSecrets reads secrets from the files of a directory, one file per secret.
+type FileSecrets struct {
+	Dir string
+}
+
+// Secret returns the content of the file name in Dir, without trailing newlines. The file is read on every call.
+func (s FileSecrets) Secret(name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) || name == ".." {
+		return "", fmt.Errorf("secret %s: invalid name", name)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(s.Dir, name))
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %s", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// secretsProvider returns Settings.SecretsProvider, or the provider configured by Settings.Secrets.
+func (s *Settings) secretsProvider() SecretsProvider {
+	if s.SecretsProvider != nil {
+		return s.SecretsProvider
+	}
+	if s.Secrets != nil && s.Secrets.Type == "file" {
+		return FileSecrets{s.Secrets.Dir}
+	}
+	return EnvSecrets{}
+}
+
+// password returns the literal password, or the secret it references.
+func (s *Settings) password(password, secret string) (string, error) {
+	if secret == "" {
+		return password, nil
+	}
+	return s.secretsProvider().Secret(secret)
+}
+
+// WatchSecret reads a secret every interval and calls onChange from its own goroutine when its value changes,
+// e.g. to reconnect a store with a rotated password. Errors are logged. Call the returned function to stop.
+func WatchSecret(config *Config, provider SecretsProvider, name string, interval time.Duration, onChange func(string)) (stop func()) {
+	logger := config.logger()
+	current, err := provider.Secret(name)
+	if err != nil {
+		logger.Errorf("Cannot read secret %s: %s", name, err)
+	}
+	done := make(chan struct{})
+	ticker := config.clock().NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.Chan():
+				value, err := provider.Secret(name)
+				if err != nil {
+					logger.Errorf("Cannot read secret %s: %s", name, err)
+					continue
+				}
+				if value != current {
+					logger.Infof("Secret %s has changed", name)
+					current = value
+					onChange(value)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,85 @@
+package kasper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mapSecrets map[string]string
+
+func (s mapSecrets) Secret(name string) (string, error) {
+	return s[name], nil
+}
+
+func TestFileSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kasper-secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "kafka-password"), []byte("42\n"), 0600)
+
+	secrets := FileSecrets{dir}
+	password, err := secrets.Secret("kafka-password")
+	assert.Nil(t, err)
+	assert.Equal(t, "42", password)
+	_, err = secrets.Secret("../kafka-password")
+	assert.EqualError(t, err, "secret ../kafka-password: invalid name")
+	_, err = secrets.Secret("redis-password")
+	assert.NotNil(t, err)
+}
+
+func TestEnvSecrets(t *testing.T) {
+	os.Setenv("KASPER_TEST_SECRET", "42")
+	defer os.Unsetenv("KASPER_TEST_SECRET")
+	password, err := EnvSecrets{}.Secret("KASPER_TEST_SECRET")
+	assert.Nil(t, err)
+	assert.Equal(t, "42", password)
+	_, err = EnvSecrets{}.Secret("KASPER_TEST_MISSING_SECRET")
+	assert.EqualError(t, err, "secret KASPER_TEST_MISSING_SECRET: environment variable is not set")
+}
+
+func TestSettings_SaramaConfig_PasswordSecret(t *testing.T) {
+	settings := &Settings{
+		SASL:            &SASLSettings{User: "arthur", PasswordSecret: "kafka-password"},
+		SecretsProvider: mapSecrets{"kafka-password": "42"},
+	}
+	saramaConfig, err := settings.SaramaConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "42", saramaConfig.Net.SASL.Password)
+}
+
+func TestSettings_Validate_Secrets(t *testing.T) {
+	settings := &Settings{
+		TopicProcessorName: "arthur-dent",
+		Brokers:            []string{"localhost:9092"},
+		InputTopics:        []string{"tweets"},
+		InputPartitions:    []int{0},
+		SASL:               &SASLSettings{User: "arthur", Password: "42", PasswordSecret: "kafka-password"},
+		Secrets:            &SecretsSettings{Type: "file"},
+	}
+	assert.Equal(t, &ConfigError{[]string{
+		"sasl: password and passwordSecret cannot be set together",
+		"secrets: dir is required",
+	}}, settings.Validate())
+}
+
+func TestWatchSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kasper-secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "redis-password")
+	ioutil.WriteFile(path, []byte("42"), 0600)
+
+	changes := make(chan string, 1)
+	stop := WatchSecret(&Config{Logger: &noopLogger{}}, FileSecrets{dir}, "redis-password", time.Millisecond, func(value string) {
+		changes <- value
+	})
+	defer stop()
+	ioutil.WriteFile(path+".new", []byte("43"), 0600)
+	os.Rename(path+".new", path)
+	assert.Equal(t, "43", <-changes)
+}
@@ -1,6 +1,8 @@
 package kasper
 
 import (
+	"bytes"
+
 	"github.com/Shopify/sarama"
 )
 
@@ -22,30 +24,73 @@ type Sender interface {
 type sender struct {
 	pp               *partitionProcessor
 	producerMessages []*sarama.ProducerMessage
+	buffers          []*bytes.Buffer
+	span             Span
+	// Added to every message sent, see Config.ProvenanceHeaders
+	headers []sarama.RecordHeader
 }
 
 func newSender(pp *partitionProcessor) *sender {
 	return &sender{
 		pp,
 		[]*sarama.ProducerMessage{},
+		nil,
+		noopSpan{},
+		nil,
 	}
 }
 
 func (sender *sender) Send(msg *sarama.ProducerMessage) {
+	if len(sender.headers) > 0 {
+		msg.Headers = append(msg.Headers, sender.headers...)
+	}
+	if propagator, ok := sender.pp.topicProcessor.config.tracer().(HeaderPropagator); ok {
+		msg.Headers = propagator.Inject(sender.span, msg.Headers)
+	}
 	sender.producerMessages = append(sender.producerMessages, msg)
 }
 
+// SendBuffer sets the value of msg to the content of buffer and appends msg like Send, see BufferSender.
+func (sender *sender) SendBuffer(msg *sarama.ProducerMessage, buffer *bytes.Buffer) {
+	msg.Value = sarama.ByteEncoder(buffer.Bytes())
+	sender.buffers = append(sender.buffers, buffer)
+	sender.Send(msg)
+}
+
+// releaseBuffers returns the buffers of the messages sent so far to Config.BufferPool, once.
+func (sender *sender) releaseBuffers() {
+	pool := sender.pp.topicProcessor.config.BufferPool
+	if pool != nil {
+		for _, buffer := range sender.buffers {
+			pool.Put(buffer)
+		}
+	}
+	sender.buffers = nil
+}
+
 func (sender *sender) Flush() error {
 	if len(sender.producerMessages) == 0 {
 		return nil
 	}
 
-	err := sender.pp.topicProcessor.producer.SendMessages(sender.producerMessages)
+	err := sender.pp.topicProcessor.produce(sender.producerMessages, sender.span)
 	if err != nil {
+		// The messages are kept with their buffers, which are released once they are produced or the batch fails
 		sender.pp.logger.Errorf("Message Sender returned error: %s", err)
 		return err
 	}
 	sender.producerMessages = []*sarama.ProducerMessage{}
+	sender.releaseBuffers()
 
 	return nil
 }
+
+// Span returns the kasper.process span of the batch, see TracingSender.
+func (sender *sender) Span() Span {
+	return sender.span
+}
+
+// MessageLogger returns the partition processor's logger bound to the message. See MessageLogger.
+func (sender *sender) MessageLogger(message *sarama.ConsumerMessage) Logger {
+	return newMessageLogger(sender.pp.logger, sender.pp.topicProcessor.config.messageTraceID(), message)
+}
@@ -1,6 +1,8 @@
 package kasper
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -14,16 +16,32 @@ type fixture struct {
 }
 
 func newFixture() *fixture {
+	return newFixtureWithConfig(&Config{})
+}
+
+func newFixtureWithConfig(config *Config) *fixture {
+	tp := newTopicProcessor(config, nil, nil)
 	return &fixture{
 		&partitionProcessor{
-			topicProcessor: &TopicProcessor{
-				config: &Config{},
-			},
+			topicProcessor: tp,
+			logger:         tp.logger,
 		},
 		&sarama.ConsumerMessage{},
 	}
 }
 
+type flakySyncProducer struct {
+	recordingSyncProducer
+	err error
+}
+
+func (p *flakySyncProducer) SendMessages(messages []*sarama.ProducerMessage) error {
+	if p.err != nil {
+		return p.err
+	}
+	return p.recordingSyncProducer.SendMessages(messages)
+}
+
 func TestSender_Send_OneMessage(t *testing.T) {
 	f := newFixture()
 	sender := newSender(f.pp)
@@ -98,6 +116,9 @@ func TestSender_Flush_No_Messages(t *testing.T) {
 }
 
 func TestSender_Flush_Messages(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
 	saramaConfig := sarama.NewConfig()
 	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
 	saramaConfig.Producer.Return.Successes = true
@@ -149,3 +170,46 @@ func BenchmarkSender_Send(b *testing.B) {
 		sender.Send(out)
 	}
 }
+
+func TestSender_Flush_DryRun(t *testing.T) {
+	metrics := newRecordingMetricsProvider()
+	f := newFixtureWithConfig(&Config{TopicProcessorName: "words", ContainerID: "c0", MetricsProvider: metrics, DryRun: true})
+	producer := &recordingSyncProducer{}
+	f.pp.topicProcessor.producer = producer
+
+	sender := newSender(f.pp)
+	sender.Send(&sarama.ProducerMessage{Topic: "hello", Value: sarama.ByteEncoder([]byte("AAA"))})
+	sender.Send(&sarama.ProducerMessage{Topic: "hello", Value: sarama.ByteEncoder([]byte("BBB"))})
+
+	assert.NoError(t, sender.Flush())
+	assert.Empty(t, sender.producerMessages)
+	assert.Empty(t, producer.messages)
+	assert.Equal(t, 2.0, metrics.values["dry_run_message_count{c0,words}"])
+}
+
+func TestSender_Flush_Failure(t *testing.T) {
+	config := &Config{BufferPool: NewBufferPool(0)}
+	f := newFixtureWithConfig(config)
+	producer := &flakySyncProducer{err: errors.New("leader not available")}
+	f.pp.topicProcessor.producer = producer
+
+	sender := newSender(f.pp)
+	buffer := config.BufferPool.Get()
+	buffer.WriteString("mars")
+	sender.SendBuffer(&sarama.ProducerMessage{Topic: "planets"}, buffer)
+
+	// The buffer is kept with the message that failed, so that it can be sent again
+	assert.EqualError(t, sender.Flush(), "leader not available")
+	assert.Equal(t, 1, len(sender.producerMessages))
+	assert.Equal(t, []*bytes.Buffer{buffer}, sender.buffers)
+
+	producer.err = nil
+	assert.NoError(t, sender.Flush())
+	assert.Equal(t, 1, len(producer.messages))
+	assert.Equal(t, sarama.ByteEncoder("mars"), producer.messages[0].Value)
+	assert.Empty(t, sender.buffers)
+
+	// Releasing again, as processConsumerMessages does when the batch ends, must not return the buffer twice
+	sender.releaseBuffers()
+	assert.NotSame(t, config.BufferPool.Get(), config.BufferPool.Get())
+}
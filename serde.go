@@ -0,0 +1,51 @@
+package kasper
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Serde serializes and deserializes values, such as message payloads or values kept in a Store.
+type Serde interface {
+	// Serialize encodes a value into bytes.
+	Serialize(value interface{}) ([]byte, error)
+	// Deserialize decodes bytes produced by Serialize. data may be the value of an incoming message, which must not
+	// be modified or referenced by the decoded value, see MessageProcessor.
+	Deserialize(data []byte) (interface{}, error)
+}
+
+// JSONSerde is a Serde using encoding/json.
+type JSONSerde struct {
+	newValue func() interface{}
+}
+
+// NewJSONSerde creates a JSONSerde. newValue returns a pointer to the value Deserialize decodes into,
+// e.g. func() interface{} { return &Tweet{} }.
+func NewJSONSerde(newValue func() interface{}) *JSONSerde {
+	return &JSONSerde{newValue}
+}
+
+// Serialize encodes a value to JSON.
+func (serde *JSONSerde) Serialize(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// SerializeTo encodes a value to JSON into buffer, e.g. a buffer of Config.BufferPool, without allocating a new
+// slice for the output.
+func (serde *JSONSerde) SerializeTo(buffer *bytes.Buffer, value interface{}) error {
+	if err := json.NewEncoder(buffer).Encode(value); err != nil {
+		return err
+	}
+	buffer.Truncate(buffer.Len() - 1)
+	return nil
+}
+
+// Deserialize decodes JSON into a new value returned by newValue.
+func (serde *JSONSerde) Deserialize(data []byte) (interface{}, error) {
+	value := serde.newValue()
+	err := json.Unmarshal(data, value)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
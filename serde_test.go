@@ -0,0 +1,26 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type serdeTestPlanet struct {
+	Name  string `json:"name"`
+	Moons int    `json:"moons"`
+}
+
+func TestJSONSerde(t *testing.T) {
+	serde := NewJSONSerde(func() interface{} { return &serdeTestPlanet{} })
+	data, err := serde.Serialize(&serdeTestPlanet{"mars", 2})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"mars","moons":2}`, string(data))
+
+	value, err := serde.Deserialize(data)
+	assert.Nil(t, err)
+	assert.Equal(t, &serdeTestPlanet{"mars", 2}, value)
+
+	_, err = serde.Deserialize([]byte("{"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,386 @@
+package kasper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/garyburd/redigo/redis"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// Settings is the serializable subset of Config, plus the Kafka brokers and the stores used by the application.
+// Use LoadConfig to read it from a JSON file, so deployments can be reconfigured without recompiling.
+type Settings struct {
+	TopicProcessorName    string                   `json:"topicProcessorName"`
+	Brokers               []string                 `json:"brokers"`
+	KafkaVersion          string                   `json:"kafkaVersion"`
+	InputTopics           []string                 `json:"inputTopics"`
+	InputPartitions       []int                    `json:"inputPartitions"`
+	BatchSize             int                      `json:"batchSize"`
+	BatchWaitDuration     Duration                 `json:"batchWaitDuration"`
+	TargetBatchLatency    Duration                 `json:"targetBatchLatency"`
+	MinBatchSize          int                      `json:"minBatchSize"`
+	OffsetCommitInterval  Duration                 `json:"offsetCommitInterval"`
+	MaxRequestSize        int                      `json:"maxRequestSize"`
+	MetricsUpdateInterval Duration                 `json:"metricsUpdateInterval"`
+	ContainerID           string                   `json:"containerID"`
+	MetricsLabels         map[string]string        `json:"metricsLabels"`
+	LogLevel              string                   `json:"logLevel"`
+	StartupTimeout        Duration                 `json:"startupTimeout"`
+	DryRun                bool                     `json:"dryRun"`
+	Stores                map[string]StoreSettings `json:"stores"`
+	TLS                   *TLSSettings             `json:"tls"`
+	SASL                  *SASLSettings            `json:"sasl"`
+	Secrets               *SecretsSettings         `json:"secrets"`
+	// Overrides Secrets, e.g. to read secrets from Vault
+	SecretsProvider SecretsProvider `json:"-"`
+}
+
+// StoreSettings describes a store opened with Settings.OpenStore.
+type StoreSettings struct {
+	// "map", "redis" or "elasticsearch"
+	Type string `json:"type"`
+	// Initial size of a Map
+	Size int `json:"size"`
+	// Redis address (host:port) and key prefix
+	Address   string `json:"address"`
+	KeyPrefix string `json:"keyPrefix"`
+	// Elasticsearch URL, index and document type
+	URL          string `json:"url"`
+	Index        string `json:"index"`
+	DocumentType string `json:"documentType"`
+	// Elasticsearch user (basic authentication) and Redis or Elasticsearch password, which can be read from
+	// the SecretsProvider by setting PasswordSecret to the name of the secret instead
+	User           string `json:"user"`
+	Password       string `json:"password"`
+	PasswordSecret string `json:"passwordSecret"`
+}
+
+// Duration is a time.Duration that is encoded in JSON as a string such as "5s" or "1m30s".
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON encodes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes a duration from a string (see time.ParseDuration) or from a number of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case string:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		d.Duration = duration
+	case float64:
+		d.Duration = time.Duration(value)
+	default:
+		return fmt.Errorf("invalid duration: %s", data)
+	}
+	return nil
+}
+
+// ConfigError reports all the problems found in a configuration.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(e.Problems, "; "))
+}
+
+// LoadConfig reads Settings from a JSON file and creates a Config, including its sarama Client.
+// See ReadSettings for the file format.
+func LoadConfig(path string) (*Config, error) {
+	settings, err := ReadSettings(path)
+	if err != nil {
+		return nil, err
+	}
+	return settings.Config()
+}
+
+// ReadSettings reads and validates Settings from a JSON file. References to environment variables of the form
+// $VAR, ${VAR} or ${VAR:-default} are replaced with their JSON-escaped values before parsing, and $$ is replaced
+// with $:
+//
+//	{
+//		"topicProcessorName": "twitter-reach",
+//		"brokers": ["${KAFKA_HOST:-localhost}:9092"],
+//		"kafkaVersion": "2.8.0",
+//		"inputTopics": ["tweets", "twitter-followers"],
+//		"inputPartitions": [0, 1, 2, 3],
+//		"batchWaitDuration": "5s",
+//		"logLevel": "info",
+//		"startupTimeout": "2m",
+//		"stores": {
+//			"reach": {"type": "redis", "address": "${REDIS_HOST}:6379", "keyPrefix": "reach"}
+//		}
+//	}
+//
+// Unknown fields are rejected, so that misspelled settings are not silently ignored. YAML files are not supported.
+func ReadSettings(path string) (*Settings, error) {
+	extension := strings.ToLower(filepath.Ext(path))
+	if extension == ".yaml" || extension == ".yml" {
+		return nil, fmt.Errorf("cannot read %s: YAML configuration files are not supported, use JSON", path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(expandEnv(string(data)))))
+	decoder.DisallowUnknownFields()
+	settings := &Settings{}
+	if err := decoder.Decode(settings); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %s", path, err)
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// expandEnv replaces environment variables in a JSON document. Their values are JSON-escaped, so that values
+// containing quotes or backslashes (e.g. passwords) cannot break the document or inject settings.
+func expandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		if i := strings.Index(name, ":-"); i >= 0 {
+			if value := os.Getenv(name[:i]); value != "" {
+				return escapeJSON(value)
+			}
+			return name[i+2:]
+		}
+		return escapeJSON(os.Getenv(name))
+	})
+}
+
+// escapeJSON escapes a value for use inside a JSON string.
+func escapeJSON(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted[1 : len(quoted)-1])
+}
+
+// Validate checks that the settings are complete and consistent. It does not connect to Kafka or to the stores.
+func (s *Settings) Validate() error {
+	problems := validateProcessing(s.TopicProcessorName, s.InputTopics, s.InputPartitions,
+		s.BatchSize, s.BatchWaitDuration.Duration, s.MetricsUpdateInterval.Duration, s.MetricsLabels)
+	problems = append(problems, validateBatchSizes(s.BatchSize, s.MinBatchSize, s.TargetBatchLatency.Duration)...)
+	if len(s.Brokers) == 0 {
+		problems = append(problems, "at least one broker is required")
+	}
+	if _, found := kafkaVersions[s.KafkaVersion]; s.KafkaVersion != "" && !found {
+		problems = append(problems, fmt.Sprintf("unsupported Kafka version %q (expected %s to %s)",
+			s.KafkaVersion, sarama.MinVersion, sarama.MaxVersion))
+	}
+	if s.LogLevel != "" {
+		if _, err := parseLevel(s.LogLevel); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if s.MaxRequestSize < 0 {
+		problems = append(problems, "max request size cannot be negative")
+	}
+	if s.StartupTimeout.Duration < 0 {
+		problems = append(problems, "startup timeout cannot be negative")
+	}
+	for name, store := range s.Stores {
+		problems = append(problems, store.validate(name)...)
+	}
+	if s.TLS != nil {
+		problems = append(problems, s.TLS.validate()...)
+	}
+	if s.SASL != nil {
+		problems = append(problems, s.SASL.validate()...)
+	}
+	if s.Secrets != nil {
+		problems = append(problems, s.Secrets.validate()...)
+	}
+	if len(problems) > 0 {
+		return &ConfigError{problems}
+	}
+	return nil
+}
+
+func (s StoreSettings) validate(name string) []string {
+	var problems []string
+	switch s.Type {
+	case "map":
+	case "redis":
+		if s.Address == "" {
+			problems = append(problems, fmt.Sprintf("store %s: address is required", name))
+		}
+	case "elasticsearch":
+		if s.URL == "" || s.Index == "" || s.DocumentType == "" {
+			problems = append(problems, fmt.Sprintf("store %s: url, index and documentType are required", name))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("store %s: unknown type %q (expected map, redis or elasticsearch)", name, s.Type))
+	}
+	if s.Password != "" && s.PasswordSecret != "" {
+		problems = append(problems, fmt.Sprintf("store %s: password and passwordSecret cannot be set together", name))
+	}
+	return problems
+}
+
+// SaramaConfig returns the sarama configuration used by Config, with RequiredAcks set to WaitForAll
+// and the Kafka version, offset commit interval, TLS and SASL settings applied. Offsets are committed
+// asynchronously every OffsetCommitInterval (1 second by default), in one request per broker for all partitions,
+// and when the TopicProcessor is closed.
+//
+// Messages sent by a MessageProcessor are batched per broker and topic-partition by the sarama producer.
+// MaxRequestSize (Producer.MaxMessageBytes, 1000000 bytes by default) limits the size of a message and of a
+// compressed batch. It should not exceed the max.request.size of the brokers.
+func (s *Settings) SaramaConfig() (*sarama.Config, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	if s.KafkaVersion != "" {
+		saramaConfig.Version = kafkaVersions[s.KafkaVersion]
+	}
+	if s.OffsetCommitInterval.Duration > 0 {
+		saramaConfig.Consumer.Offsets.CommitInterval = s.OffsetCommitInterval.Duration
+	}
+	if s.MaxRequestSize > 0 {
+		saramaConfig.Producer.MaxMessageBytes = s.MaxRequestSize
+	}
+	if err := s.applySecurity(saramaConfig); err != nil {
+		return nil, err
+	}
+	return saramaConfig, nil
+}
+
+// Config creates a Config from the settings. It connects to the Kafka brokers to create the sarama Client,
+// retrying for up to StartupTimeout (see OpenStore).
+func (s *Settings) Config() (*Config, error) {
+	saramaConfig, err := s.SaramaConfig()
+	if err != nil {
+		return nil, err
+	}
+	var logger Logger
+	if s.LogLevel != "" {
+		if logger, err = NewLevelLogger(NewBasicLogger(true), s.LogLevel); err != nil {
+			return nil, err
+		}
+	}
+	var client sarama.Client
+	err = retry(s.StartupTimeout.Duration, logger, "Kafka", func() (err error) {
+		client, err = sarama.NewClient(s.Brokers, saramaConfig)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Config{
+		TopicProcessorName:    s.TopicProcessorName,
+		Client:                client,
+		InputTopics:           s.InputTopics,
+		InputPartitions:       s.InputPartitions,
+		BatchSize:             s.BatchSize,
+		BatchWaitDuration:     s.BatchWaitDuration.Duration,
+		TargetBatchLatency:    s.TargetBatchLatency.Duration,
+		MinBatchSize:          s.MinBatchSize,
+		MetricsUpdateInterval: s.MetricsUpdateInterval.Duration,
+		ContainerID:           s.ContainerID,
+		MetricsLabels:         s.MetricsLabels,
+		Logger:                logger,
+		DryRun:                s.DryRun,
+	}, nil
+}
+
+// RuntimeConfig returns the settings that can be applied to a running TopicProcessor with Reconfigure.
+func (s *Settings) RuntimeConfig() RuntimeConfig {
+	return RuntimeConfig{s.BatchSize, s.BatchWaitDuration, s.LogLevel}
+}
+
+// kafkaVersions are the Kafka protocol versions supported by sarama (0.8.2.0 to 2.8.0), named as in
+// sarama.ParseKafkaVersion: "0.10.2.0" before 1.0 and "2.8.0" after. Settings.KafkaVersion defaults to
+// sarama.DefaultVersion (1.0.0), which newer brokers also support.
+var kafkaVersions = supportedKafkaVersions()
+
+func supportedKafkaVersions() map[string]sarama.KafkaVersion {
+	versions := make(map[string]sarama.KafkaVersion, len(sarama.SupportedVersions))
+	for _, version := range sarama.SupportedVersions {
+		versions[version.String()] = version
+	}
+	return versions
+}
+
+func kafkaVersionString(version sarama.KafkaVersion) string {
+	for name, known := range kafkaVersions {
+		if known == version {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// OpenStore connects to the store with the given name in Settings.Stores. When StartupTimeout is set, failed
+// connections are retried with exponential backoff until it elapses, so that the application can start before
+// its dependencies are up (e.g. in docker-compose or Kubernetes). The store is wrapped with NewDryRunStore.
+func (s *Settings) OpenStore(config *Config, name string) (Store, error) {
+	store, err := s.openStore(config, name)
+	if err != nil {
+		return nil, err
+	}
+	return NewDryRunStore(config, store, name), nil
+}
+
+func (s *Settings) openStore(config *Config, name string) (Store, error) {
+	store, found := s.Stores[name]
+	if !found {
+		return nil, fmt.Errorf("store %s is not configured", name)
+	}
+	switch store.Type {
+	case "map":
+		return NewMap(store.Size), nil
+	case "redis":
+		password, err := s.password(store.Password, store.PasswordSecret)
+		if err != nil {
+			return nil, err
+		}
+		var conn redis.Conn
+		err = retry(s.StartupTimeout.Duration, config.logger(), "store "+name, func() (err error) {
+			conn, err = redis.Dial("tcp", store.Address, redis.DialPassword(password))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NewRedis(config, conn, store.KeyPrefix), nil
+	case "elasticsearch":
+		options := []elastic.ClientOptionFunc{elastic.SetURL(store.URL), elastic.SetSniff(false)}
+		if store.User != "" {
+			password, err := s.password(store.Password, store.PasswordSecret)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, elastic.SetBasicAuth(store.User, password))
+		}
+		var client *elastic.Client
+		err := retry(s.StartupTimeout.Duration, config.logger(), "store "+name, func() (err error) {
+			client, err = elastic.NewClient(options...)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NewElasticsearch(config, client, store.Index, store.DocumentType), nil
+	default:
+		return nil, fmt.Errorf("store %s: unknown type %q", name, store.Type)
+	}
+}
@@ -0,0 +1,232 @@
+package kasper
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables read by ConfigFromEnv and Settings.ApplyEnv.
+// Lists are comma-separated, and partitions can be given as ranges (e.g. "0-5,8").
+// Metrics labels are given as name=value pairs (e.g. "environment=production,region=eu").
+const (
+	EnvTopicProcessorName    = "KASPER_TOPIC_PROCESSOR_NAME"
+	EnvBrokers               = "KASPER_BROKERS"
+	EnvKafkaVersion          = "KASPER_KAFKA_VERSION"
+	EnvInputTopics           = "KASPER_INPUT_TOPICS"
+	EnvInputPartitions       = "KASPER_INPUT_PARTITIONS"
+	EnvBatchSize             = "KASPER_BATCH_SIZE"
+	EnvBatchWaitDuration     = "KASPER_BATCH_WAIT_DURATION"
+	EnvMetricsUpdateInterval = "KASPER_METRICS_UPDATE_INTERVAL"
+	EnvContainerID           = "KASPER_CONTAINER_ID"
+	EnvMetricsLabels         = "KASPER_METRICS_LABELS"
+	EnvLogLevel              = "KASPER_LOG_LEVEL"
+	EnvStartupTimeout        = "KASPER_STARTUP_TIMEOUT"
+	// Set to "true" to enable dry run mode (see Config.DryRun)
+	EnvDryRun = "KASPER_DRY_RUN"
+	// Set to "true" to connect with TLS (implied by the other TLS variables)
+	EnvTLSEnabled            = "KASPER_TLS_ENABLED"
+	EnvTLSCAFile             = "KASPER_TLS_CA_FILE"
+	EnvTLSCertFile           = "KASPER_TLS_CERT_FILE"
+	EnvTLSKeyFile            = "KASPER_TLS_KEY_FILE"
+	EnvTLSInsecureSkipVerify = "KASPER_TLS_INSECURE_SKIP_VERIFY"
+	// Setting KASPER_SASL_USER enables SASL authentication
+	EnvSASLMechanism      = "KASPER_SASL_MECHANISM"
+	EnvSASLUser           = "KASPER_SASL_USER"
+	EnvSASLPassword       = "KASPER_SASL_PASSWORD"
+	EnvSASLPasswordSecret = "KASPER_SASL_PASSWORD_SECRET"
+)
+
+// ConfigFromEnv creates a Config from environment variables (see EnvBrokers and the other Env constants),
+// for 12-factor deployments. KASPER_TOPIC_PROCESSOR_NAME, KASPER_INPUT_TOPICS and KASPER_INPUT_PARTITIONS
+// are required; the brokers default to localhost:9092 and the other settings default as in Config.
+func ConfigFromEnv() (*Config, error) {
+	settings, err := SettingsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return settings.Config()
+}
+
+// SettingsFromEnv reads and validates Settings from environment variables. See ConfigFromEnv.
+func SettingsFromEnv() (*Settings, error) {
+	settings := &Settings{Brokers: []string{"localhost:9092"}}
+	if err := settings.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// ApplyEnv overrides the settings with the environment variables that are set, e.g. to override a configuration
+// file read with ReadSettings. All malformed variables are reported at once in a ConfigError.
+func (s *Settings) ApplyEnv() error {
+	return s.applyEnv(os.Getenv)
+}
+
+func (s *Settings) applyEnv(getenv func(string) string) error {
+	var problems []string
+	if value := getenv(EnvTopicProcessorName); value != "" {
+		s.TopicProcessorName = value
+	}
+	if value := getenv(EnvBrokers); value != "" {
+		s.Brokers = splitList(value)
+	}
+	if value := getenv(EnvKafkaVersion); value != "" {
+		s.KafkaVersion = value
+	}
+	if value := getenv(EnvInputTopics); value != "" {
+		s.InputTopics = splitList(value)
+	}
+	if value := getenv(EnvInputPartitions); value != "" {
+		partitions, err := parsePartitions(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", EnvInputPartitions, err))
+		}
+		s.InputPartitions = partitions
+	}
+	if value := getenv(EnvBatchSize); value != "" {
+		batchSize, err := strconv.Atoi(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %q is not an integer", EnvBatchSize, value))
+		}
+		s.BatchSize = batchSize
+	}
+	if value := getenv(EnvBatchWaitDuration); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", EnvBatchWaitDuration, err))
+		}
+		s.BatchWaitDuration = Duration{duration}
+	}
+	if value := getenv(EnvMetricsUpdateInterval); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", EnvMetricsUpdateInterval, err))
+		}
+		s.MetricsUpdateInterval = Duration{duration}
+	}
+	if value := getenv(EnvContainerID); value != "" {
+		s.ContainerID = value
+	}
+	if value := getenv(EnvMetricsLabels); value != "" {
+		labels := make(map[string]string)
+		for _, pair := range splitList(value) {
+			i := strings.Index(pair, "=")
+			if i <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a name=value pair", EnvMetricsLabels, pair))
+				continue
+			}
+			labels[pair[:i]] = pair[i+1:]
+		}
+		s.MetricsLabels = labels
+	}
+	if value := getenv(EnvLogLevel); value != "" {
+		s.LogLevel = value
+	}
+	if value := getenv(EnvDryRun); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %q is not a boolean", EnvDryRun, value))
+		}
+		s.DryRun = dryRun
+	}
+	if value := getenv(EnvStartupTimeout); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", EnvStartupTimeout, err))
+		}
+		s.StartupTimeout = Duration{duration}
+	}
+	problems = append(problems, s.applySecurityEnv(getenv)...)
+	if len(problems) > 0 {
+		return &ConfigError{problems}
+	}
+	return nil
+}
+
+func (s *Settings) applySecurityEnv(getenv func(string) string) []string {
+	var problems []string
+	tlsEnabled := false
+	if value := getenv(EnvTLSEnabled); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %q is not a boolean", EnvTLSEnabled, value))
+		}
+		tlsEnabled = enabled
+	}
+	insecure := false
+	if value := getenv(EnvTLSInsecureSkipVerify); value != "" {
+		skip, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %q is not a boolean", EnvTLSInsecureSkipVerify, value))
+		}
+		insecure = skip
+	}
+	caFile, certFile, keyFile := getenv(EnvTLSCAFile), getenv(EnvTLSCertFile), getenv(EnvTLSKeyFile)
+	if tlsEnabled || insecure || caFile != "" || certFile != "" || keyFile != "" {
+		if s.TLS == nil {
+			s.TLS = &TLSSettings{}
+		}
+		if caFile != "" {
+			s.TLS.CAFile = caFile
+		}
+		if certFile != "" {
+			s.TLS.CertFile = certFile
+		}
+		if keyFile != "" {
+			s.TLS.KeyFile = keyFile
+		}
+		s.TLS.InsecureSkipVerify = s.TLS.InsecureSkipVerify || insecure
+	}
+	if user := getenv(EnvSASLUser); user != "" {
+		if s.SASL == nil {
+			s.SASL = &SASLSettings{}
+		}
+		s.SASL.User = user
+		s.SASL.Password = getenv(EnvSASLPassword)
+		s.SASL.PasswordSecret = getenv(EnvSASLPasswordSecret)
+		if mechanism := getenv(EnvSASLMechanism); mechanism != "" {
+			s.SASL.Mechanism = mechanism
+		}
+	}
+	return problems
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePartitions parses lists of partitions and partition ranges such as "0-5,8"
+func parsePartitions(value string) ([]int, error) {
+	var partitions []int
+	for _, item := range splitList(value) {
+		bounds := strings.SplitN(item, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a partition or a range of partitions", item)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return nil, fmt.Errorf("%q is not a partition or a range of partitions", item)
+			}
+		}
+		for partition := first; partition <= last; partition++ {
+			partitions = append(partitions, partition)
+		}
+	}
+	return partitions, nil
+}
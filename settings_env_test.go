@@ -0,0 +1,77 @@
+package kasper
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettings_applyEnv(t *testing.T) {
+	env := map[string]string{
+		EnvTopicProcessorName:    "twitter-reach",
+		EnvBrokers:               "kafka-1:9092, kafka-2:9092",
+		EnvKafkaVersion:          "0.10.2.0",
+		EnvInputTopics:           "tweets,twitter-followers",
+		EnvInputPartitions:       "0-3,8",
+		EnvBatchSize:             "500",
+		EnvBatchWaitDuration:     "2s",
+		EnvMetricsUpdateInterval: "1m",
+		EnvContainerID:           "twitter-reach-0",
+		EnvMetricsLabels:         "environment=production,region=eu",
+		EnvLogLevel:              "debug",
+		EnvStartupTimeout:        "2m",
+		EnvDryRun:                "true",
+	}
+	settings := &Settings{BatchSize: 1000, Stores: map[string]StoreSettings{"cache": {Type: "map"}}}
+	assert.Nil(t, settings.applyEnv(func(name string) string { return env[name] }))
+	assert.Equal(t, &Settings{
+		TopicProcessorName:    "twitter-reach",
+		Brokers:               []string{"kafka-1:9092", "kafka-2:9092"},
+		KafkaVersion:          "0.10.2.0",
+		InputTopics:           []string{"tweets", "twitter-followers"},
+		InputPartitions:       []int{0, 1, 2, 3, 8},
+		BatchSize:             500,
+		BatchWaitDuration:     Duration{2 * time.Second},
+		MetricsUpdateInterval: Duration{time.Minute},
+		ContainerID:           "twitter-reach-0",
+		MetricsLabels:         map[string]string{"environment": "production", "region": "eu"},
+		LogLevel:              "debug",
+		StartupTimeout:        Duration{2 * time.Minute},
+		DryRun:                true,
+		Stores:                map[string]StoreSettings{"cache": {Type: "map"}},
+	}, settings)
+}
+
+func TestSettings_applyEnv_Invalid(t *testing.T) {
+	env := map[string]string{
+		EnvInputPartitions: "0-a",
+		EnvBatchSize:       "lots",
+		EnvMetricsLabels:   "production",
+	}
+	err := (&Settings{}).applyEnv(func(name string) string { return env[name] })
+	assert.Equal(t, &ConfigError{[]string{
+		`KASPER_INPUT_PARTITIONS: "0-a" is not a partition or a range of partitions`,
+		`KASPER_BATCH_SIZE: "lots" is not an integer`,
+		`KASPER_METRICS_LABELS: "production" is not a name=value pair`,
+	}}, err)
+}
+
+func TestSettingsFromEnv(t *testing.T) {
+	os.Setenv(EnvTopicProcessorName, "ford-prefect")
+	os.Setenv(EnvInputTopics, "towels")
+	os.Setenv(EnvInputPartitions, "0")
+	defer os.Unsetenv(EnvTopicProcessorName)
+	defer os.Unsetenv(EnvInputTopics)
+	defer os.Unsetenv(EnvInputPartitions)
+
+	settings, err := SettingsFromEnv()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"localhost:9092"}, settings.Brokers)
+	assert.Equal(t, []int{0}, settings.InputPartitions)
+
+	os.Unsetenv(EnvInputTopics)
+	_, err = SettingsFromEnv()
+	assert.EqualError(t, err, "invalid configuration: at least one input topic is required")
+}
@@ -0,0 +1,182 @@
+package kasper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// TLSSettings configures TLS for Kafka connections. Connections use TLS if TLSSettings is set.
+type TLSSettings struct {
+	// PEM file of the certificate authorities used to verify the brokers, defaults to the system pool
+	CAFile string `json:"caFile"`
+	// PEM files of the client certificate and key, for brokers requiring client authentication
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// Overrides the server name used to verify the broker certificates
+	ServerName string `json:"serverName"`
+	// Disables the verification of the broker certificates (for testing only)
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
+
+// SASLSettings configures SASL authentication for Kafka connections.
+type SASLSettings struct {
+	// "PLAIN" (the default), "SCRAM-SHA-256", "SCRAM-SHA-512", "OAUTHBEARER" or "GSSAPI"
+	Mechanism string `json:"mechanism"`
+	// User and password for PLAIN and SCRAM. For GSSAPI, User is the Kerberos principal (without the realm) and
+	// Password is only used when KeytabFile is not set.
+	User     string `json:"user"`
+	Password string `json:"password"`
+	// Name of the secret containing the password (see SecretsProvider), instead of Password
+	PasswordSecret string `json:"passwordSecret"`
+	// Name of the secret containing the OAUTHBEARER access token. The secret is read every time a connection
+	// is opened, so the token can be refreshed by an external process (e.g. a sidecar writing a file secret).
+	TokenSecret string `json:"tokenSecret"`
+	// Kerberos service name of the brokers, usually "kafka" (GSSAPI only)
+	KerberosServiceName string `json:"kerberosServiceName"`
+	// Kerberos realm of User (GSSAPI only)
+	KerberosRealm string `json:"kerberosRealm"`
+	// Path of the krb5.conf file (GSSAPI only)
+	KerberosConfigFile string `json:"kerberosConfigFile"`
+	// Path of the keytab used to log in (GSSAPI only). A new ticket is requested from the keytab every time a
+	// connection is opened, so tickets never need to be renewed by hand.
+	KeytabFile string `json:"keytabFile"`
+	// Set to true to disable the SASL handshake (for brokers that do not support it, e.g. Kafka 0.9)
+	DisableHandshake bool `json:"disableHandshake"`
+}
+
+// TLSConfig loads the certificates and creates a tls.Config.
+func (s *TLSSettings) TLSConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         s.ServerName,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+	}
+	if s.CAFile != "" {
+		pem, err := ioutil.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", s.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if s.CertFile != "" || s.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+func (s *TLSSettings) validate() []string {
+	if (s.CertFile == "") != (s.KeyFile == "") {
+		return []string{"tls: certFile and keyFile must be set together"}
+	}
+	return nil
+}
+
+func (s *SASLSettings) validate() []string {
+	var problems []string
+	switch s.mechanism() {
+	case sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
+		if s.User == "" {
+			problems = append(problems, "sasl: user is required")
+		}
+	case sarama.SASLTypeOAuth:
+		if s.TokenSecret == "" {
+			problems = append(problems, "sasl: tokenSecret is required for OAUTHBEARER")
+		}
+	case sarama.SASLTypeGSSAPI:
+		if s.User == "" {
+			problems = append(problems, "sasl: user is required")
+		}
+		if s.KerberosServiceName == "" || s.KerberosRealm == "" || s.KerberosConfigFile == "" {
+			problems = append(problems, "sasl: kerberosServiceName, kerberosRealm and kerberosConfigFile are required for GSSAPI")
+		}
+		if s.KeytabFile == "" && s.Password == "" && s.PasswordSecret == "" {
+			problems = append(problems, "sasl: keytabFile or a password is required for GSSAPI")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("sasl: unknown mechanism %q", s.Mechanism))
+	}
+	if s.Password != "" && s.PasswordSecret != "" {
+		problems = append(problems, "sasl: password and passwordSecret cannot be set together")
+	}
+	return problems
+}
+
+// mechanism returns the SASL mechanism in upper case, defaulting to PLAIN.
+func (s *SASLSettings) mechanism() string {
+	if s.Mechanism == "" {
+		return sarama.SASLTypePlaintext
+	}
+	return strings.ToUpper(s.Mechanism)
+}
+
+// secretTokenProvider provides OAUTHBEARER tokens read from a secret.
+type secretTokenProvider struct {
+	provider SecretsProvider
+	name     string
+}
+
+func (p secretTokenProvider) Token() (*sarama.AccessToken, error) {
+	token, err := p.provider.Secret(p.name)
+	if err != nil {
+		return nil, err
+	}
+	return &sarama.AccessToken{Token: token}, nil
+}
+
+// applySecurity sets the TLS and SASL settings on a sarama configuration, which are used by both the consumers and
+// the producer of the TopicProcessor.
+func (s *Settings) applySecurity(saramaConfig *sarama.Config) error {
+	if s.TLS != nil {
+		tlsConfig, err := s.TLS.TLSConfig()
+		if err != nil {
+			return err
+		}
+		saramaConfig.Net.TLS.Enable = true
+		saramaConfig.Net.TLS.Config = tlsConfig
+	}
+	if s.SASL != nil {
+		password, err := s.password(s.SASL.Password, s.SASL.PasswordSecret)
+		if err != nil {
+			return err
+		}
+		saramaConfig.Net.SASL.Enable = true
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLMechanism(s.SASL.mechanism())
+		saramaConfig.Net.SASL.User = s.SASL.User
+		saramaConfig.Net.SASL.Password = password
+		saramaConfig.Net.SASL.Handshake = !s.SASL.DisableHandshake
+		switch s.SASL.mechanism() {
+		case sarama.SASLTypeSCRAMSHA256:
+			saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = scramSHA256ClientGenerator
+		case sarama.SASLTypeSCRAMSHA512:
+			saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = scramSHA512ClientGenerator
+		case sarama.SASLTypeOAuth:
+			saramaConfig.Net.SASL.TokenProvider = secretTokenProvider{s.secretsProvider(), s.SASL.TokenSecret}
+		case sarama.SASLTypeGSSAPI:
+			saramaConfig.Net.SASL.GSSAPI = sarama.GSSAPIConfig{
+				AuthType:           sarama.KRB5_USER_AUTH,
+				KerberosConfigPath: s.SASL.KerberosConfigFile,
+				ServiceName:        s.SASL.KerberosServiceName,
+				Username:           s.SASL.User,
+				Password:           password,
+				Realm:              s.SASL.KerberosRealm,
+			}
+			if s.SASL.KeytabFile != "" {
+				saramaConfig.Net.SASL.GSSAPI.AuthType = sarama.KRB5_KEYTAB_AUTH
+				saramaConfig.Net.SASL.GSSAPI.KeyTabPath = s.SASL.KeytabFile
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package kasper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettings_SaramaConfig_Security(t *testing.T) {
+	settings := &Settings{
+		TLS:  &TLSSettings{ServerName: "kafka.local", InsecureSkipVerify: true},
+		SASL: &SASLSettings{Mechanism: "PLAIN", User: "arthur", Password: "42"},
+	}
+	saramaConfig, err := settings.SaramaConfig()
+	assert.Nil(t, err)
+	assert.True(t, saramaConfig.Net.TLS.Enable)
+	assert.Equal(t, "kafka.local", saramaConfig.Net.TLS.Config.ServerName)
+	assert.True(t, saramaConfig.Net.TLS.Config.InsecureSkipVerify)
+	assert.True(t, saramaConfig.Net.SASL.Enable)
+	assert.True(t, saramaConfig.Net.SASL.Handshake)
+	assert.Equal(t, "arthur", saramaConfig.Net.SASL.User)
+	assert.Equal(t, "42", saramaConfig.Net.SASL.Password)
+
+	saramaConfig, err = (&Settings{}).SaramaConfig()
+	assert.Nil(t, err)
+	assert.False(t, saramaConfig.Net.TLS.Enable)
+	assert.False(t, saramaConfig.Net.SASL.Enable)
+
+	_, err = (&Settings{TLS: &TLSSettings{CAFile: "/does/not/exist.pem"}}).SaramaConfig()
+	assert.NotNil(t, err)
+}
+
+func TestSettings_Validate_Security(t *testing.T) {
+	settings := &Settings{
+		TopicProcessorName: "arthur-dent",
+		Brokers:            []string{"localhost:9092"},
+		InputTopics:        []string{"tweets"},
+		InputPartitions:    []int{0},
+		TLS:                &TLSSettings{CertFile: "client.pem"},
+		SASL:               &SASLSettings{Mechanism: "SCRAM-SHA-512"},
+	}
+	assert.Equal(t, &ConfigError{[]string{
+		"tls: certFile and keyFile must be set together",
+		"sasl: user is required",
+	}}, settings.Validate())
+
+	settings.TLS = nil
+	settings.SASL = &SASLSettings{Mechanism: "oauthbearer"}
+	assert.Equal(t, &ConfigError{[]string{
+		"sasl: tokenSecret is required for OAUTHBEARER",
+	}}, settings.Validate())
+	settings.SASL = &SASLSettings{Mechanism: "DIGEST-MD5", User: "arthur"}
+	assert.Equal(t, &ConfigError{[]string{
+		`sasl: unknown mechanism "DIGEST-MD5"`,
+	}}, settings.Validate())
+}
+
+func TestSettings_SaramaConfig_SCRAM(t *testing.T) {
+	for mechanism, hashSize := range map[string]int{"SCRAM-SHA-256": 32, "scram-sha-512": 64} {
+		settings := &Settings{SASL: &SASLSettings{Mechanism: mechanism, User: "arthur", Password: "42"}}
+		saramaConfig, err := settings.SaramaConfig()
+		assert.Nil(t, err)
+		assert.Equal(t, sarama.SASLMechanism(strings.ToUpper(mechanism)), saramaConfig.Net.SASL.Mechanism)
+		assert.Nil(t, saramaConfig.Validate())
+		client := saramaConfig.Net.SASL.SCRAMClientGeneratorFunc().(*scramClient)
+		assert.Equal(t, hashSize, client.newHash().Size())
+	}
+}
+
+func TestSettings_SaramaConfig_OAuthBearer(t *testing.T) {
+	secrets := map[string]string{"kafka-token": "t0k3n"}
+	settings := &Settings{
+		KafkaVersion:    "2.0.0",
+		SASL:            &SASLSettings{Mechanism: "OAUTHBEARER", TokenSecret: "kafka-token"},
+		SecretsProvider: mapSecrets(secrets),
+	}
+	saramaConfig, err := settings.SaramaConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypeOAuth), saramaConfig.Net.SASL.Mechanism)
+	assert.Nil(t, saramaConfig.Validate())
+
+	// The token is read from the secret every time it is needed
+	secrets["kafka-token"] = "r3fr3sh3d"
+	token, err := saramaConfig.Net.SASL.TokenProvider.Token()
+	assert.Nil(t, err)
+	assert.Equal(t, &sarama.AccessToken{Token: "r3fr3sh3d"}, token)
+}
+
+func TestSettings_applyEnv_Security(t *testing.T) {
+	env := map[string]string{
+		EnvTLSCAFile:    "/etc/kafka/ca.pem",
+		EnvSASLUser:     "arthur",
+		EnvSASLPassword: "42",
+	}
+	settings := &Settings{}
+	assert.Nil(t, settings.applyEnv(func(name string) string { return env[name] }))
+	assert.Equal(t, &TLSSettings{CAFile: "/etc/kafka/ca.pem"}, settings.TLS)
+	assert.Equal(t, &SASLSettings{User: "arthur", Password: "42"}, settings.SASL)
+
+	settings = &Settings{}
+	assert.Nil(t, settings.applyEnv(func(name string) string { return "" }))
+	assert.Nil(t, settings.TLS)
+	assert.Nil(t, settings.SASL)
+}
+
+func TestSASLSettings_validate_GSSAPI(t *testing.T) {
+	settings := &SASLSettings{Mechanism: "GSSAPI", User: "kasper"}
+	assert.Equal(t, []string{
+		"sasl: kerberosServiceName, kerberosRealm and kerberosConfigFile are required for GSSAPI",
+		"sasl: keytabFile or a password is required for GSSAPI",
+	}, settings.validate())
+}
+
+func TestSettings_SaramaConfig_GSSAPI(t *testing.T) {
+	settings := &Settings{SASL: &SASLSettings{
+		Mechanism:           "GSSAPI",
+		User:                "kasper",
+		KerberosServiceName: "kafka",
+		KerberosRealm:       "EXAMPLE.COM",
+		KerberosConfigFile:  "/etc/krb5.conf",
+		KeytabFile:          "/etc/security/kasper.keytab",
+	}}
+	assert.Nil(t, settings.SASL.validate())
+	saramaConfig, err := settings.SaramaConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypeGSSAPI), saramaConfig.Net.SASL.Mechanism)
+	assert.Equal(t, sarama.GSSAPIConfig{
+		AuthType:           sarama.KRB5_KEYTAB_AUTH,
+		KeyTabPath:         "/etc/security/kasper.keytab",
+		KerberosConfigPath: "/etc/krb5.conf",
+		ServiceName:        "kafka",
+		Username:           "kasper",
+		Realm:              "EXAMPLE.COM",
+	}, saramaConfig.Net.SASL.GSSAPI)
+	assert.Nil(t, saramaConfig.Validate())
+
+	// Without a keytab, the password is used
+	settings.SASL.KeytabFile = ""
+	settings.SASL.Password = "42"
+	saramaConfig, err = settings.SaramaConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, sarama.KRB5_USER_AUTH, saramaConfig.Net.SASL.GSSAPI.AuthType)
+	assert.Equal(t, "42", saramaConfig.Net.SASL.GSSAPI.Password)
+	assert.Nil(t, saramaConfig.Validate())
+}
@@ -0,0 +1,155 @@
+package kasper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSettingsFile(t *testing.T, name string, content string) string {
+	dir, err := ioutil.TempDir("", "kasper-settings")
+	assert.Nil(t, err)
+	path := filepath.Join(dir, name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestReadSettings(t *testing.T) {
+	os.Setenv("KASPER_TEST_REDIS_HOST", "redis.local")
+	defer os.Unsetenv("KASPER_TEST_REDIS_HOST")
+	path := writeSettingsFile(t, "config.json", `{
+		"topicProcessorName": "twitter-reach",
+		"brokers": ["${KASPER_TEST_KAFKA_HOST:-localhost}:9092"],
+		"kafkaVersion": "0.10.2.0",
+		"inputTopics": ["tweets", "twitter-followers"],
+		"inputPartitions": [0, 1],
+		"batchSize": 500,
+		"batchWaitDuration": "5s",
+		"offsetCommitInterval": "10s",
+		"maxRequestSize": 2000000,
+		"metricsUpdateInterval": 60000000000,
+		"metricsLabels": {"environment": "test"},
+		"stores": {
+			"reach": {"type": "redis", "address": "${KASPER_TEST_REDIS_HOST}:6379", "keyPrefix": "reach$$"}
+		}
+	}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	settings, err := ReadSettings(path)
+	assert.Nil(t, err)
+	assert.Equal(t, &Settings{
+		TopicProcessorName:    "twitter-reach",
+		Brokers:               []string{"localhost:9092"},
+		KafkaVersion:          "0.10.2.0",
+		InputTopics:           []string{"tweets", "twitter-followers"},
+		InputPartitions:       []int{0, 1},
+		BatchSize:             500,
+		BatchWaitDuration:     Duration{5 * time.Second},
+		OffsetCommitInterval:  Duration{10 * time.Second},
+		MaxRequestSize:        2000000,
+		MetricsUpdateInterval: Duration{time.Minute},
+		MetricsLabels:         map[string]string{"environment": "test"},
+		Stores: map[string]StoreSettings{
+			"reach": {Type: "redis", Address: "redis.local:6379", KeyPrefix: "reach$"},
+		},
+	}, settings)
+
+	saramaConfig, err := settings.SaramaConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, sarama.V0_10_2_0, saramaConfig.Version)
+	assert.Equal(t, 10*time.Second, saramaConfig.Consumer.Offsets.CommitInterval)
+	assert.Equal(t, 2000000, saramaConfig.Producer.MaxMessageBytes)
+
+	store, err := (&Settings{Stores: map[string]StoreSettings{"cache": {Type: "map", Size: 10}}}).OpenStore(&Config{}, "cache")
+	assert.Nil(t, err)
+	assert.IsType(t, &Map{}, store)
+}
+
+func TestReadSettings_EscapedEnv(t *testing.T) {
+	os.Setenv("KASPER_TEST_SASL_PASSWORD", `p"a\ss", "user": "admin`)
+	defer os.Unsetenv("KASPER_TEST_SASL_PASSWORD")
+	path := writeSettingsFile(t, "config.json", `{
+		"topicProcessorName": "twitter-reach",
+		"brokers": ["localhost:9092"],
+		"inputTopics": ["tweets"],
+		"inputPartitions": [0],
+		"sasl": {"user": "reach", "password": "${KASPER_TEST_SASL_PASSWORD}"}
+	}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	settings, err := ReadSettings(path)
+	assert.Nil(t, err)
+	assert.Equal(t, &SASLSettings{User: "reach", Password: `p"a\ss", "user": "admin`}, settings.SASL)
+}
+
+func TestReadSettings_Invalid(t *testing.T) {
+	path := writeSettingsFile(t, "config.json", `{
+		"kafkaVersion": "3.0.0",
+		"inputPartitions": [0, 0],
+		"batchWaitDuration": "-1s",
+		"maxRequestSize": -1,
+		"stores": {"reach": {"type": "cassandra"}}
+	}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, err := ReadSettings(path)
+	assert.Equal(t, &ConfigError{[]string{
+		"topic processor name is required",
+		"at least one input topic is required",
+		"input partition 0 is listed more than once",
+		"batch wait duration cannot be negative",
+		"at least one broker is required",
+		`unsupported Kafka version "3.0.0" (expected 0.8.2.0 to 2.8.0)`,
+		"max request size cannot be negative",
+		`store reach: unknown type "cassandra" (expected map, redis or elasticsearch)`,
+	}}, err)
+}
+
+func TestReadSettings_UnknownField(t *testing.T) {
+	path := writeSettingsFile(t, "config.json", `{
+		"topicProcessorName": "twitter-reach",
+		"brokers": ["localhost:9092"],
+		"inputTopics": ["tweets"],
+		"inputPartitions": [0],
+		"stores": {"reach": {"type": "redis", "adress": "localhost:6379"}}
+	}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, err := ReadSettings(path)
+	assert.EqualError(t, err, "cannot parse "+path+`: json: unknown field "adress"`)
+}
+
+func TestSettings_Validate_MinBatchSize(t *testing.T) {
+	s := &Settings{
+		TopicProcessorName: "twitter-reach",
+		Brokers:            []string{"localhost:9092"},
+		InputTopics:        []string{"tweets"},
+		InputPartitions:    []int{0},
+		MinBatchSize:       100,
+		TargetBatchLatency: Duration{time.Second},
+	}
+	assert.Nil(t, s.Validate())
+	s.BatchSize = 50
+	assert.Equal(t, &ConfigError{[]string{
+		"MinBatchSize must be between 0 and BatchSize, and TargetBatchLatency cannot be negative",
+	}}, s.Validate())
+}
+
+func TestReadSettings_YAML(t *testing.T) {
+	_, err := ReadSettings("config.yaml")
+	assert.EqualError(t, err, "cannot read config.yaml: YAML configuration files are not supported, use JSON")
+}
+
+func TestDuration_JSON(t *testing.T) {
+	d := Duration{90 * time.Second}
+	data, err := d.MarshalJSON()
+	assert.Nil(t, err)
+	assert.Equal(t, `"1m30s"`, string(data))
+	assert.NotNil(t, d.UnmarshalJSON([]byte(`true`)))
+	assert.NotNil(t, d.UnmarshalJSON([]byte(`"5 seconds"`)))
+}
@@ -0,0 +1,188 @@
+package kasper
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ShardedMap is an in-memory Store that is safe for concurrent use, e.g. to share state between the
+// MessageProcessors of several partitions running in separate goroutines. Keys are spread over shards,
+// each protected by its own read-write lock, so that concurrent calls on different keys rarely contend,
+// unlike a Map wrapped in a SynchronizedStore. Increment, PutIfAbsent and CompareAndSet are atomic.
+type ShardedMap struct {
+	shards []*mapShard
+}
+
+type mapShard struct {
+	mutex sync.RWMutex
+	m     map[string][]byte
+}
+
+// NewShardedMap creates ShardedMap instances with the given number of shards (e.g. a small multiple of
+// GOMAXPROCS) and total initial size.
+func NewShardedMap(shards, size int) *ShardedMap {
+	if shards < 1 {
+		shards = 1
+	}
+	s := &ShardedMap{make([]*mapShard, shards)}
+	for i := range s.shards {
+		s.shards[i] = &mapShard{m: make(map[string][]byte, size/shards)}
+	}
+	return s
+}
+
+func (s *ShardedMap) shard(key string) *mapShard {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return s.shards[hash.Sum32()%uint32(len(s.shards))]
+}
+
+// Get gets a value by key. Returns (nil, nil) if the key is not present.
+func (s *ShardedMap) Get(key string) ([]byte, error) {
+	shard := s.shard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	return shard.m[key], nil
+}
+
+// GetAll returns multiple values by key. The returned map does not contain entries for missing keys.
+func (s *ShardedMap) GetAll(keys []string) (map[string][]byte, error) {
+	kvs := make(map[string][]byte, len(keys))
+	return kvs, s.GetAllInto(keys, kvs)
+}
+
+// GetAllInto adds the values of keys to kvs, see ReusingStore.
+func (s *ShardedMap) GetAllInto(keys []string, kvs map[string][]byte) error {
+	for _, key := range keys {
+		if value, _ := s.Get(key); value != nil {
+			kvs[key] = value
+		}
+	}
+	return nil
+}
+
+// Put inserts or updates a value by key.
+func (s *ShardedMap) Put(key string, value []byte) error {
+	shard := s.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	shard.m[key] = value
+	return nil
+}
+
+// PutAll inserts or updates multiple key-value pairs. Concurrent readers may see some of the pairs before others.
+func (s *ShardedMap) PutAll(kvs map[string][]byte) error {
+	for key, value := range kvs {
+		s.Put(key, value)
+	}
+	return nil
+}
+
+// Delete removes a single value by key. Does not return an error if the key is not present.
+func (s *ShardedMap) Delete(key string) error {
+	shard := s.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	delete(shard.m, key)
+	return nil
+}
+
+// DeleteAll removes multiple values by key. Does not return an error if keys are not present.
+func (s *ShardedMap) DeleteAll(keys []string) error {
+	for _, key := range keys {
+		s.Delete(key)
+	}
+	return nil
+}
+
+// Flush does nothing.
+func (s *ShardedMap) Flush() error {
+	return nil
+}
+
+// update calls fn with the current value of key and stores the value it returns, atomically.
+func (s *ShardedMap) update(key string, fn func(value []byte, found bool) ([]byte, bool, error)) error {
+	shard := s.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	current, found := shard.m[key]
+	value, write, err := fn(current, found)
+	if err == nil && write {
+		shard.m[key] = value
+	}
+	return err
+}
+
+// Increment atomically adds delta to the counter stored at key, see CounterStore.
+func (s *ShardedMap) Increment(key string, delta int64) (int64, error) {
+	var counter int64
+	err := s.update(key, func(value []byte, found bool) ([]byte, bool, error) {
+		var err error
+		if counter, err = parseCounter(key, value); err != nil {
+			return nil, false, err
+		}
+		counter += delta
+		return formatCounter(counter), true, nil
+	})
+	return counter, err
+}
+
+// PutIfAbsent atomically inserts a value if the key does not exist, see ConditionalStore.
+func (s *ShardedMap) PutIfAbsent(key string, value []byte) (bool, error) {
+	return s.CompareAndSet(key, nil, value)
+}
+
+// CompareAndSet atomically updates a value if the current value is equal to expected, see ConditionalStore.
+func (s *ShardedMap) CompareAndSet(key string, expected, value []byte) (bool, error) {
+	var set bool
+	err := s.update(key, func(current []byte, found bool) ([]byte, bool, error) {
+		set = (expected == nil && !found) || (expected != nil && found && bytes.Equal(current, expected))
+		return value, set, nil
+	})
+	return set, err
+}
+
+// Iterate calls fn for each key starting with prefix, in lexicographic order, until fn returns false.
+// It iterates over a snapshot of the keys, so fn can call the ShardedMap.
+func (s *ShardedMap) Iterate(prefix string, fn func(KeyValue) bool) error {
+	var kvs []KeyValue
+	for _, shard := range s.shards {
+		shard.mutex.RLock()
+		for key, value := range shard.m {
+			if strings.HasPrefix(key, prefix) {
+				kvs = append(kvs, KeyValue{key, value})
+			}
+		}
+		shard.mutex.RUnlock()
+	}
+	sort.Sort(keyValuesByKey(kvs))
+	for _, kv := range kvs {
+		if !fn(kv) {
+			return nil
+		}
+	}
+	return nil
+}
+
+type keyValuesByKey []KeyValue
+
+func (kvs keyValuesByKey) Len() int           { return len(kvs) }
+func (kvs keyValuesByKey) Swap(i, j int)      { kvs[i], kvs[j] = kvs[j], kvs[i] }
+func (kvs keyValuesByKey) Less(i, j int) bool { return kvs[i].Key < kvs[j].Key }
+
+// Stats returns the number of keys and the size of the values, see StatsStore.
+func (s *ShardedMap) Stats() (StoreStats, error) {
+	var stats StoreStats
+	for _, shard := range s.shards {
+		shard.mutex.RLock()
+		stats.Keys += int64(len(shard.m))
+		for _, value := range shard.m {
+			stats.Bytes += int64(len(value))
+		}
+		shard.mutex.RUnlock()
+	}
+	return stats, nil
+}
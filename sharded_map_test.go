@@ -0,0 +1,88 @@
+package kasper
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedMap(t *testing.T) {
+	s := NewShardedMap(4, 10)
+	assert.Nil(t, s.PutAll(map[string][]byte{"planet/earth": earth, "planet/mars": mars, "planet/venus": venus, "moon": jupiter}))
+	value, err := s.Get("planet/mars")
+	assert.Nil(t, err)
+	assert.Equal(t, mars, value)
+	kvs, err := s.GetAll([]string{"planet/earth", "pluto"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"planet/earth": earth}, kvs)
+
+	var keys []string
+	assert.Nil(t, s.Iterate("planet/", func(kv KeyValue) bool {
+		keys = append(keys, kv.Key)
+		return true
+	}))
+	assert.Equal(t, []string{"planet/earth", "planet/mars", "planet/venus"}, keys)
+
+	assert.Nil(t, s.DeleteAll([]string{"planet/venus", "pluto"}))
+	stats, err := s.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, StoreStats{Keys: 3, Bytes: 16}, stats)
+
+	set, _ := s.PutIfAbsent("moon", mars)
+	assert.False(t, set)
+	set, _ = s.CompareAndSet("moon", jupiter, saturn)
+	assert.True(t, set)
+	value, _ = s.Get("moon")
+	assert.Equal(t, saturn, value)
+}
+
+func TestShardedMap_ConcurrentIncrement(t *testing.T) {
+	s := NewShardedMap(8, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Increment(fmt.Sprintf("counter-%d", j%10), 1)
+			}
+		}()
+	}
+	wg.Wait()
+	for j := 0; j < 10; j++ {
+		counter, err := s.Increment(fmt.Sprintf("counter-%d", j), 0)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(80), counter)
+	}
+}
+
+func benchmarkConcurrentStore(b *testing.B, s Store) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("planet-%d", i)
+		s.Put(keys[i], earth)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%4 == 0 {
+				s.Put(key, mars)
+			} else {
+				s.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkShardedMap_Parallel(b *testing.B) {
+	benchmarkConcurrentStore(b, NewShardedMap(32, 1024))
+}
+
+func BenchmarkSynchronizedMap_Parallel(b *testing.B) {
+	benchmarkConcurrentStore(b, NewSynchronizedStore(NewMap(1024)))
+}
@@ -0,0 +1,44 @@
+package kasper
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// RunUntilSignalled runs RunLoop until the process receives SIGINT or SIGTERM, and then drains the TopicProcessor
+// (see Drain) so that a rolling deployment neither loses nor reprocesses the messages already received.
+// It returns the error returned by RunLoop, which is nil after a successful drain.
+func (tp *TopicProcessor) RunUntilSignalled() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	return tp.runUntil(signals)
+}
+
+func (tp *TopicProcessor) runUntil(signals <-chan os.Signal) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-signals:
+			tp.logger.Infof("Received %s, draining...", sig)
+			tp.Drain()
+		case <-done:
+		}
+	}()
+	return tp.RunLoop()
+}
+
+// Drain stops consuming messages, processes the messages received so far, and closes the TopicProcessor, which
+// commits the offsets of the processed messages and makes RunLoop return. If processing fails, RunLoop returns
+// the error and the unprocessed messages are consumed again on the next start.
+// It is safe to call Drain from any goroutine.
+func (tp *TopicProcessor) Drain() {
+	if err := tp.Pause(); err == nil {
+		if err := tp.Flush(); err != nil {
+			tp.logger.Errorf("Cannot process pending messages while draining: %s", err)
+		}
+	}
+	tp.Close()
+}
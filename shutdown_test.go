@@ -0,0 +1,30 @@
+package kasper
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicProcessor_RunUntilSignalled(t *testing.T) {
+	processor := &countingProcessor{}
+	tp := newFakeTopicProcessor(&Config{}, processor)
+	signals := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- tp.runUntil(signals)
+	}()
+	waitFor(t, tp.IsRunning)
+
+	tp.send(0, 4, "a")
+	waitFor(t, func() bool { return len(tp.consumers[0].messages) == 0 })
+	time.Sleep(20 * time.Millisecond)
+	signals <- syscall.SIGTERM
+	assert.Nil(t, <-done)
+	assert.Equal(t, 1, processor.processed())
+	offset, _ := tp.offsets[0].NextOffset()
+	assert.Equal(t, int64(5), offset)
+}
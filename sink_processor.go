@@ -0,0 +1,90 @@
+package kasper
+
+import "github.com/Shopify/sarama"
+
+// SinkTransform maps the key and the decoded value of a message to the key and the value written to the store.
+// Returning an empty key skips the message, and returning a nil value deletes the key.
+type SinkTransform func(key string, value interface{}) (string, interface{}, error)
+
+// SinkProcessor is a MessageProcessor which materializes its input topics into a Store without custom code:
+//
+//	sink := kasper.NewSinkProcessor(config.Store("reach"), nil, nil, nil)
+//	messageProcessors := map[int]kasper.MessageProcessor{0: sink, 1: sink}
+//
+// Keys are the message keys, and messages with a nil value (tombstones) delete their key. Each batch is written
+// with Mutate, so it is atomic if the store is transactional, and only the last message of a key in the batch is
+// written. The same SinkProcessor can be used for all partitions.
+type SinkProcessor struct {
+	store      Store
+	inputSerde Serde
+	storeSerde Serde
+	transform  SinkTransform
+}
+
+// NewSinkProcessor creates a SinkProcessor writing to store. If inputSerde is nil, message values are written as is.
+// Otherwise they are decoded with inputSerde, passed to transform (if not nil), and encoded with storeSerde, which
+// defaults to inputSerde.
+func NewSinkProcessor(store Store, inputSerde, storeSerde Serde, transform SinkTransform) *SinkProcessor {
+	if storeSerde == nil {
+		storeSerde = inputSerde
+	}
+	return &SinkProcessor{store, inputSerde, storeSerde, transform}
+}
+
+// Process writes messages to the store.
+func (p *SinkProcessor) Process(messages []*sarama.ConsumerMessage, sender Sender) error {
+	values := make(map[string][]byte, len(messages))
+	keys := make([]string, 0, len(messages))
+	for _, message := range messages {
+		key, value, err := p.convert(message)
+		if err != nil {
+			return err
+		}
+		if key == "" {
+			continue
+		}
+		if _, found := values[key]; !found {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+	var puts []KeyValue
+	var deletes []string
+	for _, key := range keys {
+		if value := values[key]; value != nil {
+			puts = append(puts, KeyValue{key, value})
+		} else {
+			deletes = append(deletes, key)
+		}
+	}
+	return Mutate(p.store, puts, deletes)
+}
+
+func (p *SinkProcessor) convert(message *sarama.ConsumerMessage) (string, []byte, error) {
+	key := string(message.Key)
+	if p.inputSerde == nil {
+		if message.Value == nil {
+			return key, nil, nil
+		}
+		// Values kept in the store would otherwise keep the whole fetch response in memory, see MessageProcessor
+		return key, append([]byte(nil), message.Value...), nil
+	}
+	var value interface{}
+	if message.Value != nil {
+		var err error
+		if value, err = p.inputSerde.Deserialize(message.Value); err != nil {
+			return "", nil, err
+		}
+	}
+	if p.transform != nil {
+		var err error
+		if key, value, err = p.transform(key, value); err != nil {
+			return "", nil, err
+		}
+	}
+	if value == nil {
+		return key, nil, nil
+	}
+	data, err := p.storeSerde.Serialize(value)
+	return key, data, err
+}
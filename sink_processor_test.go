@@ -0,0 +1,54 @@
+package kasper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func sinkMessages(kvs ...string) []*sarama.ConsumerMessage {
+	var messages []*sarama.ConsumerMessage
+	for i := 0; i < len(kvs); i += 2 {
+		message := &sarama.ConsumerMessage{Key: []byte(kvs[i])}
+		if kvs[i+1] != "" {
+			message.Value = []byte(kvs[i+1])
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestSinkProcessor(t *testing.T) {
+	store := NewMap(10)
+	store.Put("pluto", []byte("planet"))
+	sink := NewSinkProcessor(store, nil, nil, nil)
+	messages := sinkMessages("mars", "red", "venus", "yellow", "pluto", "", "mars", "rusty")
+	assert.Nil(t, sink.Process(messages, nil))
+	assert.Equal(t, map[string][]byte{"mars": []byte("rusty"), "venus": []byte("yellow")}, store.m)
+	messages[3].Value[0] = 'm'
+	assert.Equal(t, []byte("yellow"), store.m["venus"])
+}
+
+func TestSinkProcessor_Transform(t *testing.T) {
+	store := NewMap(10)
+	serde := NewJSONSerde(func() interface{} { return &serdeTestPlanet{} })
+	sink := NewSinkProcessor(store, serde, nil, func(key string, value interface{}) (string, interface{}, error) {
+		planet := value.(*serdeTestPlanet)
+		if planet.Moons == 0 {
+			return "", nil, nil
+		}
+		return "planet/" + planet.Name, planet, nil
+	})
+	messages := sinkMessages("4", `{"name":"mars","moons":2}`, "2", `{"name":"venus","moons":0}`)
+	assert.Nil(t, sink.Process(messages, nil))
+	assert.Equal(t, map[string][]byte{"planet/mars": []byte(`{"name":"mars","moons":2}`)}, store.m)
+
+	assert.NotNil(t, sink.Process(sinkMessages("5", "{"), nil))
+
+	sink = NewSinkProcessor(store, serde, nil, func(key string, value interface{}) (string, interface{}, error) {
+		return "", nil, errors.New("transform failed")
+	})
+	assert.EqualError(t, sink.Process(messages, nil), "transform failed")
+}
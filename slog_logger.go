@@ -0,0 +1,121 @@
+package kasper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// NewSlogLogger adapts a log/slog Logger to StructuredLogger. Panic and Panicf log at error level and then panic.
+func NewSlogLogger(logger *slog.Logger) StructuredLogger {
+	return &slogLogger{logger}
+}
+
+// NewSlogFanoutLogger creates a StructuredLogger that sends every entry to all handlers, e.g. to stderr and to
+// OpenTelemetry with the otelslog bridge (see https://pkg.go.dev/go.opentelemetry.io/contrib/bridges/otelslog):
+//
+//	logger := kasper.NewSlogFanoutLogger(
+//		slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}),
+//		otelslog.NewHandler("kasper", otelslog.WithLoggerProvider(loggerProvider)),
+//	)
+//
+// Each handler filters entries according to its own level.
+func NewSlogFanoutLogger(handlers ...slog.Handler) StructuredLogger {
+	return NewSlogLogger(slog.New(fanoutHandler(handlers)))
+}
+
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var firstErr error
+	for _, handler := range h {
+		if !handler.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, record.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogLogger) With(fields ...Field) StructuredLogger {
+	args := make([]any, 0, 2*len(fields))
+	for _, field := range fields {
+		args = append(args, field.Key, field.Value)
+	}
+	return &slogLogger{l.logger.With(args...)}
+}
+
+func (l *slogLogger) log(level slog.Level, message string) {
+	l.logger.Log(context.Background(), level, message)
+}
+
+func (l *slogLogger) Debug(vs ...interface{}) {
+	if l.logger.Enabled(context.Background(), slog.LevelDebug) {
+		l.log(slog.LevelDebug, fmt.Sprint(vs...))
+	}
+}
+
+func (l *slogLogger) Debugf(format string, vs ...interface{}) {
+	if l.logger.Enabled(context.Background(), slog.LevelDebug) {
+		l.log(slog.LevelDebug, fmt.Sprintf(format, vs...))
+	}
+}
+
+func (l *slogLogger) Info(vs ...interface{}) {
+	l.log(slog.LevelInfo, fmt.Sprint(vs...))
+}
+
+func (l *slogLogger) Infof(format string, vs ...interface{}) {
+	l.log(slog.LevelInfo, fmt.Sprintf(format, vs...))
+}
+
+func (l *slogLogger) Error(vs ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprint(vs...))
+}
+
+func (l *slogLogger) Errorf(format string, vs ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprintf(format, vs...))
+}
+
+func (l *slogLogger) Panic(vs ...interface{}) {
+	message := fmt.Sprint(vs...)
+	l.log(slog.LevelError, message)
+	panic(message)
+}
+
+func (l *slogLogger) Panicf(format string, vs ...interface{}) {
+	message := fmt.Sprintf(format, vs...)
+	l.log(slog.LevelError, message)
+	panic(message)
+}
@@ -0,0 +1,46 @@
+package kasper
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func removeTime(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.TimeKey && len(groups) == 0 {
+		return slog.Attr{}
+	}
+	return attr
+}
+
+func TestSlogLogger(t *testing.T) {
+	testLogger(t, NewSlogLogger(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))))
+
+	var buffer bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buffer, &slog.HandlerOptions{ReplaceAttr: removeTime})))
+	logger.With(Field{"topic", "words"}).Infof("processed %d messages", 10)
+	logger.Debug("not logged")
+	assert.Equal(t, "level=INFO msg=\"processed 10 messages\" topic=words\n", buffer.String())
+}
+
+func TestSlogLogger_DebugDisabled(t *testing.T) {
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	arg := &countingStringer{}
+	logger.Debug(arg)
+	logger.Debugf("offset %s", arg)
+	assert.Equal(t, 0, arg.count)
+}
+
+func TestSlogFanoutLogger(t *testing.T) {
+	var text, json bytes.Buffer
+	logger := NewSlogFanoutLogger(
+		slog.NewTextHandler(&text, &slog.HandlerOptions{ReplaceAttr: removeTime}),
+		slog.NewJSONHandler(&json, &slog.HandlerOptions{Level: slog.LevelError, ReplaceAttr: removeTime}),
+	).With(Field{"partition", 3})
+	logger.Info("assigned")
+	logger.Error("failed")
+	assert.Equal(t, "level=INFO msg=assigned partition=3\nlevel=ERROR msg=failed partition=3\n", text.String())
+	assert.Equal(t, "{\"level\":\"ERROR\",\"msg\":\"failed\",\"partition\":3}\n", json.String())
+}
@@ -0,0 +1,77 @@
+package kasper
+
+// SlowConsumerReason describes why a TopicProcessor was detected as a slow consumer.
+type SlowConsumerReason string
+
+const (
+	// SlowConsumerLowRate means fewer than Config.SlowConsumerRateThreshold messages per second were received
+	// while messages remained to be consumed.
+	SlowConsumerLowRate SlowConsumerReason = "low_rate"
+	// SlowConsumerGrowingLag means the number of messages behind the high water mark has grown for at least
+	// Config.SlowConsumerLagIntervals consecutive metrics update intervals.
+	SlowConsumerGrowingLag SlowConsumerReason = "growing_lag"
+)
+
+// SlowConsumerEvent is passed to Config.OnSlowConsumer.
+type SlowConsumerEvent struct {
+	Reason SlowConsumerReason
+	// Number of consecutive metrics update intervals for which the condition held
+	Intervals int
+	// Runtime metrics at the time of detection
+	Snapshot Snapshot
+}
+
+type slowConsumerDetector struct {
+	rateThreshold  float64
+	lagIntervals   int
+	callback       func(SlowConsumerEvent)
+	lowRateCount   int
+	lagGrowthCount int
+	previousLag    int64
+	hasPrevious    bool
+}
+
+func newSlowConsumerDetector(config *Config) *slowConsumerDetector {
+	return &slowConsumerDetector{
+		rateThreshold: config.SlowConsumerRateThreshold,
+		lagIntervals:  config.SlowConsumerLagIntervals,
+		callback:      config.OnSlowConsumer,
+	}
+}
+
+// check is called once per metrics update interval, and calls the callback on every interval
+// for which a slow consumer condition holds.
+func (d *slowConsumerDetector) check(snapshot Snapshot) {
+	if d.callback == nil {
+		return
+	}
+	lag := totalMessagesBehindHighWaterMark(snapshot)
+
+	if d.rateThreshold > 0 && lag > 0 && snapshot.IncomingMessageRate < d.rateThreshold {
+		d.lowRateCount++
+		d.callback(SlowConsumerEvent{SlowConsumerLowRate, d.lowRateCount, snapshot})
+	} else {
+		d.lowRateCount = 0
+	}
+
+	if d.hasPrevious && lag > d.previousLag {
+		d.lagGrowthCount++
+	} else {
+		d.lagGrowthCount = 0
+	}
+	d.previousLag = lag
+	d.hasPrevious = true
+	if d.lagIntervals > 0 && d.lagGrowthCount >= d.lagIntervals {
+		d.callback(SlowConsumerEvent{SlowConsumerGrowingLag, d.lagGrowthCount, snapshot})
+	}
+}
+
+func totalMessagesBehindHighWaterMark(snapshot Snapshot) int64 {
+	var total int64
+	for _, partitions := range snapshot.MessagesBehindHighWaterMark {
+		for _, count := range partitions {
+			total += count
+		}
+	}
+	return total
+}
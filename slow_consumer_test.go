@@ -0,0 +1,79 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSlowConsumerSnapshot(rate float64, lag int64) Snapshot {
+	return Snapshot{
+		IncomingMessageRate:         rate,
+		MessagesBehindHighWaterMark: map[string]map[int]int64{"words": {0: lag / 2, 1: lag - lag/2}},
+	}
+}
+
+func TestSlowConsumerDetector_LowRate(t *testing.T) {
+	var events []SlowConsumerEvent
+	d := newSlowConsumerDetector(&Config{
+		SlowConsumerRateThreshold: 100,
+		OnSlowConsumer:            func(e SlowConsumerEvent) { events = append(events, e) },
+	})
+
+	d.check(newSlowConsumerSnapshot(50, 0))
+	assert.Equal(t, 0, len(events), "a low rate is expected when there is nothing to consume")
+
+	d.check(newSlowConsumerSnapshot(50, 1000))
+	d.check(newSlowConsumerSnapshot(50, 900))
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, SlowConsumerLowRate, events[1].Reason)
+	assert.Equal(t, 2, events[1].Intervals)
+	assert.Equal(t, 50.0, events[1].Snapshot.IncomingMessageRate)
+
+	d.check(newSlowConsumerSnapshot(500, 400))
+	d.check(newSlowConsumerSnapshot(50, 300))
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, 1, events[2].Intervals)
+}
+
+func TestSlowConsumerDetector_GrowingLag(t *testing.T) {
+	var events []SlowConsumerEvent
+	d := newSlowConsumerDetector(&Config{
+		SlowConsumerLagIntervals: 2,
+		OnSlowConsumer:           func(e SlowConsumerEvent) { events = append(events, e) },
+	})
+
+	d.check(newSlowConsumerSnapshot(1000, 100))
+	d.check(newSlowConsumerSnapshot(1000, 200))
+	assert.Equal(t, 0, len(events))
+	d.check(newSlowConsumerSnapshot(1000, 300))
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, SlowConsumerGrowingLag, events[0].Reason)
+	assert.Equal(t, 2, events[0].Intervals)
+	d.check(newSlowConsumerSnapshot(1000, 400))
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, 3, events[1].Intervals)
+
+	d.check(newSlowConsumerSnapshot(1000, 400))
+	d.check(newSlowConsumerSnapshot(1000, 500))
+	assert.Equal(t, 2, len(events))
+}
+
+func TestSlowConsumerDetector_Disabled(t *testing.T) {
+	var events []SlowConsumerEvent
+	d := newSlowConsumerDetector(&Config{
+		OnSlowConsumer: func(e SlowConsumerEvent) { events = append(events, e) },
+	})
+	d.check(newSlowConsumerSnapshot(1, 100))
+	d.check(newSlowConsumerSnapshot(1, 200))
+	d.check(newSlowConsumerSnapshot(1, 300))
+	assert.Empty(t, events, "both checks are disabled by default")
+
+	// Without OnSlowConsumer, nothing is tracked
+	d = newSlowConsumerDetector(&Config{SlowConsumerRateThreshold: 100, SlowConsumerLagIntervals: 1})
+	d.check(newSlowConsumerSnapshot(1, 100))
+	d.check(newSlowConsumerSnapshot(1, 200))
+	assert.Equal(t, 0, d.lowRateCount)
+	assert.Equal(t, 0, d.lagGrowthCount)
+	assert.False(t, d.hasPrevious)
+}
@@ -0,0 +1,203 @@
+/*
+Package smoketest verifies that a deployed Kasper application can reach its dependencies, and prints a readiness
+report that can be used as a deployment gate.
+
+	report := smoketest.Run(config, smoketest.Options{
+		Serdes: map[string]kasper.Serde{"tweets": tweetSerde},
+		Stores: map[string]kasper.Store{"elasticsearch": store},
+	})
+	fmt.Print(report)
+	if !report.OK() {
+		os.Exit(1)
+	}
+*/
+package smoketest
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+)
+
+// ProbeKey is the key read from stores to check that they are reachable. It does not need to exist.
+const ProbeKey = "kasper-smoke-test"
+
+// Options configures the checks made by Run in addition to broker connectivity and topic existence.
+type Options struct {
+	// Serdes by topic. The last SampleSize messages of every input partition of these topics must deserialize.
+	Serdes map[string]kasper.Serde
+	// Number of messages sampled per partition, defaults to 10
+	SampleSize int
+	// Stores by name. Each store must answer a Get of ProbeKey without error.
+	Stores map[string]kasper.Store
+	// Maximum time spent waiting for sampled messages, per partition. Defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+// Check is the result of a single check.
+type Check struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// Report is the result of Run.
+type Report struct {
+	Checks []Check
+}
+
+// OK returns true if all checks passed.
+func (r *Report) OK() bool {
+	for _, check := range r.Checks {
+		if !check.OK {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) String() string {
+	var buffer bytes.Buffer
+	for _, check := range r.Checks {
+		status := "OK  "
+		if !check.OK {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&buffer, "[%s] %s", status, check.Name)
+		if check.Detail != "" {
+			fmt.Fprintf(&buffer, ": %s", check.Detail)
+		}
+		buffer.WriteString("\n")
+	}
+	if r.OK() {
+		buffer.WriteString("READY\n")
+	} else {
+		buffer.WriteString("NOT READY\n")
+	}
+	return buffer.String()
+}
+
+func (r *Report) add(name string, err error, detail string) bool {
+	if err != nil {
+		r.Checks = append(r.Checks, Check{name, false, err.Error()})
+		return false
+	}
+	r.Checks = append(r.Checks, Check{name, true, detail})
+	return true
+}
+
+// Run checks that the brokers of config.Client are reachable, that all input topics and partitions exist,
+// that sampled messages can be deserialized and that stores are reachable.
+func Run(config *kasper.Config, opts Options) *Report {
+	if opts.SampleSize == 0 {
+		opts.SampleSize = 10
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	report := &Report{}
+	client := config.Client
+	if report.add("brokers", client.RefreshMetadata(config.InputTopics...), fmt.Sprintf("%d brokers", len(client.Brokers()))) {
+		for _, topic := range config.InputTopics {
+			if report.add("topic "+topic, checkTopic(client, topic, config.InputPartitions), "") {
+				if serde, found := opts.Serdes[topic]; found {
+					count, err := checkSerde(client, topic, config.InputPartitions, serde, opts)
+					report.add("serde "+topic, err, fmt.Sprintf("%d messages sampled", count))
+				}
+			}
+		}
+	}
+	for name, store := range opts.Stores {
+		start := time.Now()
+		_, err := store.Get(ProbeKey)
+		report.add("store "+name, err, fmt.Sprintf("responded in %s", time.Since(start)))
+	}
+	return report
+}
+
+func checkTopic(client sarama.Client, topic string, partitions []int) error {
+	available, err := client.Partitions(topic)
+	if err != nil {
+		return err
+	}
+	existing := make(map[int32]bool, len(available))
+	for _, partition := range available {
+		existing[partition] = true
+	}
+	for _, partition := range partitions {
+		if !existing[int32(partition)] {
+			return fmt.Errorf("partition %d does not exist (topic has %d partitions)", partition, len(available))
+		}
+	}
+	return nil
+}
+
+func checkSerde(client sarama.Client, topic string, partitions []int, serde kasper.Serde, opts Options) (int, error) {
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return 0, err
+	}
+	defer consumer.Close()
+	count := 0
+	for _, partition := range partitions {
+		messages, err := sample(client, consumer, topic, int32(partition), opts)
+		if err != nil {
+			return count, err
+		}
+		err = deserializeAll(messages, serde)
+		if err != nil {
+			return count, err
+		}
+		count += len(messages)
+	}
+	return count, nil
+}
+
+func sample(client sarama.Client, consumer sarama.Consumer, topic string, partition int32, opts Options) ([]*sarama.ConsumerMessage, error) {
+	oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return nil, err
+	}
+	newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return nil, err
+	}
+	start := newest - int64(opts.SampleSize)
+	if start < oldest {
+		start = oldest
+	}
+	if start >= newest {
+		return nil, nil
+	}
+	partitionConsumer, err := consumer.ConsumePartition(topic, partition, start)
+	if err != nil {
+		return nil, err
+	}
+	defer partitionConsumer.Close()
+	timeout := time.After(opts.Timeout)
+	var messages []*sarama.ConsumerMessage
+	for {
+		select {
+		case message := <-partitionConsumer.Messages():
+			messages = append(messages, message)
+			if message.Offset >= newest-1 {
+				return messages, nil
+			}
+		case <-timeout:
+			return messages, fmt.Errorf("timed out sampling partition %d", partition)
+		}
+	}
+}
+
+func deserializeAll(messages []*sarama.ConsumerMessage, serde kasper.Serde) error {
+	for _, message := range messages {
+		_, err := serde.Deserialize(message.Value)
+		if err != nil {
+			return fmt.Errorf("message at offset %d of partition %d: %s", message.Offset, message.Partition, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package smoketest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/movio/kasper"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClient struct {
+	sarama.Client
+	partitions map[string][]int32
+}
+
+func (c *fakeClient) RefreshMetadata(topics ...string) error {
+	return nil
+}
+
+func (c *fakeClient) Brokers() []*sarama.Broker {
+	return []*sarama.Broker{sarama.NewBroker("localhost:9092")}
+}
+
+func (c *fakeClient) Partitions(topic string) ([]int32, error) {
+	partitions, found := c.partitions[topic]
+	if !found {
+		return nil, sarama.ErrUnknownTopicOrPartition
+	}
+	return partitions, nil
+}
+
+type failingStore struct {
+	kasper.Store
+}
+
+func (failingStore) Get(key string) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestRun(t *testing.T) {
+	config := &kasper.Config{
+		Client:          &fakeClient{partitions: map[string][]int32{"tweets": {0, 1}, "users": {0}}},
+		InputTopics:     []string{"tweets", "users", "missing"},
+		InputPartitions: []int{0, 1},
+	}
+	report := Run(config, Options{Stores: map[string]kasper.Store{
+		"map":   kasper.NewMap(10),
+		"redis": failingStore{},
+	}})
+	assert.False(t, report.OK())
+	checks := make(map[string]bool)
+	for _, check := range report.Checks {
+		checks[check.Name] = check.OK
+	}
+	assert.Equal(t, map[string]bool{
+		"brokers":       true,
+		"topic tweets":  true,
+		"topic users":   false,
+		"topic missing": false,
+		"store map":     true,
+		"store redis":   false,
+	}, checks)
+	assert.Contains(t, report.String(), "[FAIL] store redis: connection refused\n")
+	assert.Contains(t, report.String(), "NOT READY\n")
+}
+
+func TestDeserializeAll(t *testing.T) {
+	serde := kasper.NewJSONSerde(func() interface{} { return &map[string]interface{}{} })
+	messages := []*sarama.ConsumerMessage{
+		{Partition: 0, Offset: 1, Value: []byte(`{"a":1}`)},
+		{Partition: 0, Offset: 2, Value: []byte(`{"a":`)},
+	}
+	assert.Nil(t, deserializeAll(messages[:1], serde))
+	assert.Contains(t, deserializeAll(messages, serde).Error(), "message at offset 2 of partition 0")
+}
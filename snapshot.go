@@ -0,0 +1,192 @@
+package kasper
+
+import (
+	"sync"
+	"time"
+)
+
+// Snapshot is a point-in-time view of the runtime metrics of a TopicProcessor and of the stores created with
+// the same Config. Snapshots are maintained independently of the MetricsProvider, so they can be embedded in
+// application health endpoints even when metrics are disabled.
+type Snapshot struct {
+	// Time at which the snapshot was taken
+	Timestamp time.Time
+	// Number of messages received since the TopicProcessor was created
+	IncomingMessageCount int64
+	// Number of messages produced since the TopicProcessor was created
+	OutgoingMessageCount int64
+	// Messages received per second over the last metrics update interval
+	IncomingMessageRate float64
+	// Messages produced per second over the last metrics update interval
+	OutgoingMessageRate float64
+	// Number of messages remaining to consume by topic and partition, as of the last metrics update
+	MessagesBehindHighWaterMark map[string]map[int]int64
+	// Latency of store operations over the last metrics update interval, by operation (e.g. "Elasticsearch.GetAll")
+	StoreLatencies map[string]LatencyStats
+	// Number of errors since the TopicProcessor was created, by kind ("process", "produce", "store" or "metrics")
+	ErrorCounts map[string]int64
+}
+
+// LatencyStats summarizes the latency of a set of operations.
+type LatencyStats struct {
+	Count int64
+	Mean  time.Duration
+	Max   time.Duration
+}
+
+type latencyWindow struct {
+	current LatencyStats
+	total   time.Duration
+	last    LatencyStats
+}
+
+type runtimeStats struct {
+	clock           Clock
+	mutex           sync.Mutex
+	incomingCount   int64
+	outgoingCount   int64
+	incomingRate    float64
+	outgoingRate    float64
+	lastTick        time.Time
+	lastIncoming    int64
+	lastOutgoing    int64
+	behindHighWater map[string]map[int]int64
+	storeLatencies  map[string]*latencyWindow
+	errorCounts     map[string]int64
+	storeCounts     map[string]int
+}
+
+func newRuntimeStats(clock Clock) *runtimeStats {
+	return &runtimeStats{
+		clock:           clock,
+		lastTick:        clock.Now(),
+		behindHighWater: make(map[string]map[int]int64),
+		storeLatencies:  make(map[string]*latencyWindow),
+		errorCounts:     make(map[string]int64),
+		storeCounts:     make(map[string]int),
+	}
+}
+
+// addStore records a store created with the Config, by backend (e.g. "Redis").
+func (stats *runtimeStats) addStore(backend string) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stats.storeCounts[backend]++
+}
+
+func (stats *runtimeStats) stores() map[string]int {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stores := make(map[string]int, len(stats.storeCounts))
+	for backend, count := range stats.storeCounts {
+		stores[backend] = count
+	}
+	return stores
+}
+
+func (stats *runtimeStats) addIncoming(count int) {
+	stats.mutex.Lock()
+	stats.incomingCount += int64(count)
+	stats.mutex.Unlock()
+}
+
+func (stats *runtimeStats) addOutgoing(count int) {
+	stats.mutex.Lock()
+	stats.outgoingCount += int64(count)
+	stats.mutex.Unlock()
+}
+
+func (stats *runtimeStats) addError(kind string) {
+	stats.mutex.Lock()
+	stats.errorCounts[kind]++
+	stats.mutex.Unlock()
+}
+
+func (stats *runtimeStats) setMessagesBehindHighWaterMark(topic string, partition int, count int64) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	partitions, found := stats.behindHighWater[topic]
+	if !found {
+		partitions = make(map[int]int64)
+		stats.behindHighWater[topic] = partitions
+	}
+	partitions[partition] = count
+}
+
+// now returns the current time according to the clock of the Config.
+func (stats *runtimeStats) now() time.Time {
+	return stats.clock.Now()
+}
+
+// observeStoreOperation is meant to be deferred at the start of a store operation:
+//
+//	defer s.stats.observeStoreOperation("Redis.Get", s.stats.now(), &err)
+func (stats *runtimeStats) observeStoreOperation(operation string, start time.Time, err *error) {
+	latency := stats.clock.Now().Sub(start)
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	window, found := stats.storeLatencies[operation]
+	if !found {
+		window = &latencyWindow{}
+		stats.storeLatencies[operation] = window
+	}
+	window.current.Count++
+	window.total += latency
+	if latency > window.current.Max {
+		window.current.Max = latency
+	}
+	if err != nil && *err != nil {
+		stats.errorCounts["store"]++
+	}
+}
+
+// tick closes the current metrics update interval
+func (stats *runtimeStats) tick(now time.Time) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	elapsed := now.Sub(stats.lastTick).Seconds()
+	if elapsed > 0 {
+		stats.incomingRate = float64(stats.incomingCount-stats.lastIncoming) / elapsed
+		stats.outgoingRate = float64(stats.outgoingCount-stats.lastOutgoing) / elapsed
+	}
+	stats.lastTick = now
+	stats.lastIncoming = stats.incomingCount
+	stats.lastOutgoing = stats.outgoingCount
+	for _, window := range stats.storeLatencies {
+		window.last = window.current
+		if window.current.Count > 0 {
+			window.last.Mean = window.total / time.Duration(window.current.Count)
+		}
+		window.current = LatencyStats{}
+		window.total = 0
+	}
+}
+
+func (stats *runtimeStats) snapshot(now time.Time) Snapshot {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	snapshot := Snapshot{
+		Timestamp:                   now,
+		IncomingMessageCount:        stats.incomingCount,
+		OutgoingMessageCount:        stats.outgoingCount,
+		IncomingMessageRate:         stats.incomingRate,
+		OutgoingMessageRate:         stats.outgoingRate,
+		MessagesBehindHighWaterMark: make(map[string]map[int]int64, len(stats.behindHighWater)),
+		StoreLatencies:              make(map[string]LatencyStats, len(stats.storeLatencies)),
+		ErrorCounts:                 make(map[string]int64, len(stats.errorCounts)),
+	}
+	for topic, partitions := range stats.behindHighWater {
+		copied := make(map[int]int64, len(partitions))
+		for partition, count := range partitions {
+			copied[partition] = count
+		}
+		snapshot.MessagesBehindHighWaterMark[topic] = copied
+	}
+	for operation, window := range stats.storeLatencies {
+		snapshot.StoreLatencies[operation] = window.last
+	}
+	for kind, count := range stats.errorCounts {
+		snapshot.ErrorCounts[kind] = count
+	}
+	return snapshot
+}
@@ -0,0 +1,52 @@
+package kasper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeStats_Snapshot(t *testing.T) {
+	start := time.Unix(1000, 0)
+	stats := newRuntimeStats(&fixedClock{now: start})
+	stats.addIncoming(100)
+	stats.addOutgoing(50)
+	stats.addError("process")
+	stats.setMessagesBehindHighWaterMark("words", 3, 1000)
+
+	err := errors.New("store is down")
+	stats.observeStoreOperation("Redis.Get", start.Add(-10*time.Millisecond), &err)
+	var noErr error
+	stats.observeStoreOperation("Redis.Get", start.Add(-30*time.Millisecond), &noErr)
+
+	// Rates and latencies are only available once the interval is closed
+	snapshot := stats.snapshot(start)
+	assert.Equal(t, int64(100), snapshot.IncomingMessageCount)
+	assert.Equal(t, int64(50), snapshot.OutgoingMessageCount)
+	assert.Equal(t, 0.0, snapshot.IncomingMessageRate)
+	assert.Equal(t, int64(0), snapshot.StoreLatencies["Redis.Get"].Count)
+
+	stats.tick(start.Add(10 * time.Second))
+	snapshot = stats.snapshot(start.Add(10 * time.Second))
+	assert.Equal(t, 10.0, snapshot.IncomingMessageRate)
+	assert.Equal(t, 5.0, snapshot.OutgoingMessageRate)
+	assert.Equal(t, int64(1000), snapshot.MessagesBehindHighWaterMark["words"][3])
+	assert.Equal(t, map[string]int64{"process": 1, "store": 1}, snapshot.ErrorCounts)
+	latency := snapshot.StoreLatencies["Redis.Get"]
+	assert.Equal(t, int64(2), latency.Count)
+	assert.Equal(t, 30*time.Millisecond, latency.Max)
+	assert.Equal(t, 20*time.Millisecond, latency.Mean)
+
+	// Snapshots are copies
+	snapshot.MessagesBehindHighWaterMark["words"][3] = 0
+	snapshot.ErrorCounts["process"] = 0
+	assert.Equal(t, int64(1000), stats.snapshot(start).MessagesBehindHighWaterMark["words"][3])
+	assert.Equal(t, int64(1), stats.snapshot(start).ErrorCounts["process"])
+
+	stats.tick(start.Add(20 * time.Second))
+	snapshot = stats.snapshot(start.Add(20 * time.Second))
+	assert.Equal(t, 0.0, snapshot.IncomingMessageRate)
+	assert.Equal(t, int64(0), snapshot.StoreLatencies["Redis.Get"].Count)
+}
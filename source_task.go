@@ -0,0 +1,136 @@
+package kasper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// SourceTask reads records from an external system, such as an HTTP API or a database query, to produce them to
+// Kafka with a SourceRunner.
+type SourceTask interface {
+	// Poll returns the messages to produce for the records after cursor, and the cursor of the last record returned.
+	// cursor is empty on the first call. Poll returns no messages when there are no new records.
+	Poll(cursor string) (messages []*sarama.ProducerMessage, next string, err error)
+}
+
+// SourceRunner polls a SourceTask at a regular interval and produces the messages it returns. The cursor is kept
+// in a Store and saved after the messages are produced, so records are produced at least once: if the process
+// stops in between, the same records are polled and produced again after a restart.
+type SourceRunner struct {
+	config    *Config
+	task      SourceTask
+	producer  sarama.SyncProducer
+	store     Store
+	cursorKey string
+	interval  time.Duration
+	logger    Logger
+	close     chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+
+	pollCounter      Counter
+	messageCounter   Counter
+	pollErrorCounter Counter
+}
+
+// NewSourceRunner creates a SourceRunner which polls task every interval and keeps its cursor in store under
+// cursorKey. Messages are produced with a producer created from config.Client.
+func NewSourceRunner(config *Config, task SourceTask, store Store, cursorKey string, interval time.Duration) (*SourceRunner, error) {
+	producer, err := sarama.NewSyncProducerFromClient(config.Client)
+	if err != nil {
+		return nil, err
+	}
+	return newSourceRunner(config, producer, task, store, cursorKey, interval), nil
+}
+
+func newSourceRunner(config *Config, producer sarama.SyncProducer, task SourceTask, store Store, cursorKey string, interval time.Duration) *SourceRunner {
+	metrics := config.metricsProvider()
+	return &SourceRunner{
+		config,
+		task,
+		producer,
+		store,
+		cursorKey,
+		interval,
+		WithFields(config.logger(), Field{"source", cursorKey}),
+		make(chan struct{}),
+		sync.Once{},
+		make(chan struct{}),
+		metrics.NewCounter("source_poll_count", "Number of polls of the source task", "source"),
+		metrics.NewCounter("source_message_count", "Number of messages produced from the source task", "source"),
+		metrics.NewCounter("source_poll_error_count", "Number of failed polls of the source task", "source"),
+	}
+}
+
+// Run polls the task until Close is called. Errors returned by Poll are logged and the poll is retried on the next
+// interval. Run returns the first error producing the messages or saving the cursor, after closing the producer.
+func (r *SourceRunner) Run() (err error) {
+	defer close(r.done)
+	defer func() {
+		if closeErr := r.producer.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	value, err := r.store.Get(r.cursorKey)
+	if err != nil {
+		return err
+	}
+	cursor := string(value)
+	ticker := r.config.clock().NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		next, produced, err := r.poll(cursor)
+		if err != nil {
+			return err
+		}
+		cursor = next
+		if produced {
+			// Poll again right away until the task has caught up
+			select {
+			case <-r.close:
+				return nil
+			default:
+				continue
+			}
+		}
+		select {
+		case <-ticker.Chan():
+		case <-r.close:
+			return nil
+		}
+	}
+}
+
+// poll produces the messages after cursor and saves the next cursor. It returns true if messages were produced.
+func (r *SourceRunner) poll(cursor string) (string, bool, error) {
+	r.pollCounter.Inc(r.cursorKey)
+	messages, next, err := r.task.Poll(cursor)
+	if err != nil {
+		r.logger.Errorf("Failed to poll source: %s", err)
+		r.pollErrorCounter.Inc(r.cursorKey)
+		return cursor, false, nil
+	}
+	if len(messages) == 0 {
+		return cursor, false, nil
+	}
+	if err := r.producer.SendMessages(messages); err != nil {
+		r.logger.Errorf("Failed to produce messages: %s", err)
+		return cursor, false, err
+	}
+	r.messageCounter.Add(float64(len(messages)), r.cursorKey)
+	if err := r.store.Put(r.cursorKey, []byte(next)); err != nil {
+		return cursor, false, err
+	}
+	if err := r.store.Flush(); err != nil {
+		return cursor, false, err
+	}
+	return next, true, nil
+}
+
+// Close stops Run and waits for it to return. It must only be called after Run was started.
+func (r *SourceRunner) Close() {
+	r.closeOnce.Do(func() { close(r.close) })
+	<-r.done
+}
@@ -0,0 +1,84 @@
+package kasper
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// planetSource returns one record per poll until the planets run out, failing once on the second poll
+type planetSource struct {
+	mutex   sync.Mutex
+	planets []string
+	cursors []string
+}
+
+func (s *planetSource) Poll(cursor string) ([]*sarama.ProducerMessage, string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cursors = append(s.cursors, cursor)
+	if len(s.cursors) == 2 {
+		return nil, "", errors.New("source unavailable")
+	}
+	next := 0
+	if cursor != "" {
+		next, _ = strconv.Atoi(cursor)
+	}
+	if next >= len(s.planets) {
+		return nil, cursor, nil
+	}
+	message := &sarama.ProducerMessage{Topic: "planets", Value: sarama.StringEncoder(s.planets[next])}
+	return []*sarama.ProducerMessage{message}, strconv.Itoa(next + 1), nil
+}
+
+func (s *planetSource) polls() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.cursors...)
+}
+
+func TestSourceRunner(t *testing.T) {
+	store := NewSynchronizedStore(NewMap(10))
+	store.Put("planets-cursor", []byte("1"))
+	task := &planetSource{planets: []string{"mercury", "venus", "earth", "mars"}}
+	producer := &recordingSyncProducer{}
+	provider := newRecordingMetricsProvider()
+	config := &Config{Logger: &noopLogger{}, MetricsProvider: provider}
+	runner := newSourceRunner(config, producer, task, store, "planets-cursor", time.Millisecond)
+	done := make(chan error)
+	go func() { done <- runner.Run() }()
+	waitFor(t, func() bool { return len(task.polls()) >= 6 })
+	runner.Close()
+	assert.Nil(t, <-done)
+
+	assert.Equal(t, []string{"1", "2", "2", "3", "4", "4"}, task.polls()[:6])
+	var values []string
+	for _, message := range producer.messages {
+		values = append(values, string(message.Value.(sarama.StringEncoder)))
+	}
+	assert.Equal(t, []string{"venus", "earth", "mars"}, values)
+	cursor, _ := store.Get("planets-cursor")
+	assert.Equal(t, []byte("4"), cursor)
+}
+
+type failingSyncProducer struct {
+	recordingSyncProducer
+}
+
+func (p *failingSyncProducer) SendMessages(messages []*sarama.ProducerMessage) error {
+	return errors.New("broker unavailable")
+}
+
+func TestSourceRunner_ProduceError(t *testing.T) {
+	store := NewMap(10)
+	task := &planetSource{planets: []string{"mercury"}}
+	runner := newSourceRunner(&Config{Logger: &noopLogger{}}, &failingSyncProducer{}, task, store, "planets-cursor", time.Hour)
+	assert.EqualError(t, runner.Run(), "broker unavailable")
+	cursor, _ := store.Get("planets-cursor")
+	assert.Nil(t, cursor)
+}
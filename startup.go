@@ -0,0 +1,40 @@
+package kasper
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	initialStartupBackoff = 500 * time.Millisecond
+	maxStartupBackoff     = 30 * time.Second
+)
+
+// retry calls connect until it succeeds, waiting with exponential backoff between attempts, and gives up once
+// timeout has elapsed. connect is called only once if timeout is 0.
+func retry(timeout time.Duration, logger Logger, dependency string, connect func() error) error {
+	if logger == nil {
+		logger = NewBasicLogger(false)
+	}
+	deadline := time.Now().Add(timeout)
+	backoff := initialStartupBackoff
+	for {
+		err := connect()
+		if err == nil || timeout <= 0 {
+			return err
+		}
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			return fmt.Errorf("%s still unavailable after %s: %s", dependency, timeout, err)
+		}
+		if backoff > remaining {
+			backoff = remaining
+		}
+		logger.Infof("Cannot connect to %s, retrying in %s: %s", dependency, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxStartupBackoff {
+			backoff = maxStartupBackoff
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package kasper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	defer func(initial time.Duration) { initialStartupBackoff = initial }(initialStartupBackoff)
+	initialStartupBackoff = time.Millisecond
+
+	attempts := 0
+	err := retry(time.Second, &noopLogger{}, "Kafka", func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = retry(0, &noopLogger{}, "Kafka", func() error {
+		attempts++
+		return errors.New("connection refused")
+	})
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, 1, attempts)
+
+	err = retry(10*time.Millisecond, &noopLogger{}, "store reach", func() error {
+		return errors.New("connection refused")
+	})
+	assert.EqualError(t, err, "store reach still unavailable after 10ms: connection refused")
+}
@@ -0,0 +1,134 @@
+package kasper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Backups start with backupMagic, followed by frames of the form
+// {key length: uint32}{key}{value length: uint32}{value}, big-endian, and end with backupEnd.
+var backupMagic = []byte{'K', 'S', 'B', 1}
+
+const backupEnd = ^uint32(0)
+
+const restoreBatchSize = 1000
+
+// Backup writes all the keys of store to w, e.g. to snapshot the state of a processor before a risky deploy.
+// store must implement IterableStore. It returns the number of keys written. Keys written during the backup
+// may or may not be included, so processing should be paused for a consistent snapshot.
+func Backup(store Store, w io.Writer) (int, error) {
+	buffered := bufio.NewWriter(w)
+	if _, err := buffered.Write(backupMagic); err != nil {
+		return 0, err
+	}
+	count := 0
+	var writeErr error
+	err := Iterate(store, "", func(kv KeyValue) bool {
+		writeErr = writeBackupFrame(buffered, kv)
+		if writeErr != nil {
+			return false
+		}
+		count++
+		return true
+	})
+	if err != nil {
+		return count, err
+	}
+	if writeErr != nil {
+		return count, writeErr
+	}
+	if err := binary.Write(buffered, binary.BigEndian, backupEnd); err != nil {
+		return count, err
+	}
+	return count, buffered.Flush()
+}
+
+func writeBackupFrame(w io.Writer, kv KeyValue) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(kv.Key))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, kv.Key); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(kv.Value))); err != nil {
+		return err
+	}
+	_, err := w.Write(kv.Value)
+	return err
+}
+
+// Restore writes the keys of a backup created with Backup to store, in batches with PutAll, and flushes store.
+// Existing keys that are not in the backup are left untouched. It returns the number of keys restored, and an
+// error if the backup is invalid or truncated, in which case some keys may have been restored.
+func Restore(store Store, r io.Reader) (int, error) {
+	buffered := bufio.NewReader(r)
+	magic := make([]byte, len(backupMagic))
+	if _, err := io.ReadFull(buffered, magic); err != nil || !bytes.Equal(magic, backupMagic) {
+		return 0, errors.New("invalid backup: bad header")
+	}
+	count := 0
+	batch := make(map[string][]byte, restoreBatchSize)
+	for {
+		key, value, err := readBackupFrame(buffered)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		batch[key] = value
+		if len(batch) == restoreBatchSize {
+			if err := store.PutAll(batch); err != nil {
+				return count, err
+			}
+			count += len(batch)
+			batch = make(map[string][]byte, restoreBatchSize)
+		}
+	}
+	if len(batch) > 0 {
+		if err := store.PutAll(batch); err != nil {
+			return count, err
+		}
+		count += len(batch)
+	}
+	return count, store.Flush()
+}
+
+// readBackupFrame returns io.EOF at the end of the backup.
+func readBackupFrame(r io.Reader) (string, []byte, error) {
+	key, err := readBackupField(r)
+	if err != nil {
+		return "", nil, err
+	}
+	if key == nil {
+		return "", nil, io.EOF
+	}
+	value, err := readBackupField(r)
+	if err != nil {
+		return "", nil, err
+	}
+	if value == nil {
+		return "", nil, errors.New("invalid backup: unexpected end marker")
+	}
+	return string(key), value, nil
+}
+
+// readBackupField returns nil at the end marker.
+func readBackupField(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, fmt.Errorf("invalid backup: %s", io.ErrUnexpectedEOF)
+	}
+	if length == backupEnd {
+		return nil, nil
+	}
+	field := make([]byte, length)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, fmt.Errorf("invalid backup: %s", io.ErrUnexpectedEOF)
+	}
+	return field, nil
+}
@@ -0,0 +1,33 @@
+package kasper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackup(t *testing.T) {
+	src := NewMap(10)
+	src.PutAll(map[string][]byte{"mercury": mercury, "venus": venus, "earth": earth, "void": {}})
+
+	var backup bytes.Buffer
+	count, err := Backup(src, &backup)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, count)
+
+	dst := NewMap(10)
+	dst.Put("mars", mars)
+	count, err = Restore(dst, bytes.NewReader(backup.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, map[string][]byte{"mercury": mercury, "venus": venus, "earth": earth, "void": {}, "mars": mars}, dst.GetMap())
+
+	_, err = Restore(NewMap(10), bytes.NewReader(backup.Bytes()[:backup.Len()-6]))
+	assert.EqualError(t, err, "invalid backup: unexpected EOF")
+	_, err = Restore(NewMap(10), bytes.NewReader([]byte("mercury")))
+	assert.EqualError(t, err, "invalid backup: bad header")
+
+	_, err = Backup(NewAuditStore(&Config{}, src, "planets", nil), &backup)
+	assert.EqualError(t, err, "*kasper.AuditStore does not support iteration")
+}
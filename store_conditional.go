@@ -0,0 +1,45 @@
+package kasper
+
+import "bytes"
+
+// ConditionalStore is a Store that supports conditional writes, so that processors sharing a store can update
+// keys safely without a lock.
+type ConditionalStore interface {
+	Store
+	// PutIfAbsent inserts a value if the key does not exist, and returns false otherwise.
+	PutIfAbsent(key string, value []byte) (bool, error)
+	// CompareAndSet updates a value if the current value is equal to expected (or if the key does not exist and
+	// expected is nil), and returns false otherwise.
+	CompareAndSet(key string, expected, value []byte) (bool, error)
+}
+
+// PutIfAbsent inserts a value if the key does not exist and returns true, or returns false if it exists. It uses
+// the native implementation of stores that implement ConditionalStore, and otherwise reads the key before writing
+// it, which is not atomic: wrap the store in a SynchronizedStore if it is shared by several goroutines.
+func PutIfAbsent(store Store, key string, value []byte) (bool, error) {
+	if conditionalStore, ok := store.(ConditionalStore); ok {
+		return conditionalStore.PutIfAbsent(key, value)
+	}
+	return compareAndSetValue(store, key, nil, value)
+}
+
+// CompareAndSet updates a value if the current value is equal to expected (or if the key does not exist and
+// expected is nil) and returns true, or returns false otherwise. It uses the native implementation of stores that
+// implement ConditionalStore, and otherwise reads the key before writing it, like PutIfAbsent.
+func CompareAndSet(store Store, key string, expected, value []byte) (bool, error) {
+	if conditionalStore, ok := store.(ConditionalStore); ok {
+		return conditionalStore.CompareAndSet(key, expected, value)
+	}
+	return compareAndSetValue(store, key, expected, value)
+}
+
+func compareAndSetValue(store Store, key string, expected, value []byte) (bool, error) {
+	current, err := store.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if (current == nil) != (expected == nil) || !bytes.Equal(current, expected) {
+		return false, nil
+	}
+	return true, store.Put(key, value)
+}
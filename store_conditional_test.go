@@ -0,0 +1,43 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutIfAbsent(t *testing.T) {
+	s := NewMap(10)
+	inserted, err := PutIfAbsent(s, "mercury", mercury)
+	assert.Nil(t, err)
+	assert.True(t, inserted)
+	inserted, err = PutIfAbsent(NewSynchronizedStore(s), "mercury", venus)
+	assert.Nil(t, err)
+	assert.False(t, inserted)
+	value, _ := s.Get("mercury")
+	assert.Equal(t, mercury, value)
+}
+
+func TestCompareAndSet(t *testing.T) {
+	s := NewMap(10)
+	config := &Config{TopicProcessorName: "hari-seldon", ContainerID: "container-1"}
+	for _, store := range []Store{s, NewStoreMetrics(config, s, "planets"), NewDryRunStore(&Config{}, s, "planets")} {
+		s.Put("earth", earth)
+		updated, err := CompareAndSet(store, "earth", mars, venus)
+		assert.Nil(t, err)
+		assert.False(t, updated)
+		updated, err = CompareAndSet(store, "earth", earth, mars)
+		assert.Nil(t, err)
+		assert.True(t, updated)
+		value, _ := s.Get("earth")
+		assert.Equal(t, mars, value)
+
+		updated, err = CompareAndSet(store, "earth", nil, venus)
+		assert.Nil(t, err)
+		assert.False(t, updated)
+		s.Delete("earth")
+		updated, err = CompareAndSet(store, "earth", nil, venus)
+		assert.Nil(t, err)
+		assert.True(t, updated)
+	}
+}
@@ -0,0 +1,77 @@
+package kasper
+
+import "golang.org/x/net/context"
+
+// ContextStore is a Store whose operations take a context, so that store calls participate in the deadlines
+// and cancellation of the caller. Use NewContextStore to get a ContextStore from any Store.
+type ContextStore interface {
+	Store
+	// GetContext is like Get, with a context.
+	GetContext(ctx context.Context, key string) ([]byte, error)
+	// GetAllContext is like GetAll, with a context.
+	GetAllContext(ctx context.Context, keys []string) (map[string][]byte, error)
+	// PutContext is like Put, with a context.
+	PutContext(ctx context.Context, key string, value []byte) error
+	// PutAllContext is like PutAll, with a context.
+	PutAllContext(ctx context.Context, kvs map[string][]byte) error
+	// DeleteContext is like Delete, with a context.
+	DeleteContext(ctx context.Context, key string) error
+	// FlushContext is like Flush, with a context.
+	FlushContext(ctx context.Context) error
+}
+
+// NewContextStore returns store if it implements ContextStore (e.g. Elasticsearch or StoreMetrics).
+// Otherwise, it wraps store so that each operation returns the error of the context without calling store
+// if the context is already done. Operations that have started are not interrupted in that case.
+func NewContextStore(store Store) ContextStore {
+	if contextStore, ok := store.(ContextStore); ok {
+		return contextStore
+	}
+	return &contextStore{store}
+}
+
+type contextStore struct {
+	Store
+}
+
+func (s *contextStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Get(key)
+}
+
+func (s *contextStore) GetAllContext(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.GetAll(keys)
+}
+
+func (s *contextStore) PutContext(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Put(key, value)
+}
+
+func (s *contextStore) PutAllContext(ctx context.Context, kvs map[string][]byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.PutAll(kvs)
+}
+
+func (s *contextStore) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Delete(key)
+}
+
+func (s *contextStore) FlushContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Flush()
+}
@@ -0,0 +1,32 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestNewContextStore(t *testing.T) {
+	store := NewContextStore(NewMap(10))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	assert.Nil(t, store.PutContext(ctx, "mercury", mercury))
+	value, err := store.GetContext(ctx, "mercury")
+	assert.Nil(t, err)
+	assert.Equal(t, mercury, value)
+
+	cancel()
+	assert.Equal(t, context.Canceled, store.PutContext(ctx, "venus", venus))
+	_, err = store.GetAllContext(ctx, []string{"mercury"})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, context.Canceled, store.DeleteContext(ctx, "mercury"))
+	value, _ = store.Get("mercury")
+	assert.Equal(t, mercury, value)
+	value, _ = store.Get("venus")
+	assert.Nil(t, value)
+
+	metrics := NewStoreMetrics(&Config{TopicProcessorName: "hari-seldon", ContainerID: "container-1"}, store, "planets")
+	assert.Equal(t, metrics, NewContextStore(metrics))
+	assert.Equal(t, context.Canceled, metrics.FlushContext(ctx))
+}
@@ -0,0 +1,53 @@
+package kasper
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// CounterStore is a Store that can increment counters atomically, without a read-modify-write round trip.
+// Counters are stored as decimal strings (e.g. "42"), except in Elasticsearch (see Elasticsearch.Increment).
+type CounterStore interface {
+	Store
+	// Increment adds delta to the counter stored at key, which is created with the value 0 if it does not
+	// exist, and returns the new value.
+	Increment(key string, delta int64) (int64, error)
+}
+
+// Increment adds delta to the counter stored at key and returns the new value. It uses the native implementation
+// of stores that implement CounterStore, and otherwise reads, increments and writes back the counter, which is
+// not atomic: wrap the store in a SynchronizedStore if it is shared by several goroutines.
+func Increment(store Store, key string, delta int64) (int64, error) {
+	if counterStore, ok := store.(CounterStore); ok {
+		return counterStore.Increment(key, delta)
+	}
+	return incrementValue(store, key, delta)
+}
+
+func incrementValue(store Store, key string, delta int64) (int64, error) {
+	value, err := store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	counter, err := parseCounter(key, value)
+	if err != nil {
+		return 0, err
+	}
+	counter += delta
+	return counter, store.Put(key, formatCounter(counter))
+}
+
+func parseCounter(key string, value []byte) (int64, error) {
+	if value == nil {
+		return 0, nil
+	}
+	counter, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value of %s is not a counter: %s", key, err)
+	}
+	return counter, nil
+}
+
+func formatCounter(counter int64) []byte {
+	return []byte(strconv.FormatInt(counter, 10))
+}
@@ -0,0 +1,31 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrement(t *testing.T) {
+	s := NewMap(10)
+	counter, err := Increment(s, "moons", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), counter)
+	counter, err = Increment(NewSynchronizedStore(s), "moons", -3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-1), counter)
+	value, _ := s.Get("moons")
+	assert.Equal(t, []byte("-1"), value)
+
+	// Stores that do not implement CounterStore are read and written back
+	dryRun := NewDryRunStore(&Config{DryRun: true}, s, "planets")
+	counter, err = Increment(dryRun, "moons", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), counter)
+	value, _ = s.Get("moons")
+	assert.Equal(t, []byte("-1"), value)
+
+	s.Put("earth", earth)
+	_, err = Increment(s, "earth", 1)
+	assert.EqualError(t, err, `value of earth is not a counter: strconv.ParseInt: parsing "earth": invalid syntax`)
+}
@@ -0,0 +1,22 @@
+package kasper
+
+// BatchDeleteStore is a Store that can delete multiple keys in one round trip.
+type BatchDeleteStore interface {
+	Store
+	// DeleteAll deletes multiple keys. Missing keys are ignored.
+	DeleteAll(keys []string) error
+}
+
+// DeleteAll deletes multiple keys from store. It uses the native implementation of stores that implement
+// BatchDeleteStore, and otherwise calls Delete for each key.
+func DeleteAll(store Store, keys []string) error {
+	if batchDeleteStore, ok := store.(BatchDeleteStore); ok {
+		return batchDeleteStore.DeleteAll(keys)
+	}
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAll(t *testing.T) {
+	provider := newRecordingMetricsProvider()
+	config := &Config{TopicProcessorName: "hari-seldon", ContainerID: "container-1", MetricsProvider: provider}
+	s := NewMap(10)
+	s.PutAll(map[string][]byte{"mercury": mercury, "venus": venus, "earth": earth})
+
+	err := DeleteAll(NewStoreMetrics(config, s, "planets"), []string{"mercury", "venus", "pluto"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"earth": earth}, s.GetMap())
+	assert.Equal(t, 3.0, provider.values["Store_Delete{planets,,container-1,hari-seldon}"])
+
+	log := &recordingAuditLog{}
+	err = DeleteAll(NewAuditStore(config, s, "planets", log), []string{"earth"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(s.GetMap()))
+	assert.Equal(t, 1, len(log.records))
+	assert.Equal(t, "Delete", log.records[0].Operation)
+}
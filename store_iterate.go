@@ -0,0 +1,51 @@
+package kasper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KeyValue is a key and its value.
+type KeyValue struct {
+	Key   string
+	Value []byte
+}
+
+// IterableStore is a Store whose keys can be iterated by prefix, e.g. for periodic sweeps and migrations.
+type IterableStore interface {
+	Store
+	// Iterate calls fn for each key starting with prefix (all keys if prefix is empty) until fn returns false.
+	// Keys are not visited in a defined order, unless the store documents one. Keys written during the
+	// iteration may or may not be visited.
+	Iterate(prefix string, fn func(KeyValue) bool) error
+}
+
+// Iterate calls fn for each key of store starting with prefix until fn returns false, see IterableStore.
+// It returns an error if store does not implement IterableStore.
+func Iterate(store Store, prefix string, fn func(KeyValue) bool) error {
+	iterableStore, ok := store.(IterableStore)
+	if !ok {
+		return fmt.Errorf("%T does not support iteration", store)
+	}
+	return iterableStore.Iterate(prefix, fn)
+}
+
+// Iterate calls fn for each key starting with prefix, in lexicographic order, until fn returns false.
+// The map can be modified by fn.
+func (s *Map) Iterate(prefix string, fn func(KeyValue) bool) error {
+	var keys []string
+	for key := range s.m {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, found := s.m[key]
+		if found && !fn(KeyValue{key, value}) {
+			break
+		}
+	}
+	return nil
+}
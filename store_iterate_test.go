@@ -0,0 +1,35 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterate(t *testing.T) {
+	s := NewMap(10)
+	s.PutAll(map[string][]byte{"planet/mercury": mercury, "planet/venus": venus, "planet/earth": earth, "moon": nil})
+	var keys []string
+	err := Iterate(NewSynchronizedStore(s), "planet/", func(kv KeyValue) bool {
+		keys = append(keys, kv.Key)
+		assert.Equal(t, kv.Key, "planet/"+string(kv.Value))
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"planet/earth", "planet/mercury", "planet/venus"}, keys)
+
+	// fn can delete keys and stop the iteration
+	err = s.Iterate("", func(kv KeyValue) bool {
+		s.Delete(kv.Key)
+		return kv.Key != "planet/earth"
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(s.GetMap()))
+
+	err = Iterate(&DryRunStore{store: NewAuditStore(&Config{}, s, "planets", nil)}, "", func(KeyValue) bool { return true })
+	assert.EqualError(t, err, "*kasper.AuditStore does not support iteration")
+}
+
+func TestRedisPatternEscaper(t *testing.T) {
+	assert.Equal(t, `words/a\*b\?\[c\]\\`, redisPatternEscaper.Replace(`words/a*b?[c]\`))
+}
@@ -0,0 +1,210 @@
+package kasper
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// StoreMetrics wraps a Store and instruments it with metrics.
+// It records the number of operations, the size of GetAll() and PutAll() batches and the number of bytes
+// read and written, which makes it easy to tune batch sizes for any Store implementation (e.g. Map).
+// Latencies of the operations are also reported in TopicProcessor.Metrics().
+// StoreMetrics implements ContextStore, and passes contexts to the underlying store (see NewContextStore).
+type StoreMetrics struct {
+	store        Store
+	contextStore ContextStore
+	name         string
+	stats        *runtimeStats
+
+	labelValues        []string
+	getCounter         Counter
+	getAllSummary      Summary
+	putCounter         Counter
+	putAllSummary      Summary
+	deleteCounter      Counter
+	flushCounter       Counter
+	getBytesSummary    Summary
+	getAllBytesSummary Summary
+	putBytesSummary    Summary
+	putAllBytesSummary Summary
+}
+
+// NewStoreMetrics creates StoreMetrics instances. The name is used as the value of the "store" label.
+func NewStoreMetrics(config *Config, store Store, name string) *StoreMetrics {
+	metrics := config.storeMetricsProvider()
+	labelNames := []string{"store"}
+	return &StoreMetrics{
+		store,
+		NewContextStore(store),
+		name,
+		config.stats(),
+		[]string{name},
+		metrics.NewCounter("Store_Get", "Number of Get() calls", labelNames...),
+		metrics.NewSummary("Store_GetAll", "Summary of GetAll() batch sizes", labelNames...),
+		metrics.NewCounter("Store_Put", "Number of Put() calls", labelNames...),
+		metrics.NewSummary("Store_PutAll", "Summary of PutAll() batch sizes", labelNames...),
+		metrics.NewCounter("Store_Delete", "Number of Delete() calls", labelNames...),
+		metrics.NewCounter("Store_Flush", "Number of Flush() calls", labelNames...),
+		metrics.NewSummary("Store_Get_Bytes", "Summary of Get() bytes read", labelNames...),
+		metrics.NewSummary("Store_GetAll_Bytes", "Summary of GetAll() bytes read", labelNames...),
+		metrics.NewSummary("Store_Put_Bytes", "Summary of Put() bytes written", labelNames...),
+		metrics.NewSummary("Store_PutAll_Bytes", "Summary of PutAll() bytes written", labelNames...),
+	}
+}
+
+// Get gets a value by key from the underlying store.
+func (s *StoreMetrics) Get(key string) ([]byte, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext gets a value by key from the underlying store.
+func (s *StoreMetrics) GetContext(ctx context.Context, key string) (value []byte, err error) {
+	defer s.stats.observeStoreOperation(s.name+".Get", s.stats.now(), &err)
+	s.getCounter.Inc(s.labelValues...)
+	value, err = s.contextStore.GetContext(ctx, key)
+	s.getBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	return value, err
+}
+
+// GetAll gets multiple values by key from the underlying store.
+func (s *StoreMetrics) GetAll(keys []string) (map[string][]byte, error) {
+	return s.GetAllContext(context.Background(), keys)
+}
+
+// GetAllContext gets multiple values by key from the underlying store.
+func (s *StoreMetrics) GetAllContext(ctx context.Context, keys []string) (kvs map[string][]byte, err error) {
+	defer s.stats.observeStoreOperation(s.name+".GetAll", s.stats.now(), &err)
+	s.getAllSummary.Observe(float64(len(keys)), s.labelValues...)
+	kvs, err = s.contextStore.GetAllContext(ctx, keys)
+	s.getAllBytesSummary.Observe(float64(countBytes(kvs)), s.labelValues...)
+	return kvs, err
+}
+
+// GetAllInto adds the values of keys in the underlying store to kvs, see the GetAllInto function.
+func (s *StoreMetrics) GetAllInto(keys []string, kvs map[string][]byte) (err error) {
+	defer s.stats.observeStoreOperation(s.name+".GetAll", s.stats.now(), &err)
+	s.getAllSummary.Observe(float64(len(keys)), s.labelValues...)
+	err = GetAllInto(s.store, keys, kvs)
+	s.getAllBytesSummary.Observe(float64(countBytes(kvs)), s.labelValues...)
+	return err
+}
+
+// Put inserts or updates a value by key in the underlying store.
+func (s *StoreMetrics) Put(key string, value []byte) error {
+	return s.PutContext(context.Background(), key, value)
+}
+
+// PutContext inserts or updates a value by key in the underlying store.
+func (s *StoreMetrics) PutContext(ctx context.Context, key string, value []byte) (err error) {
+	defer s.stats.observeStoreOperation(s.name+".Put", s.stats.now(), &err)
+	s.putCounter.Inc(s.labelValues...)
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	return s.contextStore.PutContext(ctx, key, value)
+}
+
+// PutWithTTL inserts or updates a value that expires after ttl in the underlying store, see the PutWithTTL function.
+func (s *StoreMetrics) PutWithTTL(key string, value []byte, ttl time.Duration) (err error) {
+	defer s.stats.observeStoreOperation(s.name+".PutWithTTL", s.stats.now(), &err)
+	s.putCounter.Inc(s.labelValues...)
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	return PutWithTTL(s.store, key, value, ttl)
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store.
+func (s *StoreMetrics) PutAll(kvs map[string][]byte) error {
+	return s.PutAllContext(context.Background(), kvs)
+}
+
+// PutAllContext inserts or updates multiple key-value pairs in the underlying store.
+func (s *StoreMetrics) PutAllContext(ctx context.Context, kvs map[string][]byte) (err error) {
+	defer s.stats.observeStoreOperation(s.name+".PutAll", s.stats.now(), &err)
+	s.putAllSummary.Observe(float64(len(kvs)), s.labelValues...)
+	s.putAllBytesSummary.Observe(float64(countBytes(kvs)), s.labelValues...)
+	return s.contextStore.PutAllContext(ctx, kvs)
+}
+
+// Delete deletes a key from the underlying store.
+func (s *StoreMetrics) Delete(key string) error {
+	return s.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext deletes a key from the underlying store.
+func (s *StoreMetrics) DeleteContext(ctx context.Context, key string) (err error) {
+	defer s.stats.observeStoreOperation(s.name+".Delete", s.stats.now(), &err)
+	s.deleteCounter.Inc(s.labelValues...)
+	return s.contextStore.DeleteContext(ctx, key)
+}
+
+// DeleteAll deletes multiple keys from the underlying store, see the DeleteAll function.
+func (s *StoreMetrics) DeleteAll(keys []string) (err error) {
+	defer s.stats.observeStoreOperation(s.name+".DeleteAll", s.stats.now(), &err)
+	s.deleteCounter.Add(float64(len(keys)), s.labelValues...)
+	return DeleteAll(s.store, keys)
+}
+
+// Mutate applies writes to the underlying store, see the Mutate function.
+func (s *StoreMetrics) Mutate(puts []KeyValue, deletes []string) (err error) {
+	defer s.stats.observeStoreOperation(s.name+".Mutate", s.stats.now(), &err)
+	s.putCounter.Add(float64(len(puts)), s.labelValues...)
+	s.deleteCounter.Add(float64(len(deletes)), s.labelValues...)
+	return Mutate(s.store, puts, deletes)
+}
+
+// Flush flushes the underlying store.
+func (s *StoreMetrics) Flush() error {
+	return s.FlushContext(context.Background())
+}
+
+// FlushContext flushes the underlying store.
+func (s *StoreMetrics) FlushContext(ctx context.Context) (err error) {
+	defer s.stats.observeStoreOperation(s.name+".Flush", s.stats.now(), &err)
+	s.flushCounter.Inc(s.labelValues...)
+	return s.contextStore.FlushContext(ctx)
+}
+
+// Increment adds delta to the counter stored at key in the underlying store, see the Increment function.
+func (s *StoreMetrics) Increment(key string, delta int64) (counter int64, err error) {
+	defer s.stats.observeStoreOperation(s.name+".Increment", s.stats.now(), &err)
+	return Increment(s.store, key, delta)
+}
+
+// PutIfAbsent inserts a value in the underlying store if the key does not exist, see the PutIfAbsent function.
+func (s *StoreMetrics) PutIfAbsent(key string, value []byte) (_ bool, err error) {
+	defer s.stats.observeStoreOperation(s.name+".PutIfAbsent", s.stats.now(), &err)
+	s.putCounter.Inc(s.labelValues...)
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	return PutIfAbsent(s.store, key, value)
+}
+
+// CompareAndSet updates a value in the underlying store if it is equal to expected, see the CompareAndSet function.
+func (s *StoreMetrics) CompareAndSet(key string, expected, value []byte) (_ bool, err error) {
+	defer s.stats.observeStoreOperation(s.name+".CompareAndSet", s.stats.now(), &err)
+	s.putCounter.Inc(s.labelValues...)
+	s.putBytesSummary.Observe(float64(len(value)), s.labelValues...)
+	return CompareAndSet(s.store, key, expected, value)
+}
+
+// Iterate iterates the keys of the underlying store, see the Iterate function.
+func (s *StoreMetrics) Iterate(prefix string, fn func(KeyValue) bool) (err error) {
+	defer s.stats.observeStoreOperation(s.name+".Iterate", s.stats.now(), &err)
+	return Iterate(s.store, prefix, fn)
+}
+
+// Stats returns the statistics of the underlying store, see the GetStats function.
+func (s *StoreMetrics) Stats() (StoreStats, error) {
+	return GetStats(s.store)
+}
+
+// GetStore returns the underlying Store
+func (s *StoreMetrics) GetStore() Store {
+	return s.store
+}
+
+func countBytes(kvs map[string][]byte) int {
+	n := 0
+	for _, value := range kvs {
+		n += len(value)
+	}
+	return n
+}
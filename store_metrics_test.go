@@ -0,0 +1,51 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreMetrics(t *testing.T) {
+	provider := newRecordingMetricsProvider()
+	config := &Config{
+		TopicProcessorName: "hari-seldon",
+		ContainerID:        "container-1",
+		MetricsProvider:    provider,
+	}
+	s := NewStoreMetrics(config, NewMap(10), "planets")
+
+	s.Put("mercury", mercury)
+	s.PutAll(map[string][]byte{"venus": venus, "earth": earth})
+	s.Get("mercury")
+	s.Get("pluto")
+	s.GetAll([]string{"venus", "earth", "pluto"})
+	s.Delete("earth")
+	s.Flush()
+
+	// The partition label is empty outside of RunLoop
+	labels := "{planets,,container-1,hari-seldon}"
+	assert.Equal(t, 1.0, provider.values["Store_Put"+labels])
+	assert.Equal(t, 2.0, provider.values["Store_PutAll"+labels])
+	assert.Equal(t, 2.0, provider.values["Store_Get"+labels])
+	assert.Equal(t, 3.0, provider.values["Store_GetAll"+labels])
+	assert.Equal(t, 1.0, provider.values["Store_Delete"+labels])
+	assert.Equal(t, 1.0, provider.values["Store_Flush"+labels])
+	assert.Equal(t, float64(len(mercury)), provider.values["Store_Put_Bytes"+labels])
+	assert.Equal(t, float64(len(venus)+len(earth)), provider.values["Store_PutAll_Bytes"+labels])
+	assert.Equal(t, float64(len(mercury)), provider.values["Store_Get_Bytes"+labels])
+	assert.Equal(t, float64(len(venus)+len(earth)), provider.values["Store_GetAll_Bytes"+labels])
+
+	assert.Equal(t, []string{"store", "partition", "containerID", "topicProcessor"}, provider.labelNames["Store_Put"])
+
+	config.setActivePartition(3)
+	s.Put("mars", mars)
+	config.setActivePartition(-1)
+	assert.Equal(t, 1.0, provider.values["Store_Put{planets,3,container-1,hari-seldon}"])
+
+	config.stats().tick(time.Now())
+	snapshot := config.stats().snapshot(time.Now())
+	assert.Equal(t, int64(2), snapshot.StoreLatencies["planets.Get"].Count)
+	assert.Equal(t, int64(1), snapshot.StoreLatencies["planets.PutAll"].Count)
+}
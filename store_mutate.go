@@ -0,0 +1,49 @@
+package kasper
+
+// TransactionalStore is a Store that can apply several writes atomically, see IsTransactional.
+type TransactionalStore interface {
+	Store
+	// Mutate inserts or updates the puts, then deletes the keys in deletes, as a single atomic operation.
+	Mutate(puts []KeyValue, deletes []string) error
+}
+
+type storeWrapper interface {
+	GetStore() Store
+}
+
+// Mutate inserts or updates the puts, then deletes the keys in deletes. It is atomic if IsTransactional(store)
+// returns true, and otherwise calls PutAll and DeleteAll, so a failure can leave some of the writes applied.
+func Mutate(store Store, puts []KeyValue, deletes []string) error {
+	if transactionalStore, ok := store.(TransactionalStore); ok {
+		return transactionalStore.Mutate(puts, deletes)
+	}
+	return mutateValues(store, puts, deletes)
+}
+
+// IsTransactional returns true if Mutate is atomic for store. Wrappers that delegate Mutate to another store
+// (e.g. StoreMetrics) are transactional if the store they wrap is.
+func IsTransactional(store Store) bool {
+	if _, ok := store.(TransactionalStore); !ok {
+		return false
+	}
+	if wrapper, ok := store.(storeWrapper); ok {
+		return IsTransactional(wrapper.GetStore())
+	}
+	return true
+}
+
+func mutateValues(store Store, puts []KeyValue, deletes []string) error {
+	if len(puts) > 0 {
+		kvs := make(map[string][]byte, len(puts))
+		for _, kv := range puts {
+			kvs[kv.Key] = kv.Value
+		}
+		if err := store.PutAll(kvs); err != nil {
+			return err
+		}
+	}
+	if len(deletes) > 0 {
+		return DeleteAll(store, deletes)
+	}
+	return nil
+}
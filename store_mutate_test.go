@@ -0,0 +1,26 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutate(t *testing.T) {
+	s := NewMap(10)
+	s.PutAll(map[string][]byte{"mercury": mercury, "venus": venus})
+	metrics := NewStoreMetrics(&Config{}, NewSynchronizedStore(s), "planets")
+	assert.True(t, IsTransactional(metrics))
+
+	err := Mutate(metrics, []KeyValue{{"earth", earth}, {"mars", mars}}, []string{"mercury", "mars"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"venus": venus, "earth": earth}, s.GetMap())
+
+	// Stores that do not implement TransactionalStore are updated with PutAll and DeleteAll
+	audit := NewAuditStore(&Config{}, s, "planets", &recordingAuditLog{})
+	assert.False(t, IsTransactional(audit))
+	assert.False(t, IsTransactional(NewStoreMetrics(&Config{}, audit, "planets")))
+	err = Mutate(audit, []KeyValue{{"jupiter", jupiter}}, []string{"venus"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"earth": earth, "jupiter": jupiter}, s.GetMap())
+}
@@ -0,0 +1,52 @@
+package kasper
+
+// ReusingStore is a Store that can read values into a map provided by the caller, so that a MessageProcessor can
+// reuse the same map for every batch instead of allocating one per GetAll:
+//
+//	// p.keys and p.kvs are allocated once, when the processor is created
+//	func (p *Processor) Process(messages []*sarama.ConsumerMessage, sender kasper.Sender) error {
+//		p.keys = p.keys[:0]
+//		for _, message := range messages {
+//			p.keys = append(p.keys, string(message.Key))
+//		}
+//		if err := kasper.GetAllInto(p.store, p.keys, p.kvs); err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// Writes can reuse slices in the same way with Mutate, which takes a slice of KeyValue.
+type ReusingStore interface {
+	Store
+	// GetAllInto adds the values of keys to kvs. Missing keys are not added.
+	GetAllInto(keys []string, kvs map[string][]byte) error
+}
+
+// GetAllInto clears kvs and fills it with the values of keys, like GetAll. If store does not implement
+// ReusingStore, it calls GetAll and copies the result.
+func GetAllInto(store Store, keys []string, kvs map[string][]byte) error {
+	for key := range kvs {
+		delete(kvs, key)
+	}
+	if reusingStore, ok := store.(ReusingStore); ok {
+		return reusingStore.GetAllInto(keys, kvs)
+	}
+	values, err := store.GetAll(keys)
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		kvs[key] = value
+	}
+	return nil
+}
+
+// GetAllInto adds the values of keys to kvs, see ReusingStore.
+func (s *Map) GetAllInto(keys []string, kvs map[string][]byte) error {
+	for _, key := range keys {
+		if value, found := s.m[key]; found && value != nil {
+			kvs[key] = value
+		}
+	}
+	return nil
+}
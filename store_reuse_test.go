@@ -0,0 +1,20 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAllInto(t *testing.T) {
+	s := NewMap(10)
+	s.PutAll(map[string][]byte{"mercury": mercury, "venus": venus})
+	kvs := map[string][]byte{"mars": mars}
+
+	assert.Nil(t, GetAllInto(NewStoreMetrics(&Config{}, s, "Map"), []string{"mercury", "pluto"}, kvs))
+	assert.Equal(t, map[string][]byte{"mercury": mercury}, kvs)
+
+	// Stores that do not implement ReusingStore fall back to GetAll
+	assert.Nil(t, GetAllInto(NewPrefixedStore(s, "v"), []string{"enus"}, kvs))
+	assert.Equal(t, map[string][]byte{"enus": venus}, kvs)
+}
@@ -0,0 +1,106 @@
+package kasper
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrStatsNotSupported is returned by GetStats, and by the Stats method of wrappers such as StoreMetrics, when the
+// store does not support statistics.
+var ErrStatsNotSupported = errors.New("kasper: store does not support statistics")
+
+// StoreStats are statistics reported by a StatsStore. Statistics that a store cannot compute are zero.
+type StoreStats struct {
+	// Approximate number of keys
+	Keys int64 `json:"keys"`
+	// Approximate size of the values in bytes
+	Bytes int64 `json:"bytes"`
+	// Ratio of the keys read from a cache to all the keys read, between 0 and 1
+	CacheHitRate float64 `json:"cacheHitRate"`
+	// Number of writes buffered by the store and not applied yet
+	PendingWrites int64 `json:"pendingWrites"`
+}
+
+// StatsStore is a Store that reports statistics, see GetStats.
+type StatsStore interface {
+	Store
+	Stats() (StoreStats, error)
+}
+
+// GetStats returns the statistics of store. It returns ErrStatsNotSupported if store does not implement StatsStore.
+// The statistics of the stores registered in Config.Stores are reported as metrics with a "store" label on every
+// Config.MetricsUpdateInterval, and by TopicProcessor.Diagnostics.
+func GetStats(store Store) (StoreStats, error) {
+	statsStore, ok := store.(StatsStore)
+	if !ok {
+		return StoreStats{}, ErrStatsNotSupported
+	}
+	return statsStore.Stats()
+}
+
+// Stats returns the number of keys and the size of the values of the map.
+func (s *Map) Stats() (StoreStats, error) {
+	stats := StoreStats{Keys: int64(len(s.m))}
+	for _, value := range s.m {
+		stats.Bytes += int64(len(value))
+	}
+	return stats, nil
+}
+
+// storeStatsReporter reports the statistics of Config.Stores as metrics, and keeps the last ones for Diagnostics,
+// which can be called from any goroutine.
+type storeStatsReporter struct {
+	stores        []NamedStore
+	logger        Logger
+	keys          Gauge
+	bytes         Gauge
+	cacheHitRate  Gauge
+	pendingWrites Gauge
+	mutex         sync.Mutex
+	last          map[string]StoreStats
+}
+
+func newStoreStatsReporter(config *Config) *storeStatsReporter {
+	provider := config.metricsProvider()
+	return &storeStatsReporter{
+		config.Stores,
+		WithFields(config.logger(), Field{"topicProcessor", config.TopicProcessorName}),
+		provider.NewGauge("store_key_count", "Approximate number of keys in the store", "store"),
+		provider.NewGauge("store_bytes", "Approximate size of the values in the store", "store"),
+		provider.NewGauge("store_cache_hit_rate", "Ratio of the keys read from the cache of the store", "store"),
+		provider.NewGauge("store_pending_write_count", "Number of writes buffered by the store", "store"),
+		sync.Mutex{},
+		make(map[string]StoreStats),
+	}
+}
+
+// report is called once per metrics update interval, from the goroutine that uses the stores.
+func (r *storeStatsReporter) report() {
+	for _, store := range r.stores {
+		stats, err := GetStats(store.Store)
+		if err == ErrStatsNotSupported {
+			continue
+		}
+		if err != nil {
+			r.logger.Errorf("Cannot get statistics of store %s: %s", store.Name, err)
+			continue
+		}
+		r.keys.Set(float64(stats.Keys), store.Name)
+		r.bytes.Set(float64(stats.Bytes), store.Name)
+		r.cacheHitRate.Set(stats.CacheHitRate, store.Name)
+		r.pendingWrites.Set(float64(stats.PendingWrites), store.Name)
+		r.mutex.Lock()
+		r.last[store.Name] = stats
+		r.mutex.Unlock()
+	}
+}
+
+func (r *storeStatsReporter) lastStats() map[string]StoreStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	last := make(map[string]StoreStats, len(r.last))
+	for name, stats := range r.last {
+		last[name] = stats
+	}
+	return last
+}
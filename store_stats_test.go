@@ -0,0 +1,62 @@
+package kasper
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStats(t *testing.T) {
+	config := &Config{}
+	s := NewMap(10)
+	s.PutAll(map[string][]byte{"mercury": mercury, "venus": venus})
+
+	stats, err := GetStats(NewStoreMetrics(config, s, "Map"))
+	assert.Nil(t, err)
+	assert.Equal(t, StoreStats{Keys: 2, Bytes: 12}, stats)
+
+	cachingStore := NewCachingStore(config, s, "planets", time.Minute, 0, 0)
+	cachingStore.GetAll([]string{"mercury", "earth"})
+	cachingStore.Get("mercury")
+	writeBehindStore := NewWriteBehindStore(config, cachingStore, 0, 0)
+	writeBehindStore.Put("mars", mars)
+	stats, err = GetStats(writeBehindStore)
+	assert.Nil(t, err)
+	assert.Equal(t, StoreStats{Keys: 2, Bytes: 12, CacheHitRate: 1.0 / 3, PendingWrites: 1}, stats)
+
+	_, err = GetStats(NewAuditStore(config, s, "planets", nil))
+	assert.Equal(t, ErrStatsNotSupported, err)
+	_, err = GetStats(NewStoreMetrics(config, NewAuditStore(config, s, "planets", nil), "planets"))
+	assert.Equal(t, ErrStatsNotSupported, err)
+}
+
+type errorRecordingLogger struct {
+	Logger
+	errors []string
+}
+
+func (l *errorRecordingLogger) Errorf(format string, vs ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(format, vs...))
+}
+
+func TestTopicProcessor_StoreStats(t *testing.T) {
+	provider := newRecordingMetricsProvider()
+	logger := &errorRecordingLogger{noopLogger{}, nil}
+	s := NewMap(10)
+	s.PutAll(map[string][]byte{"mercury": mercury, "venus": venus})
+	config := &Config{ContainerID: "c0", MetricsProvider: provider, Logger: logger, Stores: []NamedStore{
+		{"planets", s},
+		{"audit", NewAuditStore(&Config{}, s, "planets", nil)},
+		{"audit-metrics", NewStoreMetrics(&Config{}, NewAuditStore(&Config{}, s, "planets", nil), "audit")},
+	}}
+	tp := newFakeTopicProcessor(config, &countingProcessor{})
+	assert.Equal(t, map[string]StoreStats{}, tp.Diagnostics().StoreStats)
+
+	tp.onMetricsTick()
+	assert.Equal(t, 2.0, provider.values["store_key_count{planets,c0,fake}"])
+	assert.Equal(t, 12.0, provider.values["store_bytes{planets,c0,fake}"])
+	assert.Equal(t, map[string]StoreStats{"planets": {Keys: 2, Bytes: 12}}, tp.Diagnostics().StoreStats)
+	assert.Empty(t, logger.errors)
+}
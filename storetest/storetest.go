@@ -0,0 +1,125 @@
+/*
+Package storetest provides a conformance test suite for implementations of kasper.Store.
+
+	func TestMyStore(t *testing.T) {
+		storetest.TestStore(t, func() kasper.Store {
+			return NewMyStore(...)
+		})
+	}
+*/
+package storetest
+
+import (
+	"testing"
+
+	"github.com/movio/kasper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Values are JSON documents so that the suite also applies to document stores such as Elasticsearch.
+var (
+	earth   = []byte(`{"planet":"earth"}`)
+	mars    = []byte(`{"planet":"mars"}`)
+	venus   = []byte(`{"planet":"venus"}`)
+	jupiter = []byte(`{"planet":"jupiter"}`)
+)
+
+// TestStore runs the conformance test suite against the stores returned by factory.
+// factory is called once per test case and must return an empty store.
+func TestStore(t *testing.T, factory func() kasper.Store) {
+	tests := []struct {
+		name string
+		test func(*testing.T, kasper.Store)
+	}{
+		{"Get_ExistingKey", testGetExistingKey},
+		{"Get_MissingKey", testGetMissingKey},
+		{"Put_Overwrite", testPutOverwrite},
+		{"GetAll_MissingKeys", testGetAllMissingKeys},
+		{"GetAll_NoKeys", testGetAllNoKeys},
+		{"PutAll", testPutAll},
+		{"PutAll_Empty", testPutAllEmpty},
+		{"Delete_ExistingKey", testDeleteExistingKey},
+		{"Delete_MissingKey", testDeleteMissingKey},
+		{"Flush", testFlush},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, factory())
+		})
+	}
+}
+
+func testGetExistingKey(t *testing.T, s kasper.Store) {
+	assert.Nil(t, s.Put("earth", earth))
+	actual, err := s.Get("earth")
+	assert.Nil(t, err)
+	assert.Equal(t, earth, actual)
+}
+
+func testGetMissingKey(t *testing.T, s kasper.Store) {
+	assert.Nil(t, s.Put("earth", earth))
+	actual, err := s.Get("mars")
+	assert.Nil(t, err)
+	assert.Nil(t, actual)
+}
+
+func testPutOverwrite(t *testing.T, s kasper.Store) {
+	assert.Nil(t, s.Put("planet", earth))
+	assert.Nil(t, s.Put("planet", mars))
+	actual, err := s.Get("planet")
+	assert.Nil(t, err)
+	assert.Equal(t, mars, actual)
+}
+
+func testGetAllMissingKeys(t *testing.T, s kasper.Store) {
+	assert.Nil(t, s.Put("earth", earth))
+	assert.Nil(t, s.Put("mars", mars))
+	kvs, err := s.GetAll([]string{"earth", "venus", "mars"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"earth": earth, "mars": mars}, kvs)
+}
+
+func testGetAllNoKeys(t *testing.T, s kasper.Store) {
+	kvs, err := s.GetAll([]string{})
+	assert.Nil(t, err)
+	assert.NotNil(t, kvs)
+	assert.Equal(t, 0, len(kvs))
+}
+
+func testPutAll(t *testing.T, s kasper.Store) {
+	assert.Nil(t, s.Put("venus", earth))
+	assert.Nil(t, s.PutAll(map[string][]byte{"venus": venus, "jupiter": jupiter}))
+	kvs, err := s.GetAll([]string{"venus", "jupiter"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"venus": venus, "jupiter": jupiter}, kvs)
+}
+
+func testPutAllEmpty(t *testing.T, s kasper.Store) {
+	assert.Nil(t, s.PutAll(map[string][]byte{}))
+}
+
+func testDeleteExistingKey(t *testing.T, s kasper.Store) {
+	assert.Nil(t, s.Put("earth", earth))
+	assert.Nil(t, s.Put("mars", mars))
+	assert.Nil(t, s.Delete("earth"))
+	actual, err := s.Get("earth")
+	assert.Nil(t, err)
+	assert.Nil(t, actual)
+	actual, err = s.Get("mars")
+	assert.Nil(t, err)
+	assert.Equal(t, mars, actual)
+}
+
+func testDeleteMissingKey(t *testing.T, s kasper.Store) {
+	assert.Nil(t, s.Delete("pluto"))
+}
+
+func testFlush(t *testing.T, s kasper.Store) {
+	assert.Nil(t, s.Put("earth", earth))
+	assert.Nil(t, s.Flush())
+	assert.Nil(t, s.Flush())
+	actual, err := s.Get("earth")
+	assert.Nil(t, err)
+	assert.Equal(t, earth, actual)
+}
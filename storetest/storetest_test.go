@@ -0,0 +1,54 @@
+package storetest
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/movio/kasper"
+	"github.com/movio/kasper/kaspertest"
+)
+
+func TestMap(t *testing.T) {
+	TestStore(t, func() kasper.Store {
+		return kasper.NewMap(10)
+	})
+}
+
+func TestStoreMetrics(t *testing.T) {
+	TestStore(t, func() kasper.Store {
+		return kasper.NewStoreMetrics(&kasper.Config{TopicProcessorName: "storetest"}, kasper.NewMap(10), "map")
+	})
+}
+
+func TestSynchronizedStore(t *testing.T) {
+	TestStore(t, func() kasper.Store {
+		return kasper.NewSynchronizedStore(kasper.NewMap(10))
+	})
+}
+
+func TestInMemoryStore(t *testing.T) {
+	TestStore(t, func() kasper.Store {
+		return kaspertest.NewInMemoryStore()
+	})
+}
+
+func TestRedis(t *testing.T) {
+	host := os.Getenv("KASPER_CI_HOST")
+	if testing.Short() || host == "" {
+		t.Skip()
+	}
+	conn, err := redis.DialURL(fmt.Sprintf("redis://%s:6379", host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	config := &kasper.Config{TopicProcessorName: "storetest"}
+	n := 0
+	TestStore(t, func() kasper.Store {
+		n++
+		return kasper.NewRedis(config, conn, fmt.Sprintf("storetest-%d-%d", time.Now().UnixNano(), n))
+	})
+}
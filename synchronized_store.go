@@ -0,0 +1,140 @@
+package kasper
+
+import (
+	"sync"
+	"time"
+)
+
+// SynchronizedStore wraps a Store and serializes all calls with a mutex, which makes any Store implementation
+// safe for concurrent use, e.g. when sharing a Map between MessageProcessors of different partitions
+// running in separate goroutines. All calls are serialized, including reads, because some stores (such as Redis)
+// use a single connection that does not support concurrent requests.
+type SynchronizedStore struct {
+	store Store
+	mutex sync.Mutex
+}
+
+// NewSynchronizedStore creates a new SynchronizedStore.
+func NewSynchronizedStore(store Store) *SynchronizedStore {
+	return &SynchronizedStore{store: store}
+}
+
+// Get gets a value by key from the underlying store.
+func (s *SynchronizedStore) Get(key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.store.Get(key)
+}
+
+// GetAll gets multiple values by key from the underlying store.
+func (s *SynchronizedStore) GetAll(keys []string) (map[string][]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.store.GetAll(keys)
+}
+
+// GetAllInto adds the values of keys in the underlying store to kvs, see the GetAllInto function.
+func (s *SynchronizedStore) GetAllInto(keys []string, kvs map[string][]byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return GetAllInto(s.store, keys, kvs)
+}
+
+// Put inserts or updates a value by key in the underlying store.
+func (s *SynchronizedStore) Put(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.store.Put(key, value)
+}
+
+// PutWithTTL inserts or updates a value that expires after ttl in the underlying store, see the PutWithTTL function.
+func (s *SynchronizedStore) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return PutWithTTL(s.store, key, value, ttl)
+}
+
+// PutAll inserts or updates multiple key-value pairs in the underlying store.
+func (s *SynchronizedStore) PutAll(kvs map[string][]byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.store.PutAll(kvs)
+}
+
+// Delete deletes a key from the underlying store.
+func (s *SynchronizedStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.store.Delete(key)
+}
+
+// DeleteAll deletes multiple keys from the underlying store, see the DeleteAll function.
+func (s *SynchronizedStore) DeleteAll(keys []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return DeleteAll(s.store, keys)
+}
+
+// Mutate applies writes to the underlying store while holding the lock, see the Mutate function.
+func (s *SynchronizedStore) Mutate(puts []KeyValue, deletes []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return Mutate(s.store, puts, deletes)
+}
+
+// Flush flushes the underlying store.
+func (s *SynchronizedStore) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.store.Flush()
+}
+
+// Increment adds delta to the counter stored at key in the underlying store, see the Increment function.
+// Stores that do not implement CounterStore are updated atomically with respect to the other calls.
+func (s *SynchronizedStore) Increment(key string, delta int64) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return Increment(s.store, key, delta)
+}
+
+// PutIfAbsent inserts a value in the underlying store if the key does not exist, see the PutIfAbsent function.
+func (s *SynchronizedStore) PutIfAbsent(key string, value []byte) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return PutIfAbsent(s.store, key, value)
+}
+
+// CompareAndSet updates a value in the underlying store if it is equal to expected, see the CompareAndSet function.
+func (s *SynchronizedStore) CompareAndSet(key string, expected, value []byte) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return CompareAndSet(s.store, key, expected, value)
+}
+
+// Iterate iterates the keys of the underlying store, see the Iterate function. The lock is held during the whole
+// iteration, so fn must not call the SynchronizedStore.
+func (s *SynchronizedStore) Iterate(prefix string, fn func(KeyValue) bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return Iterate(s.store, prefix, fn)
+}
+
+// Stats returns the statistics of the underlying store, see the GetStats function.
+func (s *SynchronizedStore) Stats() (StoreStats, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return GetStats(s.store)
+}
+
+// Do calls fn while holding the lock, so that a sequence of operations on the underlying store
+// (e.g. a read followed by a write) is atomic with respect to the other calls.
+func (s *SynchronizedStore) Do(fn func(store Store) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return fn(s.store)
+}
+
+// GetStore returns the underlying Store. Calls made directly to it are not synchronized.
+func (s *SynchronizedStore) GetStore() Store {
+	return s.store
+}
@@ -0,0 +1,36 @@
+package kasper
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynchronizedStore(t *testing.T) {
+	s := NewSynchronizedStore(NewMap(10))
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			for j := 0; j < 100; j++ {
+				key := strconv.Itoa(i)
+				s.Put(key, []byte(strconv.Itoa(j)))
+				s.Get(key)
+				s.GetAll([]string{key, "counter"})
+				s.Do(func(store Store) error {
+					value, _ := store.Get("counter")
+					count, _ := strconv.Atoi(string(value))
+					return store.Put("counter", []byte(strconv.Itoa(count+1)))
+				})
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+	value, err := s.Get("counter")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1000"), value)
+	assert.Equal(t, 11, len(s.GetStore().(*Map).GetMap()))
+}
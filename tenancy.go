@@ -0,0 +1,116 @@
+package kasper
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Tenancy helps MessageProcessors isolate the tenants sharing a TopicProcessor. The tenant of each message is
+// extracted with a function, e.g. from its key, and determines the store, output topics and metrics labels used
+// for the message. A rate limit per tenant keeps a noisy tenant from starving the others:
+//
+//	tenancy := kasper.NewTenancy(config, tenantFromKey, 1000)
+//	...
+//	func (p *Processor) Process(messages []*sarama.ConsumerMessage, sender kasper.Sender) error {
+//		byTenant, throttled := p.tenancy.Split(messages)
+//		for tenant, messages := range byTenant {
+//			store := p.stores.Tenant(tenant)
+//			...
+//			sender.Send(&sarama.ProducerMessage{Topic: p.tenancy.Topic("word-counts", tenant), ...})
+//		}
+//		for _, message := range throttled {
+//			sender.Send(&sarama.ProducerMessage{Topic: "word-overflow", Key: sarama.ByteEncoder(message.Key), ...})
+//		}
+//		return nil
+//	}
+//
+// Throttled messages are typically forwarded to an overflow topic consumed by another TopicProcessor.
+// The number of messages received and throttled are counted by tenant, with a "tenant" label that
+// Config.MetricsLabels must not define.
+// Tenancy is not safe for concurrent use, like the stores used by MessageProcessors.
+type Tenancy struct {
+	config            *Config
+	tenantOf          func(*sarama.ConsumerMessage) string
+	messagesPerSecond float64
+	buckets           map[string]*tokenBucket
+	messageCount      Counter
+	throttledCount    Counter
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewTenancy creates a Tenancy. Each tenant can process up to messagesPerSecond messages per second on average,
+// with bursts of up to one second worth of messages (0 disables rate limiting).
+func NewTenancy(config *Config, tenantOf func(*sarama.ConsumerMessage) string, messagesPerSecond float64) *Tenancy {
+	metrics := config.metricsProvider()
+	return &Tenancy{
+		config,
+		tenantOf,
+		messagesPerSecond,
+		make(map[string]*tokenBucket),
+		metrics.NewCounter("tenant_message_count", "Number of messages received by tenant", "tenant"),
+		metrics.NewCounter("tenant_throttled_message_count", "Number of messages throttled by tenant", "tenant"),
+	}
+}
+
+// Tenant returns the tenant of a message.
+func (t *Tenancy) Tenant(message *sarama.ConsumerMessage) string {
+	return t.tenantOf(message)
+}
+
+// Split groups messages by tenant, preserving their order, and returns separately the messages that exceed
+// the rate limit of their tenant.
+func (t *Tenancy) Split(messages []*sarama.ConsumerMessage) (map[string][]*sarama.ConsumerMessage, []*sarama.ConsumerMessage) {
+	byTenant := make(map[string][]*sarama.ConsumerMessage)
+	var throttled []*sarama.ConsumerMessage
+	now := t.config.clock().Now()
+	for _, message := range messages {
+		tenant := t.tenantOf(message)
+		t.messageCount.Inc(tenant)
+		if !t.allow(tenant, now) {
+			t.throttledCount.Inc(tenant)
+			throttled = append(throttled, message)
+			continue
+		}
+		byTenant[tenant] = append(byTenant[tenant], message)
+	}
+	return byTenant, throttled
+}
+
+func (t *Tenancy) allow(tenant string, now time.Time) bool {
+	if t.messagesPerSecond <= 0 {
+		return true
+	}
+	bucket, found := t.buckets[tenant]
+	if !found {
+		bucket = &tokenBucket{t.messagesPerSecond, now}
+		t.buckets[tenant] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.updated).Seconds() * t.messagesPerSecond
+	if bucket.tokens > t.messagesPerSecond {
+		bucket.tokens = t.messagesPerSecond
+	}
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// KeyPrefix returns the key prefix of a tenant's store, following the {tenant}/{keyPrefix} convention of
+// MultiRedis, e.g. to create a Redis or Elasticsearch store per tenant.
+func (t *Tenancy) KeyPrefix(keyPrefix, tenant string) string {
+	return fmt.Sprintf("%s/%s", tenant, keyPrefix)
+}
+
+// Topic returns the name of a tenant's output topic, of the form {topic}.{tenant}. Tenants must only contain
+// characters allowed in topic names (letters, digits, '.', '_' and '-').
+func (t *Tenancy) Topic(topic, tenant string) string {
+	return fmt.Sprintf("%s.%s", topic, tenant)
+}
@@ -0,0 +1,48 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func tenantMessages(tenants ...string) []*sarama.ConsumerMessage {
+	messages := make([]*sarama.ConsumerMessage, len(tenants))
+	for i, tenant := range tenants {
+		messages[i] = &sarama.ConsumerMessage{Key: []byte(tenant), Offset: int64(i)}
+	}
+	return messages
+}
+
+func TestTenancy_Split(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	metrics := newRecordingMetricsProvider()
+	config := &Config{TopicProcessorName: "words", ContainerID: "c0", Clock: clock, MetricsProvider: metrics}
+	tenancy := NewTenancy(config, func(message *sarama.ConsumerMessage) string { return string(message.Key) }, 2)
+
+	messages := tenantMessages("acme", "acme", "globex", "acme")
+	byTenant, throttled := tenancy.Split(messages)
+	assert.Equal(t, map[string][]*sarama.ConsumerMessage{
+		"acme":   {messages[0], messages[1]},
+		"globex": {messages[2]},
+	}, byTenant)
+	assert.Equal(t, []*sarama.ConsumerMessage{messages[3]}, throttled)
+	assert.Equal(t, 3.0, metrics.values["tenant_message_count{acme,c0,words}"])
+	assert.Equal(t, 1.0, metrics.values["tenant_throttled_message_count{acme,c0,words}"])
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	byTenant, throttled = tenancy.Split(tenantMessages("acme", "acme"))
+	assert.Len(t, byTenant["acme"], 1)
+	assert.Len(t, throttled, 1)
+}
+
+func TestTenancy_Unlimited(t *testing.T) {
+	tenancy := NewTenancy(&Config{}, func(message *sarama.ConsumerMessage) string { return string(message.Key) }, 0)
+	byTenant, throttled := tenancy.Split(tenantMessages("acme", "acme", "acme"))
+	assert.Len(t, byTenant["acme"], 3)
+	assert.Nil(t, throttled)
+	assert.Equal(t, "acme/words", tenancy.KeyPrefix("words", "acme"))
+	assert.Equal(t, "word-counts.acme", tenancy.Topic("word-counts", "acme"))
+}
@@ -0,0 +1,134 @@
+package kasper
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Maximum number of distinct messages tracked; the oldest ones are forgotten first
+const maxThrottledMessages = 100
+
+// NewThrottledLogger wraps a Logger so that identical error messages are logged at most once per interval.
+// When a suppressed message expires (i.e. is logged again after the interval, or is forgotten because more
+// recent messages are tracked), "Last message repeated N times" is logged. Debug and info messages are not throttled.
+// Set Config.ErrorLogThrottleInterval to throttle the errors logged by Kasper, in which case TopicProcessor also
+// reports the expired messages on every metrics update interval.
+func NewThrottledLogger(logger Logger, interval time.Duration, clock Clock) StructuredLogger {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &throttledLogger{logger, interval, clock, newThrottleState()}
+}
+
+type throttledMessageKey struct {
+	logger  *throttledLogger
+	message string
+}
+
+type throttledMessage struct {
+	key        throttledMessageKey
+	loggedAt   time.Time
+	suppressed int
+}
+
+// throttleState is shared by a throttledLogger and the loggers derived from it with With. Messages are kept in
+// the order they were logged in, so that the oldest ones can be expired and evicted without scanning them all.
+type throttleState struct {
+	mutex    sync.Mutex
+	messages map[throttledMessageKey]*list.Element
+	order    *list.List
+}
+
+func newThrottleState() *throttleState {
+	return &throttleState{messages: make(map[throttledMessageKey]*list.Element), order: list.New()}
+}
+
+type throttledLogger struct {
+	logger   Logger
+	interval time.Duration
+	clock    Clock
+	state    *throttleState
+}
+
+// With returns a logger sharing the throttling state, whose messages are throttled separately since messages
+// with different fields are not identical.
+func (l *throttledLogger) With(fields ...Field) StructuredLogger {
+	return &throttledLogger{WithFields(l.logger, fields...), l.interval, l.clock, l.state}
+}
+
+func (l *throttledLogger) Debug(vs ...interface{}) {
+	l.logger.Debug(vs...)
+}
+
+func (l *throttledLogger) Debugf(format string, vs ...interface{}) {
+	l.logger.Debugf(format, vs...)
+}
+
+func (l *throttledLogger) Info(vs ...interface{}) {
+	l.logger.Info(vs...)
+}
+
+func (l *throttledLogger) Infof(format string, vs ...interface{}) {
+	l.logger.Infof(format, vs...)
+}
+
+func (l *throttledLogger) Error(vs ...interface{}) {
+	l.error(fmt.Sprint(vs...))
+}
+
+func (l *throttledLogger) Errorf(format string, vs ...interface{}) {
+	l.error(fmt.Sprintf(format, vs...))
+}
+
+func (l *throttledLogger) Panic(vs ...interface{}) {
+	l.logger.Panic(vs...)
+}
+
+func (l *throttledLogger) Panicf(format string, vs ...interface{}) {
+	l.logger.Panicf(format, vs...)
+}
+
+func (l *throttledLogger) error(message string) {
+	state := l.state
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	now := l.clock.Now()
+	l.expire(now)
+	key := throttledMessageKey{l, message}
+	if element, found := state.messages[key]; found {
+		element.Value.(*throttledMessage).suppressed++
+		return
+	}
+	l.logger.Error(message)
+	state.messages[key] = state.order.PushBack(&throttledMessage{key, now, 0})
+	if state.order.Len() > maxThrottledMessages {
+		l.forget(state.order.Front())
+	}
+}
+
+// flushExpired forgets the expired messages, reporting how many times they were suppressed.
+func (l *throttledLogger) flushExpired() {
+	l.state.mutex.Lock()
+	defer l.state.mutex.Unlock()
+	l.expire(l.clock.Now())
+}
+
+func (l *throttledLogger) expire(now time.Time) {
+	for element := l.state.order.Front(); element != nil; element = l.state.order.Front() {
+		if now.Sub(element.Value.(*throttledMessage).loggedAt) < l.interval {
+			return
+		}
+		l.forget(element)
+	}
+}
+
+func (l *throttledLogger) forget(element *list.Element) {
+	throttled := element.Value.(*throttledMessage)
+	if throttled.suppressed > 0 {
+		throttled.key.logger.logger.Errorf("Last message repeated %d times: %s", throttled.suppressed, throttled.key.message)
+	}
+	l.state.order.Remove(element)
+	delete(l.state.messages, throttled.key)
+}
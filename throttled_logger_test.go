@@ -0,0 +1,92 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottledLogger(t *testing.T) {
+	zap := &recordingZapLogger{}
+	clock := &fixedClock{now: time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)}
+	logger := NewThrottledLogger(NewZapLogger(zap), time.Minute, clock)
+
+	for i := 0; i < 1000; i++ {
+		logger.Errorf("Cannot connect to %s", "redis:6379")
+		logger.Info("retrying")
+	}
+	logger.Error("Cannot connect to elasticsearch:9200")
+	clock.now = clock.now.Add(time.Minute)
+	logger.Errorf("Cannot connect to %s", "redis:6379")
+
+	assert.Equal(t, 1004, len(zap.entries))
+	assert.Equal(t, []string{
+		"ERROR Cannot connect to redis:6379 []",
+		"INFO retrying []",
+	}, zap.entries[:2])
+	assert.Equal(t, []string{
+		"ERROR Cannot connect to elasticsearch:9200 []",
+		"ERROR Last message repeated 999 times: Cannot connect to redis:6379 []",
+		"ERROR Cannot connect to redis:6379 []",
+	}, zap.entries[1001:])
+}
+
+func TestThrottledLogger_With(t *testing.T) {
+	zap := &recordingZapLogger{}
+	logger := NewThrottledLogger(NewZapLogger(zap), time.Minute, nil)
+	logger.With(Field{"partition", 0}).Error("failed")
+	logger.With(Field{"partition", 1}).Error("failed")
+	logger.Error("failed")
+	logger.Error("failed")
+	assert.Equal(t, []string{
+		"ERROR failed [partition 0]",
+		"ERROR failed [partition 1]",
+		"ERROR failed []",
+	}, zap.entries)
+}
+
+func TestThrottledLogger_EvictsOldestMessages(t *testing.T) {
+	zap := &recordingZapLogger{}
+	clock := &fixedClock{now: time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)}
+	logger := NewThrottledLogger(NewZapLogger(zap), time.Minute, clock).(*throttledLogger)
+	logger.Error("message 0")
+	logger.Error("message 0")
+	for i := 1; i <= 10*maxThrottledMessages; i++ {
+		logger.Errorf("message %d", i)
+	}
+	assert.Len(t, logger.state.messages, maxThrottledMessages)
+	assert.Equal(t, maxThrottledMessages, logger.state.order.Len())
+	assert.Equal(t, "ERROR Last message repeated 1 times: message 0 []", zap.entries[maxThrottledMessages+1])
+}
+
+func TestThrottledLogger_FlushExpired(t *testing.T) {
+	zap := &recordingZapLogger{}
+	clock := &fixedClock{now: time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)}
+	logger := NewThrottledLogger(NewZapLogger(zap), time.Minute, clock).(*throttledLogger)
+	child := logger.With(Field{"partition", 1})
+	child.Error("failed")
+	child.Error("failed")
+	child.Error("failed")
+	logger.flushExpired()
+	assert.Len(t, zap.entries, 1)
+
+	clock.now = clock.now.Add(time.Minute)
+	logger.flushExpired()
+	assert.Equal(t, []string{
+		"ERROR failed [partition 1]",
+		"ERROR Last message repeated 2 times: failed [partition 1]",
+	}, zap.entries)
+	assert.Empty(t, logger.state.messages)
+}
+
+func TestConfig_logger(t *testing.T) {
+	config := &Config{}
+	assert.NotNil(t, config.logger())
+	assert.True(t, config.logger() == config.Logger)
+
+	config.ErrorLogThrottleInterval = time.Second
+	throttled := config.logger()
+	assert.IsType(t, &throttledLogger{}, throttled)
+	assert.True(t, throttled == config.logger())
+}
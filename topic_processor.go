@@ -41,8 +41,10 @@ to a new topic with the desired number of partitions.
 	}
 
 Kasper is instrumented with a number of useful metrics so we
-recommend setting MetricsProvider for production applications. Kasper includes an implementation for collecting
-metrics in Prometheus and adapting the interface to other tools should be easy.
+recommend setting MetricsProvider for production applications. All metrics are labeled with the TopicProcessorName
+and the ContainerID (which defaults to the hostname), and per-partition and store metrics also carry a partition label.
+Static labels such as the environment can be added to all metrics with MetricsLabels. Kasper includes implementations for collecting
+metrics in Prometheus and for pushing metrics to InfluxDB, and adapting the interface to other tools should be easy.
 
 Step 3 - Create a MessageProcessor per input partition
 
@@ -65,6 +67,8 @@ Step 4 - Create a TopicProcessor
 To start processing messages, call TopicProcessor.RunLoop(). Kasper does not spawn any goroutines and runs a single-threaded
 event loop instead. RunLoop() will block the current goroutine and will run forever until an error occurs or until
 Close() is called.
+Use TopicProcessor.RunUntilSignalled() instead to stop gracefully on SIGINT or SIGTERM, processing the messages
+already received before closing.
 For parallel processing, run multiple TopicProcessor instances in different goroutines or processes
 (the input partitions cannot overlap). You should set Config.TopicProcessorName to the same value on
 all instances in order to easily scale the processing up or down.
@@ -73,9 +77,10 @@ all instances in order to easily scale the processing up or down.
 package kasper
 
 import (
+	"fmt"
 	"strconv"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/Shopify/sarama"
 )
@@ -96,6 +101,16 @@ type TopicProcessor struct {
 	incomingMessageCount        Counter
 	outgoingMessageCount        Counter
 	messagesBehindHighWaterMark Gauge
+	dryRunMessageCount          Counter
+	stats                       *runtimeStats
+	slowConsumerDetector        *slowConsumerDetector
+	metricsPushMonitor          *metricsPushMonitor
+	payloadSampler              *payloadSampler
+	storeStatsReporter          *storeStatsReporter
+	batchSizeController         *batchSizeController
+	running                     int32
+	requests                    chan *loopRequest
+	loopDone                    chan struct{}
 }
 
 // MessageProcessor is the interface that encapsulates application business logic.
@@ -103,6 +118,9 @@ type TopicProcessor struct {
 type MessageProcessor interface {
 	// Process receives a slice of incoming Kafka messages and a Sender to send messages to output topics.
 	// References to the byte slice or Sender interface cannot be held between calls.
+	// The keys and values of the messages are passed as decoded by sarama, without copies: they share the memory
+	// of the fetch response, so they must not be modified, and a value kept after Process returns (e.g. put in a
+	// Map) keeps the whole fetch response in memory. Copy the values that are kept, or store their decoded form.
 	// If Process returns a non-nil error value, Kasper stops all processing.
 	// This error value is then returned by TopicProcessor.RunLoop().
 	Process([]*sarama.ConsumerMessage, Sender) error
@@ -112,44 +130,73 @@ type MessageProcessor interface {
 // For parallel processing, run multiple TopicProcessor instances in different goroutines or processes
 // (the input partitions cannot overlap). You should set Config.TopicProcessorName to the same value on
 // all instances in order to easily scale the processing up or down.
+// Errors are passed to Config.OnFatalError, which panics by default, so NewTopicProcessor never returns nil.
+// Use OpenTopicProcessor to handle errors instead.
 func NewTopicProcessor(config *Config, messageProcessors map[int]MessageProcessor) *TopicProcessor {
+	topicProcessor, err := OpenTopicProcessor(config, messageProcessors)
+	if err != nil {
+		config.fatalError(err)
+	}
+	return topicProcessor
+}
+
+// OpenTopicProcessor is like NewTopicProcessor but returns an error if the TopicProcessor cannot be created
+// (e.g. if Kafka is unreachable). Any consumers already opened are closed before returning.
+func OpenTopicProcessor(config *Config, messageProcessors map[int]MessageProcessor) (*TopicProcessor, error) {
 	config.setDefaults()
-	inputTopics := config.InputTopics
-	partitions := config.InputPartitions
-	offsetManager := mustSetupOffsetManager(config)
-	partitionProcessors := make(map[int32]*partitionProcessor, len(partitions))
-	producer := mustSetupProducer(config)
-	provider := config.MetricsProvider
-	topicProcessor := TopicProcessor{
+	for _, partition := range config.InputPartitions {
+		if _, found := messageProcessors[partition]; !found {
+			return nil, fmt.Errorf("messageProcessor doesn't contain an entry for partition %d", partition)
+		}
+	}
+	offsetManager, err := config.offsetManager()
+	if err != nil {
+		return nil, err
+	}
+	producer, err := sarama.NewSyncProducerFromClient(config.Client)
+	if err != nil {
+		offsetManager.Close()
+		return nil, err
+	}
+	topicProcessor := newTopicProcessor(config, producer, offsetManager)
+	for _, partition := range config.InputPartitions {
+		pp, err := newPartitionProcessor(topicProcessor, messageProcessors[partition], partition)
+		if err != nil {
+			topicProcessor.onClose()
+			return nil, err
+		}
+		topicProcessor.partitionProcessors[int32(partition)] = pp
+	}
+	return topicProcessor, nil
+}
+
+// newTopicProcessor creates a TopicProcessor without any partition processors.
+func newTopicProcessor(config *Config, producer sarama.SyncProducer, offsetManager sarama.OffsetManager) *TopicProcessor {
+	provider := config.metricsProvider()
+	return &TopicProcessor{
 		config,
 		producer,
 		offsetManager,
-		partitionProcessors,
-		inputTopics,
-		partitions,
+		make(map[int32]*partitionProcessor, len(config.InputPartitions)),
+		config.InputTopics,
+		config.InputPartitions,
 		make(chan struct{}),
 		sync.WaitGroup{},
-		config.Logger,
+		WithFields(config.logger(), Field{"topicProcessor", config.TopicProcessorName}),
 		provider.NewCounter("incoming_message_count", "Number of incoming messages received", "topic", "partition"),
 		provider.NewCounter("outgoing_message_count", "Number of outgoing messages sent", "topic", "partition"),
 		provider.NewGauge("messages_behind_high_water_mark_count", "Number of messages remaining to consume on the topic/partition", "topic", "partition"),
+		provider.NewCounter("dry_run_message_count", "Number of outgoing messages not sent in dry run mode"),
+		config.stats(),
+		newSlowConsumerDetector(config),
+		newMetricsPushMonitor(config),
+		newPayloadSampler(config),
+		newStoreStatsReporter(config),
+		newBatchSizeController(config),
+		0,
+		make(chan *loopRequest),
+		make(chan struct{}),
 	}
-	for _, partition := range partitions {
-		mp, found := messageProcessors[partition]
-		if !found {
-			config.Logger.Panicf("messageProcessor doesn't contain an entry for partition %d", partition)
-		}
-		partitionProcessors[int32(partition)] = newPartitionProcessor(&topicProcessor, mp, partition)
-	}
-	return &topicProcessor
-}
-
-func mustSetupOffsetManager(config *Config) sarama.OffsetManager {
-	offsetManager, err := sarama.NewOffsetManagerFromClient(config.kafkaConsumerGroup(), config.Client)
-	if err != nil {
-		config.Logger.Panic(err)
-	}
-	return offsetManager
 }
 
 // Close safely shuts down the TopicProcessor, which makes RunLoop() return.
@@ -161,6 +208,19 @@ func (tp *TopicProcessor) Close() {
 	tp.waitGroup.Wait()
 }
 
+// Metrics returns a snapshot of the runtime metrics of the TopicProcessor and of the stores created with its Config.
+// Rates and store latencies are computed over the last Config.MetricsUpdateInterval.
+// It is safe to call Metrics from any goroutine.
+func (tp *TopicProcessor) Metrics() Snapshot {
+	return tp.stats.snapshot(tp.config.clock().Now())
+}
+
+// IsRunning returns true when RunLoop has assigned all partitions and is processing messages.
+// It is safe to call IsRunning from any goroutine.
+func (tp *TopicProcessor) IsRunning() bool {
+	return atomic.LoadInt32(&tp.running) == 1
+}
+
 // HasConsumedAllMessages returns true when all input topics have been entirely consumed.
 // Kasper checks all high water marks and offsets for all topics before returning.
 func (tp *TopicProcessor) HasConsumedAllMessages() bool {
@@ -187,96 +247,241 @@ func (tp *TopicProcessor) getBatches() map[int][]*sarama.ConsumerMessage {
 // RunLoop is the main processing loop of Kasper. It does not spawn any goroutines and runs a single-threaded
 // event loop instead. RunLoop will block the current goroutine and will run forever until an error occurs or until
 // Close() is called. RunLoop propagates the error returned by MessageProcessor.Process if not nil.
+// After Close() is called, RunLoop returns the first error encountered while closing the consumers and the producer.
 func (tp *TopicProcessor) RunLoop() error {
+	for _, partition := range tp.partitions {
+		err := tp.partitionProcessors[int32(partition)].onAssigned()
+		if err != nil {
+			tp.logger.Errorf("Failed to assign partition %d: %s", partition, err)
+			tp.onClose()
+			return err
+		}
+	}
 	consumerChan := tp.getConsumerMessagesChan()
-	metricsTicker := time.NewTicker(tp.config.MetricsUpdateInterval)
-	batchTicker := time.NewTicker(tp.config.BatchWaitDuration)
+	metricsTicker := tp.config.Clock.NewTicker(tp.config.MetricsUpdateInterval)
+	batchTicker := tp.config.Clock.NewTicker(tp.config.BatchWaitDuration)
 
 	batches := tp.getBatches()
 	lengths := make(map[int]int)
+	paused := false
+
+	processPendingBatches := func() error {
+		for _, partition := range tp.partitions {
+			if lengths[partition] == 0 {
+				continue
+			}
+			tp.logger.Debugf("Processing batch of %d messages...", lengths[partition])
+			err := tp.processConsumerMessages(batches[partition][0:lengths[partition]], partition)
+			if err != nil {
+				return err
+			}
+			lengths[partition] = 0
+			tp.logger.Debug("Processing of batch complete")
+		}
+		return nil
+	}
 
 	tp.logger.Info("Entering run loop")
+	atomic.StoreInt32(&tp.running, 1)
 
 	for {
+		messages := consumerChan
+		if paused {
+			messages = nil
+		}
 		select {
-		case consumerMessage := <-consumerChan:
-			tp.logger.Debugf("Received: %s", consumerMessage)
+		case consumerMessage := <-messages:
+			tp.logger.Debugf("Received: %v", consumerMessage)
 			partition := int(consumerMessage.Partition)
 			batches[partition][lengths[partition]] = consumerMessage
 			lengths[partition]++
-			if lengths[partition] == tp.config.BatchSize {
-				tp.logger.Debugf("Processing batch of %d messages...", tp.config.BatchSize)
-				err := tp.processConsumerMessages(batches[partition], partition)
+			if length := lengths[partition]; length >= tp.batchSizeController.batchSize(tp.config.BatchSize) {
+				tp.logger.Debugf("Processing batch of %d messages...", length)
+				start := tp.config.Clock.Now()
+				err := tp.processConsumerMessages(batches[partition][0:length], partition)
 				if err != nil {
 					tp.onClose(metricsTicker, batchTicker)
 					return err
 				}
+				tp.batchSizeController.observe(length, tp.config.BatchSize, tp.config.Clock.Now().Sub(start))
 				lengths[partition] = 0
 				tp.logger.Debug("Processing of batch complete")
 			}
-		case <-metricsTicker.C:
+		case <-metricsTicker.Chan():
 			tp.onMetricsTick()
-		case <-batchTicker.C:
-			for _, partition := range tp.partitions {
-				if lengths[partition] == 0 {
-					continue
+		case <-batchTicker.Chan():
+			err := processPendingBatches()
+			if err != nil {
+				tp.onClose(metricsTicker, batchTicker)
+				return err
+			}
+		case request := <-tp.requests:
+			var err error
+			switch request.command {
+			case commandPause:
+				tp.logger.Info("Pausing consumption")
+				paused = true
+			case commandResume:
+				tp.logger.Info("Resuming consumption")
+				paused = false
+			case commandFlush:
+				err = processPendingBatches()
+			case commandOffsets:
+				request.offsets = tp.offsets()
+			case commandCall:
+				request.callErr = request.call()
+			case commandReconfigure:
+				update := request.update
+				if update.BatchSize > 0 && update.BatchSize != tp.config.BatchSize {
+					err = processPendingBatches()
+					if err == nil {
+						tp.config.BatchSize = update.BatchSize
+						batches = tp.getBatches()
+					}
 				}
-				tp.logger.Debugf("Processing batch of %d messages...", lengths[partition])
-				err := tp.processConsumerMessages(batches[partition][0:lengths[partition]], partition)
-				if err != nil {
-					tp.onClose(metricsTicker, batchTicker)
-					return err
+				if err == nil && update.BatchWaitDuration.Duration > 0 && update.BatchWaitDuration.Duration != tp.config.BatchWaitDuration {
+					batchTicker.Stop()
+					tp.config.BatchWaitDuration = update.BatchWaitDuration.Duration
+					batchTicker = tp.config.Clock.NewTicker(tp.config.BatchWaitDuration)
 				}
-				lengths[partition] = 0
-				tp.logger.Debug("Processing of batch complete")
+			}
+			request.done <- err
+			if err != nil {
+				tp.onClose(metricsTicker, batchTicker)
+				return err
 			}
 		case <-tp.close:
-			tp.onClose(metricsTicker, batchTicker)
-			return nil
+			return tp.onClose(metricsTicker, batchTicker)
 		}
 	}
 }
 
-func (tp *TopicProcessor) processConsumerMessages(messages []*sarama.ConsumerMessage, partition int) error {
+func (tp *TopicProcessor) processConsumerMessages(messages []*sarama.ConsumerMessage, partition int) (err error) {
 	for _, message := range messages {
 		tp.incomingMessageCount.Inc(message.Topic, strconv.Itoa(int(message.Partition)))
 	}
+	tp.stats.addIncoming(len(messages))
+	tp.config.setActivePartition(partition)
+	defer tp.config.setActivePartition(-1)
 	pp := tp.partitionProcessors[int32(partition)]
-	producerMessages, err := pp.process(messages)
+	tracer := tp.config.tracer()
+	batchSpan := tracer.StartSpan("kasper.batch", extractParent(tracer, messages), map[string]string{
+		"topicProcessor": tp.config.TopicProcessorName,
+		"partition":      strconv.Itoa(partition),
+		"messages":       strconv.Itoa(len(messages)),
+	})
+	defer func() { batchSpan.Finish(err) }()
+	span := tracer.StartSpan("kasper.process", batchSpan, nil)
+	sender, err := pp.process(messages, span)
+	span.Finish(err)
 	if err != nil {
+		tp.stats.addError("process")
+		return err
+	}
+	defer sender.releaseBuffers()
+	if err := tp.produce(sender.producerMessages, batchSpan); err != nil {
+		tp.logger.Errorf("Failed to produce messages: %s", err)
+		tp.stats.addError("produce")
 		return err
 	}
-	if len(producerMessages) > 0 {
-		tp.logger.Debugf("Producing %d Kafka messages...", len(producerMessages))
-		err := tp.producer.SendMessages(producerMessages)
-		tp.logger.Debug("Producing of Kafka messages complete")
+	if tp.config.OffsetStore != nil {
+		// The offsets are flushed with the stores, see Config.OffsetStore
+		span = tracer.StartSpan("kasper.commit", batchSpan, nil)
+		err = pp.markOffsets(messages)
+		span.Finish(err)
 		if err != nil {
-			tp.logger.Errorf("Failed to produce messages: %s", err)
+			tp.logger.Errorf("Failed to write offsets: %s", err)
+			tp.stats.addError("store")
 			return err
 		}
 	}
-	pp.markOffsets(messages)
-	for _, message := range producerMessages {
+	span = tracer.StartSpan("kasper.flush", batchSpan, nil)
+	err = tp.config.flushStores()
+	if err == nil {
+		err = tp.config.flushOffsetStore()
+	}
+	span.Finish(err)
+	if err != nil {
+		tp.logger.Errorf("Failed to flush stores: %s", err)
+		tp.stats.addError("flush")
+		return err
+	}
+	if tp.config.OffsetStore == nil {
+		span = tracer.StartSpan("kasper.commit", batchSpan, nil)
+		pp.markOffsets(messages)
+		span.Finish(nil)
+	}
+	if tp.config.ProgressTopic != "" && !tp.config.DryRun {
+		pp.updateStreamTimes(messages)
+		tp.publishProgress(pp)
+	}
+	return nil
+}
+
+// produce sends messages to Kafka and waits for the acks, both at the end of a batch and from Sender.Flush.
+// When Config.DryRun is set, the messages are logged at debug level and counted instead.
+func (tp *TopicProcessor) produce(messages []*sarama.ProducerMessage, parent Span) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	if tp.config.DryRun {
+		tp.logger.Debugf("Dry run: not producing %d Kafka messages", len(messages))
+		tp.dryRunMessageCount.Add(float64(len(messages)))
+		return nil
+	}
+	tp.logger.Debugf("Producing %d Kafka messages...", len(messages))
+	span := tp.config.tracer().StartSpan("kasper.produce", parent, nil)
+	err := tp.producer.SendMessages(messages)
+	span.Finish(err)
+	if err != nil {
+		return err
+	}
+	tp.logger.Debug("Producing of Kafka messages complete")
+	for _, message := range messages {
 		tp.outgoingMessageCount.Inc(message.Topic, strconv.Itoa(int(message.Partition)))
 	}
+	tp.stats.addOutgoing(len(messages))
 	return nil
 }
 
-func (tp *TopicProcessor) onClose(tickers ...*time.Ticker) {
+// onClose releases all consumers and the producer. All errors are logged and the first one is returned.
+func (tp *TopicProcessor) onClose(tickers ...Ticker) error {
 	tp.logger.Info("Closing topic processor...")
+	if atomic.SwapInt32(&tp.running, 0) == 1 {
+		close(tp.loopDone)
+	}
 	for _, ticker := range tickers {
 		if ticker != nil {
 			ticker.Stop()
 		}
 	}
+	tp.metricsPushMonitor.close()
+	var firstErr error
 	for _, pp := range tp.partitionProcessors {
-		pp.onClose()
+		err := pp.onClose()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	// Closing the offset manager commits the offsets marked since the last periodic commit
+	if tp.offsetManager != nil {
+		err := tp.offsetManager.Close()
+		if err != nil {
+			tp.logger.Errorf("Cannot close offset manager: %s", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
 	}
 	err := tp.producer.Close()
 	if err != nil {
-		tp.logger.Panic(err)
+		tp.logger.Errorf("Cannot close producer: %s", err)
+		if firstErr == nil {
+			firstErr = err
+		}
 	}
 	tp.logger.Info("Close complete")
+	return firstErr
 }
 
 func (tp *TopicProcessor) isClosed() bool {
@@ -312,6 +517,14 @@ func (tp *TopicProcessor) onMetricsTick() {
 	for _, pp := range tp.partitionProcessors {
 		pp.onMetricsTick()
 	}
+	now := tp.config.Clock.Now()
+	tp.stats.tick(now)
+	tp.slowConsumerDetector.check(tp.stats.snapshot(now))
+	tp.storeStatsReporter.report()
+	tp.metricsPushMonitor.push()
+	if tp.config.throttledLogger != nil {
+		tp.config.throttledLogger.flushExpired()
+	}
 }
 
 func (tp *TopicProcessor) consumerMessageChannels() []<-chan *sarama.ConsumerMessage {
@@ -322,11 +535,3 @@ func (tp *TopicProcessor) consumerMessageChannels() []<-chan *sarama.ConsumerMes
 	}
 	return chans
 }
-
-func mustSetupProducer(config *Config) sarama.SyncProducer {
-	producer, err := sarama.NewSyncProducerFromClient(config.Client)
-	if err != nil {
-		config.Logger.Panic(err)
-	}
-	return producer
-}
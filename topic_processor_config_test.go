@@ -1,8 +1,11 @@
 package kasper
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,3 +22,201 @@ func TestTopicProcessorConfig_producerClientID(t *testing.T) {
 	}
 	assert.Equal(t, "kasper-topic-processor-ford-prefect", c.producerClientID())
 }
+
+type configOnlyClient struct {
+	sarama.Client
+	config *sarama.Config
+}
+
+func (c *configOnlyClient) Config() *sarama.Config {
+	return c.config
+}
+
+func TestConfig_fatalError(t *testing.T) {
+	var fatalErr error
+	c := &Config{
+		Logger:       &noopLogger{},
+		OnFatalError: func(err error) { fatalErr = err },
+	}
+	// OnFatalError must not return, Kasper panics if it does
+	assert.Panics(t, func() {
+		c.fatalError(errors.New("boom"))
+	})
+	assert.EqualError(t, fatalErr, "boom")
+
+	c = &Config{Logger: &noopLogger{}}
+	assert.Panics(t, func() {
+		c.fatalError(errors.New("boom"))
+	})
+}
+
+func TestOpenTopicProcessor_MissingMessageProcessor(t *testing.T) {
+	var fatalErr error
+	c := &Config{
+		TopicProcessorName: "zaphod-beeblebrox",
+		Client:             &configOnlyClient{config: sarama.NewConfig()},
+		InputTopics:        []string{"heads"},
+		InputPartitions:    []int{0, 1},
+		Logger:             &noopLogger{},
+		OnFatalError:       func(err error) { fatalErr = err },
+	}
+	messageProcessors := map[int]MessageProcessor{0: nil}
+
+	tp, err := OpenTopicProcessor(c, messageProcessors)
+	assert.Nil(t, tp)
+	assert.EqualError(t, err, "messageProcessor doesn't contain an entry for partition 1")
+
+	assert.Panics(t, func() {
+		NewTopicProcessor(c, messageProcessors)
+	})
+	assert.EqualError(t, fatalErr, "messageProcessor doesn't contain an entry for partition 1")
+}
+
+type metadataClient struct {
+	sarama.Client
+	partitions map[string]int
+	refreshErr error
+}
+
+func (c *metadataClient) RefreshMetadata(topics ...string) error {
+	return c.refreshErr
+}
+
+func (c *metadataClient) Partitions(topic string) ([]int32, error) {
+	count, found := c.partitions[topic]
+	if !found {
+		return nil, errors.New("unknown topic")
+	}
+	partitions := make([]int32, count)
+	for i := range partitions {
+		partitions[i] = int32(i)
+	}
+	return partitions, nil
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := &Config{
+		TopicProcessorName: "arthur-dent",
+		Client:             &metadataClient{partitions: map[string]int{"tweets": 4, "followers": 4, "reach": 2}},
+		InputTopics:        []string{"tweets", "followers"},
+		InputPartitions:    []int{0, 1, 2, 3},
+		OutputTopics:       []string{"reach"},
+		Serdes:             map[string]Serde{"tweets": nil, "followers": nil, "reach": nil},
+	}
+	assert.Nil(t, c.Validate())
+}
+
+func TestConfig_Validate_Problems(t *testing.T) {
+	c := &Config{
+		Client:                &metadataClient{partitions: map[string]int{"tweets": 4, "followers": 2}},
+		InputTopics:           []string{"tweets", "followers"},
+		InputPartitions:       []int{3, 1, 1},
+		OutputTopics:          []string{"reach"},
+		BatchWaitDuration:     -time.Second,
+		MetricsUpdateInterval: time.Millisecond,
+		Serdes:                map[string]Serde{"tweets": nil},
+	}
+	assert.Equal(t, &ConfigError{[]string{
+		"topic processor name is required",
+		"input partition 1 is listed more than once",
+		"batch wait duration cannot be negative",
+		"no serde for topic followers",
+		"no serde for topic reach",
+		"input topic followers has 2 partitions, but tweets has 4",
+		"input topic followers has no partition 3",
+		"output topic reach: unknown topic",
+	}}, c.Validate())
+}
+
+func TestConfig_Validate_DefaultBatchSize(t *testing.T) {
+	c := &Config{
+		TopicProcessorName: "arthur-dent",
+		Client:             &metadataClient{partitions: map[string]int{"tweets": 4}},
+		InputTopics:        []string{"tweets"},
+		InputPartitions:    []int{0},
+		MinBatchSize:       100,
+		TargetBatchLatency: time.Second,
+	}
+	assert.Nil(t, c.Validate())
+	c.MinBatchSize = defaultBatchSize + 1
+	assert.Equal(t, &ConfigError{[]string{
+		"MinBatchSize must be between 0 and BatchSize, and TargetBatchLatency cannot be negative",
+	}}, c.Validate())
+}
+
+func TestConfig_Validate_Unreachable(t *testing.T) {
+	c := &Config{
+		TopicProcessorName: "arthur-dent",
+		Client:             &metadataClient{refreshErr: errors.New("connection refused")},
+		InputTopics:        []string{"tweets"},
+		InputPartitions:    []int{0},
+	}
+	assert.EqualError(t, c.Validate(), "invalid configuration: cannot reach Kafka brokers: connection refused")
+}
+
+func TestConfig_Stores(t *testing.T) {
+	words := NewMap(10)
+	c := &Config{Stores: []NamedStore{{"words", words}, {"counts", nil}, {"words", NewMap(10)}}}
+	assert.Equal(t, words, c.Store("words"))
+	assert.Nil(t, c.Store("users"))
+	err := c.Validate().(*ConfigError)
+	assert.Contains(t, err.Problems, "stores must have a name and a Store")
+	assert.Contains(t, err.Problems, "store words is registered more than once")
+}
+
+func TestTopicProcessor_FlushStores(t *testing.T) {
+	failing := &flakyStore{NewMap(10), 0, errors.New("disk full"), 0}
+	var flushes []string
+	config := &Config{Stores: []NamedStore{
+		{"words", &flushRecordingStore{NewMap(10), "words", &flushes}},
+		{"counts", &flushRecordingStore{failing, "counts", &flushes}},
+	}}
+	tp := newFakeTopicProcessor(config, &countingProcessor{})
+	done := tp.start()
+	tp.send(0, 0, "a")
+	waitFor(t, func() bool {
+		assert.Nil(t, tp.Flush())
+		offset, _ := tp.offsets[0].NextOffset()
+		return offset == 1
+	})
+	assert.Equal(t, []string{"words", "counts"}, flushes)
+
+	// Offsets are not committed if a store cannot be flushed
+	failing.failures = 100
+	tp.send(0, 1, "b")
+	var err error
+	waitFor(t, func() bool {
+		err = tp.Flush()
+		return err != nil
+	})
+	assert.EqualError(t, err, "cannot flush store counts: disk full")
+	assert.NotNil(t, <-done)
+	offset, _ := tp.offsets[0].NextOffset()
+	assert.Equal(t, int64(1), offset)
+}
+
+type flushRecordingStore struct {
+	Store
+	name    string
+	flushes *[]string
+}
+
+func (s *flushRecordingStore) Flush() error {
+	*s.flushes = append(*s.flushes, s.name)
+	return s.Store.Flush()
+}
+
+func TestTopicProcessor_MessageValuesAreNotCopied(t *testing.T) {
+	value := []byte("mars")
+	received := make(chan []byte, 1)
+	processor := processorFunc(func(messages []*sarama.ConsumerMessage, sender Sender) error {
+		received <- messages[0].Value
+		return nil
+	})
+	tp := newFakeTopicProcessor(&Config{BatchSize: 1}, processor)
+	done := tp.start()
+	tp.consumers[0].messages <- &sarama.ConsumerMessage{Topic: "input", Value: value}
+	assert.True(t, &value[0] == &(<-received)[0])
+	tp.Close()
+	assert.Nil(t, <-done)
+}
@@ -0,0 +1,89 @@
+package kasper
+
+import "github.com/Shopify/sarama"
+
+// Tracer records how long each stage of processing a batch takes, as spans of a trace per batch:
+//
+//	kasper.batch            tags: topicProcessor, partition, messages
+//	├── kasper.process      MessageProcessor.Process, see TracingSender for child spans
+//	├── kasper.produce      sending the outgoing messages and waiting for the acks
+//	├── kasper.flush        flushing Config.Stores
+//	└── kasper.commit       marking the offsets
+//
+// Implement it to export the spans to a tracing system such as OpenTelemetry or Jaeger. Tracers implementing
+// HeaderPropagator also propagate the trace context through Kafka record headers.
+type Tracer interface {
+	// StartSpan starts a span. parent is nil for the kasper.batch span, unless it was extracted from the
+	// headers of the messages by a HeaderPropagator.
+	StartSpan(operation string, parent Span, tags map[string]string) Span
+}
+
+// HeaderPropagator is implemented by Tracers that propagate the trace context through Kafka record headers
+// (Kafka 0.11 or later), e.g. in the W3C traceparent header. The kasper.batch span continues the trace of the first
+// message of the batch carrying a trace context, and the context of the kasper.process span is injected into the
+// headers of the messages passed to Sender.
+type HeaderPropagator interface {
+	// Extract returns a span representing the trace context carried by the headers of an incoming message, or nil.
+	// It is only used as a parent: Kasper never calls Finish on it.
+	Extract(headers []*sarama.RecordHeader) Span
+	// Inject returns the headers of an outgoing message with the trace context of span added.
+	Inject(span Span, headers []sarama.RecordHeader) []sarama.RecordHeader
+}
+
+// extractParent returns the trace context of the first message carrying one, if tracer is a HeaderPropagator.
+func extractParent(tracer Tracer, messages []*sarama.ConsumerMessage) Span {
+	propagator, ok := tracer.(HeaderPropagator)
+	if !ok {
+		return nil
+	}
+	for _, message := range messages {
+		if parent := propagator.Extract(message.Headers); parent != nil {
+			return parent
+		}
+	}
+	return nil
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// Tracer returns the Tracer which started the span, to start child spans.
+	Tracer() Tracer
+	// SetTag adds a tag to the span.
+	SetTag(key, value string)
+	// Finish ends the span. err is the error of the operation, or nil if it succeeded.
+	Finish(err error)
+}
+
+// TracingSender is implemented by the Sender given to MessageProcessor.Process. Span returns the kasper.process
+// span, so that the MessageProcessor can start child spans, e.g. around its store calls.
+type TracingSender interface {
+	Sender
+	Span() Span
+}
+
+// StartSpan starts a child span of the span of sender, if it implements TracingSender. Otherwise it returns a Span
+// which does nothing. Call Finish on the returned span:
+//
+//	span := kasper.StartSpan(sender, "store.GetAll", nil)
+//	kvs, err := store.GetAll(keys)
+//	span.Finish(err)
+func StartSpan(sender Sender, operation string, tags map[string]string) Span {
+	tracingSender, ok := sender.(TracingSender)
+	if !ok {
+		return noopSpan{}
+	}
+	parent := tracingSender.Span()
+	return parent.Tracer().StartSpan(operation, parent, tags)
+}
+
+type noopTracer struct{}
+
+func (noopTracer) StartSpan(operation string, parent Span, tags map[string]string) Span {
+	return noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) Tracer() Tracer           { return noopTracer{} }
+func (noopSpan) SetTag(key, value string) {}
+func (noopSpan) Finish(err error)         {}
@@ -0,0 +1,132 @@
+package kasper
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingTracer struct {
+	mutex    sync.Mutex
+	finished []*recordingSpan
+}
+
+type recordingSpan struct {
+	tracer    *recordingTracer
+	operation string
+	parent    Span
+	tags      map[string]string
+	err       error
+}
+
+func (t *recordingTracer) StartSpan(operation string, parent Span, tags map[string]string) Span {
+	return &recordingSpan{t, operation, parent, tags, nil}
+}
+
+func (s *recordingSpan) Tracer() Tracer { return s.tracer }
+
+func (s *recordingSpan) SetTag(key, value string) {
+	if s.tags == nil {
+		s.tags = make(map[string]string)
+	}
+	s.tags[key] = value
+}
+
+func (s *recordingSpan) Finish(err error) {
+	s.err = err
+	s.tracer.mutex.Lock()
+	defer s.tracer.mutex.Unlock()
+	s.tracer.finished = append(s.tracer.finished, s)
+}
+
+func (t *recordingTracer) operations() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var operations []string
+	for _, span := range t.finished {
+		operations = append(operations, span.operation)
+	}
+	return operations
+}
+
+func TestTopicProcessor_Tracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	processor := processorFunc(func(messages []*sarama.ConsumerMessage, sender Sender) error {
+		span := StartSpan(sender, "store.Get", map[string]string{"store": "planets"})
+		span.Finish(nil)
+		sender.Send(&sarama.ProducerMessage{Topic: "output", Value: sarama.StringEncoder("mars")})
+		if string(messages[0].Value) == "pluto" {
+			return errors.New("not a planet")
+		}
+		return nil
+	})
+	tp := newFakeTopicProcessor(&Config{BatchSize: 1, Tracer: tracer}, processor)
+	done := tp.start()
+	tp.send(0, 0, "mars")
+	waitFor(t, func() bool { return len(tracer.operations()) == 6 })
+	assert.Equal(t, []string{"store.Get", "kasper.process", "kasper.produce", "kasper.flush", "kasper.commit", "kasper.batch"}, tracer.operations())
+	spans := tracer.finished
+	batch := spans[5]
+	assert.Nil(t, batch.parent)
+	assert.Equal(t, map[string]string{"topicProcessor": "fake", "partition": "0", "messages": "1"}, batch.tags)
+	assert.Equal(t, spans[1], spans[0].parent)
+	for _, span := range spans[1:5] {
+		assert.Equal(t, batch, span.parent)
+	}
+
+	tp.send(0, 1, "pluto")
+	assert.EqualError(t, <-done, "not a planet")
+	operations := tracer.operations()
+	assert.Equal(t, []string{"store.Get", "kasper.process", "kasper.batch"}, operations[6:])
+	assert.EqualError(t, tracer.finished[8].err, "not a planet")
+}
+
+// propagatingTracer propagates the operation of the spans in a "trace" header.
+type propagatingTracer struct {
+	*recordingTracer
+}
+
+func (t propagatingTracer) Extract(headers []*sarama.RecordHeader) Span {
+	for _, header := range headers {
+		if string(header.Key) == "trace" {
+			return &recordingSpan{operation: string(header.Value)}
+		}
+	}
+	return nil
+}
+
+func (t propagatingTracer) Inject(span Span, headers []sarama.RecordHeader) []sarama.RecordHeader {
+	return append(headers, sarama.RecordHeader{Key: []byte("trace"), Value: []byte(span.(*recordingSpan).operation)})
+}
+
+func TestTopicProcessor_Tracer_Headers(t *testing.T) {
+	tracer := propagatingTracer{&recordingTracer{}}
+	processor := processorFunc(func(messages []*sarama.ConsumerMessage, sender Sender) error {
+		sender.Send(&sarama.ProducerMessage{Topic: "output", Value: sarama.StringEncoder("mars")})
+		return nil
+	})
+	tp := newFakeTopicProcessor(&Config{Tracer: tracer}, processor)
+	err := tp.processConsumerMessages([]*sarama.ConsumerMessage{
+		{Topic: "input", Offset: 1},
+		{Topic: "input", Offset: 2, Headers: []*sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("upstream")}}},
+	}, 0)
+	assert.Nil(t, err)
+	batch := tracer.finished[len(tracer.finished)-1]
+	assert.Equal(t, "kasper.batch", batch.operation)
+	assert.Equal(t, "upstream", batch.parent.(*recordingSpan).operation)
+	assert.Equal(t, []sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("kasper.process")}}, tp.producer.messages[0].Headers)
+
+	// Batches without a trace context start a new trace
+	assert.Nil(t, tp.processConsumerMessages([]*sarama.ConsumerMessage{{Topic: "input", Offset: 3}}, 0))
+	assert.Nil(t, tracer.finished[len(tracer.finished)-1].parent)
+}
+
+func TestStartSpan_NoTracingSender(t *testing.T) {
+	span := StartSpan(struct{ Sender }{}, "store.Get", nil)
+	span.SetTag("store", "planets")
+	span.Finish(nil)
+	assert.Equal(t, noopSpan{}, span)
+}
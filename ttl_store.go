@@ -0,0 +1,209 @@
+package kasper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// ExpiringStore is a Store whose keys can expire.
+type ExpiringStore interface {
+	Store
+	// PutWithTTL inserts or updates a value by key, and deletes the key after ttl.
+	PutWithTTL(key string, value []byte, ttl time.Duration) error
+}
+
+// PutWithTTL inserts or updates a value that expires after ttl, see ExpiringStore.
+// It returns an error if store does not implement ExpiringStore.
+func PutWithTTL(store Store, key string, value []byte, ttl time.Duration) error {
+	expiringStore, ok := store.(ExpiringStore)
+	if !ok {
+		return fmt.Errorf("%T does not support TTLs", store)
+	}
+	return expiringStore.PutWithTTL(key, value, ttl)
+}
+
+const ttlHeaderSize = 8
+
+// TTLStore adds TTLs to a Store without native expiry, such as Map, see NewTTLStore.
+// Each value is prefixed with an 8-byte header holding its expiry time (0 if it never expires), so the values of
+// the underlying store must only be written through the TTLStore. Expired values are hidden from reads,
+// and deleted by Sweep. Elasticsearch and Redis implement ExpiringStore themselves and do not need a TTLStore.
+type TTLStore struct {
+	store  Store
+	clock  Clock
+	logger Logger
+}
+
+// NewTTLStore creates TTLStore instances.
+func NewTTLStore(config *Config, store Store) *TTLStore {
+	return &TTLStore{
+		store,
+		config.clock(),
+		config.logger(),
+	}
+}
+
+func (s *TTLStore) encode(value []byte, expiry int64) []byte {
+	encoded := make([]byte, ttlHeaderSize+len(value))
+	binary.BigEndian.PutUint64(encoded, uint64(expiry))
+	copy(encoded[ttlHeaderSize:], value)
+	return encoded
+}
+
+// decode returns the value without its header, or nil if it has expired.
+func (s *TTLStore) decode(key string, encoded []byte, now int64) ([]byte, error) {
+	if encoded == nil {
+		return nil, nil
+	}
+	if len(encoded) < ttlHeaderSize {
+		return nil, fmt.Errorf("value of %s has no TTL header", key)
+	}
+	expiry := int64(binary.BigEndian.Uint64(encoded))
+	if expiry != 0 && expiry <= now {
+		return nil, nil
+	}
+	return encoded[ttlHeaderSize:], nil
+}
+
+// Get gets a value by key. Returns (nil, nil) if the key is missing or has expired.
+func (s *TTLStore) Get(key string) ([]byte, error) {
+	encoded, err := s.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(key, encoded, s.clock.Now().UnixNano())
+}
+
+// GetAll gets multiple values by key. The returned map does not contain entries for expired keys.
+func (s *TTLStore) GetAll(keys []string) (map[string][]byte, error) {
+	encoded, err := s.store.GetAll(keys)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now().UnixNano()
+	kvs := make(map[string][]byte, len(encoded))
+	for key, value := range encoded {
+		value, err := s.decode(key, value, now)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			kvs[key] = value
+		}
+	}
+	return kvs, nil
+}
+
+// Put inserts or updates a value that never expires.
+func (s *TTLStore) Put(key string, value []byte) error {
+	return s.store.Put(key, s.encode(value, 0))
+}
+
+// PutWithTTL inserts or updates a value that expires after ttl, which must be positive.
+func (s *TTLStore) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid TTL for key %s: %s (must be positive)", key, ttl)
+	}
+	return s.store.Put(key, s.encode(value, s.clock.Now().Add(ttl).UnixNano()))
+}
+
+// PutAll inserts or updates multiple key-value pairs that never expire.
+func (s *TTLStore) PutAll(kvs map[string][]byte) error {
+	encoded := make(map[string][]byte, len(kvs))
+	for key, value := range kvs {
+		encoded[key] = s.encode(value, 0)
+	}
+	return s.store.PutAll(encoded)
+}
+
+// Delete deletes a key from the underlying store.
+func (s *TTLStore) Delete(key string) error {
+	return s.store.Delete(key)
+}
+
+// DeleteAll deletes multiple keys from the underlying store, see the DeleteAll function.
+func (s *TTLStore) DeleteAll(keys []string) error {
+	return DeleteAll(s.store, keys)
+}
+
+// Flush flushes the underlying store.
+func (s *TTLStore) Flush() error {
+	return s.store.Flush()
+}
+
+// Iterate calls fn for each key starting with prefix that has not expired, see the Iterate function.
+func (s *TTLStore) Iterate(prefix string, fn func(KeyValue) bool) error {
+	now := s.clock.Now().UnixNano()
+	var err error
+	iterateErr := Iterate(s.store, prefix, func(kv KeyValue) bool {
+		var value []byte
+		value, err = s.decode(kv.Key, kv.Value, now)
+		if err != nil {
+			return false
+		}
+		return value == nil || fn(KeyValue{kv.Key, value})
+	})
+	if iterateErr != nil {
+		return iterateErr
+	}
+	return err
+}
+
+// Sweep deletes the expired keys from the underlying store, which must implement IterableStore, and returns
+// the number of keys deleted. Run it periodically (see SweepEvery), e.g. only in the leader (see LeaderElection).
+// Each key is read again before it is deleted, and kept if its value changed since the scan, so that a key
+// rewritten in the meantime is not lost. The read and the delete are not atomic, so a write between them can
+// still be lost.
+func (s *TTLStore) Sweep() (int, error) {
+	now := s.clock.Now().UnixNano()
+	var expired []KeyValue
+	err := Iterate(s.store, "", func(kv KeyValue) bool {
+		if value, err := s.decode(kv.Key, kv.Value, now); err == nil && value == nil {
+			expired = append(expired, KeyValue{kv.Key, append([]byte(nil), kv.Value...)})
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, kv := range expired {
+		current, err := s.store.Get(kv.Key)
+		if err != nil {
+			return deleted, err
+		}
+		if current == nil || !bytes.Equal(current, kv.Value) {
+			continue
+		}
+		if err := s.store.Delete(kv.Key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// SweepEvery calls Sweep every interval from its own goroutine until the returned function is called.
+// Errors are logged. The underlying store must be safe for concurrent use (see SynchronizedStore).
+func (s *TTLStore) SweepEvery(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	ticker := s.clock.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.Chan():
+				if _, err := s.Sweep(); err != nil {
+					s.logger.Errorf("Cannot sweep expired keys: %s", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,83 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLStore(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	s := NewMap(10)
+	store := NewTTLStore(&Config{Clock: clock}, s)
+
+	assert.Nil(t, store.Put("mercury", mercury))
+	assert.Nil(t, PutWithTTL(store, "venus", venus, time.Minute))
+	assert.Nil(t, PutWithTTL(NewStoreMetrics(&Config{}, store, "planets"), "earth", earth, time.Hour))
+	kvs, err := store.GetAll([]string{"mercury", "venus", "earth"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"mercury": mercury, "venus": venus, "earth": earth}, kvs)
+
+	clock.now = clock.now.Add(time.Minute)
+	value, err := store.Get("venus")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	var keys []string
+	store.Iterate("", func(kv KeyValue) bool {
+		keys = append(keys, kv.Key)
+		return true
+	})
+	assert.Equal(t, []string{"earth", "mercury"}, keys)
+
+	deleted, err := store.Sweep()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, 2, len(s.GetMap()))
+
+	s.Put("mars", []byte{1})
+	_, err = store.Get("mars")
+	assert.EqualError(t, err, "value of mars has no TTL header")
+	assert.EqualError(t, PutWithTTL(s, "mars", mars, time.Minute), "*kasper.Map does not support TTLs")
+}
+
+// rewritingStore simulates a concurrent write by rewriting a key the first time it is read.
+type rewritingStore struct {
+	*Map
+	key   string
+	value []byte
+}
+
+func (s *rewritingStore) Get(key string) ([]byte, error) {
+	if key == s.key && s.value != nil {
+		s.Map.Put(key, s.value)
+		s.value = nil
+	}
+	return s.Map.Get(key)
+}
+
+func TestTTLStore_SweepKeepsRewrittenKeys(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	s := &rewritingStore{Map: NewMap(10), key: "venus"}
+	store := NewTTLStore(&Config{Clock: clock}, s)
+	assert.Nil(t, store.PutWithTTL("venus", venus, time.Minute))
+	assert.Nil(t, store.PutWithTTL("earth", earth, time.Minute))
+	s.value = store.encode(mars, 0)
+
+	clock.now = clock.now.Add(time.Minute)
+	deleted, err := store.Sweep()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	value, err := store.Get("venus")
+	assert.Nil(t, err)
+	assert.Equal(t, mars, value)
+	value, err = store.Get("earth")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+}
+
+func TestTTLStore_PutWithTTL_Invalid(t *testing.T) {
+	store := NewTTLStore(&Config{}, NewMap(10))
+	assert.EqualError(t, store.PutWithTTL("venus", venus, 0), "invalid TTL for key venus: 0s (must be positive)")
+	assert.EqualError(t, store.PutWithTTL("venus", venus, -time.Second), "invalid TTL for key venus: -1s (must be positive)")
+}
@@ -0,0 +1,98 @@
+package kasper
+
+import "fmt"
+
+// TypedStore wraps a Store and a Serde, so that MessageProcessors can read and write values such as structs
+// without serializing them by hand:
+//
+//	tweets := kasper.NewTypedStore[Tweet](store, kasper.NewJSONSerde(func() interface{} { return &Tweet{} }))
+//	tweet, err := tweets.Get(key) // *Tweet, nil if the key is missing
+//	...
+//	err = tweets.Put(key, tweet)
+//
+// Serde.Deserialize must return a *T.
+type TypedStore[T any] struct {
+	store Store
+	serde Serde
+}
+
+// NewTypedStore creates TypedStore instances.
+func NewTypedStore[T any](store Store, serde Serde) *TypedStore[T] {
+	return &TypedStore[T]{store, serde}
+}
+
+func (s *TypedStore[T]) deserialize(key string, data []byte) (*T, error) {
+	value, err := s.serde.Deserialize(data)
+	if err != nil {
+		return nil, err
+	}
+	typed, ok := value.(*T)
+	if !ok {
+		return nil, fmt.Errorf("value of %s deserialized to %T, expected %T", key, value, typed)
+	}
+	return typed, nil
+}
+
+// Get gets a value by key and deserializes it. Returns (nil, nil) if the key is missing.
+func (s *TypedStore[T]) Get(key string) (*T, error) {
+	data, err := s.store.Get(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return s.deserialize(key, data)
+}
+
+// GetAll gets multiple values by key and deserializes them. The returned map does not contain entries for
+// missing keys.
+func (s *TypedStore[T]) GetAll(keys []string) (map[string]*T, error) {
+	kvs, err := s.store.GetAll(keys)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]*T, len(kvs))
+	for key, data := range kvs {
+		value, err := s.deserialize(key, data)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Put serializes a value and inserts or updates it by key.
+func (s *TypedStore[T]) Put(key string, value *T) error {
+	data, err := s.serde.Serialize(value)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(key, data)
+}
+
+// PutAll serializes multiple values and inserts or updates them by key.
+func (s *TypedStore[T]) PutAll(values map[string]*T) error {
+	kvs := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := s.serde.Serialize(value)
+		if err != nil {
+			return err
+		}
+		kvs[key] = data
+	}
+	return s.store.PutAll(kvs)
+}
+
+// Delete deletes a key from the underlying store.
+func (s *TypedStore[T]) Delete(key string) error {
+	return s.store.Delete(key)
+}
+
+// Flush flushes the underlying store.
+func (s *TypedStore[T]) Flush() error {
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *TypedStore[T]) GetStore() Store {
+	return s.store
+}
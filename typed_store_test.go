@@ -0,0 +1,40 @@
+package kasper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedStore(t *testing.T) {
+	s := NewMap(10)
+	planets := NewTypedStore[serdeTestPlanet](s, NewJSONSerde(func() interface{} { return &serdeTestPlanet{} }))
+
+	assert.Nil(t, planets.Put("mars", &serdeTestPlanet{"mars", 2}))
+	assert.Nil(t, planets.PutAll(map[string]*serdeTestPlanet{"earth": {"earth", 1}}))
+	data, _ := s.Get("mars")
+	assert.Equal(t, `{"name":"mars","moons":2}`, string(data))
+
+	planet, err := planets.Get("mars")
+	assert.Nil(t, err)
+	assert.Equal(t, &serdeTestPlanet{"mars", 2}, planet)
+	planet, err = planets.Get("pluto")
+	assert.Nil(t, err)
+	assert.Nil(t, planet)
+
+	values, err := planets.GetAll([]string{"mars", "earth", "pluto"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]*serdeTestPlanet{"mars": {"mars", 2}, "earth": {"earth", 1}}, values)
+
+	s.Put("venus", venus)
+	_, err = planets.Get("venus")
+	assert.NotNil(t, err)
+}
+
+func TestTypedStore_WrongSerde(t *testing.T) {
+	s := NewMap(10)
+	s.Put("mars", []byte(`{"name":"mars","moons":2}`))
+	planets := NewTypedStore[serdeTestPlanet](s, NewJSONSerde(func() interface{} { return &map[string]interface{}{} }))
+	_, err := planets.Get("mars")
+	assert.EqualError(t, err, "value of mars deserialized to *map[string]interface {}, expected *kasper.serdeTestPlanet")
+}
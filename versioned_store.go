@@ -0,0 +1,211 @@
+package kasper
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// ValueVersion is a value of a key in a VersionedStore, with the time at which it was written.
+// The value is nil if the key was deleted.
+type ValueVersion struct {
+	Timestamp time.Time
+	Value     []byte
+}
+
+const versionHeaderSize = 12
+
+// VersionedStore keeps the last values of each key with the time at which they were written, so that values can
+// be read as of a point in time, e.g. for temporal joins against slowly changing dimensions:
+//
+//	prices := kasper.NewVersionedStore(config, store, 10, 30*24*time.Hour)
+//	...
+//	price, err := prices.GetAsOf(productID, orderTime)
+//
+// All the versions of a key are stored in a single value of the underlying store, so the values of the underlying
+// store must only be written through the VersionedStore. Versions are packed with binary headers, so the underlying
+// store must accept arbitrary bytes, such as Map or Redis.
+type VersionedStore struct {
+	store       Store
+	clock       Clock
+	maxVersions int
+	maxAge      time.Duration
+}
+
+// NewVersionedStore creates VersionedStore instances. Up to maxVersions versions are kept per key
+// (0 means unlimited). Versions that were replaced more than maxAge ago are discarded on writes (0 means never),
+// so that GetAsOf can read any time within maxAge.
+func NewVersionedStore(config *Config, store Store, maxVersions int, maxAge time.Duration) *VersionedStore {
+	return &VersionedStore{
+		store,
+		config.clock(),
+		maxVersions,
+		maxAge,
+	}
+}
+
+// encodeVersions encodes versions, latest first, as {timestamp: int64}{length: int32, -1 if deleted}{value}.
+func encodeVersions(versions []ValueVersion) []byte {
+	size := 0
+	for _, version := range versions {
+		size += versionHeaderSize + len(version.Value)
+	}
+	encoded := make([]byte, 0, size)
+	header := make([]byte, versionHeaderSize)
+	for _, version := range versions {
+		binary.BigEndian.PutUint64(header, uint64(version.Timestamp.UnixNano()))
+		length := int32(len(version.Value))
+		if version.Value == nil {
+			length = -1
+		}
+		binary.BigEndian.PutUint32(header[8:], uint32(length))
+		encoded = append(encoded, header...)
+		encoded = append(encoded, version.Value...)
+	}
+	return encoded
+}
+
+func decodeVersions(key string, encoded []byte) ([]ValueVersion, error) {
+	var versions []ValueVersion
+	for len(encoded) > 0 {
+		if len(encoded) < versionHeaderSize {
+			return nil, fmt.Errorf("value of %s has a truncated version header", key)
+		}
+		version := ValueVersion{Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(encoded)))}
+		length := int32(binary.BigEndian.Uint32(encoded[8:]))
+		encoded = encoded[versionHeaderSize:]
+		if length >= 0 {
+			if int(length) > len(encoded) {
+				return nil, fmt.Errorf("value of %s has a truncated version", key)
+			}
+			version.Value = encoded[:length:length]
+			encoded = encoded[length:]
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// Versions returns the versions of key, latest first.
+func (s *VersionedStore) Versions(key string) ([]ValueVersion, error) {
+	encoded, err := s.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return decodeVersions(key, encoded)
+}
+
+// GetAsOf returns the value of key at time t. It returns nil if the key did not exist at that time, was deleted,
+// or if its versions at that time have been discarded.
+func (s *VersionedStore) GetAsOf(key string, t time.Time) ([]byte, error) {
+	versions, err := s.Versions(key)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		if !version.Timestamp.After(t) {
+			return version.Value, nil
+		}
+	}
+	return nil, nil
+}
+
+// Get gets the latest value of a key. Returns (nil, nil) if the key is missing or was deleted.
+func (s *VersionedStore) Get(key string) ([]byte, error) {
+	versions, err := s.Versions(key)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return versions[0].Value, nil
+}
+
+// GetAll gets the latest values of multiple keys. The returned map does not contain entries for deleted keys.
+func (s *VersionedStore) GetAll(keys []string) (map[string][]byte, error) {
+	encoded, err := s.store.GetAll(keys)
+	if err != nil {
+		return nil, err
+	}
+	kvs := make(map[string][]byte, len(encoded))
+	for key, value := range encoded {
+		versions, err := decodeVersions(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) > 0 && versions[0].Value != nil {
+			kvs[key] = versions[0].Value
+		}
+	}
+	return kvs, nil
+}
+
+// Put adds a version of a key.
+func (s *VersionedStore) Put(key string, value []byte) error {
+	return s.PutAll(map[string][]byte{key: value})
+}
+
+// PutAll adds a version of multiple keys.
+func (s *VersionedStore) PutAll(kvs map[string][]byte) error {
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	encoded, err := s.store.GetAll(keys)
+	if err != nil {
+		return err
+	}
+	now := s.clock.Now()
+	updated := make(map[string][]byte, len(kvs))
+	for key, value := range kvs {
+		if value == nil {
+			value = []byte{}
+		}
+		if updated[key], err = s.addVersion(key, encoded[key], ValueVersion{now, value}); err != nil {
+			return err
+		}
+	}
+	return s.store.PutAll(updated)
+}
+
+// Delete adds a version marking a key as deleted, so that its previous versions can still be read with GetAsOf.
+func (s *VersionedStore) Delete(key string) error {
+	encoded, err := s.store.Get(key)
+	if err != nil || encoded == nil {
+		return err
+	}
+	updated, err := s.addVersion(key, encoded, ValueVersion{s.clock.Now(), nil})
+	if err != nil {
+		return err
+	}
+	return s.store.Put(key, updated)
+}
+
+func (s *VersionedStore) addVersion(key string, encoded []byte, version ValueVersion) ([]byte, error) {
+	versions, err := decodeVersions(key, encoded)
+	if err != nil {
+		return nil, err
+	}
+	versions = append([]ValueVersion{version}, versions...)
+	if s.maxVersions > 0 && len(versions) > s.maxVersions {
+		versions = versions[:s.maxVersions]
+	}
+	if s.maxAge > 0 {
+		oldest := version.Timestamp.Add(-s.maxAge)
+		for i := 1; i < len(versions); i++ {
+			if versions[i].Timestamp.Before(oldest) {
+				versions = versions[:i+1]
+				break
+			}
+		}
+	}
+	return encodeVersions(versions), nil
+}
+
+// Flush flushes the underlying store.
+func (s *VersionedStore) Flush() error {
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *VersionedStore) GetStore() Store {
+	return s.store
+}
@@ -0,0 +1,49 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionedStore(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	store := NewVersionedStore(&Config{Clock: clock}, NewMap(10), 3, time.Hour)
+
+	assert.Nil(t, store.Put("planet", mercury))
+	clock.now = time.Unix(2000, 0)
+	assert.Nil(t, store.PutAll(map[string][]byte{"planet": venus, "moon": []byte("luna")}))
+	clock.now = time.Unix(3000, 0)
+	assert.Nil(t, store.Delete("planet"))
+
+	value, err := store.Get("planet")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	value, _ = store.GetAsOf("planet", time.Unix(999, 0))
+	assert.Nil(t, value)
+	value, _ = store.GetAsOf("planet", time.Unix(1500, 0))
+	assert.Equal(t, mercury, value)
+	value, _ = store.GetAsOf("planet", time.Unix(2000, 0))
+	assert.Equal(t, venus, value)
+	kvs, err := store.GetAll([]string{"planet", "moon"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"moon": []byte("luna")}, kvs)
+
+	// Only the last 3 versions are kept
+	clock.now = time.Unix(4000, 0)
+	assert.Nil(t, store.Put("planet", earth))
+	versions, err := store.Versions("planet")
+	assert.Nil(t, err)
+	assert.Equal(t, []ValueVersion{
+		{time.Unix(4000, 0), earth},
+		{time.Unix(3000, 0), nil},
+		{time.Unix(2000, 0), venus},
+	}, versions)
+
+	// Versions replaced more than an hour ago are discarded
+	clock.now = time.Unix(10000, 0)
+	assert.Nil(t, store.Put("planet", mars))
+	versions, _ = store.Versions("planet")
+	assert.Equal(t, []ValueVersion{{time.Unix(10000, 0), mars}, {time.Unix(4000, 0), earth}}, versions)
+}
@@ -0,0 +1,165 @@
+package kasper
+
+import (
+	"sort"
+	"time"
+)
+
+// WriteBehindStore wraps a Store and buffers Puts and Deletes in memory, keeping only the last write of each key,
+// until Flush is called or one of the triggers of NewWriteBehindStore fires. The buffered writes are then applied
+// with Mutate, followed by Flush of the underlying store. Reads see the buffered writes.
+//
+// Buffered writes are lost if the process crashes, so register the store in Config.Stores to keep at-least-once
+// semantics: it is then flushed after each batch, before the offsets are committed, and the writes are batched per
+// call to Process, which removes the repeated writes of keys updated by several messages of a batch.
+// WriteBehindStore is not safe for concurrent use.
+type WriteBehindStore struct {
+	store          Store
+	clock          Clock
+	maxPendingKeys int
+	maxDelay       time.Duration
+	pending        map[string][]byte
+	firstWrite     time.Time
+}
+
+// NewWriteBehindStore creates WriteBehindStore instances. The buffered writes are applied when maxPendingKeys
+// keys are pending or when the oldest pending write is maxDelay old. Zero values disable the corresponding trigger.
+// maxDelay is only checked on writes, so the writes of a store that stops receiving them stay buffered until the
+// next Flush, which is why stores that are not in Config.Stores must be flushed explicitly.
+func NewWriteBehindStore(config *Config, store Store, maxPendingKeys int, maxDelay time.Duration) *WriteBehindStore {
+	return &WriteBehindStore{
+		store,
+		config.clock(),
+		maxPendingKeys,
+		maxDelay,
+		make(map[string][]byte),
+		time.Time{},
+	}
+}
+
+// Get gets a value by key, from the pending writes or from the underlying store.
+func (s *WriteBehindStore) Get(key string) ([]byte, error) {
+	if value, found := s.pending[key]; found {
+		return value, nil
+	}
+	return s.store.Get(key)
+}
+
+// GetAll gets multiple values by key, from the pending writes or from the underlying store.
+func (s *WriteBehindStore) GetAll(keys []string) (map[string][]byte, error) {
+	var missing []string
+	for _, key := range keys {
+		if _, found := s.pending[key]; !found {
+			missing = append(missing, key)
+		}
+	}
+	kvs := make(map[string][]byte, len(keys))
+	if len(missing) > 0 {
+		stored, err := s.store.GetAll(missing)
+		if err != nil {
+			return nil, err
+		}
+		kvs = stored
+	}
+	for _, key := range keys {
+		if value, found := s.pending[key]; found && value != nil {
+			kvs[key] = value
+		}
+	}
+	return kvs, nil
+}
+
+// Put buffers a write.
+func (s *WriteBehindStore) Put(key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	return s.write(key, value)
+}
+
+// PutAll buffers multiple writes.
+func (s *WriteBehindStore) PutAll(kvs map[string][]byte) error {
+	for key, value := range kvs {
+		if err := s.Put(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete buffers a deletion.
+func (s *WriteBehindStore) Delete(key string) error {
+	return s.write(key, nil)
+}
+
+func (s *WriteBehindStore) write(key string, value []byte) error {
+	if len(s.pending) == 0 {
+		s.firstWrite = s.clock.Now()
+	}
+	s.pending[key] = value
+	if s.maxPendingKeys > 0 && len(s.pending) >= s.maxPendingKeys {
+		return s.applyPending()
+	}
+	if s.maxDelay > 0 && s.clock.Now().Sub(s.firstWrite) >= s.maxDelay {
+		return s.applyPending()
+	}
+	return nil
+}
+
+// Pending returns the number of keys with buffered writes.
+func (s *WriteBehindStore) Pending() int {
+	return len(s.pending)
+}
+
+// Stats returns the statistics of the underlying store if it implements StatsStore, with the number of keys with
+// buffered writes.
+func (s *WriteBehindStore) Stats() (StoreStats, error) {
+	var stats StoreStats
+	if statsStore, ok := s.store.(StatsStore); ok {
+		var err error
+		if stats, err = statsStore.Stats(); err != nil && err != ErrStatsNotSupported {
+			return stats, err
+		}
+	}
+	stats.PendingWrites = int64(len(s.pending))
+	return stats, nil
+}
+
+func (s *WriteBehindStore) applyPending() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(s.pending))
+	for key := range s.pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var puts []KeyValue
+	var deletes []string
+	for _, key := range keys {
+		if value := s.pending[key]; value != nil {
+			puts = append(puts, KeyValue{key, value})
+		} else {
+			deletes = append(deletes, key)
+		}
+	}
+	if err := Mutate(s.store, puts, deletes); err != nil {
+		return err
+	}
+	s.pending = make(map[string][]byte)
+	return nil
+}
+
+// Flush applies the buffered writes, then flushes the underlying store. The writes stay buffered if they
+// cannot be applied, so Flush can be retried.
+func (s *WriteBehindStore) Flush() error {
+	if err := s.applyPending(); err != nil {
+		return err
+	}
+	return s.store.Flush()
+}
+
+// GetStore returns the underlying Store
+func (s *WriteBehindStore) GetStore() Store {
+	return s.store
+}
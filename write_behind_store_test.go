@@ -0,0 +1,42 @@
+package kasper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteBehindStore(t *testing.T) {
+	s := NewMap(10)
+	s.Put("mercury", mercury)
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	store := NewWriteBehindStore(&Config{Clock: clock}, s, 3, time.Minute)
+
+	store.Put("earth", venus)
+	store.Put("earth", earth)
+	store.Delete("mercury")
+	assert.Equal(t, 2, store.Pending())
+	assert.Equal(t, 1, len(s.GetMap()))
+	value, _ := store.Get("mercury")
+	assert.Nil(t, value)
+	kvs, err := store.GetAll([]string{"mercury", "earth"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"earth": earth}, kvs)
+
+	assert.Nil(t, store.Flush())
+	assert.Equal(t, 0, store.Pending())
+	assert.Equal(t, map[string][]byte{"earth": earth}, s.GetMap())
+
+	// Size trigger
+	store.PutAll(map[string][]byte{"mars": mars, "venus": venus, "jupiter": jupiter})
+	assert.Equal(t, 0, store.Pending())
+	assert.Equal(t, 4, len(s.GetMap()))
+
+	// Time trigger
+	store.Put("saturn", saturn)
+	clock.now = clock.now.Add(time.Minute)
+	store.Put("uranus", uranus)
+	assert.Equal(t, 0, store.Pending())
+	assert.Equal(t, 6, len(s.GetMap()))
+}
@@ -0,0 +1,84 @@
+package kasper
+
+import "fmt"
+
+// ZapSugaredLogger is the subset of *zap.SugaredLogger used by NewZapLogger.
+// See https://godoc.org/go.uber.org/zap#SugaredLogger
+type ZapSugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+	Panicw(msg string, keysAndValues ...interface{})
+}
+
+// NewZapLogger adapts a zap SugaredLogger to StructuredLogger. Fields are passed as loosely-typed key-value pairs.
+//
+//	logger := kasper.NewZapLogger(zapLogger.Sugar())
+func NewZapLogger(logger ZapSugaredLogger) StructuredLogger {
+	return &zapLogger{logger, nil, nil}
+}
+
+// NewZapLoggerWithLevel is like NewZapLogger, but debug messages are only formatted when debugEnabled returns true,
+// which avoids formatting the messages discarded by zap:
+//
+//	logger := kasper.NewZapLoggerWithLevel(zapLogger.Sugar(), func() bool {
+//		return zapLogger.Core().Enabled(zap.DebugLevel)
+//	})
+func NewZapLoggerWithLevel(logger ZapSugaredLogger, debugEnabled func() bool) StructuredLogger {
+	return &zapLogger{logger, debugEnabled, nil}
+}
+
+type zapLogger struct {
+	logger        ZapSugaredLogger
+	debugEnabled  func() bool
+	keysAndValues []interface{}
+}
+
+func (l *zapLogger) debug() bool {
+	return l.debugEnabled == nil || l.debugEnabled()
+}
+
+func (l *zapLogger) With(fields ...Field) StructuredLogger {
+	keysAndValues := make([]interface{}, 0, len(l.keysAndValues)+2*len(fields))
+	keysAndValues = append(keysAndValues, l.keysAndValues...)
+	for _, field := range fields {
+		keysAndValues = append(keysAndValues, field.Key, field.Value)
+	}
+	return &zapLogger{l.logger, l.debugEnabled, keysAndValues}
+}
+
+func (l *zapLogger) Debug(vs ...interface{}) {
+	if l.debug() {
+		l.logger.Debugw(fmt.Sprint(vs...), l.keysAndValues...)
+	}
+}
+
+func (l *zapLogger) Debugf(format string, vs ...interface{}) {
+	if l.debug() {
+		l.logger.Debugw(fmt.Sprintf(format, vs...), l.keysAndValues...)
+	}
+}
+
+func (l *zapLogger) Info(vs ...interface{}) {
+	l.logger.Infow(fmt.Sprint(vs...), l.keysAndValues...)
+}
+
+func (l *zapLogger) Infof(format string, vs ...interface{}) {
+	l.logger.Infow(fmt.Sprintf(format, vs...), l.keysAndValues...)
+}
+
+func (l *zapLogger) Error(vs ...interface{}) {
+	l.logger.Errorw(fmt.Sprint(vs...), l.keysAndValues...)
+}
+
+func (l *zapLogger) Errorf(format string, vs ...interface{}) {
+	l.logger.Errorw(fmt.Sprintf(format, vs...), l.keysAndValues...)
+}
+
+func (l *zapLogger) Panic(vs ...interface{}) {
+	l.logger.Panicw(fmt.Sprint(vs...), l.keysAndValues...)
+}
+
+func (l *zapLogger) Panicf(format string, vs ...interface{}) {
+	l.logger.Panicw(fmt.Sprintf(format, vs...), l.keysAndValues...)
+}